@@ -0,0 +1,65 @@
+package app
+
+import (
+	"Hamburger/gateway/admin"
+	"Hamburger/gateway/runtime"
+	"Hamburger/internal/config"
+	"fmt"
+	"slices"
+)
+
+// 管理接口需要的应用操作
+// 自定义服务的修改在当前配置的副本上进行 经过与热重载相同的校验和替换流程
+
+// ResyncDomains 重新加载域名映射文件
+func (app *HamburgerApp) ResyncDomains() {
+	app.reloadMu.Lock()
+	defer app.reloadMu.Unlock()
+	runtime.RefreshRuntimeDomains(app.appConf)
+}
+
+// AddCustomService 新增自定义服务 域名已存在时替换
+func (app *HamburgerApp) AddCustomService(service config.CustomServiceConfig) error {
+	return app.updateCustomServices(func(services []config.CustomServiceConfig) ([]config.CustomServiceConfig, error) {
+		index := slices.IndexFunc(services, func(s config.CustomServiceConfig) bool {
+			return s.Domain == service.Domain
+		})
+		if index >= 0 {
+			services[index] = service
+			return services, nil
+		}
+		return append(services, service), nil
+	})
+}
+
+// RemoveCustomService 删除自定义服务
+func (app *HamburgerApp) RemoveCustomService(domain string) error {
+	return app.updateCustomServices(func(services []config.CustomServiceConfig) ([]config.CustomServiceConfig, error) {
+		index := slices.IndexFunc(services, func(s config.CustomServiceConfig) bool {
+			return s.Domain == domain
+		})
+		if index < 0 {
+			return nil, fmt.Errorf("custom service %s: %w", domain, admin.ErrNotFound)
+		}
+		return slices.Delete(services, index, index+1), nil
+	})
+}
+
+func (app *HamburgerApp) updateCustomServices(fn func([]config.CustomServiceConfig) ([]config.CustomServiceConfig, error)) error {
+	app.reloadMu.Lock()
+	defer app.reloadMu.Unlock()
+
+	cfg := *app.conf
+	services, err := fn(slices.Clone(cfg.PxyCustomService.CustomService))
+	if err != nil {
+		return err
+	}
+	cfg.PxyCustomService.CustomService = services
+	if err = validate(&cfg); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+	if !cfg.PxyCustomService.Enable {
+		app.logger.Warn().Msg("custom service is disabled, changes take effect after enabling it")
+	}
+	return app.switchConfig(&cfg)
+}
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"Hamburger/gateway/accesslog"
+	"Hamburger/gateway/admin"
 	"Hamburger/gateway/stat"
 	"os"
 	"os/signal"
@@ -11,11 +13,13 @@ import (
 	"Hamburger/frontend_proxy"
 	"Hamburger/gateway/core"
 	"Hamburger/gateway/manager"
+	"Hamburger/gateway/metrics"
 	"Hamburger/gateway/modifier"
 	"Hamburger/initialize"
 	"Hamburger/internal/config"
 	grpc_proxy "Hamburger/internal/grpc"
 	"Hamburger/internal/logger"
+	"Hamburger/internal/upgrade"
 
 	"github.com/rs/zerolog"
 )
@@ -24,8 +28,10 @@ type HamburgerApp struct {
 	appConf *config.AppConfig
 	conf    *config.Config
 
-	logger  *zerolog.Logger // APP日志
-	pidFile string
+	logger     *zerolog.Logger // APP日志
+	pidFile    string
+	configFile string
+	reloadMu   sync.Mutex // 串行化配置重载
 
 	// Proxy
 	FrontServer     *frontend_proxy.HeliosServer
@@ -35,6 +41,8 @@ type HamburgerApp struct {
 	GrpcProxy       *grpc_proxy.GrpcProxy
 	ModifierManager *modifier.ModifierManager
 	StatServer      *stat.StatServer
+	AdminServer     *admin.AdminServer
+	MetricsServer   *metrics.MetricsServer
 }
 
 const (
@@ -55,9 +63,10 @@ func NewHamburgerApp(configFile string) (*HamburgerApp, error) {
 	config.Set(cfg)
 
 	return &HamburgerApp{
-		appConf: appCfg,
-		conf:    cfg,
-		logger:  logger.GetLogger(),
+		appConf:    appCfg,
+		conf:       cfg,
+		logger:     logger.GetLogger(),
+		configFile: configFile,
 	}, nil
 }
 
@@ -73,7 +82,9 @@ func (app *HamburgerApp) InitApp() error {
 	app.GrpcProxy = i.GrpcProxy
 	app.ModifierManager = i.ModifierManager
 	app.StatServer = i.StatServer
+	app.MetricsServer = i.MetricsServer
 	app.logger = i.GetLogger()
+	app.AdminServer = admin.NewAdminServer(app.conf.Admin, app.Manager, app, app.logger)
 
 	return nil
 }
@@ -87,8 +98,13 @@ func (app *HamburgerApp) Run() {
 	app.Status()
 	app.LifeCycle()
 
+	// 前端服务先同步创建监听器 确保通知父进程就绪前全部端口已接管
+	if err := app.FrontServer.Listen(); err != nil {
+		app.logger.Fatal().Err(err).Msg("frontend server error")
+	}
+
 	wg := sync.WaitGroup{}
-	wg.Add(3)
+	wg.Add(2)
 
 	go func() {
 		defer wg.Done()
@@ -102,13 +118,19 @@ func (app *HamburgerApp) Run() {
 		if err := app.Manager.Start(); err != nil {
 			app.logger.Fatal().Err(err).Msg("gateway server error")
 		}
-	}()
-
-	go func() {
-		defer wg.Done()
 		if err := app.StatServer.Start(); err != nil {
 			app.logger.Fatal().Err(err).Msg("stat server error")
 		}
+		if err := app.AdminServer.Start(); err != nil {
+			app.logger.Fatal().Err(err).Msg("admin server error")
+		}
+		if err := app.MetricsServer.Start(); err != nil {
+			app.logger.Fatal().Err(err).Msg("metrics server error")
+		}
+		// 平滑升级启动时通知父进程开始退出
+		if err := upgrade.Ready(); err != nil {
+			app.logger.Error().Err(err).Msg("notify parent process failed")
+		}
 	}()
 
 	wg.Wait()
@@ -141,13 +163,71 @@ func (app *HamburgerApp) LifeCycle() {
 	go func() {
 		<-c
 		app.logger.Info().Msg("received shutdown signal, gracefully shutting down...")
-		app.FrontServer.Shutdown()
-		if err := app.Manager.Stop(); err != nil {
-			app.logger.Error().Err(err).Msg("gateway server shutdown failed")
-		}
+		app.shutdown()
 		app.removePidFile()
 		os.Exit(0)
 	}()
+
+	// SIGHUP触发进程内配置重载
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			app.logger.Info().Msg("received reload signal, reloading config...")
+			if err := app.Reload(); err != nil {
+				app.logger.Error().Err(err).Msg("reload config failed, keep running with current config")
+			}
+		}
+	}()
+
+	// SIGUSR1重新打开日志文件 配合外部logrotate使用
+	if logger.ReopenSignal != nil {
+		reopen := make(chan os.Signal, 1)
+		signal.Notify(reopen, logger.ReopenSignal)
+		go func() {
+			for range reopen {
+				if err := logger.ReopenAll(); err != nil {
+					app.logger.Error().Err(err).Msg("reopen log files failed")
+					continue
+				}
+				app.logger.Info().Msg("log files reopened")
+			}
+		}()
+	}
+
+	// 升级信号触发平滑升级 新进程就绪后当前进程退出
+	if upgrade.Signal != nil {
+		usr := make(chan os.Signal, 1)
+		signal.Notify(usr, upgrade.Signal)
+		go func() {
+			for range usr {
+				app.logger.Info().Msg("received upgrade signal, starting new process...")
+				if err := app.Upgrade(); err != nil {
+					app.logger.Error().Err(err).Msg("upgrade failed, keep running current process")
+				}
+			}
+		}()
+	}
+}
+
+// shutdown 停止接收新连接并等待进行中的请求处理完成
+func (app *HamburgerApp) shutdown() {
+	app.FrontServer.Shutdown()
+	if err := app.Manager.Stop(); err != nil {
+		app.logger.Error().Err(err).Msg("gateway server shutdown failed")
+	}
+	if err := app.StatServer.Stop(); err != nil {
+		app.logger.Error().Err(err).Msg("stat server shutdown failed")
+	}
+	if err := app.AdminServer.Stop(); err != nil {
+		app.logger.Error().Err(err).Msg("admin server shutdown failed")
+	}
+	if err := app.MetricsServer.Stop(); err != nil {
+		app.logger.Error().Err(err).Msg("metrics server shutdown failed")
+	}
+	if err := accesslog.Close(); err != nil {
+		app.logger.Error().Err(err).Msg("close access log failed")
+	}
 }
 
 func (app *HamburgerApp) SetPidFile(pidFile string) {
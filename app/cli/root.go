@@ -10,6 +10,7 @@ import (
 
 	"Hamburger/app"
 	"Hamburger/internal/config"
+	"Hamburger/internal/upgrade"
 	"github.com/spf13/cobra"
 )
 
@@ -83,7 +84,9 @@ func newTestCmd(configFile *string) *cobra.Command {
 }
 
 func newReloadCmd(configFile *string) *cobra.Command {
-	return &cobra.Command{
+	var inPlace, restart bool
+
+	cmd := &cobra.Command{
 		Use:   "reload",
 		Short: "reload service",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -91,7 +94,18 @@ func newReloadCmd(configFile *string) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			if err := signalProcess(pid); err != nil {
+			if inPlace {
+				// 通知运行中的进程重新加载配置 不中断现有连接
+				return signalProcess(pid, syscall.SIGHUP)
+			}
+			if !restart && upgrade.Signal != nil {
+				// 平滑升级 新进程接管监听器后旧进程退出
+				if err := signalProcess(pid, upgrade.Signal); err != nil {
+					return err
+				}
+				return waitPidChanged(pidFileName, pid, upgrade.DefaultTimeout+5*time.Second)
+			}
+			if err := signalProcess(pid, syscall.SIGTERM); err != nil {
 				return err
 			}
 			if err := waitPidFileRemoved(pidFileName, 30*time.Second); err != nil {
@@ -100,6 +114,10 @@ func newReloadCmd(configFile *string) *cobra.Command {
 			return startNewProcess(*configFile)
 		},
 	}
+
+	cmd.Flags().BoolVar(&inPlace, "in-place", false, "reload config in the running process without restart")
+	cmd.Flags().BoolVar(&restart, "restart", false, "stop the running process before starting a new one")
+	return cmd
 }
 
 func runWithConfig(configFile string) error {
@@ -132,12 +150,12 @@ func readPid(path string) (int, error) {
 	return pid, nil
 }
 
-func signalProcess(pid int) error {
+func signalProcess(pid int, sig os.Signal) error {
 	proc, err := os.FindProcess(pid)
 	if err != nil {
 		return err
 	}
-	return proc.Signal(syscall.SIGTERM)
+	return proc.Signal(sig)
 }
 
 func waitPidFileRemoved(path string, timeout time.Duration) error {
@@ -151,6 +169,18 @@ func waitPidFileRemoved(path string, timeout time.Duration) error {
 	return fmt.Errorf("timeout waiting for process shutdown")
 }
 
+// waitPidChanged 等待新进程写入pid文件
+func waitPidChanged(path string, oldPid int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if pid, err := readPid(path); err == nil && pid != oldPid {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return fmt.Errorf("timeout waiting for new process")
+}
+
 func startNewProcess(configFile string) error {
 	exe, err := os.Executable()
 	if err != nil {
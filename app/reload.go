@@ -0,0 +1,125 @@
+package app
+
+import (
+	"Hamburger/gateway/accesslog"
+	"Hamburger/gateway/balancer"
+	"Hamburger/gateway/breaker"
+	"Hamburger/gateway/clientauth"
+	"Hamburger/gateway/clientip"
+	flow "Hamburger/gateway/flow_control"
+	"Hamburger/gateway/geo"
+	"Hamburger/gateway/grpc_proxy"
+	"Hamburger/gateway/grpc_server"
+	"Hamburger/gateway/modifier"
+	"Hamburger/gateway/prehandler"
+	"Hamburger/gateway/resolver"
+	"Hamburger/gateway/upstreamtls"
+	"Hamburger/gateway/waf"
+	"Hamburger/internal/config"
+	"Hamburger/internal/logger"
+	"Hamburger/internal/route"
+	"errors"
+	"fmt"
+)
+
+// 进程内热重载
+// 重新加载配置文件并整体替换转发规则、熔断阈值、前置处理链、响应修改链、流控规则、客户端证书认证、TLS证书映射和域名组策略、会话票据配置、自定义服务及其上游TLS配置、gRPC路由、日志输出和访问日志
+// 新配置校验失败时不做任何替换 替换过程中出错时回滚到旧配置
+
+// Reload 重新加载配置文件
+func (app *HamburgerApp) Reload() error {
+	app.reloadMu.Lock()
+	defer app.reloadMu.Unlock()
+
+	appCfg, err := config.LoadConfig(app.configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	cfg := config.Merge(appCfg)
+	if err = validate(cfg); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	if err = app.switchConfig(cfg); err != nil {
+		return err
+	}
+	app.appConf = appCfg
+	app.logger.Info().Str("config", app.configFile).Msg("config reloaded")
+	return nil
+}
+
+// switchConfig 替换正在使用的配置 失败时回滚到旧配置
+func (app *HamburgerApp) switchConfig(cfg *config.Config) error {
+	old := app.conf
+	if err := app.apply(cfg); err != nil {
+		app.logger.Error().Err(err).Msg("reload config failed, rolling back")
+		if rollbackErr := app.apply(old); rollbackErr != nil {
+			app.logger.Error().Err(rollbackErr).Msg("rollback config failed")
+		}
+		return err
+	}
+	app.conf = cfg
+	return nil
+}
+
+// apply 替换运行时使用的配置 可能失败的步骤放在最前
+func (app *HamburgerApp) apply(cfg *config.Config) error {
+	if err := logger.ReloadLogger(&cfg.Log); err != nil {
+		return err
+	}
+	if err := accesslog.Init(cfg.Log.Access); err != nil {
+		return err
+	}
+	if err := clientip.Init(cfg.Security); err != nil {
+		return err
+	}
+	if err := clientauth.Init(cfg); err != nil {
+		return err
+	}
+	if err := upstreamtls.Init(cfg.PxyCustomService); err != nil {
+		return err
+	}
+	if app.Manager != nil {
+		if err := app.Manager.Reload(cfg); err != nil {
+			return err
+		}
+	}
+	config.Set(cfg)
+	balancer.Reset()
+	breaker.Reload()
+	geo.Init(cfg.Stat.GeoDB)
+	resolver.OneResolver(cfg, app.logger).Reload(cfg)
+	grpc_server.Reload(cfg)
+	if err := prehandler.GetManager().Reload(); err != nil {
+		return err
+	}
+	modifier.GetManager().Reload()
+	return nil
+}
+
+// validate 校验配置、API路由规则、gRPC描述符集和WAF规则
+func validate(cfg *config.Config) error {
+	if err := config.Validate(cfg); err != nil {
+		return err
+	}
+	var errs []error
+	for _, server := range cfg.PxyFrontend.Servers {
+		if _, err := route.NewRouter(server.Backends); err != nil {
+			errs = append(errs, fmt.Errorf("server %s: %w", server.Name, err))
+		}
+	}
+	if bridge := cfg.Features.GrpcProxy; bridge.Enabled && bridge.Bridge && len(bridge.DescriptorSets) > 0 {
+		if _, err := grpc_proxy.LoadDescriptorSets(bridge.DescriptorSets); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := flow.Validate(cfg.Features.FlowControl); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.Middleware.WAF.Enabled {
+		if _, err := waf.New(cfg.Middleware.WAF); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
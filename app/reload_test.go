@@ -0,0 +1,140 @@
+package app
+
+import (
+	"Hamburger/gateway/accesslog"
+	"Hamburger/gateway/prehandler"
+	"Hamburger/internal/config"
+	"Hamburger/internal/logger"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig 写入只包含日志、访问日志和IP黑名单的最小配置
+func writeConfig(t *testing.T, file, logFile, accessFile string, denyIPs ...string) {
+	t.Helper()
+	cfg := config.AppConfig{
+		Servers:  []config.ServerConfig{{Name: "web", Port: 8080, Enabled: true}},
+		Security: config.SecurityConfig{DenyIPs: denyIPs},
+		Log: config.LogConfig{
+			LogFile:  logFile,
+			Encoding: "json",
+			Access:   config.AccessLogConfig{Enabled: true, File: accessFile},
+		},
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestApp(t *testing.T, file string) *HamburgerApp {
+	t.Helper()
+	app, err := NewHamburgerApp(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = app.apply(app.conf); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = accesslog.Close()
+		_ = logger.ReloadLogger(&config.LogConfig{})
+		logger.InitLogger()
+	})
+	return app
+}
+
+// handleChain 按网关的方式依次执行前置处理链
+func handleChain(chain []prehandler.PreHandler, remote string) error {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	req.RemoteAddr = remote + ":1234"
+	for _, ph := range chain {
+		if err := ph.Handle(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assertOutput 写入一条日志和访问日志 检查分别输出到了期望的文件
+func assertOutput(t *testing.T, marker, logFile, accessFile string) {
+	t.Helper()
+	logger.GetLogger().Info().Msg(marker)
+	accesslog.Log(httptest.NewRequest(http.MethodGet, "http://localhost/"+marker, nil), nil, nil, http.StatusOK, 0)
+	for _, file := range []string{logFile, accessFile} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), marker) {
+			t.Fatalf("%s does not contain %s", file, marker)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	writeConfig(t, file, path("a.log"), path("a-access.log"), "203.0.113.1")
+	app := newTestApp(t, file)
+	assertOutput(t, "marker-a", path("a.log"), path("a-access.log"))
+	inFlight := prehandler.GetManager().GetPreHandlers()
+
+	writeConfig(t, file, path("b.log"), path("b-access.log"), "203.0.113.2")
+	if err := app.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	assertOutput(t, "marker-b", path("b.log"), path("b-access.log"))
+	chain := prehandler.GetManager().GetPreHandlers()
+	if handleChain(chain, "203.0.113.2") == nil || handleChain(chain, "203.0.113.1") != nil {
+		t.Fatal("reloaded chain should use the new deny list")
+	}
+	// 重载前取得处理链的请求继续按旧规则处理
+	if handleChain(inFlight, "203.0.113.1") == nil || handleChain(inFlight, "203.0.113.2") != nil {
+		t.Fatal("in-flight chain should keep the old deny list")
+	}
+
+	// 校验失败 不做任何替换
+	cfg := config.Get()
+	writeConfig(t, file, path("c.log"), path("c-access.log"), "not-an-ip")
+	if err := app.Reload(); err == nil {
+		t.Fatal("invalid ip should fail validation")
+	}
+	if config.Get() != cfg || app.conf != cfg {
+		t.Fatal("config should not change after validation failure")
+	}
+	assertOutput(t, "marker-c", path("b.log"), path("b-access.log"))
+	if _, err := os.Stat(path("c.log")); !os.IsNotExist(err) {
+		t.Fatal("log file of the invalid config should not be created")
+	}
+
+	// 日志已切换后访问日志创建失败 回滚到旧的日志输出和处理链
+	if err := os.WriteFile(path("blocker"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, file, path("d.log"), filepath.Join(path("blocker"), "access.log"), "203.0.113.4")
+	if err := app.Reload(); err == nil {
+		t.Fatal("access log under a regular file should fail")
+	}
+	if config.Get() != cfg || app.conf != cfg {
+		t.Fatal("config should be rolled back")
+	}
+	assertOutput(t, "marker-d", path("b.log"), path("b-access.log"))
+	if data, _ := os.ReadFile(path("d.log")); strings.Contains(string(data), "marker-d") {
+		t.Fatal("logger should be rolled back")
+	}
+	chain = prehandler.GetManager().GetPreHandlers()
+	if handleChain(chain, "203.0.113.2") == nil || handleChain(chain, "203.0.113.4") != nil {
+		t.Fatal("prehandler chain should be rolled back")
+	}
+}
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"Hamburger/internal/upgrade"
+	"os"
+	"strconv"
+)
+
+// 平滑升级
+// 将当前进程持有的监听器传递给新进程 新进程开始服务后当前进程再停止并退出
+// 升级期间监听端口始终有进程接收连接 pid文件由新进程覆盖
+
+// Upgrade 启动新进程接管监听器 成功时不返回
+func (app *HamburgerApp) Upgrade() error {
+	// 升级期间不处理配置重载
+	app.reloadMu.Lock()
+	defer app.reloadMu.Unlock()
+
+	proc, err := upgrade.Upgrade(upgrade.DefaultTimeout)
+	if err != nil {
+		// 新进程可能已覆盖pid文件 恢复为当前进程
+		if app.pidFile != "" {
+			_ = os.WriteFile(app.pidFile, []byte(strconv.Itoa(os.Getpid())), 0644)
+		}
+		return err
+	}
+	app.logger.Info().Int("pid", proc.Pid).Msg("new process is ready, draining current process")
+
+	// 新进程已写入pid文件 退出时不能删除
+	app.pidFile = ""
+	app.shutdown()
+	os.Exit(0)
+	return nil
+}
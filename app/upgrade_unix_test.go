@@ -0,0 +1,46 @@
+//go:build !windows
+
+package app
+
+import (
+	"Hamburger/internal/logger"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// envUpgradeChild 测试二进制作为升级启动的新进程时未就绪即退出
+const envUpgradeChild = "HAMBURGER_APP_TEST_CHILD"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envUpgradeChild) != "" {
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// TestUpgradeFailure 测试新进程启动失败时恢复pid文件并继续服务
+func TestUpgradeFailure(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "hamburger.pid")
+	if err := os.WriteFile(pidFile, []byte("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envUpgradeChild, "1")
+
+	app := &HamburgerApp{pidFile: pidFile, logger: logger.GetLogger()}
+	if err := app.Upgrade(); err == nil {
+		t.Fatal("expected upgrade error")
+	}
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != strconv.Itoa(os.Getpid()) || app.pidFile != pidFile {
+		t.Fatalf("pid file = %s, want %d", data, os.Getpid())
+	}
+	if !app.reloadMu.TryLock() {
+		t.Fatal("reload lock should be released")
+	}
+	app.reloadMu.Unlock()
+}
@@ -2,7 +2,9 @@ package backend_proxy
 
 import (
 	"Hamburger/internal/config"
+	"Hamburger/internal/upgrade"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/rs/zerolog"
 	"net/http"
@@ -52,9 +54,14 @@ func (s *Server) Start() {
 		Handler: s.GetHandler(),
 	}
 	s.svr = svr
+	ln, err := upgrade.Listen("tcp", svr.Addr)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("backend server start error")
+		return
+	}
 	go func() {
 		s.started = true
-		if err := svr.ListenAndServe(); err != nil {
+		if err := svr.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.logger.Error().Err(err).Msg("backend server start error")
 		}
 	}()
@@ -1,7 +1,7 @@
 package frontend_proxy
 
 import (
-	"Hamburger/internal/config"
+	"Hamburger/internal/route"
 	"bytes"
 	"context"
 	"fmt"
@@ -25,61 +25,34 @@ func BackendProxyMiddleware(server *HeliosServer) gin.HandlerFunc {
 			return
 		}
 
-		// 查找对应的服务器配置
-		var serverConfig *config.FrontServerConfig
-
-		for _, srv := range server.config.Servers {
-			if srv.Name == internalFlag {
-				serverConfig = &srv
-				break
-			}
-		}
-
-		if serverConfig == nil {
+		// 查找对应的服务器路由 未配置backends时路由为空
+		router, ok := server.routers[internalFlag]
+		if !ok || router.Size() == 0 {
 			c.Next()
 			return
 		}
 
-		// 检查是否配置了backends
-		if len(serverConfig.Backends) == 0 {
-			c.Next()
+		// 查找匹配的API路由
+		match, ok := router.Match(c.Request)
+		if ok {
+			// 执行后端代理转发
+			proxyToBackend(server, c, match)
+			// 代理转发完成后直接返回，不继续执行后续中间件
+			c.Abort()
 			return
 		}
 
-		// 遍历所有backend配置，查找匹配的API路径
-		requestPath := c.Request.URL.Path
-		for _, backend := range serverConfig.Backends {
-			// 检查API路径和服务名是否配置
-			if backend.API == "" || backend.Service == "" {
-				continue
-			}
-
-			// 检查请求路径是否匹配backend.api
-			if strings.HasPrefix(requestPath, backend.API) {
-				// 执行后端代理转发
-				proxyToBackend(server, c, &backend)
-				// 代理转发完成后直接返回，不继续执行后续中间件
-				c.Abort()
-				return
-			}
-		}
-
 		// 没有匹配的backend配置，继续执行后续中间件
 		c.Next()
 	}
 }
 
 // proxyToBackend 代理请求到后端服务
-func proxyToBackend(server *HeliosServer, c *gin.Context, backend *config.BackendConfig) {
+func proxyToBackend(server *HeliosServer, c *gin.Context, match route.Match) {
+	backend := match.Route.Backend
 	server.logger.Debug().Str("backend_service", backend.Service).Msg("proxy request to backend")
-	// 构建目标URL，处理URL重写
-	targetPath := c.Request.URL.Path
-	if backend.UseRewrite {
-		// 将API路径重写为指定的rewrite路径
-		if strings.HasPrefix(targetPath, backend.API) {
-			targetPath = strings.Replace(targetPath, backend.API, backend.Rewrite, 1)
-		}
-	}
+	// 路由匹配时已处理URL重写
+	targetPath := match.Path
 
 	balancer := server.config.Balancer
 	if !strings.HasPrefix(balancer, "http://") {
@@ -2,8 +2,13 @@ package frontend_proxy
 
 import (
 	"Hamburger/internal/config"
+	"Hamburger/internal/route"
+	"Hamburger/internal/upgrade"
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -23,6 +28,9 @@ type HeliosServer struct {
 	gin          *gin.Engine
 	cacheManager *CacheManager
 	clientPool   *sync.Pool
+	routers      map[string]*route.Router // 服务名对应的后端API路由
+	server       *http.Server
+	listener     net.Listener
 }
 
 // NewFrontServer 创建新的服务器实例
@@ -54,6 +62,15 @@ func NewFrontServer(cfg *config.Config, logger *zerolog.Logger) (*HeliosServer,
 		logger:       logger,
 		cacheManager: cacheManager,
 		clientPool:   clientPool,
+		routers:      make(map[string]*route.Router),
+	}
+
+	for _, srv := range cfg.PxyFrontend.Servers {
+		router, err := route.NewRouter(srv.Backends)
+		if err != nil {
+			logger.Warn().Err(err).Str("server", srv.Name).Msg("skip invalid backend route")
+		}
+		server.routers[srv.Name] = router
 	}
 
 	server.setupGin()
@@ -289,17 +306,44 @@ func (s *HeliosServer) HandleError(c *gin.Context, statusCode int, message strin
 	c.JSON(statusCode, gin.H{"error": message})
 }
 
-// Start 启动服务器
-func (s *HeliosServer) Start() error {
+// Listen 创建监听器 平滑升级启动时复用父进程的监听器
+func (s *HeliosServer) Listen() error {
+	if s.listener != nil {
+		return nil
+	}
 	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
-	s.logger.Info().Str("address", addr).Msg("starting helios server")
-	return s.gin.Run(addr)
+	ln, err := upgrade.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+	s.server = &http.Server{Addr: addr, Handler: s.gin.Handler()}
+	return nil
 }
 
-// Shutdown 优雅关闭服务器
+// Start 启动服务器 未调用Listen时先创建监听器
+func (s *HeliosServer) Start() error {
+	if err := s.Listen(); err != nil {
+		return err
+	}
+	s.logger.Info().Str("address", s.server.Addr).Msg("starting helios server")
+	err := s.server.Serve(s.listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown 优雅关闭服务器 等待进行中的请求处理完成
 func (s *HeliosServer) Shutdown() {
 	s.logger.Info().Msg("shutting down helios server...")
-
+	if s.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.server.Shutdown(ctx); err != nil {
+			s.logger.Error().Err(err).Msg("helios server shutdown failed")
+		}
+	}
 	s.logger.Info().Msg("server shutdown complete")
 }
 
@@ -0,0 +1,232 @@
+// Package accesslog
+// 网关访问日志 每个经过网关的请求记录一行 支持JSON和nginx风格的模板格式
+package accesslog
+
+import (
+	"Hamburger/gateway/reqinfo"
+	"Hamburger/internal/config"
+	"Hamburger/internal/logger"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	FormatJSON  = "json"
+	FormatNginx = "nginx"
+)
+
+var (
+	current atomic.Pointer[AccessLog]
+	initMu  sync.Mutex
+)
+
+// AccessLog 访问日志输出
+type AccessLog struct {
+	conf   config.AccessLogConfig
+	out    io.Writer
+	closer io.Closer
+	json   zerolog.Logger
+	tpl    template
+}
+
+// Entry 单条访问记录
+type Entry struct {
+	Time         time.Time
+	TraceID      string
+	RemoteAddr   string
+	Host         string
+	Method       string
+	URI          string
+	Proto        string // HTTP/1.1
+	Protocol     string // h1 h2 h3
+	Status       int
+	Bytes        int64
+	Duration     time.Duration
+	Upstream     string
+	UpstreamType string
+	UpstreamTime time.Duration
+	TLSVersion   string
+	SNI          string
+	Rejected     string
+	Referer      string
+	UserAgent    string
+}
+
+// New 按配置创建访问日志 文件为空时输出到标准输出
+func New(conf config.AccessLogConfig) (*AccessLog, error) {
+	a := &AccessLog{conf: conf}
+	switch conf.Format {
+	case "", FormatJSON:
+	case FormatNginx:
+		tpl, err := compile(conf.Template)
+		if err != nil {
+			return nil, err
+		}
+		a.tpl = tpl
+	default:
+		return nil, fmt.Errorf("unknown access log format: %s", conf.Format)
+	}
+
+	if conf.File == "" {
+		a.out = os.Stdout
+	} else {
+		w, err := logger.NewRotateWriter(conf.File, conf.LogRotateConfig)
+		if err != nil {
+			return nil, err
+		}
+		a.out = w
+		a.closer = w
+	}
+	a.json = zerolog.New(a.out)
+	return a, nil
+}
+
+// Init 初始化或按新配置替换访问日志 配置未变化时不做处理
+func Init(conf config.AccessLogConfig) error {
+	initMu.Lock()
+	defer initMu.Unlock()
+
+	old := current.Load()
+	if old != nil && old.conf == conf {
+		return nil
+	}
+	if old == nil && !conf.Enabled {
+		return nil
+	}
+
+	var a *AccessLog
+	if conf.Enabled {
+		var err error
+		if a, err = New(conf); err != nil {
+			return err
+		}
+	}
+	current.Store(a)
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
+
+// Close 关闭访问日志
+func Close() error {
+	initMu.Lock()
+	defer initMu.Unlock()
+	if a := current.Swap(nil); a != nil {
+		return a.Close()
+	}
+	return nil
+}
+
+func (a *AccessLog) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// Log 记录请求 未开启访问日志时直接返回
+// header为已写出的响应头 请求未携带TraceID时从中获取网关生成的TraceID
+func Log(req *http.Request, header http.Header, info *reqinfo.Info, status int, bytes int64) {
+	a := current.Load()
+	if a == nil {
+		return
+	}
+	a.Write(NewEntry(req, header, info, status, bytes))
+}
+
+// NewEntry 从请求和处理信息生成访问记录
+func NewEntry(req *http.Request, header http.Header, info *reqinfo.Info, status int, bytes int64) *Entry {
+	if status <= 0 {
+		status = http.StatusOK
+	}
+	e := &Entry{
+		Time:       time.Now(),
+		RemoteAddr: req.RemoteAddr,
+		Host:       req.Host,
+		Method:     req.Method,
+		URI:        req.RequestURI,
+		Proto:      req.Proto,
+		Protocol:   reqinfo.Protocol(req),
+		Status:     status,
+		Bytes:      bytes,
+		Referer:    req.Referer(),
+		UserAgent:  req.UserAgent(),
+	}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		e.RemoteAddr = host
+	}
+	if e.URI == "" {
+		e.URI = req.URL.RequestURI()
+	}
+	if traceHeader := config.Get().ProxyHeader.TraceId; traceHeader != "" {
+		e.TraceID = req.Header.Get(traceHeader)
+		if e.TraceID == "" && header != nil {
+			e.TraceID = header.Get(traceHeader)
+		}
+	}
+	if req.TLS != nil {
+		e.TLSVersion = tlsVersion(req.TLS.Version)
+		e.SNI = req.TLS.ServerName
+	}
+	if info != nil {
+		if info.ClientIP.IsValid() {
+			e.RemoteAddr = info.ClientIP.String()
+		}
+		e.Duration = time.Since(info.Start)
+		e.Upstream = info.Upstream
+		e.UpstreamType = info.UpstreamType
+		e.UpstreamTime = info.UpstreamTime
+		e.Rejected = info.Rejected
+	}
+	return e
+}
+
+// Write 按配置的格式输出一行
+func (a *AccessLog) Write(e *Entry) {
+	if a.tpl != nil {
+		buf := make([]byte, 0, 256)
+		buf = a.tpl.append(buf, e)
+		_, _ = a.out.Write(append(buf, '\n'))
+		return
+	}
+	a.json.Log().
+		Time("time", e.Time).
+		Str("trace_id", e.TraceID).
+		Str("remote_addr", e.RemoteAddr).
+		Str("host", e.Host).
+		Str("method", e.Method).
+		Str("uri", e.URI).
+		Str("protocol", e.Protocol).
+		Int("status", e.Status).
+		Int64("bytes", e.Bytes).
+		Float64("duration_ms", milliseconds(e.Duration)).
+		Str("upstream", e.Upstream).
+		Str("upstream_type", e.UpstreamType).
+		Float64("upstream_ms", milliseconds(e.UpstreamTime)).
+		Str("tls_version", e.TLSVersion).
+		Str("sni", e.SNI).
+		Str("rejected", e.Rejected).
+		Str("referer", e.Referer).
+		Str("user_agent", e.UserAgent).
+		Send()
+}
+
+// tlsVersion TLS 1.3 -> TLSv1.3 与nginx的$ssl_protocol一致
+func tlsVersion(version uint16) string {
+	return strings.Replace(tls.VersionName(version), "TLS ", "TLSv", 1)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
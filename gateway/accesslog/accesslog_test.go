@@ -0,0 +1,81 @@
+package accesslog
+
+import (
+	"Hamburger/internal/json"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func testEntry() *Entry {
+	return &Entry{
+		Time:         time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		TraceID:      "trace-1",
+		RemoteAddr:   "10.0.0.1",
+		Host:         "example.com",
+		Method:       "GET",
+		URI:          "/api?q=1",
+		Proto:        "HTTP/2.0",
+		Protocol:     "h2",
+		Status:       502,
+		Bytes:        42,
+		Duration:     1500 * time.Millisecond,
+		Upstream:     "127.0.0.1:8080",
+		UpstreamType: "backend",
+		UpstreamTime: 12 * time.Millisecond,
+		TLSVersion:   "TLSv1.3",
+		SNI:          "example.com",
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	tpl, err := compile(`$remote_addr [$time_local] "$request" $status $body_bytes_sent $request_time ` +
+		`$upstream_addr $upstream_response_time $ssl_protocol $rejected_by $$ $`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(tpl.append(nil, testEntry()))
+	want := `10.0.0.1 [02/Jan/2025:03:04:05 +0000] "GET /api?q=1 HTTP/2.0" 502 42 1.500 ` +
+		`127.0.0.1:8080 0.012 TLSv1.3 - $$ $`
+	if got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+
+	if _, err = compile("$status $unknown"); err == nil {
+		t.Fatal("expected error for unknown variable")
+	}
+	if _, err = compile(""); err != nil {
+		t.Fatalf("default template: %v", err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	a := &AccessLog{out: &buf, json: zerolog.New(&buf)}
+	a.Write(testEntry())
+
+	line := buf.String()
+	if !strings.HasSuffix(line, "\n") || strings.Count(line, "\n") != 1 {
+		t.Fatalf("expected one line, got %q", line)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]any{
+		"trace_id":      "trace-1",
+		"upstream":      "127.0.0.1:8080",
+		"upstream_type": "backend",
+		"upstream_ms":   float64(12),
+		"status":        float64(502),
+		"protocol":      "h2",
+		"sni":           "example.com",
+	} {
+		if fields[key] != want {
+			t.Errorf("%s = %v, want %v", key, fields[key], want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package accesslog
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// nginx风格的日志模板 $变量在输出时替换为请求的对应值 值为空时输出-
+// 支持的变量
+// $time_local             请求结束时间 02/Jan/2006:15:04:05 -0700
+// $time_iso8601           请求结束时间 RFC3339
+// $trace_id               TraceID
+// $remote_addr            客户端地址
+// $host                   请求的Host
+// $request                请求行 "GET /path HTTP/1.1"
+// $request_method         请求方法
+// $request_uri            请求路径和参数
+// $server_protocol        HTTP/1.1 HTTP/2.0 HTTP/3.0
+// $protocol               h1 h2 h3
+// $status                 响应状态码
+// $body_bytes_sent        响应字节数
+// $request_time           请求总耗时(秒)
+// $upstream_addr          最终使用的上游地址
+// $upstream_type          上游类型 frontend backend custom none
+// $upstream_response_time 等待上游响应头的耗时(秒)
+// $ssl_protocol           TLS版本
+// $ssl_server_name        SNI
+// $rejected_by            拒绝请求的处理环节
+// $http_referer           Referer
+// $http_user_agent        User-Agent
+
+const DefaultTemplate = `$remote_addr - [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent" ` +
+	`host=$host upstream=$upstream_addr type=$upstream_type rt=$request_time urt=$upstream_response_time ` +
+	`proto=$protocol tls=$ssl_protocol sni=$ssl_server_name trace=$trace_id rejected=$rejected_by`
+
+type variable func(buf []byte, e *Entry) []byte
+
+var variables = map[string]variable{
+	"time_local": func(buf []byte, e *Entry) []byte {
+		return e.Time.AppendFormat(buf, "02/Jan/2006:15:04:05 -0700")
+	},
+	"time_iso8601": func(buf []byte, e *Entry) []byte {
+		return e.Time.AppendFormat(buf, time.RFC3339)
+	},
+	"trace_id":       stringVar(func(e *Entry) string { return e.TraceID }),
+	"remote_addr":    stringVar(func(e *Entry) string { return e.RemoteAddr }),
+	"host":           stringVar(func(e *Entry) string { return e.Host }),
+	"request_method": stringVar(func(e *Entry) string { return e.Method }),
+	"request_uri":    stringVar(func(e *Entry) string { return e.URI }),
+	"request": func(buf []byte, e *Entry) []byte {
+		buf = append(buf, e.Method...)
+		buf = append(buf, ' ')
+		buf = append(buf, e.URI...)
+		buf = append(buf, ' ')
+		return append(buf, e.Proto...)
+	},
+	"server_protocol": stringVar(func(e *Entry) string { return e.Proto }),
+	"protocol":        stringVar(func(e *Entry) string { return e.Protocol }),
+	"status": func(buf []byte, e *Entry) []byte {
+		return strconv.AppendInt(buf, int64(e.Status), 10)
+	},
+	"body_bytes_sent": func(buf []byte, e *Entry) []byte {
+		return strconv.AppendInt(buf, e.Bytes, 10)
+	},
+	"request_time": func(buf []byte, e *Entry) []byte {
+		return appendSeconds(buf, e.Duration)
+	},
+	"upstream_addr": stringVar(func(e *Entry) string { return e.Upstream }),
+	"upstream_type": stringVar(func(e *Entry) string { return e.UpstreamType }),
+	"upstream_response_time": func(buf []byte, e *Entry) []byte {
+		if e.Upstream == "" {
+			return append(buf, '-')
+		}
+		return appendSeconds(buf, e.UpstreamTime)
+	},
+	"ssl_protocol":    stringVar(func(e *Entry) string { return e.TLSVersion }),
+	"ssl_server_name": stringVar(func(e *Entry) string { return e.SNI }),
+	"rejected_by":     stringVar(func(e *Entry) string { return e.Rejected }),
+	"http_referer":    stringVar(func(e *Entry) string { return e.Referer }),
+	"http_user_agent": stringVar(func(e *Entry) string { return e.UserAgent }),
+}
+
+func stringVar(get func(e *Entry) string) variable {
+	return func(buf []byte, e *Entry) []byte {
+		value := get(e)
+		if value == "" {
+			return append(buf, '-')
+		}
+		return append(buf, value...)
+	}
+}
+
+// appendSeconds 秒 精确到毫秒 与nginx一致
+func appendSeconds(buf []byte, d time.Duration) []byte {
+	return strconv.AppendFloat(buf, d.Seconds(), 'f', 3, 64)
+}
+
+// template 编译后的模板 由字面量和变量交替组成
+type template []variable
+
+func (t template) append(buf []byte, e *Entry) []byte {
+	for _, part := range t {
+		buf = part(buf, e)
+	}
+	return buf
+}
+
+// compile 解析模板 未知变量返回错误
+func compile(text string) (template, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+	var tpl template
+	literal := func(s string) {
+		if s != "" {
+			tpl = append(tpl, func(buf []byte, _ *Entry) []byte { return append(buf, s...) })
+		}
+	}
+
+	start := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] != '$' {
+			continue
+		}
+		end := i + 1
+		for end < len(text) && isNameChar(text[end]) {
+			end++
+		}
+		if end == i+1 {
+			continue
+		}
+		name := text[i+1 : end]
+		v, ok := variables[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown access log variable: $%s", name)
+		}
+		literal(text[start:i])
+		tpl = append(tpl, v)
+		start = end
+		i = end - 1
+	}
+	literal(text[start:])
+	return tpl, nil
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,283 @@
+package admin
+
+import (
+	"Hamburger/gateway/balancer"
+	"Hamburger/gateway/breaker"
+	"Hamburger/gateway/health"
+	"Hamburger/gateway/prehandler"
+	"Hamburger/gateway/runtime"
+	"Hamburger/internal/config"
+	"Hamburger/internal/json"
+	"Hamburger/internal/logger"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+)
+
+// 接口列表
+// GET    /admin/servers                  网关服务器实例
+// GET    /admin/runtime/domains          域名映射
+// GET    /admin/runtime/ports            域名端口组
+// GET    /admin/breaker                  熔断状态
+// GET    /admin/flow                     流控状态
+// GET    /admin/health                   健康检查和摘除的端口
+// GET    /admin/custom-services          自定义服务
+// POST   /admin/custom-services          新增或替换自定义服务
+// DELETE /admin/custom-services/{domain} 删除自定义服务
+// POST   /admin/drain                    摘除上游 {"host":"","port":0} host为空时为本机端口
+// POST   /admin/undrain                  恢复上游 {"host":"","port":0}
+// POST   /admin/resync/domains           重新加载域名映射文件
+// POST   /admin/resync/ports             从Mongo重新同步域名端口组
+// POST   /admin/reload                   重新加载配置文件
+// GET    /admin/log/level                当前日志级别
+// PUT    /admin/log/level                修改日志级别 {"level":"debug"} 配置重载后恢复为配置文件中的级别
+
+type serverStatus struct {
+	Name     string `json:"name"`
+	Protocol string `json:"protocol"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	TLS      bool   `json:"tls"`
+	Started  bool   `json:"started"`
+	Error    string `json:"error,omitempty"`
+}
+
+type http3Status struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+type levelRequest struct {
+	Level string `json:"level"`
+}
+
+type portRequest struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+func (s *AdminServer) registerMux(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/servers", s.handleServers)
+	mux.HandleFunc("GET /admin/runtime/domains", handleDomains)
+	mux.HandleFunc("GET /admin/runtime/ports", handlePorts)
+	mux.HandleFunc("GET /admin/breaker", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, breaker.Snapshot())
+	})
+	mux.HandleFunc("GET /admin/flow", func(w http.ResponseWriter, r *http.Request) {
+		status, ok := prehandler.FlowStatus()
+		if !ok {
+			writeError(w, http.StatusNotFound, errors.New("rate limiter not loaded"))
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	})
+	mux.HandleFunc("GET /admin/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"instances": health.Snapshot(),
+			"drained":   health.Drained(),
+		})
+	})
+	mux.HandleFunc("GET /admin/custom-services", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, config.Get().PxyCustomService)
+	})
+	mux.HandleFunc("POST /admin/custom-services", s.handleAddCustomService)
+	mux.HandleFunc("DELETE /admin/custom-services/{domain}", s.handleRemoveCustomService)
+	mux.HandleFunc("POST /admin/drain", handleDrain)
+	mux.HandleFunc("POST /admin/undrain", handleUndrain)
+	mux.HandleFunc("POST /admin/resync/domains", func(w http.ResponseWriter, r *http.Request) {
+		s.controller.ResyncDomains()
+		s.logger.Info().Msg("admin: domains map resynced")
+		writeJSON(w, http.StatusOK, map[string]any{"domains": len(runtime.Domains)})
+	})
+	mux.HandleFunc("POST /admin/resync/ports", func(w http.ResponseWriter, r *http.Request) {
+		runtime.RefreshDomainPortsMap()
+		balancer.Reset()
+		s.logger.Info().Msg("admin: domain ports resynced")
+		writeJSON(w, http.StatusOK, map[string]any{"domains": runtime.DomainPortsMap.Size()})
+	})
+	mux.HandleFunc("POST /admin/reload", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.controller.Reload(); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"reloaded": true})
+	})
+	mux.HandleFunc("GET /admin/log/level", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, levelRequest{Level: logger.Level()})
+	})
+	mux.HandleFunc("PUT /admin/log/level", s.handleLogLevel)
+}
+
+func (s *AdminServer) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req levelRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := logger.SetLevel(req.Level); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.logger.Info().Str("level", req.Level).Msg("admin: log level changed")
+	writeJSON(w, http.StatusOK, levelRequest{Level: logger.Level()})
+}
+
+func (s *AdminServer) handleServers(w http.ResponseWriter, r *http.Request) {
+	servers := make([]serverStatus, 0)
+	for _, instance := range s.manager.GetServerStatus() {
+		status := serverStatus{
+			Name:     instance.Name,
+			Protocol: instance.Config.Protocol,
+			Host:     instance.Config.Host,
+			Port:     instance.Config.Port,
+			TLS:      instance.TLS,
+			Started:  instance.Started,
+		}
+		if instance.Error != nil {
+			status.Error = instance.Error.Error()
+		}
+		servers = append(servers, status)
+	}
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].Name < servers[j].Name
+	})
+
+	http3 := make([]http3Status, 0)
+	for _, instance := range s.manager.GetHttp3ServerStatus() {
+		http3 = append(http3, http3Status{Name: instance.Name, Address: instance.Address})
+	}
+	sort.Slice(http3, func(i, j int) bool {
+		return http3[i].Name < http3[j].Name
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"servers": servers,
+		"http3":   http3,
+	})
+}
+
+func handleDomains(w http.ResponseWriter, r *http.Request) {
+	runtime.DomainLock.RLock()
+	domains := runtime.DomainsRuntimeMap
+	runtime.DomainLock.RUnlock()
+
+	services := make(map[string]any)
+	if domains.DomainsMap != nil {
+		for _, key := range domains.DomainsMap.Keys() {
+			if value, ok := domains.DomainsMap.Get(key); ok {
+				services[key] = value
+			}
+		}
+	}
+	fronts := make(map[string]string)
+	if domains.DomainFrontMap != nil {
+		domains.DomainFrontMap.Range(func(key string, value string) bool {
+			fronts[key] = value
+			return true
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"domains":  domains.Domains,
+		"services": services,
+		"fronts":   fronts,
+	})
+}
+
+func handlePorts(w http.ResponseWriter, r *http.Request) {
+	ports := make(map[string][]int)
+	runtime.DomainPortsMap.Range(func(key string, value []int) bool {
+		ports[key] = value
+		return true
+	})
+	writeJSON(w, http.StatusOK, ports)
+}
+
+func (s *AdminServer) handleAddCustomService(w http.ResponseWriter, r *http.Request) {
+	var service config.CustomServiceConfig
+	if err := decodeBody(r, &service); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if service.Domain == "" || len(service.Upstream) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("domain and upstream are required"))
+		return
+	}
+	if err := s.controller.AddCustomService(service); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.logger.Info().Str("domain", service.Domain).Msg("admin: custom service added")
+	writeJSON(w, http.StatusOK, service)
+}
+
+func (s *AdminServer) handleRemoveCustomService(w http.ResponseWriter, r *http.Request) {
+	domain := r.PathValue("domain")
+	if err := s.controller.RemoveCustomService(domain); err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, ErrNotFound) {
+			code = http.StatusNotFound
+		}
+		writeError(w, code, err)
+		return
+	}
+	s.logger.Info().Str("domain", domain).Msg("admin: custom service removed")
+	writeJSON(w, http.StatusOK, map[string]any{"removed": domain})
+}
+
+func handleDrain(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePort(w, r)
+	if !ok {
+		return
+	}
+	health.Drain(req.Host, req.Port)
+	writeJSON(w, http.StatusOK, health.Drained())
+}
+
+func handleUndrain(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePort(w, r)
+	if !ok {
+		return
+	}
+	if !health.Undrain(req.Host, req.Port) {
+		writeError(w, http.StatusNotFound, ErrNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, health.Drained())
+}
+
+func decodePort(w http.ResponseWriter, r *http.Request) (portRequest, bool) {
+	var req portRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return req, false
+	}
+	if req.Port <= 0 || req.Port > 65535 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid port"))
+		return req, false
+	}
+	return req, true
+}
+
+func decodeBody(r *http.Request, v any) error {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
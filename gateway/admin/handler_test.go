@@ -0,0 +1,163 @@
+package admin
+
+import (
+	"Hamburger/gateway/health"
+	"Hamburger/gateway/runtime"
+	"Hamburger/internal/config"
+	"Hamburger/internal/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+const testToken = "admin-token"
+
+type fakeController struct {
+	reloadErr error
+	services  map[string]config.CustomServiceConfig
+}
+
+func (c *fakeController) Reload() error  { return c.reloadErr }
+func (c *fakeController) ResyncDomains() {}
+func (c *fakeController) AddCustomService(service config.CustomServiceConfig) error {
+	c.services[service.Domain] = service
+	return nil
+}
+func (c *fakeController) RemoveCustomService(domain string) error {
+	if _, ok := c.services[domain]; !ok {
+		return ErrNotFound
+	}
+	delete(c.services, domain)
+	return nil
+}
+
+func newTestAdmin(t *testing.T) (http.Handler, *fakeController) {
+	t.Helper()
+	config.Set(&config.Config{})
+	logger := zerolog.Nop()
+	controller := &fakeController{services: make(map[string]config.CustomServiceConfig)}
+	s := NewAdminServer(config.AdminConfig{Enabled: true, Token: testToken}, nil, controller, &logger)
+	return s.server.Handler, controller
+}
+
+func call(t *testing.T, h http.Handler, method, target, body string, v any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: %v %s", method, target, err, rec.Body.String())
+		}
+	}
+	return rec.Code
+}
+
+func TestAuth(t *testing.T) {
+	h, _ := newTestAdmin(t)
+	for _, header := range []string{"", "Bearer wrong", testToken} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/health", nil)
+		req.Header.Set("Authorization", header)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d", header, rec.Code)
+		}
+	}
+	if code := call(t, h, http.MethodGet, "/admin/health", "", nil); code != http.StatusOK {
+		t.Fatalf("authorized request: status %d", code)
+	}
+}
+
+func TestDrainEndpoints(t *testing.T) {
+	h, _ := newTestAdmin(t)
+
+	// 不同主机上相同端口的上游分别摘除
+	var drained []health.DrainedPort
+	if code := call(t, h, http.MethodPost, "/admin/drain", `{"host":"10.0.0.2","port":8080}`, &drained); code != http.StatusOK {
+		t.Fatalf("drain: status %d", code)
+	}
+	t.Cleanup(func() { health.Undrain("10.0.0.2", 8080) })
+	if len(drained) != 1 || drained[0] != (health.DrainedPort{Host: "10.0.0.2", Port: 8080}) {
+		t.Fatalf("drained = %v", drained)
+	}
+	if health.IsUpstreamUp("app.example.com", "10.0.0.2", 8080) || !health.IsUpstreamUp("app.example.com", "10.0.0.3", 8080) {
+		t.Fatal("drain should only affect the given host")
+	}
+
+	var status struct {
+		Drained []health.DrainedPort `json:"drained"`
+	}
+	call(t, h, http.MethodGet, "/admin/health", "", &status)
+	if len(status.Drained) != 1 {
+		t.Fatalf("health drained = %v", status.Drained)
+	}
+
+	cases := []struct {
+		path string
+		body string
+		code int
+	}{
+		{"/admin/drain", `{"host":"10.0.0.2","port":0}`, http.StatusBadRequest},
+		{"/admin/drain", `{bad json`, http.StatusBadRequest},
+		{"/admin/undrain", `{"host":"10.0.0.3","port":8080}`, http.StatusNotFound},
+		{"/admin/undrain", `{"host":"10.0.0.2","port":8080}`, http.StatusOK},
+		{"/admin/undrain", `{"host":"10.0.0.2","port":8080}`, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		if code := call(t, h, http.MethodPost, c.path, c.body, nil); code != c.code {
+			t.Errorf("%s %s: status %d, want %d", c.path, c.body, code, c.code)
+		}
+	}
+}
+
+func TestCustomServiceEndpoints(t *testing.T) {
+	h, controller := newTestAdmin(t)
+
+	body := `{"domain":"svc.example.com","upstream":[{"host":"10.0.0.2","port":8080}]}`
+	if code := call(t, h, http.MethodPost, "/admin/custom-services", body, nil); code != http.StatusOK {
+		t.Fatalf("add: status %d", code)
+	}
+	if _, ok := controller.services["svc.example.com"]; !ok {
+		t.Fatal("service not passed to controller")
+	}
+	if code := call(t, h, http.MethodPost, "/admin/custom-services", `{"domain":"svc.example.com"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("add without upstream: status %d", code)
+	}
+	if code := call(t, h, http.MethodDelete, "/admin/custom-services/svc.example.com", "", nil); code != http.StatusOK {
+		t.Fatalf("remove: status %d", code)
+	}
+	if code := call(t, h, http.MethodDelete, "/admin/custom-services/svc.example.com", "", nil); code != http.StatusNotFound {
+		t.Fatalf("remove missing: status %d", code)
+	}
+}
+
+func TestRuntimeEndpoints(t *testing.T) {
+	h, controller := newTestAdmin(t)
+
+	runtime.DomainPortsMap.Put("ports.example.com", []int{8080, 8081})
+	defer runtime.DomainPortsMap.Delete("ports.example.com")
+	var ports map[string][]int
+	call(t, h, http.MethodGet, "/admin/runtime/ports", "", &ports)
+	if len(ports["ports.example.com"]) != 2 {
+		t.Fatalf("ports = %v", ports)
+	}
+
+	if code := call(t, h, http.MethodPost, "/admin/reload", "", nil); code != http.StatusOK {
+		t.Fatalf("reload: status %d", code)
+	}
+	controller.reloadErr = errors.New("invalid config")
+	var result map[string]string
+	if code := call(t, h, http.MethodPost, "/admin/reload", "", &result); code != http.StatusInternalServerError || result["error"] != "invalid config" {
+		t.Fatalf("failed reload: status %d %v", code, result)
+	}
+
+	if code := call(t, h, http.MethodPut, "/admin/log/level", `{"level":"nope"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("invalid level: status %d", code)
+	}
+}
@@ -0,0 +1,111 @@
+// Package admin
+// 管理接口 用于查看运行时状态和执行运维操作
+package admin
+
+import (
+	"Hamburger/gateway/manager"
+	"Hamburger/internal/config"
+	"Hamburger/internal/upgrade"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// 管理接口运行在独立的监听地址上 所有请求需要携带 Authorization: Bearer <token>
+// 修改类操作与配置重载、定时同步任务使用相同的代码路径
+// 通过接口新增或删除的自定义服务不会写回配置文件 从配置文件重载后以配置文件为准
+
+const maxBodySize = 1 << 20
+
+// ErrNotFound 操作的对象不存在
+var ErrNotFound = errors.New("not found")
+
+// Controller 需要由应用完成的操作
+type Controller interface {
+	Reload() error
+	ResyncDomains()
+	AddCustomService(service config.CustomServiceConfig) error
+	RemoveCustomService(domain string) error
+}
+
+type AdminServer struct {
+	Enabled bool
+	Addr    string
+
+	token      string
+	manager    *manager.Manager
+	controller Controller
+	logger     *zerolog.Logger
+	server     *http.Server
+}
+
+func NewAdminServer(c config.AdminConfig, m *manager.Manager, controller Controller, l *zerolog.Logger) *AdminServer {
+	s := &AdminServer{
+		Enabled:    c.Enabled,
+		Addr:       fmt.Sprintf("%s:%d", c.Host, c.Port),
+		token:      c.Token,
+		manager:    m,
+		controller: controller,
+		logger:     l,
+	}
+	mux := http.NewServeMux()
+	s.registerMux(mux)
+	s.server = &http.Server{
+		Addr:              s.Addr,
+		Handler:           s.auth(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+func (s *AdminServer) Start() error {
+	if !s.Enabled {
+		return nil
+	}
+	if s.token == "" {
+		s.logger.Warn().Msg("admin token is empty, admin server disabled")
+		s.Enabled = false
+		return nil
+	}
+	s.logger.Info().Str("address", s.Addr).Msg("start admin server")
+
+	ln, err := upgrade.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		err := s.server.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error().Err(err).Msg("admin server listen err")
+		}
+	}()
+	return nil
+}
+
+func (s *AdminServer) Stop() error {
+	if !s.Enabled {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// auth 校验Bearer令牌
+func (s *AdminServer) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		next.ServeHTTP(w, r)
+	})
+}
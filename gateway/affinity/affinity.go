@@ -0,0 +1,144 @@
+// Package affinity
+// 基于Cookie的会话保持
+package affinity
+
+import (
+	"Hamburger/gateway/health"
+	"Hamburger/gateway/runtime"
+	"Hamburger/internal/config"
+	"Hamburger/internal/utils"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 网关在首次转发后下发签名的会话Cookie 记录选中的后端端口
+// 后续请求在端口仍注册且健康时转发到同一端口 否则回退到正常的负载均衡
+
+const (
+	DefaultCookieName = "HAMBURGER_AFFINITY"
+	signLength        = 16 // 签名截取的hex长度
+)
+
+var (
+	secret     []byte
+	secretOnce sync.Once
+)
+
+func getSecret() []byte {
+	secretOnce.Do(func() {
+		if s := config.Get().Features.Sticky.Secret; s != "" {
+			secret = []byte(s)
+			return
+		}
+		// 未配置密钥时随机生成 重启后旧Cookie失效并回退到负载均衡
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	})
+	return secret
+}
+
+// CookieName 会话保持Cookie名称
+func CookieName() string {
+	return utils.DefaultString(config.Get().Features.Sticky.CookieName, DefaultCookieName)
+}
+
+// Enabled 域名映射中是否为该域名开启了会话保持
+func Enabled(domain string) bool {
+	sm, ok := runtime.DomainsRuntimeMap.DomainsMap.Get(domain)
+	if !ok {
+		return false
+	}
+	return sm.Sticky
+}
+
+// Sign 生成绑定域名和端口的Cookie值 格式: port.expires.signature
+// 配置了有效期时expires为过期的Unix时间 会话Cookie为0
+func Sign(domain string, port int) string {
+	var expires int64
+	if maxAge := config.Get().Features.Sticky.MaxAge; maxAge > 0 {
+		expires = time.Now().Add(time.Duration(maxAge) * time.Second).Unix()
+	}
+	return sign(domain, port, expires)
+}
+
+func sign(domain string, port int, expires int64) string {
+	payload := strconv.Itoa(port) + "." + strconv.FormatInt(expires, 10)
+	return payload + "." + signature(domain, payload)
+}
+
+// Verify 校验Cookie值并解析端口 签名不匹配或已过期时返回false
+func Verify(domain, value string) (int, bool) {
+	payload, sign, ok := cutLast(value, ".")
+	if !ok {
+		return 0, false
+	}
+	if !hmac.Equal([]byte(sign), []byte(signature(domain, payload))) {
+		return 0, false
+	}
+	p, e, ok := strings.Cut(payload, ".")
+	if !ok {
+		return 0, false
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return 0, false
+	}
+	expires, err := strconv.ParseInt(e, 10, 64)
+	if err != nil || (expires > 0 && time.Now().Unix() >= expires) {
+		return 0, false
+	}
+	return port, true
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+func signature(domain, payload string) string {
+	mac := hmac.New(sha256.New, getSecret())
+	mac.Write([]byte(domain + "|" + payload))
+	return hex.EncodeToString(mac.Sum(nil))[:signLength]
+}
+
+// Lookup 从请求的会话Cookie中获取仍然可用的端口
+func Lookup(req *http.Request, domain string, ports []int) (int, bool) {
+	if !Enabled(domain) {
+		return 0, false
+	}
+	cookie, err := req.Cookie(CookieName())
+	if err != nil {
+		return 0, false
+	}
+	port, ok := Verify(domain, cookie.Value)
+	if !ok {
+		return 0, false
+	}
+	if !slices.Contains(ports, port) || !health.IsUp(domain, port) {
+		return 0, false
+	}
+	return port, true
+}
+
+// NewCookie 创建指向端口的会话Cookie
+func NewCookie(domain string, port int) *http.Cookie {
+	return &http.Cookie{
+		Name:     CookieName(),
+		Value:    Sign(domain, port),
+		Path:     "/",
+		MaxAge:   config.Get().Features.Sticky.MaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
@@ -0,0 +1,98 @@
+package affinity
+
+import (
+	"Hamburger/gateway/health"
+	"Hamburger/gateway/runtime"
+	"Hamburger/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDomain = "app.example.com"
+
+func setupSticky(t *testing.T) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Features.Sticky = config.StickyConfig{Secret: "test-secret", MaxAge: 3600}
+	config.Set(cfg)
+
+	path := filepath.Join(t.TempDir(), "domains.json")
+	data := `{"` + testDomain + `": {"backend": "app", "sticky": true}, "plain.example.com": {"backend": "plain"}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runtime.InitRuntimeDomains(&config.AppConfig{DomainMap: path})
+}
+
+func TestSignVerify(t *testing.T) {
+	setupSticky(t)
+
+	value := Sign(testDomain, 8080)
+	if port, ok := Verify(testDomain, value); !ok || port != 8080 {
+		t.Fatalf("Verify(%q) = %d %v", value, port, ok)
+	}
+
+	port, expires, _ := strings.Cut(strings.TrimPrefix(value, "8080."), ".")
+	tampered := []string{
+		"",
+		"8080",
+		strings.Replace(value, "8080", "8081", 1),
+		"8080." + port + ".0000000000000000",
+		"8080.0." + expires,
+		value + "0",
+	}
+	for _, v := range tampered {
+		if _, ok := Verify(testDomain, v); ok {
+			t.Errorf("tampered value %q accepted", v)
+		}
+	}
+	if _, ok := Verify("other.example.com", value); ok {
+		t.Error("cookie for another domain accepted")
+	}
+
+	expired := sign(testDomain, 8080, time.Now().Add(-time.Second).Unix())
+	if _, ok := Verify(testDomain, expired); ok {
+		t.Error("expired cookie accepted")
+	}
+	// 会话Cookie不在服务端过期
+	if port, ok := Verify(testDomain, sign(testDomain, 8080, 0)); !ok || port != 8080 {
+		t.Error("session cookie rejected")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	setupSticky(t)
+	ports := []int{8080, 8081}
+	request := func(domain, value string) *http.Request {
+		req := httptest.NewRequest("GET", "http://"+domain+"/", nil)
+		if value != "" {
+			req.AddCookie(&http.Cookie{Name: CookieName(), Value: value})
+		}
+		return req
+	}
+
+	if port, ok := Lookup(request(testDomain, Sign(testDomain, 8081)), testDomain, ports); !ok || port != 8081 {
+		t.Fatalf("Lookup = %d %v", port, ok)
+	}
+	if _, ok := Lookup(request(testDomain, ""), testDomain, ports); ok {
+		t.Error("request without cookie pinned")
+	}
+	if _, ok := Lookup(request(testDomain, Sign(testDomain, 9000)), testDomain, ports); ok {
+		t.Error("unregistered port pinned")
+	}
+	if _, ok := Lookup(request("plain.example.com", Sign("plain.example.com", 8080)), "plain.example.com", ports); ok {
+		t.Error("domain without sticky pinned")
+	}
+
+	// 固定的端口不可用时回退到负载均衡
+	health.Drain("", 8081)
+	defer health.Undrain("", 8081)
+	if _, ok := Lookup(request(testDomain, Sign(testDomain, 8081)), testDomain, ports); ok {
+		t.Error("unhealthy port pinned")
+	}
+}
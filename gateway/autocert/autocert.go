@@ -3,22 +3,82 @@ package autocert
 // 自动TLS证书申请
 
 import (
+	"Hamburger/internal/config"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
 )
 
+// 验证方式
+const (
+	ChallengeHTTP    = "http-01"
+	ChallengeTLSALPN = "tls-alpn-01"
+	ChallengeDNS     = "dns-01"
+)
+
+const DefaultCacheDir = "./autocert"
+
+// UseIssuer 是否使用Issuer申请证书
+// http-01仍由autocert处理 需要在验证时接管80端口 其余验证方式不占用额外端口
+func UseIssuer(conf config.AutoCertConfig) bool {
+	return conf.Challenge == ChallengeTLSALPN || conf.Challenge == ChallengeDNS
+}
+
 // NewCertManager 无法使用dns使用80端口验证 需要在验证时关闭80端口
-func NewCertManager(domains []string, email string) *autocert.Manager {
+func NewCertManager(conf config.AutoCertConfig, domains []string) (*autocert.Manager, error) {
+	client, err := newClient(conf)
+	if err != nil {
+		return nil, err
+	}
 	cm := &autocert.Manager{
 		Prompt:                 autocert.AcceptTOS,
-		Cache:                  autocert.DirCache("./autocert"),
+		Cache:                  autocert.DirCache(cacheDir(conf)),
 		HostPolicy:             autocert.HostWhitelist(domains...),
 		RenewBefore:            0,
-		Client:                 nil,
-		Email:                  email,
+		Client:                 client,
+		Email:                  conf.Email,
 		ForceRSA:               false,
 		ExtraExtensions:        nil,
 		ExternalAccountBinding: nil,
 	}
 
-	return cm
+	return cm, nil
+}
+
+// newClient 创建ACME客户端 账户密钥由调用方设置
+func newClient(conf config.AutoCertConfig) (*acme.Client, error) {
+	client := &acme.Client{
+		DirectoryURL: conf.DirectoryURL,
+		UserAgent:    "Hamburger",
+	}
+	if client.DirectoryURL == "" {
+		client.DirectoryURL = acme.LetsEncryptURL
+	}
+	if conf.DirectoryCA != "" {
+		pem, err := os.ReadFile(conf.DirectoryCA)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, errors.New("autocert: no certificate found in directory ca " + conf.DirectoryCA)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+		client.HTTPClient = &http.Client{Transport: transport}
+	}
+	return client, nil
+}
+
+func cacheDir(conf config.AutoCertConfig) string {
+	if conf.CacheDir == "" {
+		return DefaultCacheDir
+	}
+	return filepath.Clean(conf.CacheDir)
 }
@@ -0,0 +1,86 @@
+package autocert
+
+import (
+	"Hamburger/internal/config"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// dns-01验证的DNS提供方
+// 验证记录为 _acme-challenge.<domain>. 的TXT记录 通配符域名和其基础域名使用同一个记录名
+// 同时验证两者时记录名下会有两个不同的值 提供方需要按值追加和删除而不是覆盖
+
+const (
+	defaultDNSTimeout = 30 * time.Second
+	defaultDNSTTL     = 60
+)
+
+// DNSProvider 写入和清理验证用的TXT记录
+// fqdn为带结尾点的完整记录名 value为TXT记录的值
+type DNSProvider interface {
+	Present(ctx context.Context, fqdn, value string) error
+	CleanUp(ctx context.Context, fqdn, value string) error
+}
+
+// NewDNSProvider 按配置创建DNS提供方
+func NewDNSProvider(conf config.DNSProviderConfig) (DNSProvider, error) {
+	switch conf.Provider {
+	case "rfc2136":
+		return NewRFC2136Provider(conf)
+	case "exec":
+		if conf.Command == "" {
+			return nil, fmt.Errorf("autocert: exec provider requires command")
+		}
+		return &ExecProvider{Command: conf.Command, Timeout: dnsTimeout(conf)}, nil
+	default:
+		return nil, fmt.Errorf("autocert: unknown dns provider %q", conf.Provider)
+	}
+}
+
+// ChallengeRecord 域名对应的验证记录名 通配符域名去掉*.前缀
+func ChallengeRecord(domain string) string {
+	domain = strings.TrimPrefix(domain, "*.")
+	return "_acme-challenge." + strings.TrimSuffix(domain, ".") + "."
+}
+
+func dnsTimeout(conf config.DNSProviderConfig) time.Duration {
+	if conf.Timeout > 0 {
+		return time.Duration(conf.Timeout) * time.Second
+	}
+	return defaultDNSTimeout
+}
+
+// ExecProvider 调用外部命令写入和清理记录
+// 命令参数为 present|cleanup <fqdn> <value> 退出码非0视为失败
+type ExecProvider struct {
+	Command string
+	Timeout time.Duration
+}
+
+func (p *ExecProvider) Present(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "present", fqdn, value)
+}
+
+func (p *ExecProvider) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "cleanup", fqdn, value)
+}
+
+func (p *ExecProvider) run(ctx context.Context, action, fqdn, value string) error {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, p.Command, action, fqdn, value)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("autocert: dns hook %s %s: %w: %s", action, fqdn, err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
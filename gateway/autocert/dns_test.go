@@ -0,0 +1,245 @@
+package autocert
+
+import (
+	"Hamburger/internal/config"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNS 接受DNS UPDATE的本地服务 校验hmac-sha256 TSIG签名并记录TXT记录
+type fakeDNS struct {
+	conn    net.PacketConn
+	keyName string
+	secret  []byte
+
+	mu      sync.Mutex
+	records map[string][]string
+}
+
+func newFakeDNS(t *testing.T, keyName string, secret []byte) *fakeDNS {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	s := &fakeDNS{conn: conn, keyName: keyName, secret: secret, records: make(map[string][]string)}
+	go s.serve()
+	return s
+}
+
+func (s *fakeDNS) serve() {
+	buf := make([]byte, 4096)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if reply := s.handle(buf[:n]); reply != nil {
+			_, _ = s.conn.WriteTo(reply, addr)
+		}
+	}
+}
+
+func (s *fakeDNS) handle(msg []byte) []byte {
+	var p dnsmessage.Parser
+	header, err := p.Start(msg)
+	if err != nil {
+		return nil
+	}
+	rcode := dnsmessage.RCodeSuccess
+	if _, err := p.AllQuestions(); err != nil {
+		return nil
+	}
+	if err := p.SkipAllAnswers(); err != nil {
+		return nil
+	}
+	updates, err := p.AllAuthorities()
+	if err != nil {
+		return nil
+	}
+	if !s.verify(msg, &p) {
+		rcode = dnsmessage.RCodeRefused
+	} else {
+		s.mu.Lock()
+		for _, rr := range updates {
+			name := rr.Header.Name.String()
+			value := rr.Body.(*dnsmessage.TXTResource).TXT[0]
+			if rr.Header.Class == dnsmessage.ClassINET {
+				s.records[name] = append(s.records[name], value)
+			} else {
+				s.records[name] = slices.DeleteFunc(s.records[name], func(v string) bool { return v == value })
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: header.ID, Response: true, OpCode: header.OpCode, RCode: rcode})
+	reply, _ := b.Finish()
+	return reply
+}
+
+// verify 校验报文最后的TSIG记录
+func (s *fakeDNS) verify(msg []byte, p *dnsmessage.Parser) bool {
+	rh, err := p.AdditionalHeader()
+	if err != nil || rh.Type != typeTSIG || rh.Name.String() != s.keyName {
+		return false
+	}
+	body, err := p.UnknownResource()
+	if err != nil {
+		return false
+	}
+	rdata := body.Data
+	algorithm := rdata[:len(wireName("hmac-sha256."))]
+	rest := rdata[len(algorithm):]
+	timeFudge := rest[:8]
+	macSize := binary.BigEndian.Uint16(rest[8:])
+	mac := rest[10 : 10+macSize]
+
+	unsigned := slices.Clone(msg[:len(msg)-(len(wireName(s.keyName))+10+int(rh.Length))])
+	binary.BigEndian.PutUint16(unsigned[10:], binary.BigEndian.Uint16(unsigned[10:])-1)
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(unsigned)
+	h.Write(wireName(s.keyName))
+	h.Write([]byte{0, 255, 0, 0, 0, 0})
+	h.Write(algorithm)
+	h.Write(timeFudge)
+	h.Write([]byte{0, 0, 0, 0})
+	return hmac.Equal(h.Sum(nil), mac)
+}
+
+func (s *fakeDNS) txt(name string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.records[name])
+}
+
+func TestRFC2136Provider(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	server := newFakeDNS(t, "acme-key.", secret)
+	conf := config.DNSProviderConfig{
+		Provider:   "rfc2136",
+		Nameserver: server.conn.LocalAddr().String(),
+		Zone:       "example.com",
+		TSIGKey:    "acme-key",
+		TSIGSecret: base64.StdEncoding.EncodeToString(secret),
+	}
+	provider, err := NewDNSProvider(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 通配符和基础域名共用记录名 两个值需要同时存在
+	record := ChallengeRecord("*.example.com")
+	if record != "_acme-challenge.example.com." {
+		t.Fatalf("unexpected record name %s", record)
+	}
+	ctx := context.Background()
+	for _, value := range []string{"token-a", "token-b"} {
+		if err := provider.Present(ctx, record, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := server.txt(record); !slices.Equal(got, []string{"token-a", "token-b"}) {
+		t.Fatalf("unexpected records after present: %v", got)
+	}
+	if err := provider.CleanUp(ctx, record, "token-a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.txt(record); !slices.Equal(got, []string{"token-b"}) {
+		t.Fatalf("unexpected records after cleanup: %v", got)
+	}
+
+	conf.TSIGSecret = base64.StdEncoding.EncodeToString([]byte("wrong secret"))
+	provider, err = NewDNSProvider(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.Present(ctx, record, "token-c"); err == nil || !strings.Contains(err.Error(), "Refused") {
+		t.Fatalf("expected refused update, got %v", err)
+	}
+	if got := server.txt(record); !slices.Equal(got, []string{"token-b"}) {
+		t.Fatalf("unsigned update applied: %v", got)
+	}
+}
+
+func TestExecProvider(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "calls")
+	script := filepath.Join(dir, "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$1 $2 $3\" >> "+out+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewDNSProvider(config.DNSProviderConfig{Provider: "exec", Command: script})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := provider.Present(ctx, "_acme-challenge.example.com.", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.CleanUp(ctx, "_acme-challenge.example.com.", "value"); err != nil {
+		t.Fatal(err)
+	}
+	calls, _ := os.ReadFile(out)
+	want := "present _acme-challenge.example.com. value\ncleanup _acme-challenge.example.com. value\n"
+	if string(calls) != want {
+		t.Fatalf("unexpected hook calls:\n%s", calls)
+	}
+
+	failing := filepath.Join(dir, "fail.sh")
+	if err := os.WriteFile(failing, []byte("#!/bin/sh\necho zone locked >&2\nexit 3\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	provider, _ = NewDNSProvider(config.DNSProviderConfig{Provider: "exec", Command: failing})
+	if err := provider.Present(ctx, "_acme-challenge.example.com.", "value"); err == nil || !strings.Contains(err.Error(), "zone locked") {
+		t.Fatalf("expected hook failure with output, got %v", err)
+	}
+}
+
+func TestIssuerTLSALPNCertificate(t *testing.T) {
+	logger := zerolog.Nop()
+	conf := config.AutoCertConfig{Challenge: ChallengeTLSALPN, CacheDir: t.TempDir()}
+	if _, err := NewIssuer(conf, []string{"*.example.com"}, &logger); err == nil {
+		t.Fatal("expected wildcard domain to be rejected for tls-alpn-01")
+	}
+	issuer, err := NewIssuer(conf, []string{"example.com"}, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer.client.Key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	challenge, err := issuer.client.TLSALPN01ChallengeCert("token", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer.alpn.Put("example.com", &challenge)
+
+	cert, err := issuer.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com", SupportedProtos: []string{acme.ALPNProto}})
+	if err != nil || cert != &challenge {
+		t.Fatalf("expected challenge certificate, got %v %v", cert, err)
+	}
+	if _, err := issuer.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.com", SupportedProtos: []string{acme.ALPNProto}}); err == nil {
+		t.Fatal("expected error for domain without pending challenge")
+	}
+	// 普通握手在证书签发前回退到配置的证书
+	if cert, err := issuer.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com", SupportedProtos: []string{"h2"}}); cert != nil || err != nil {
+		t.Fatalf("expected fallback before issuance, got %v %v", cert, err)
+	}
+}
@@ -0,0 +1,410 @@
+package autocert
+
+import (
+	"Hamburger/internal/config"
+	"Hamburger/internal/structure"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/acme"
+)
+
+// Issuer 使用tls-alpn-01或dns-01申请证书
+// 一张证书覆盖配置的全部域名 证书和账户密钥缓存在CacheDir中 重启后直接加载
+// tls-alpn-01的验证证书由443监听的GetCertificate在协商acme-tls/1时返回 不需要额外端口
+
+const (
+	accountKeyFile     = "acme_account+key" // 与autocert.DirCache共用账户密钥
+	defaultRenewBefore = 30 * 24 * time.Hour
+	issueTimeout       = 10 * time.Minute
+	maxCheckInterval   = 12 * time.Hour
+	minRetryDelay      = time.Minute
+	maxRetryDelay      = time.Hour
+)
+
+type Issuer struct {
+	conf        config.AutoCertConfig
+	domains     []string
+	challenge   string
+	provider    DNSProvider
+	client      *acme.Client
+	logger      *zerolog.Logger
+	dir         string
+	renewBefore time.Duration
+	registered  bool
+
+	cert atomic.Pointer[tls.Certificate]
+	alpn *structure.Map[*tls.Certificate] // 正在验证的域名和tls-alpn-01验证证书
+}
+
+// NewIssuer 创建Issuer 存在可用的缓存证书时直接加载
+func NewIssuer(conf config.AutoCertConfig, domains []string, logger *zerolog.Logger) (*Issuer, error) {
+	if len(domains) == 0 {
+		return nil, errors.New("autocert: no domains configured")
+	}
+	i := &Issuer{
+		conf:        conf,
+		challenge:   conf.Challenge,
+		logger:      logger,
+		dir:         cacheDir(conf),
+		renewBefore: defaultRenewBefore,
+		alpn:        structure.NewMap[*tls.Certificate](),
+	}
+	for _, domain := range domains {
+		i.domains = append(i.domains, strings.ToLower(strings.TrimSuffix(domain, ".")))
+	}
+	if conf.RenewBefore > 0 {
+		i.renewBefore = time.Duration(conf.RenewBefore) * 24 * time.Hour
+	}
+
+	switch i.challenge {
+	case ChallengeTLSALPN:
+		for _, domain := range i.domains {
+			if strings.HasPrefix(domain, "*.") {
+				return nil, fmt.Errorf("autocert: wildcard domain %s requires dns-01 challenge", domain)
+			}
+		}
+	case ChallengeDNS:
+		provider, err := NewDNSProvider(conf.DNS)
+		if err != nil {
+			return nil, err
+		}
+		i.provider = provider
+	default:
+		return nil, fmt.Errorf("autocert: issuer does not support challenge %q", i.challenge)
+	}
+
+	client, err := newClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	i.client = client
+
+	if cert, err := i.loadCached(); err == nil {
+		i.cert.Store(cert)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		logger.Warn().Err(err).Msg("autocert: ignore cached certificate")
+	}
+	return i, nil
+}
+
+// GetCertificate 返回握手使用的证书
+// 协商acme-tls/1时只返回验证证书 尚未签发或不匹配SNI时返回nil 由调用方回退到配置的证书
+func (i *Issuer) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if slices.Contains(hello.SupportedProtos, acme.ALPNProto) {
+		if cert, ok := i.alpn.Get(strings.ToLower(hello.ServerName)); ok {
+			return cert, nil
+		}
+		return nil, fmt.Errorf("autocert: no tls-alpn-01 challenge for %q", hello.ServerName)
+	}
+	cert := i.cert.Load()
+	if cert == nil {
+		return nil, nil
+	}
+	if hello.ServerName == "" || cert.Leaf.VerifyHostname(hello.ServerName) == nil {
+		return cert, nil
+	}
+	return nil, nil
+}
+
+// Run 按到期时间申请和续期证书 失败时退避重试 直到ctx取消
+func (i *Issuer) Run(ctx context.Context) {
+	failures := 0
+	for {
+		wait := i.nextCheck(time.Now())
+		if wait == 0 {
+			if err := i.issue(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				failures++
+				wait = retryDelay(failures)
+				i.logger.Error().Err(err).Strs("domains", i.domains).Dur("retry", wait).Msg("autocert: failed to obtain certificate")
+			} else {
+				failures = 0
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// nextCheck 距离需要续期的时间 需要立即申请时返回0
+func (i *Issuer) nextCheck(now time.Time) time.Duration {
+	cert := i.cert.Load()
+	if cert == nil {
+		return 0
+	}
+	wait := cert.Leaf.NotAfter.Add(-i.renewBefore).Sub(now)
+	if wait <= 0 {
+		return 0
+	}
+	return min(wait, maxCheckInterval)
+}
+
+func retryDelay(failures int) time.Duration {
+	delay := minRetryDelay << min(failures-1, 6)
+	return min(delay, maxRetryDelay)
+}
+
+// issue 完成一次订单: 逐个完成授权验证 然后提交CSR下载证书
+func (i *Issuer) issue(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, issueTimeout)
+	defer cancel()
+	if err := i.register(ctx); err != nil {
+		return err
+	}
+
+	i.logger.Info().Strs("domains", i.domains).Str("challenge", i.challenge).Msg("autocert: requesting certificate")
+	order, err := i.client.AuthorizeOrder(ctx, acme.DomainIDs(i.domains...))
+	if err != nil {
+		return err
+	}
+	var cleanups []func()
+	defer func() {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}()
+	for _, u := range order.AuthzURLs {
+		authz, err := i.client.GetAuthorization(ctx, u)
+		if err != nil {
+			return err
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+		cleanup, err := i.solve(ctx, authz)
+		if cleanup != nil {
+			cleanups = append(cleanups, cleanup)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if order, err = i.client.WaitOrder(ctx, order.URI); err != nil {
+		return err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: i.domains}, key)
+	if err != nil {
+		return err
+	}
+	der, _, err := i.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return err
+	}
+	cert, err := i.save(der, key)
+	if err != nil {
+		return err
+	}
+	i.cert.Store(cert)
+	i.logger.Info().Strs("domains", i.domains).Time("not_after", cert.Leaf.NotAfter).Msg("autocert: certificate obtained")
+	return nil
+}
+
+// solve 准备并提交授权的验证 返回的cleanup在订单结束后撤销验证用的证书或记录
+func (i *Issuer) solve(ctx context.Context, authz *acme.Authorization) (func(), error) {
+	domain := authz.Identifier.Value
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == i.challenge {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return nil, fmt.Errorf("autocert: %s challenge not offered for %s", i.challenge, domain)
+	}
+
+	var cleanup func()
+	switch i.challenge {
+	case ChallengeTLSALPN:
+		cert, err := i.client.TLSALPN01ChallengeCert(chal.Token, domain)
+		if err != nil {
+			return nil, err
+		}
+		i.alpn.Put(domain, &cert)
+		cleanup = func() { i.alpn.Delete(domain) }
+	case ChallengeDNS:
+		value, err := i.client.DNS01ChallengeRecord(chal.Token)
+		if err != nil {
+			return nil, err
+		}
+		record := ChallengeRecord(domain)
+		if err := i.provider.Present(ctx, record, value); err != nil {
+			return nil, err
+		}
+		cleanup = func() {
+			// 申请被取消时也需要清理记录
+			if err := i.provider.CleanUp(context.WithoutCancel(ctx), record, value); err != nil {
+				i.logger.Warn().Err(err).Str("record", record).Msg("autocert: failed to clean up dns challenge")
+			}
+		}
+		if delay := time.Duration(i.conf.DNS.PropagationDelay) * time.Second; delay > 0 {
+			select {
+			case <-ctx.Done():
+				return cleanup, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+	}
+
+	if _, err := i.client.Accept(ctx, chal); err != nil {
+		return cleanup, err
+	}
+	if _, err := i.client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return cleanup, err
+	}
+	return cleanup, nil
+}
+
+// register 加载或创建账户密钥并注册账户 已存在的账户视为成功
+func (i *Issuer) register(ctx context.Context) error {
+	if i.registered {
+		return nil
+	}
+	key, err := i.accountKey()
+	if err != nil {
+		return err
+	}
+	i.client.Key = key
+	account := &acme.Account{}
+	if i.conf.Email != "" {
+		account.Contact = []string{"mailto:" + i.conf.Email}
+	}
+	if _, err := i.client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return err
+	}
+	i.registered = true
+	return nil
+}
+
+func (i *Issuer) accountKey() (crypto.Signer, error) {
+	path := filepath.Join(i.dir, accountKeyFile)
+	if data, err := os.ReadFile(path); err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("autocert: invalid account key %s", path)
+		}
+		return parsePrivateKey(block.Bytes)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// certName 缓存文件名 通配符的*替换为_
+func (i *Issuer) certName() string {
+	return strings.ReplaceAll(i.domains[0], "*", "_")
+}
+
+// save 写入证书链和私钥缓存
+func (i *Issuer) save(der [][]byte, key *ecdsa.PrivateKey) (*tls.Certificate, error) {
+	var certPEM []byte
+	for _, b := range der {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: b})...)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	// 先写私钥 加载时证书和私钥不匹配会忽略缓存
+	base := filepath.Join(i.dir, i.certName())
+	if err := writeFile(base+".key", keyPEM); err != nil {
+		return nil, err
+	}
+	if err := writeFile(base+".crt", certPEM); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// loadCached 加载缓存证书 证书需要覆盖全部配置的域名
+func (i *Issuer) loadCached() (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(filepath.Join(i.dir, i.certName()+".crt"), filepath.Join(i.dir, i.certName()+".key"))
+	if err != nil {
+		return nil, err
+	}
+	for _, domain := range i.domains {
+		if !slices.Contains(cert.Leaf.DNSNames, domain) {
+			return nil, fmt.Errorf("autocert: cached certificate does not cover %s", domain)
+		}
+	}
+	return &cert, nil
+}
+
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, errors.New("autocert: unknown account key type")
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("autocert: unknown account key type")
+	}
+	return signer, nil
+}
+
+// writeFile 写入临时文件后重命名 避免读取到写了一半的文件
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
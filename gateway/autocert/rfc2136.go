@@ -0,0 +1,242 @@
+package autocert
+
+import (
+	"Hamburger/internal/config"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// RFC 2136 DNS UPDATE
+// Zone段复用Question段 Update段复用Authority段 TSIG签名(RFC 8945)追加在Additional段最后
+
+const (
+	opUpdate  dnsmessage.OpCode = 5
+	classNone dnsmessage.Class  = 254
+	classAny  dnsmessage.Class  = 255
+	typeTSIG  dnsmessage.Type   = 250
+	tsigFudge                   = 300
+)
+
+var tsigAlgorithms = map[string]func() hash.Hash{
+	"hmac-sha256.": sha256.New,
+	"hmac-sha512.": sha512.New,
+	"hmac-sha1.":   sha1.New,
+}
+
+// RFC2136Provider 通过DNS UPDATE在权威服务器上增删TXT记录
+type RFC2136Provider struct {
+	nameserver string
+	zone       dnsmessage.Name
+	ttl        uint32
+	timeout    time.Duration
+	// TSIG 未配置密钥时发送不签名的更新
+	keyName   string
+	algorithm string
+	secret    []byte
+}
+
+// NewRFC2136Provider 创建RFC2136提供方
+func NewRFC2136Provider(conf config.DNSProviderConfig) (*RFC2136Provider, error) {
+	if conf.Nameserver == "" || conf.Zone == "" {
+		return nil, errors.New("autocert: rfc2136 provider requires nameserver and zone")
+	}
+	zone, err := dnsmessage.NewName(canonicalName(conf.Zone))
+	if err != nil {
+		return nil, fmt.Errorf("autocert: invalid zone %s: %w", conf.Zone, err)
+	}
+	p := &RFC2136Provider{
+		nameserver: conf.Nameserver,
+		zone:       zone,
+		ttl:        defaultDNSTTL,
+		timeout:    dnsTimeout(conf),
+	}
+	if _, _, err := net.SplitHostPort(p.nameserver); err != nil {
+		p.nameserver = net.JoinHostPort(p.nameserver, "53")
+	}
+	if conf.TTL > 0 {
+		p.ttl = uint32(conf.TTL)
+	}
+	if conf.TSIGKey != "" {
+		p.keyName = canonicalName(conf.TSIGKey)
+		p.algorithm = canonicalName(conf.TSIGAlgorithm)
+		if conf.TSIGAlgorithm == "" {
+			p.algorithm = "hmac-sha256."
+		}
+		if _, ok := tsigAlgorithms[p.algorithm]; !ok {
+			return nil, fmt.Errorf("autocert: unknown tsig algorithm %s", conf.TSIGAlgorithm)
+		}
+		if p.secret, err = base64.StdEncoding.DecodeString(conf.TSIGSecret); err != nil {
+			return nil, fmt.Errorf("autocert: invalid tsig secret: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// Present 追加TXT记录 不影响记录名下已有的其他值
+func (p *RFC2136Provider) Present(ctx context.Context, fqdn, value string) error {
+	return p.update(ctx, fqdn, value, true)
+}
+
+// CleanUp 删除指定值的TXT记录
+func (p *RFC2136Provider) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.update(ctx, fqdn, value, false)
+}
+
+func (p *RFC2136Provider) update(ctx context.Context, name, value string, add bool) error {
+	var idBuf [2]byte
+	_, _ = rand.Read(idBuf[:])
+	id := binary.BigEndian.Uint16(idBuf[:])
+	msg, err := p.message(id, name, value, add, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", p.nameserver)
+	if err != nil {
+		return fmt.Errorf("autocert: dns update %s: %w", name, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return fmt.Errorf("autocert: dns update %s: %w", name, err)
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return fmt.Errorf("autocert: dns update %s: %w", name, err)
+		}
+		var parser dnsmessage.Parser
+		header, err := parser.Start(buf[:n])
+		if err != nil || header.ID != id || !header.Response {
+			// 忽略无法解析或不属于本次请求的报文
+			continue
+		}
+		if header.RCode != dnsmessage.RCodeSuccess {
+			return fmt.Errorf("autocert: dns update %s rejected by %s: %s", name, p.nameserver, header.RCode)
+		}
+		return nil
+	}
+}
+
+// message 构建UPDATE报文 add为false时删除指定值的记录(class NONE, TTL 0)
+func (p *RFC2136Provider) message(id uint16, name, value string, add bool, now time.Time) ([]byte, error) {
+	rrName, err := dnsmessage.NewName(canonicalName(name))
+	if err != nil {
+		return nil, fmt.Errorf("autocert: invalid record name %s: %w", name, err)
+	}
+	b := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{ID: id, OpCode: opUpdate})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: p.zone, Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	if err := b.StartAuthorities(); err != nil {
+		return nil, err
+	}
+	header := dnsmessage.ResourceHeader{Name: rrName, Class: dnsmessage.ClassINET, TTL: p.ttl}
+	if !add {
+		header.Class = classNone
+		header.TTL = 0
+	}
+	if err := b.TXTResource(header, dnsmessage.TXTResource{TXT: []string{value}}); err != nil {
+		return nil, err
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+	if p.keyName == "" {
+		return msg, nil
+	}
+	return p.sign(msg, id, now), nil
+}
+
+// sign 追加TSIG记录 MAC覆盖不含TSIG的报文和TSIG变量(RFC 8945 4.3.3)
+func (p *RFC2136Provider) sign(msg []byte, id uint16, now time.Time) []byte {
+	keyName := wireName(p.keyName)
+	algorithm := wireName(p.algorithm)
+	signed := uint64(now.Unix())
+
+	var vars []byte
+	vars = append(vars, keyName...)
+	vars = binary.BigEndian.AppendUint16(vars, uint16(classAny))
+	vars = binary.BigEndian.AppendUint32(vars, 0)
+	vars = append(vars, algorithm...)
+	vars = appendTime48(vars, signed)
+	vars = binary.BigEndian.AppendUint16(vars, tsigFudge)
+	vars = binary.BigEndian.AppendUint16(vars, 0) // error
+	vars = binary.BigEndian.AppendUint16(vars, 0) // other len
+
+	mac := hmac.New(tsigAlgorithms[p.algorithm], p.secret)
+	mac.Write(msg)
+	mac.Write(vars)
+	sum := mac.Sum(nil)
+
+	var rdata []byte
+	rdata = append(rdata, algorithm...)
+	rdata = appendTime48(rdata, signed)
+	rdata = binary.BigEndian.AppendUint16(rdata, tsigFudge)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(sum)))
+	rdata = append(rdata, sum...)
+	rdata = binary.BigEndian.AppendUint16(rdata, id)
+	rdata = binary.BigEndian.AppendUint16(rdata, 0) // error
+	rdata = binary.BigEndian.AppendUint16(rdata, 0) // other len
+
+	out := make([]byte, 0, len(msg)+len(keyName)+10+len(rdata))
+	out = append(out, msg...)
+	out = append(out, keyName...)
+	out = binary.BigEndian.AppendUint16(out, uint16(typeTSIG))
+	out = binary.BigEndian.AppendUint16(out, uint16(classAny))
+	out = binary.BigEndian.AppendUint32(out, 0)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(rdata)))
+	out = append(out, rdata...)
+	// ARCOUNT加1
+	binary.BigEndian.PutUint16(out[10:], binary.BigEndian.Uint16(out[10:])+1)
+	return out
+}
+
+// wireName 不压缩的小写线格式域名 TSIG要求使用规范形式
+func wireName(name string) []byte {
+	var out []byte
+	for _, label := range strings.Split(strings.TrimSuffix(strings.ToLower(name), "."), ".") {
+		if label == "" {
+			continue
+		}
+		out = append(out, byte(len(label)))
+		out = append(out, label...)
+	}
+	return append(out, 0)
+}
+
+func appendTime48(b []byte, t uint64) []byte {
+	return append(b, byte(t>>40), byte(t>>32), byte(t>>24), byte(t>>16), byte(t>>8), byte(t))
+}
+
+func canonicalName(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(name) + "."
+}
@@ -6,7 +6,10 @@ Created: 2021/12/12 by Landers
 package balancer
 
 import (
+	"Hamburger/internal/config"
 	"Hamburger/internal/structure"
+	"fmt"
+	"net/http"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -17,20 +20,70 @@ import (
 
 // 负载均衡
 // 基于轮询和随机的混合算法，优化性能
+// 支持按域名或自定义服务配置的均衡策略
 
 type LoadBalancer struct {
 	counter    uint64 // 原子计数器，用于轮询
 	ports      []int
+	targets    []Target
 	length     int
+	strategy   Strategy
 	lastUpdate time.Time
 	mu         sync.RWMutex
 }
 
+// Target 负载均衡的目标实例
+type Target struct {
+	Host   string
+	Port   int
+	Weight int
+}
+
+// Addr 目标地址 与转发时的URL.Host格式保持一致
+func (t Target) Addr() string {
+	return fmt.Sprintf("%s:%d", t.Host, t.Port)
+}
+
+// maxCachedBalancers 缓存的均衡器数量上限 超出时整体清空重建
+const maxCachedBalancers = 1024
+
 var (
 	// 全局负载均衡器实例
 	balancerCache = structure.NewMap[*LoadBalancer](100)
 )
 
+// Reset 清空缓存的负载均衡器 重载配置或重新同步端口组后调用
+// 已下线的实例组合不再保留 新的组合在下次选择时重建
+func Reset() {
+	balancerCache.Clear()
+}
+
+// cacheBalancer 缓存均衡器 实例组合持续变化时限制缓存大小
+func cacheBalancer(key string, balancer *LoadBalancer) {
+	if balancerCache.Size() >= maxCachedBalancers {
+		balancerCache.Clear()
+	}
+	balancerCache.Put(key, balancer)
+}
+
+// PortTargets 将本地端口组转换为目标实例
+func PortTargets(host string, ports []int) []Target {
+	targets := make([]Target, 0, len(ports))
+	for _, port := range ports {
+		targets = append(targets, Target{Host: host, Port: port, Weight: 1})
+	}
+	return targets
+}
+
+// UpstreamTargets 将自定义服务的上游转换为目标实例
+func UpstreamTargets(upstreams []config.Upstream) []Target {
+	targets := make([]Target, 0, len(upstreams))
+	for _, upstream := range upstreams {
+		targets = append(targets, Target{Host: upstream.Host, Port: upstream.Port, Weight: upstream.Weight})
+	}
+	return targets
+}
+
 // GetBalancer 获取或创建负载均衡器
 func GetBalancer(ports []int) *LoadBalancer {
 	if len(ports) == 0 {
@@ -48,17 +101,54 @@ func GetBalancer(ports []int) *LoadBalancer {
 		return balancer
 	}
 
-	balancer = &LoadBalancer{
-		ports:      make([]int, len(ports)),
-		length:     len(ports),
-		lastUpdate: time.Now(),
+	balancer = newLoadBalancer(config.BalanceConfig{}, PortTargets("", ports))
+	cacheBalancer(key, balancer)
+
+	return balancer
+}
+
+// GetStrategyBalancer 获取或创建指定策略的负载均衡器
+// 缓存key包含策略 同一组实例在不同策略下互不影响
+func GetStrategyBalancer(cfg config.BalanceConfig, targets []Target) *LoadBalancer {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	key := StrategyName(cfg.Strategy) + "|" + cfg.HashKey + "|"
+	for _, target := range targets {
+		key += target.Addr() + "@" + strconv.Itoa(target.Weight) + "|"
 	}
-	copy(balancer.ports, ports)
-	balancerCache.Put(key, balancer)
+
+	balancer, exists := balancerCache.Get(key)
+	if exists {
+		return balancer
+	}
+
+	balancer = newLoadBalancer(cfg, targets)
+	cacheBalancer(key, balancer)
 
 	return balancer
 }
 
+func newLoadBalancer(cfg config.BalanceConfig, targets []Target) *LoadBalancer {
+	lb := &LoadBalancer{
+		ports:      make([]int, len(targets)),
+		targets:    make([]Target, len(targets)),
+		length:     len(targets),
+		lastUpdate: time.Now(),
+	}
+	copy(lb.targets, targets)
+	for i, target := range targets {
+		lb.ports[i] = target.Port
+		if target.Weight <= 0 {
+			lb.targets[i].Weight = 1
+		}
+	}
+	lb.strategy = newStrategy(cfg, lb.targets)
+
+	return lb
+}
+
 // PickOne 使用轮询算法选择主机，性能更优
 func (lb *LoadBalancer) PickOne() int {
 	if lb.length == 1 {
@@ -70,6 +160,33 @@ func (lb *LoadBalancer) PickOne() int {
 	return lb.ports[index]
 }
 
+// Pick 按配置的策略选择目标实例 跳过available判定为不可用的实例
+// 全部实例都不可用时在全部实例中选择
+func (lb *LoadBalancer) Pick(req *http.Request, available func(target Target) bool) Target {
+	if lb.length == 1 {
+		return lb.targets[0]
+	}
+
+	candidates := make([]int, 0, lb.length)
+	for i, target := range lb.targets {
+		if available == nil || available(target) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		for i := range lb.targets {
+			candidates = append(candidates, i)
+		}
+	}
+
+	return lb.targets[lb.strategy.Pick(req, candidates)]
+}
+
+// Strategy 获取当前均衡器使用的策略名称
+func (lb *LoadBalancer) Strategy() string {
+	return lb.strategy.Name()
+}
+
 // PickOne 保持向后兼容的函数接口
 func PickOne(hosts []int) int {
 	if len(hosts) == 1 {
@@ -89,17 +206,3 @@ func PickOneRoundRobin(ports []int) int {
 	}
 	return balancer.PickOne()
 }
-
-func PickOneAddrRoundRobin(addrs []struct {
-	Host string
-	Port int
-}) (host string, port int) {
-	if len(addrs) == 0 {
-		return
-	}
-	i := mathutil.RandomInt(0, len(addrs)-1)
-	host = addrs[i].Host
-	port = addrs[i].Port
-
-	return
-}
@@ -0,0 +1,126 @@
+package balancer
+
+import (
+	"Hamburger/gateway/clientip"
+	"Hamburger/internal/config"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func testTargets(weights ...int) []Target {
+	targets := make([]Target, 0, len(weights))
+	for i, w := range weights {
+		targets = append(targets, Target{Host: "127.0.0.1", Port: 9000 + i, Weight: w})
+	}
+	return targets
+}
+
+// TestWeightedRoundRobin 测试平滑加权轮询的分布
+func TestWeightedRoundRobin(t *testing.T) {
+	lb := GetStrategyBalancer(config.BalanceConfig{Strategy: Weighted}, testTargets(5, 1, 1))
+	counts := make(map[int]int)
+	for i := 0; i < 70; i++ {
+		counts[lb.Pick(nil, nil).Port]++
+	}
+	if counts[9000] != 50 || counts[9001] != 10 || counts[9002] != 10 {
+		t.Errorf("Expected distribution 50/10/10, got %v", counts)
+	}
+}
+
+// TestPickSkipsUnavailable 测试跳过不可用实例以及全部不可用时的兜底
+func TestPickSkipsUnavailable(t *testing.T) {
+	for _, strategy := range []string{RoundRobin, Weighted, LeastConn, Hash, P2C} {
+		lb := GetStrategyBalancer(config.BalanceConfig{Strategy: strategy}, testTargets(1, 1, 1))
+		for i := 0; i < 20; i++ {
+			req := &http.Request{RemoteAddr: "10.0.0.1:1234", Header: http.Header{}}
+			target := lb.Pick(req, func(target Target) bool {
+				return target.Port != 9001
+			})
+			if target.Port == 9001 {
+				t.Errorf("%s: expected port 9001 to be skipped", strategy)
+			}
+		}
+		target := lb.Pick(nil, func(target Target) bool { return false })
+		if target.Port < 9000 || target.Port > 9002 {
+			t.Errorf("%s: expected fallback to any target, got %d", strategy, target.Port)
+		}
+	}
+}
+
+// TestConsistentHash 测试相同的键命中相同的实例
+func TestConsistentHash(t *testing.T) {
+	lb := GetStrategyBalancer(config.BalanceConfig{Strategy: Hash, HashKey: "header:X-User"}, testTargets(1, 1, 1, 1))
+	pick := func(user string) int {
+		req := &http.Request{Header: http.Header{}}
+		req.Header.Set("X-User", user)
+		return lb.Pick(req, nil).Port
+	}
+	for _, user := range []string{"alice", "bob", "carol"} {
+		first := pick(user)
+		for i := 0; i < 10; i++ {
+			if port := pick(user); port != first {
+				t.Errorf("Expected user %s to stick to %d, got %d", user, first, port)
+			}
+		}
+	}
+}
+
+// TestStrategyCacheKey 测试不同策略不共享均衡器
+func TestStrategyCacheKey(t *testing.T) {
+	targets := testTargets(1, 1)
+	a := GetStrategyBalancer(config.BalanceConfig{Strategy: RoundRobin}, targets)
+	b := GetStrategyBalancer(config.BalanceConfig{Strategy: LeastConn}, targets)
+	if a == b {
+		t.Error("Expected different balancers for different strategies")
+	}
+	if a != GetStrategyBalancer(config.BalanceConfig{}, targets) {
+		t.Error("Expected empty strategy to reuse round robin balancer")
+	}
+}
+
+// TestHashClientIP 测试ip键使用经过可信代理解析出的客户端IP
+func TestHashClientIP(t *testing.T) {
+	if err := clientip.Init(config.SecurityConfig{TrustedProxies: []string{"10.0.0.0/8"}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = clientip.Init(config.SecurityConfig{}) })
+
+	lb := GetStrategyBalancer(config.BalanceConfig{Strategy: Hash, HashKey: "ip"}, testTargets(1, 1, 1, 1))
+	pick := func(remote, forwarded string) int {
+		req := &http.Request{RemoteAddr: remote, Header: http.Header{}}
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		return lb.Pick(req, nil).Port
+	}
+	// 同一客户端经过不同的可信代理命中相同的实例
+	for i := 0; i < 20; i++ {
+		client := "203.0.113." + strconv.Itoa(i)
+		direct := pick(client+":1234", "")
+		if port := pick("10.0.0.1:1234", client); port != direct {
+			t.Fatalf("client %s via proxy picked %d, direct %d", client, port, direct)
+		}
+		if port := pick("10.0.0.2:5678", client); port != direct {
+			t.Fatalf("client %s via another proxy picked %d, direct %d", client, port, direct)
+		}
+	}
+}
+
+// TestBalancerCacheBound 测试重置和缓存上限
+func TestBalancerCacheBound(t *testing.T) {
+	targets := testTargets(1, 1)
+	lb := GetStrategyBalancer(config.BalanceConfig{}, targets)
+	Reset()
+	if balancerCache.Size() != 0 || GetStrategyBalancer(config.BalanceConfig{}, targets) == lb {
+		t.Fatal("Expected reset to drop cached balancers")
+	}
+
+	for i := 0; i < maxCachedBalancers*2; i++ {
+		GetBalancer([]int{i + 1})
+		if size := balancerCache.Size(); size > maxCachedBalancers {
+			t.Fatalf("Expected cache size at most %d, got %d", maxCachedBalancers, size)
+		}
+	}
+	Reset()
+}
@@ -0,0 +1,243 @@
+package balancer
+
+import (
+	"Hamburger/gateway/clientip"
+	"Hamburger/internal/config"
+	"hash/crc32"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 负载均衡策略
+// 所有策略只在候选实例(candidates, 即targets的下标)中选择
+
+const (
+	RoundRobin = "round_robin"
+	Weighted   = "weighted"
+	LeastConn  = "least_conn"
+	Hash       = "hash"
+	P2C        = "p2c"
+
+	// 一致性hash环上每个权重对应的虚拟节点数
+	hashReplicas = 100
+)
+
+// Strategy 负载均衡策略接口
+type Strategy interface {
+	// Pick 返回选中实例在targets中的下标
+	Pick(req *http.Request, candidates []int) int
+	Name() string
+}
+
+// StrategyName 规范化策略名称 未知策略按轮询处理
+func StrategyName(name string) string {
+	switch name {
+	case Weighted, LeastConn, Hash, P2C:
+		return name
+	default:
+		return RoundRobin
+	}
+}
+
+func newStrategy(cfg config.BalanceConfig, targets []Target) Strategy {
+	switch StrategyName(cfg.Strategy) {
+	case Weighted:
+		return newWeightedRoundRobin(targets)
+	case LeastConn:
+		return &leastConn{targets: targets}
+	case Hash:
+		return newConsistentHash(cfg.HashKey, targets)
+	case P2C:
+		return &powerOfTwo{targets: targets}
+	default:
+		return &roundRobin{}
+	}
+}
+
+// roundRobin 轮询
+type roundRobin struct {
+	counter uint64
+}
+
+func (r *roundRobin) Pick(_ *http.Request, candidates []int) int {
+	index := atomic.AddUint64(&r.counter, 1) % uint64(len(candidates))
+	return candidates[index]
+}
+
+func (r *roundRobin) Name() string {
+	return RoundRobin
+}
+
+// weightedRoundRobin 平滑加权轮询
+type weightedRoundRobin struct {
+	mu      sync.Mutex
+	targets []Target
+	current []int
+}
+
+func newWeightedRoundRobin(targets []Target) *weightedRoundRobin {
+	return &weightedRoundRobin{
+		targets: targets,
+		current: make([]int, len(targets)),
+	}
+}
+
+func (w *weightedRoundRobin) Pick(_ *http.Request, candidates []int) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	total := 0
+	best := candidates[0]
+	for _, i := range candidates {
+		weight := w.targets[i].Weight
+		w.current[i] += weight
+		total += weight
+		if w.current[i] > w.current[best] {
+			best = i
+		}
+	}
+	w.current[best] -= total
+	return best
+}
+
+func (w *weightedRoundRobin) Name() string {
+	return Weighted
+}
+
+// leastConn 最少活跃连接 连接数相同时轮询
+type leastConn struct {
+	counter uint64
+	targets []Target
+}
+
+func (l *leastConn) Pick(_ *http.Request, candidates []int) int {
+	start := atomic.AddUint64(&l.counter, 1)
+	best := -1
+	var bestActive int64
+	for n := range candidates {
+		i := candidates[(start+uint64(n))%uint64(len(candidates))]
+		active := GetTargetStats(l.targets[i].Addr()).Active()
+		if best == -1 || active < bestActive {
+			best = i
+			bestActive = active
+		}
+	}
+	return best
+}
+
+func (l *leastConn) Name() string {
+	return LeastConn
+}
+
+// consistentHash 一致性hash 根据客户端IP、请求头或Cookie保持路由稳定
+type consistentHash struct {
+	fallback roundRobin
+	keyFn    func(req *http.Request) string
+	ring     []uint32
+	owners   map[uint32]int
+}
+
+func newConsistentHash(hashKey string, targets []Target) *consistentHash {
+	ch := &consistentHash{
+		keyFn:  hashKeyFunc(hashKey),
+		owners: make(map[uint32]int),
+	}
+	for i, target := range targets {
+		for r := 0; r < hashReplicas*target.Weight; r++ {
+			h := crc32.ChecksumIEEE([]byte(target.Addr() + "#" + strconv.Itoa(r)))
+			if _, exists := ch.owners[h]; exists {
+				continue
+			}
+			ch.owners[h] = i
+			ch.ring = append(ch.ring, h)
+		}
+	}
+	sort.Slice(ch.ring, func(i, j int) bool {
+		return ch.ring[i] < ch.ring[j]
+	})
+	return ch
+}
+
+func (c *consistentHash) Pick(req *http.Request, candidates []int) int {
+	key := ""
+	if req != nil {
+		key = c.keyFn(req)
+	}
+	if key == "" || len(c.ring) == 0 {
+		return c.fallback.Pick(req, candidates)
+	}
+
+	allowed := make(map[int]struct{}, len(candidates))
+	for _, i := range candidates {
+		allowed[i] = struct{}{}
+	}
+
+	// 顺时针查找第一个候选实例
+	h := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(c.ring), func(i int) bool {
+		return c.ring[i] >= h
+	})
+	for n := 0; n < len(c.ring); n++ {
+		owner := c.owners[c.ring[(start+n)%len(c.ring)]]
+		if _, ok := allowed[owner]; ok {
+			return owner
+		}
+	}
+	return c.fallback.Pick(req, candidates)
+}
+
+func (c *consistentHash) Name() string {
+	return Hash
+}
+
+// hashKeyFunc 解析hash键配置 ip | header:<name> | cookie:<name>
+func hashKeyFunc(hashKey string) func(req *http.Request) string {
+	kind, name, _ := strings.Cut(hashKey, ":")
+	switch kind {
+	case "header":
+		return func(req *http.Request) string {
+			return req.Header.Get(name)
+		}
+	case "cookie":
+		return func(req *http.Request) string {
+			cookie, err := req.Cookie(name)
+			if err != nil {
+				return ""
+			}
+			return cookie.Value
+		}
+	default:
+		// 经过可信代理时使用解析出的真实客户端IP
+		return clientip.String
+	}
+}
+
+// powerOfTwo 随机选择两个实例 取观测延迟与活跃连接综合更低的一个
+type powerOfTwo struct {
+	targets []Target
+}
+
+func (p *powerOfTwo) Pick(_ *http.Request, candidates []int) int {
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	a := rand.IntN(len(candidates))
+	b := rand.IntN(len(candidates) - 1)
+	if b >= a {
+		b++
+	}
+	first, second := candidates[a], candidates[b]
+	if GetTargetStats(p.targets[second].Addr()).Score() < GetTargetStats(p.targets[first].Addr()).Score() {
+		return second
+	}
+	return first
+}
+
+func (p *powerOfTwo) Name() string {
+	return P2C
+}
@@ -0,0 +1,86 @@
+package balancer
+
+import (
+	"Hamburger/internal/structure"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 上游实例的运行时统计
+// 记录活跃连接数和响应延迟 供least_conn和p2c策略使用
+
+const (
+	// ewmaDecay 延迟滑动平均的衰减系数
+	ewmaDecay = 0.3
+	// errorPenalty 请求失败时按此延迟计入 避免快速失败的实例吸引流量
+	errorPenalty = time.Second
+)
+
+var targetStats = structure.NewMap[*TargetStats](100)
+
+// TargetStats 单个上游实例的统计
+type TargetStats struct {
+	active  int64
+	mu      sync.Mutex
+	latency float64 // 纳秒
+}
+
+// GetTargetStats 获取或创建实例统计
+func GetTargetStats(addr string) *TargetStats {
+	if stats, ok := targetStats.Get(addr); ok {
+		return stats
+	}
+	stats := &TargetStats{}
+	targetStats.Put(addr, stats)
+	return stats
+}
+
+// Track 在请求发往上游前调用 返回的函数在请求结束后调用
+func Track(addr string) func(err error) {
+	if addr == "" {
+		return func(error) {}
+	}
+	stats := GetTargetStats(addr)
+	atomic.AddInt64(&stats.active, 1)
+	start := time.Now()
+
+	return func(err error) {
+		atomic.AddInt64(&stats.active, -1)
+		cost := time.Since(start)
+		if err != nil && cost < errorPenalty {
+			cost = errorPenalty
+		}
+		stats.observe(cost)
+	}
+}
+
+func (s *TargetStats) observe(cost time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latency == 0 {
+		s.latency = float64(cost)
+		return
+	}
+	s.latency = s.latency*(1-ewmaDecay) + float64(cost)*ewmaDecay
+}
+
+// Active 当前活跃连接数
+func (s *TargetStats) Active() int64 {
+	return atomic.LoadInt64(&s.active)
+}
+
+// Latency 延迟的滑动平均值
+func (s *TargetStats) Latency() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Duration(s.latency)
+}
+
+// Score 综合负载评分 越低越优先
+func (s *TargetStats) Score() float64 {
+	s.mu.Lock()
+	latency := s.latency
+	s.mu.Unlock()
+	return latency * float64(s.Active()+1)
+}
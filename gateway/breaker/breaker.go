@@ -14,90 +14,295 @@ import (
 	"Hamburger/internal/logger"
 	"Hamburger/internal/structure"
 	"Hamburger/internal/utils"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	DefaultMaxError = 5
-	DefaultBucket   = 10
+	DefaultMaxError       = 5
+	DefaultBucket         = 10
+	DefaultReset          = 60
+	DefaultErrorRate      = 0.5
+	DefaultHalfOpenProbes = 1
 )
 
 // 熔断控制器
 // 在需要转发的微服务返回大量失败时，直接熔断当前的连接请求禁止客户端访问
+// 每个上游(域名+地址)维护独立的状态机:
+// closed   正常转发 统计最近的请求结果 连续失败或错误率超出阈值时熔断
+// open     直接拒绝 冷却时间结束后进入半开状态
+// halfOpen 放行有限的探测请求 全部成功后恢复 任一失败重新熔断
 
-var breaker *Breaker
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+var breaker atomic.Pointer[Breaker]
 
 func InitBreaker() {
-	breaker = NewBreaker()
-	go breaker.Reset()
+	breaker.Store(NewBreaker())
 }
 
-type BreakerBucket struct {
-	errorConn int
-	bucket    chan int
+// Reload 按新配置重建熔断器 保留各上游已有的熔断状态
+func Reload() {
+	b := NewBreaker()
+	if old := breaker.Load(); old != nil {
+		b.circuits = old.circuits
+	}
+	breaker.Store(b)
+}
+
+// Status 熔断器状态快照
+type Status struct {
+	Domain     string    `json:"domain"`
+	Upstream   string    `json:"upstream"`
+	State      string    `json:"state"`
+	Failures   int       `json:"failures"` // 连续失败次数
+	Requests   int       `json:"requests"` // 统计窗口内的请求数
+	Errors     int       `json:"errors"`   // 统计窗口内的失败数
+	LastChange time.Time `json:"last_change"`
+}
+
+type circuit struct {
+	mu         sync.Mutex
+	domain     string
+	upstream   string
+	state      State
+	failures   int    // 连续失败次数
+	window     []bool // 最近请求结果的环形缓冲 true为失败
+	next       int
+	requests   int
+	errors     int
+	probes     int // 半开状态已放行的探测数
+	successes  int // 半开状态探测成功数
+	lastChange time.Time
 }
 
 type Breaker struct {
-	serviceBucket *structure.Map[*BreakerBucket]
-	cf            *config.BreakConfig
+	circuits *structure.Map[*circuit]
+	enabled  bool
+
+	maxError  int
+	bucket    int
+	minReq    int
+	errorRate float64
+	coolDown  time.Duration
+	maxProbes int
 }
 
 func NewBreaker() *Breaker {
+	cf := config.Get().Features.Break
+	bucket := utils.DefaultInt(cf.Bucket, DefaultBucket)
 	return &Breaker{
-		serviceBucket: structure.NewSizeMap[*BreakerBucket](DefaultBucket),
-		cf:            &config.Get().Features.Break,
+		circuits:  structure.NewSizeMap[*circuit](DefaultBucket),
+		enabled:   cf.Enabled,
+		maxError:  utils.DefaultInt(cf.MaxError, DefaultMaxError),
+		bucket:    bucket,
+		minReq:    utils.DefaultInt(cf.MinRequests, bucket),
+		errorRate: utils.DefaultFloat64(cf.ErrorRate, DefaultErrorRate),
+		coolDown:  utils.ToSecond(utils.DefaultInt(cf.Reset, DefaultReset)),
+		maxProbes: utils.DefaultInt(cf.HalfOpenProbes, DefaultHalfOpenProbes),
 	}
 }
 
-func (b *Breaker) Get(domain string) bool {
-	sb, ok := b.serviceBucket.Get(domain)
-	if !ok {
-		b.add(domain)
+// Allow 判断是否允许请求转发到上游
+func (b *Breaker) Allow(domain, upstream string) bool {
+	if !b.enabled {
 		return true
 	}
-	if len(sb.bucket) >= sb.errorConn {
-		logger.GetLogger().Info().Str("domain", domain).Msg("breaker now is broken")
-		return false
-	}
-	return true
-}
-
-func (b *Breaker) Set(domain string) bool {
-	sb, ok := b.serviceBucket.Get(domain)
+	c, ok := b.circuits.Get(circuitKey(domain, upstream))
 	if !ok {
 		return true
 	}
-	if len(sb.bucket) < sb.errorConn {
-		sb.bucket <- 1
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	switch c.state {
+	case Open:
+		if now.Sub(c.lastChange) < b.coolDown {
+			return false
+		}
+		b.transition(c, HalfOpen, now)
+	case HalfOpen:
+		// 探测请求长时间未回报结果时重新放行一批探测
+		if c.probes >= b.maxProbes && now.Sub(c.lastChange) >= b.coolDown {
+			b.transition(c, HalfOpen, now)
+		}
+	default:
 		return true
 	}
 
+	if c.probes < b.maxProbes {
+		c.probes++
+		return true
+	}
 	return false
 }
 
-func (b *Breaker) add(domain string) {
-	b.serviceBucket.Put(domain, &BreakerBucket{
-		errorConn: utils.DefaultInt(b.cf.MaxError, DefaultMaxError),
-		bucket:    make(chan int, utils.DefaultInt(b.cf.Bucket, DefaultBucket)),
-	})
+// Report 回报上游请求结果
+func (b *Breaker) Report(domain, upstream string, success bool) {
+	if !b.enabled {
+		return
+	}
+	c := b.getCircuit(domain, upstream)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	switch c.state {
+	case Closed:
+		c.record(!success)
+		if c.failures >= b.maxError ||
+			(c.requests >= b.minReq && float64(c.errors)/float64(c.requests) >= b.errorRate) {
+			b.transition(c, Open, now)
+		}
+	case HalfOpen:
+		if !success {
+			b.transition(c, Open, now)
+			return
+		}
+		c.successes++
+		if c.successes >= b.maxProbes {
+			b.transition(c, Closed, now)
+		}
+	default:
+		// 熔断期间的迟到结果不影响状态
+	}
+}
+
+func (b *Breaker) getCircuit(domain, upstream string) *circuit {
+	key := circuitKey(domain, upstream)
+	if c, ok := b.circuits.Get(key); ok {
+		return c
+	}
+	c := &circuit{
+		domain:     domain,
+		upstream:   upstream,
+		window:     make([]bool, b.bucket),
+		lastChange: time.Now(),
+	}
+	b.circuits.Put(key, c)
+	return c
 }
 
-// Reset 自定重置
-func (b *Breaker) Reset() {
-	ticker := time.Tick(time.Duration(b.cf.Reset) * time.Second)
-	for range ticker {
-		b.serviceBucket.Range(func(key string, value *BreakerBucket) bool {
-			value.bucket = make(chan int, b.cf.Bucket)
-			logger.GetLogger().Info().Str("domain", key).Msg("breaker now is reset")
-			return true
+// transition 切换状态并重置统计 调用方需持有锁
+func (b *Breaker) transition(c *circuit, state State, now time.Time) {
+	from := c.state
+	c.state = state
+	c.lastChange = now
+	c.probes = 0
+	c.successes = 0
+	if state == Closed {
+		c.failures = 0
+		c.requests = 0
+		c.errors = 0
+		c.next = 0
+		clear(c.window)
+	}
+	if from == state {
+		return
+	}
+
+	event := logger.GetLogger().Info()
+	if state == Open {
+		event = logger.GetLogger().Warn()
+	}
+	event.Str("domain", c.domain).Str("upstream", c.upstream).
+		Str("from", from.String()).Str("to", state.String()).
+		Msg("breaker state changed")
+}
+
+// record 记录一次请求结果到统计窗口
+func (c *circuit) record(failed bool) {
+	if failed {
+		c.failures++
+	} else {
+		c.failures = 0
+	}
+
+	if c.requests == len(c.window) {
+		if c.window[c.next] {
+			c.errors--
+		}
+	} else {
+		c.requests++
+	}
+	c.window[c.next] = failed
+	if failed {
+		c.errors++
+	}
+	c.next = (c.next + 1) % len(c.window)
+}
+
+// Snapshot 获取全部上游的熔断状态
+func (b *Breaker) Snapshot() []Status {
+	result := make([]Status, 0, b.circuits.Size())
+	b.circuits.Range(func(key string, c *circuit) bool {
+		c.mu.Lock()
+		result = append(result, Status{
+			Domain:     c.domain,
+			Upstream:   c.upstream,
+			State:      c.state.String(),
+			Failures:   c.failures,
+			Requests:   c.requests,
+			Errors:     c.errors,
+			LastChange: c.lastChange,
 		})
+		c.mu.Unlock()
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Domain == result[j].Domain {
+			return result[i].Upstream < result[j].Upstream
+		}
+		return result[i].Domain < result[j].Domain
+	})
+	return result
+}
+
+func circuitKey(domain, upstream string) string {
+	return domain + "@" + upstream
+}
+
+// Allow 未初始化时默认放行
+func Allow(domain, upstream string) bool {
+	b := breaker.Load()
+	if b == nil {
+		return true
 	}
+	return b.Allow(domain, upstream)
 }
 
-func Get(domain string) bool {
-	return breaker.Get(domain)
+func Report(domain, upstream string, success bool) {
+	b := breaker.Load()
+	if b == nil {
+		return
+	}
+	b.Report(domain, upstream, success)
 }
 
-func Set(domain string) bool {
-	return breaker.Set(domain)
+func Snapshot() []Status {
+	b := breaker.Load()
+	if b == nil {
+		return []Status{}
+	}
+	return b.Snapshot()
 }
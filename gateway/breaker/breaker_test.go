@@ -0,0 +1,109 @@
+package breaker
+
+import (
+	"Hamburger/internal/config"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(cf config.BreakConfig) *Breaker {
+	cfg := config.Merge(config.GetDefaultConfig())
+	cfg.Features.Break = cf
+	config.Set(cfg)
+	return NewBreaker()
+}
+
+// TestConsecutiveFailures 测试连续失败触发熔断 冷却后半开探测恢复
+func TestConsecutiveFailures(t *testing.T) {
+	b := newTestBreaker(config.BreakConfig{Enabled: true, MaxError: 3, Bucket: 100, HalfOpenProbes: 2})
+	b.coolDown = 20 * time.Millisecond
+
+	for i := 0; i < 3; i++ {
+		if !b.Allow("a.renj.io", "127.0.0.1:9000") {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+		b.Report("a.renj.io", "127.0.0.1:9000", false)
+	}
+	if b.Allow("a.renj.io", "127.0.0.1:9000") {
+		t.Fatal("Expected breaker to be open after consecutive failures")
+	}
+	if !b.Allow("a.renj.io", "127.0.0.1:9001") {
+		t.Error("Expected other upstream to be unaffected")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.Allow("a.renj.io", "127.0.0.1:9000") || !b.Allow("a.renj.io", "127.0.0.1:9000") {
+		t.Fatal("Expected half-open breaker to allow probes")
+	}
+	if b.Allow("a.renj.io", "127.0.0.1:9000") {
+		t.Error("Expected half-open breaker to limit probes")
+	}
+	b.Report("a.renj.io", "127.0.0.1:9000", true)
+	b.Report("a.renj.io", "127.0.0.1:9000", true)
+	if state := b.Snapshot()[0].State; state != Closed.String() {
+		t.Errorf("Expected breaker to close after successful probes, got %s", state)
+	}
+}
+
+// TestErrorRate 测试错误率触发熔断以及半开探测失败重新熔断
+func TestErrorRate(t *testing.T) {
+	b := newTestBreaker(config.BreakConfig{Enabled: true, MaxError: 100, Bucket: 10, ErrorRate: 0.5, MinRequests: 4})
+	b.coolDown = 20 * time.Millisecond
+
+	b.Report("b.renj.io", "127.0.0.1:9000", false)
+	b.Report("b.renj.io", "127.0.0.1:9000", true)
+	b.Report("b.renj.io", "127.0.0.1:9000", false)
+	if !b.Allow("b.renj.io", "127.0.0.1:9000") {
+		t.Fatal("Expected breaker to stay closed below min requests")
+	}
+	b.Report("b.renj.io", "127.0.0.1:9000", true)
+	if b.Allow("b.renj.io", "127.0.0.1:9000") {
+		t.Fatal("Expected breaker to open when error rate reaches threshold")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.Allow("b.renj.io", "127.0.0.1:9000") {
+		t.Fatal("Expected half-open breaker to allow a probe")
+	}
+	b.Report("b.renj.io", "127.0.0.1:9000", false)
+	if b.Allow("b.renj.io", "127.0.0.1:9000") {
+		t.Error("Expected failed probe to reopen breaker")
+	}
+}
+
+// TestDisabled 测试未启用时始终放行
+func TestDisabled(t *testing.T) {
+	b := newTestBreaker(config.BreakConfig{MaxError: 1})
+	b.Report("c.renj.io", "127.0.0.1:9000", false)
+	if !b.Allow("c.renj.io", "127.0.0.1:9000") {
+		t.Error("Expected disabled breaker to allow requests")
+	}
+}
+
+// TestReload 测试重载后使用新阈值并保留已有的熔断状态
+func TestReload(t *testing.T) {
+	newTestBreaker(config.BreakConfig{Enabled: true, MaxError: 1})
+	InitBreaker()
+	Report("d.renj.io", "127.0.0.1:9000", false)
+	if Allow("d.renj.io", "127.0.0.1:9000") {
+		t.Fatal("Expected breaker to be open")
+	}
+
+	cfg := config.Merge(config.GetDefaultConfig())
+	cfg.Features.Break = config.BreakConfig{Enabled: true, MaxError: 3}
+	config.Set(cfg)
+	Reload()
+	if Allow("d.renj.io", "127.0.0.1:9000") {
+		t.Fatal("Expected open state to survive reload")
+	}
+	Report("d.renj.io", "127.0.0.1:9001", false)
+	if !Allow("d.renj.io", "127.0.0.1:9001") {
+		t.Error("Expected reloaded threshold to allow a single failure")
+	}
+
+	cfg.Features.Break.Enabled = false
+	Reload()
+	if !Allow("d.renj.io", "127.0.0.1:9000") {
+		t.Error("Expected disabled breaker to allow requests after reload")
+	}
+}
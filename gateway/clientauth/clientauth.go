@@ -0,0 +1,249 @@
+// Package clientauth
+// 按域名组校验客户端证书(mTLS)
+// 握手时只请求客户端证书不做校验 证书在前置处理器中校验 失败时返回错误页而不是直接中断握手
+package clientauth
+
+import (
+	"Hamburger/internal/config"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	ModeNone     = "none"
+	ModeOptional = "optional"
+	ModeRequired = "required"
+
+	DefaultSubjectHeader     = "X-Client-Cert-Subject"
+	DefaultSANHeader         = "X-Client-Cert-SAN"
+	DefaultFingerprintHeader = "X-Client-Cert-Fingerprint"
+)
+
+var (
+	// ErrNoCertificate 客户端未发送证书
+	ErrNoCertificate = errors.New("client certificate required")
+	// ErrMisdirected 连接按其他SNI建立 握手时没有请求客户端证书
+	// HTTP/2连接复用到开启认证的域名时出现 客户端收到421后会使用新连接重试
+	ErrMisdirected = errors.New("client certificate not requested on this connection")
+	// ErrVerify 证书链校验失败或证书已吊销
+	ErrVerify = errors.New("client certificate verification failed")
+)
+
+// Policy 域名组的客户端证书校验策略
+type Policy struct {
+	Mode        string
+	roots       *x509.CertPool
+	crls        []*crlFile
+	subject     string
+	san         string
+	fingerprint string
+}
+
+// registry 域名到策略的索引 通配符按去掉*的后缀索引 只匹配一级子域名
+type registry struct {
+	exact    map[string]*Policy
+	wildcard map[string]*Policy
+	headers  []string // 所有策略使用的请求头 转发前总是移除客户端发送的值
+}
+
+var current atomic.Pointer[registry]
+
+// Init 按https服务器的域名组配置替换全局策略 CA或CRL文件无法加载时返回错误并保留原有策略
+func Init(cfg *config.Config) error {
+	r := &registry{exact: make(map[string]*Policy), wildcard: make(map[string]*Policy)}
+	headers := make(map[string]struct{})
+	var errs []error
+	for _, server := range cfg.Servers {
+		if !server.Enabled || server.Protocol != "https" {
+			continue
+		}
+		for _, group := range server.DomainConfig {
+			if group.ClientAuth == nil || group.ClientAuth.Mode == "" || group.ClientAuth.Mode == ModeNone {
+				continue
+			}
+			policy, err := NewPolicy(*group.ClientAuth)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("server %s domains %v: %w", server.Name, group.Domains, err))
+				continue
+			}
+			for _, name := range []string{policy.subject, policy.san, policy.fingerprint} {
+				if _, ok := headers[name]; !ok {
+					headers[name] = struct{}{}
+					r.headers = append(r.headers, name)
+				}
+			}
+			for _, domain := range group.Domains {
+				domain = strings.ToLower(domain)
+				if strings.HasPrefix(domain, "*.") {
+					r.wildcard[domain[1:]] = policy
+				} else {
+					r.exact[domain] = policy
+				}
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	current.Store(r)
+	return nil
+}
+
+// NewPolicy 加载CA和CRL文件
+func NewPolicy(conf config.ClientAuthConfig) (*Policy, error) {
+	p := &Policy{
+		Mode:        conf.Mode,
+		roots:       x509.NewCertPool(),
+		subject:     headerName(conf.SubjectHeader, DefaultSubjectHeader),
+		san:         headerName(conf.SANHeader, DefaultSANHeader),
+		fingerprint: headerName(conf.FingerprintHeader, DefaultFingerprintHeader),
+	}
+	switch p.Mode {
+	case ModeOptional, ModeRequired:
+	default:
+		return nil, fmt.Errorf("unknown client auth mode %s", p.Mode)
+	}
+	if len(conf.CAFiles) == 0 {
+		return nil, errors.New("client auth requires ca_files")
+	}
+	for _, file := range conf.CAFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !p.roots.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in %s", file)
+		}
+	}
+	for _, file := range conf.CRLFiles {
+		crl, err := loadCRLFile(file)
+		if err != nil {
+			return nil, err
+		}
+		p.crls = append(p.crls, crl)
+	}
+	return p, nil
+}
+
+func headerName(name, def string) string {
+	if name == "" {
+		return def
+	}
+	return http.CanonicalHeaderKey(name)
+}
+
+// Lookup 域名对应的策略 未开启认证时返回nil
+func Lookup(host string) *Policy {
+	r := current.Load()
+	if r == nil || (len(r.exact) == 0 && len(r.wildcard) == 0) {
+		return nil
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if p, ok := r.exact[host]; ok {
+		return p
+	}
+	if i := strings.IndexByte(host, '.'); i > 0 {
+		return r.wildcard[host[i:]]
+	}
+	return nil
+}
+
+// Strip 移除客户端发送的证书信息请求头 避免伪造
+func Strip(h http.Header) {
+	r := current.Load()
+	if r == nil {
+		return
+	}
+	for _, name := range r.headers {
+		h.Del(name)
+	}
+}
+
+// Verify 校验连接上的客户端证书 返回校验通过的终端证书
+// 返回的错误为ErrNoCertificate、ErrMisdirected或包装的ErrVerify
+func (p *Policy) Verify(state *tls.ConnectionState) (*x509.Certificate, error) {
+	if state == nil {
+		return nil, ErrNoCertificate
+	}
+	if len(state.PeerCertificates) == 0 {
+		if Lookup(state.ServerName) == nil {
+			return nil, ErrMisdirected
+		}
+		return nil, ErrNoCertificate
+	}
+	leaf := state.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         p.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerify, err)
+	}
+	if err := p.checkRevocation(chains[0]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerify, err)
+	}
+	return leaf, nil
+}
+
+// checkRevocation 按CRL检查证书链中除根证书外的每张证书
+func (p *Policy) checkRevocation(chain []*x509.Certificate) error {
+	for i := 0; i < len(chain)-1; i++ {
+		cert, issuer := chain[i], chain[i+1]
+		for _, crl := range p.crls {
+			if crl.current().revoked(cert, issuer) {
+				return fmt.Errorf("certificate %s (serial %s) is revoked", cert.Subject, cert.SerialNumber)
+			}
+		}
+	}
+	return nil
+}
+
+// SetHeaders 写入转发给上游的证书信息
+func (p *Policy) SetHeaders(h http.Header, cert *x509.Certificate) {
+	h.Set(p.subject, cert.Subject.String())
+	if sans := SANs(cert); sans != "" {
+		h.Set(p.san, sans)
+	}
+	h.Set(p.fingerprint, Fingerprint(cert))
+}
+
+// SANs 证书的主题备用名称 格式为 DNS:a.example.com, email:a@example.com, IP:10.0.0.1, URI:spiffe://a
+func SANs(cert *x509.Certificate) string {
+	var names []string
+	for _, name := range cert.DNSNames {
+		names = append(names, "DNS:"+name)
+	}
+	for _, email := range cert.EmailAddresses {
+		names = append(names, "email:"+email)
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, "IP:"+ip.String())
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, "URI:"+uri.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// Fingerprint 证书DER编码的SHA-256指纹 小写十六进制
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,169 @@
+package clientauth
+
+import (
+	"Hamburger/internal/config"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) issue(t *testing.T, serial int64, cn string) *x509.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	spiffe, _ := url.Parse("spiffe://example.com/admin")
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(serial),
+		Subject:        pkix.Name{CommonName: cn, Organization: []string{"Hamburger"}},
+		DNSNames:       []string{cn},
+		EmailAddresses: []string{"ops@example.com"},
+		URIs:           []*url.URL{spiffe},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert
+}
+
+func (ca *testCA) writeCRL(t *testing.T, path string, number int64, revoked ...*x509.Certificate) {
+	tmpl := &x509.RevocationList{
+		Number:     big.NewInt(number),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	for _, cert := range revoked {
+		tmpl.RevokedCertificateEntries = append(tmpl.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   cert.SerialNumber,
+			RevocationTime: time.Now(),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, tmpl, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "client ca")
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	crlFile := filepath.Join(dir, "ca.crl")
+	ca.writeCRL(t, crlFile, 1)
+
+	cfg := &config.Config{Servers: []config.ServerConfig{{
+		Name:     "https",
+		Protocol: "https",
+		Enabled:  true,
+		DomainConfig: []config.DomainConfig{
+			{Domains: []string{"admin.example.com", "*.internal.example.com"}, ClientAuth: &config.ClientAuthConfig{
+				Mode: ModeRequired, CAFiles: []string{caFile}, CRLFiles: []string{crlFile}, FingerprintHeader: "x-client-sha256",
+			}},
+			{Domains: []string{"www.example.com"}},
+		},
+	}}}
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { current.Store(nil) })
+
+	policy := Lookup("admin.example.com:443")
+	if policy == nil || Lookup("api.internal.example.com") != policy {
+		t.Fatal("expected policy for exact and wildcard domains")
+	}
+	if Lookup("www.example.com") != nil || Lookup("a.b.internal.example.com") != nil {
+		t.Fatal("unexpected policy for domain without client auth")
+	}
+
+	client := ca.issue(t, 2, "alice")
+	cert, err := policy.Verify(&tls.ConnectionState{ServerName: "admin.example.com", PeerCertificates: []*x509.Certificate{client}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Set("X-Client-Cert-Subject", "CN=forged")
+	Strip(header)
+	if header.Get("X-Client-Cert-Subject") != "" {
+		t.Fatal("forged header not stripped")
+	}
+	policy.SetHeaders(header, cert)
+	if got := header.Get("X-Client-Cert-Subject"); got != "CN=alice,O=Hamburger" {
+		t.Fatalf("unexpected subject header %q", got)
+	}
+	if got := header.Get("X-Client-Cert-San"); got != "DNS:alice, email:ops@example.com, URI:spiffe://example.com/admin" {
+		t.Fatalf("unexpected san header %q", got)
+	}
+	if got := header.Get("X-Client-Sha256"); got != Fingerprint(client) || len(got) != 64 {
+		t.Fatalf("unexpected fingerprint header %q", got)
+	}
+
+	// 其他CA签发的证书
+	other := newTestCA(t, "other ca").issue(t, 2, "mallory")
+	if _, err := policy.Verify(&tls.ConnectionState{ServerName: "admin.example.com", PeerCertificates: []*x509.Certificate{other}}); !errors.Is(err, ErrVerify) {
+		t.Fatalf("expected verify error, got %v", err)
+	}
+	// 未发送证书 以及复用了未请求证书的连接
+	if _, err := policy.Verify(&tls.ConnectionState{ServerName: "admin.example.com"}); !errors.Is(err, ErrNoCertificate) {
+		t.Fatalf("expected missing certificate, got %v", err)
+	}
+	if _, err := policy.Verify(&tls.ConnectionState{ServerName: "www.example.com"}); !errors.Is(err, ErrMisdirected) {
+		t.Fatalf("expected misdirected request, got %v", err)
+	}
+
+	// 更新吊销列表后重新加载
+	ca.writeCRL(t, crlFile, 2, client)
+	future := time.Now().Add(time.Second)
+	_ = os.Chtimes(crlFile, future, future)
+	policy.crls[0].checked.Store(0)
+	if _, err := policy.Verify(&tls.ConnectionState{ServerName: "admin.example.com", PeerCertificates: []*x509.Certificate{client}}); !errors.Is(err, ErrVerify) {
+		t.Fatalf("expected revoked certificate to fail, got %v", err)
+	}
+	if _, err := policy.Verify(&tls.ConnectionState{ServerName: "admin.example.com", PeerCertificates: []*x509.Certificate{ca.issue(t, 3, "bob")}}); err != nil {
+		t.Fatalf("unrevoked certificate rejected: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package clientauth
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 证书吊销列表
+// 请求中按间隔检查文件修改时间 变化后重新加载 加载失败时继续使用旧的列表
+
+const crlCheckInterval = 10 * time.Second
+
+type crlFile struct {
+	path    string
+	mu      sync.Mutex
+	checked atomic.Int64 // 上次检查文件的时间(UnixNano)
+	list    atomic.Pointer[crlList]
+}
+
+type crlList struct {
+	mod      time.Time
+	crl      *x509.RevocationList
+	serials  map[string]struct{}
+	verified atomic.Pointer[x509.Certificate] // 已验证过签名的签发者
+}
+
+func loadCRLFile(path string) (*crlFile, error) {
+	f := &crlFile{path: path}
+	list, err := parseCRLFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f.list.Store(list)
+	f.checked.Store(time.Now().UnixNano())
+	return f, nil
+}
+
+func parseCRLFile(path string) (*crlList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse crl %s: %w", path, err)
+	}
+	list := &crlList{mod: info.ModTime(), crl: crl, serials: make(map[string]struct{}, len(crl.RevokedCertificateEntries))}
+	for _, entry := range crl.RevokedCertificateEntries {
+		list.serials[string(entry.SerialNumber.Bytes())] = struct{}{}
+	}
+	return list, nil
+}
+
+// current 当前的吊销列表 到达检查间隔时检查文件是否变化
+func (f *crlFile) current() *crlList {
+	now := time.Now()
+	if now.UnixNano()-f.checked.Load() >= int64(crlCheckInterval) && f.mu.TryLock() {
+		f.checked.Store(now.UnixNano())
+		if info, err := os.Stat(f.path); err == nil && !info.ModTime().Equal(f.list.Load().mod) {
+			if list, err := parseCRLFile(f.path); err == nil {
+				f.list.Store(list)
+			}
+		}
+		f.mu.Unlock()
+	}
+	return f.list.Load()
+}
+
+// revoked 证书是否在签发者的吊销列表中 列表不是该签发者签发时返回false
+func (l *crlList) revoked(cert, issuer *x509.Certificate) bool {
+	if !bytes.Equal(l.crl.RawIssuer, issuer.RawSubject) {
+		return false
+	}
+	if _, ok := l.serials[string(cert.SerialNumber.Bytes())]; !ok {
+		return false
+	}
+	if verified := l.verified.Load(); verified == nil || !verified.Equal(issuer) {
+		if l.crl.CheckSignatureFrom(issuer) != nil {
+			return false
+		}
+		l.verified.Store(issuer)
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+// Package clientip
+// 解析请求的真实客户端IP
+// 只有直接连接方属于可信代理时才读取客户端IP请求头 避免客户端伪造
+package clientip
+
+import (
+	"Hamburger/gateway/reqinfo"
+	"Hamburger/internal/config"
+	"Hamburger/internal/structure"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+const DefaultHeader = "X-Forwarded-For"
+
+// Resolver 客户端IP解析器
+type Resolver struct {
+	trusted *structure.IPTrie
+	header  string
+}
+
+var current atomic.Pointer[Resolver]
+
+// NewResolver 按可信代理列表创建解析器
+func NewResolver(trusted []string, header string) (*Resolver, error) {
+	trie, err := structure.NewIPTrieFrom(trusted)
+	if err != nil {
+		return nil, err
+	}
+	if header == "" {
+		header = DefaultHeader
+	}
+	return &Resolver{trusted: trie, header: http.CanonicalHeaderKey(header)}, nil
+}
+
+// Init 按安全配置替换全局解析器
+func Init(conf config.SecurityConfig) error {
+	r, err := NewResolver(conf.TrustedProxies, conf.RealIPHeader)
+	if err != nil {
+		return err
+	}
+	current.Store(r)
+	return nil
+}
+
+// Get 请求的客户端IP 优先使用请求信息中已解析的结果
+func Get(req *http.Request) netip.Addr {
+	info := reqinfo.From(req.Context())
+	if info != nil && info.ClientIP.IsValid() {
+		return info.ClientIP
+	}
+	addr := Resolve(req)
+	info.SetClientIP(addr)
+	return addr
+}
+
+// String 客户端IP的字符串形式 无法解析时返回连接地址
+func String(req *http.Request) string {
+	if addr := Get(req); addr.IsValid() {
+		return addr.String()
+	}
+	return req.RemoteAddr
+}
+
+// Resolve 使用全局解析器解析客户端IP 未初始化时使用连接地址
+func Resolve(req *http.Request) netip.Addr {
+	return current.Load().Resolve(req)
+}
+
+// Resolve 解析客户端IP
+// X-Forwarded-For从右向左跳过可信代理 第一个不可信的地址即客户端 全部可信时使用最左侧的地址
+func (r *Resolver) Resolve(req *http.Request) netip.Addr {
+	remote := parseAddr(req.RemoteAddr)
+	if r == nil || r.trusted.Len() == 0 || !r.trusted.Contains(remote) {
+		return remote
+	}
+
+	values := req.Header.Values(r.header)
+	if len(values) == 0 {
+		return remote
+	}
+	if r.header != DefaultHeader {
+		if addr := parseAddr(values[len(values)-1]); addr.IsValid() {
+			return addr
+		}
+		return remote
+	}
+
+	client := remote
+	for i := len(values) - 1; i >= 0; i-- {
+		parts := strings.Split(values[i], ",")
+		for j := len(parts) - 1; j >= 0; j-- {
+			addr := parseAddr(parts[j])
+			if !addr.IsValid() {
+				return client
+			}
+			client = addr
+			if !r.trusted.Contains(addr) {
+				return client
+			}
+		}
+	}
+	return client
+}
+
+// parseAddr 解析IP 支持带端口和IPv6方括号的格式
+func parseAddr(s string) netip.Addr {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap().WithZone("")
+}
@@ -0,0 +1,50 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	r, err := NewResolver([]string{"10.0.0.0/8", "fd00::/8"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"untrusted remote ignores header", "203.0.113.9:5000", []string{"1.1.1.1"}, "203.0.113.9"},
+		{"trusted remote without header", "10.0.0.1:5000", nil, "10.0.0.1"},
+		{"skip trusted hops", "10.0.0.1:5000", []string{"1.1.1.1, 2.2.2.2, 10.0.0.2"}, "2.2.2.2"},
+		{"multiple header lines", "10.0.0.1:5000", []string{"1.1.1.1", "10.0.0.3"}, "1.1.1.1"},
+		{"all trusted uses leftmost", "10.0.0.1:5000", []string{"10.0.0.5, 10.0.0.6"}, "10.0.0.5"},
+		{"invalid entry stops", "10.0.0.1:5000", []string{"1.1.1.1, bogus"}, "10.0.0.1"},
+		{"ipv6", "[fd00::1]:443", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"ipv4 mapped remote", "[::ffff:10.0.0.1]:80", []string{"3.3.3.3"}, "3.3.3.3"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = c.remote
+		for _, v := range c.xff {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		if got := r.Resolve(req).String(); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+
+	realIP, err := NewResolver([]string{"127.0.0.1"}, "X-Real-IP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("X-Real-IP", "198.51.100.7")
+	if got := realIP.Resolve(req).String(); got != "198.51.100.7" {
+		t.Errorf("X-Real-IP: got %s", got)
+	}
+}
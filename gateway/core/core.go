@@ -2,17 +2,20 @@ package core
 
 import (
 	"Hamburger/gateway/prehandler"
+	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"sync"
 
 	"Hamburger/gateway/breaker"
 	"Hamburger/gateway/error_page"
-	"Hamburger/gateway/grpc_proxy"
 	"Hamburger/gateway/modifier"
+	"Hamburger/gateway/reqinfo"
 	"Hamburger/gateway/resolver"
 	"Hamburger/gateway/stat"
+	"Hamburger/gateway/waf"
 	"Hamburger/internal/config"
 	"Hamburger/internal/constant"
 	"Hamburger/internal/serror"
@@ -95,35 +98,41 @@ func ProxyDirector(cfg *config.Config, logger *zerolog.Logger) func(request *htt
 			Str("Host", request.Host).
 			Str("Trace-ID", request.Header.Get(cfg.ProxyHeader.TraceId)).
 			Msg("parse request")
-		// 检查是否为gRPC代理请求
-		if grpc_proxy.IsEnabled() {
-			proxy := grpc_proxy.GetGrpcProxy()
-			if proxy != nil && proxy.IsGrpcRequest(request) {
-				logger.Debug().Msg("detected gRPC proxy request")
-				// 设置特殊的scheme来标识gRPC请求，后续在Transport中处理
-				request.URL = &url.URL{Scheme: constant.SchemeGrpc}
-				return
-			}
-		}
-
-		// 请求次数
-		stat.Add(stat.Total)
-		// 统计域名
-		stat.AddDomainStat(request.Host)
-		// 统计远程地址
-		stat.AddGeo(request.RemoteAddr)
-
-		pm := prehandler.GetManager()
-		for _, handler := range pm.GetPreHandlers() {
-			if err := handler.Handle(request); err != nil {
-				logger.Debug().Err(err).Str("Name", handler.Name()).Err(err).Msg("pre handler failed")
-				request.URL = &url.URL{Scheme: constant.SchemeSandwich}
-				return
-			}
+		if !preHandle(logger, request) {
+			request.URL = &url.URL{Scheme: constant.SchemeSandwich}
+			return
 		}
 		request.URL = resolver.OneResolver(cfg, logger).Parse(request)
 		logger.Debug().Any("URL", request.URL).Msg("parse request")
+
+		// 上游处于熔断状态时快速失败
+		if request.URL != nil && !breaker.Allow(request.Host, request.URL.Host) {
+			logger.Debug().Str("Host", request.Host).Str("Upstream", request.URL.Host).Msg("upstream breaker is open")
+			reqinfo.From(request.Context()).Reject("Breaker")
+			request.Header.Set(serror.SandwichInternalFlag, serror.SandwichBucketLimit)
+			request.URL = &url.URL{Scheme: constant.SchemeSandwich}
+		}
+	}
+}
+
+// preHandle 统计请求并执行前置处理器 返回false表示请求被拒绝 拒绝原因记录在请求头的内部标识中
+func preHandle(logger *zerolog.Logger, request *http.Request) bool {
+	// 请求次数
+	stat.Add(stat.Total)
+	// 统计域名
+	stat.AddDomainStat(request.Host)
+	// 统计远程地址
+	stat.AddGeo(request.RemoteAddr)
+
+	pm := prehandler.GetManager()
+	for _, handler := range pm.GetPreHandlers() {
+		if err := handler.Handle(request); err != nil {
+			logger.Debug().Err(err).Str("Name", handler.Name()).Err(err).Msg("pre handler failed")
+			reqinfo.From(request.Context()).Reject(handler.Name())
+			return false
+		}
 	}
+	return true
 }
 
 // ProxyModifyResponse 响应修改逻辑
@@ -166,14 +175,34 @@ func ProxyErrorHandler(logger *zerolog.Logger) func(writer http.ResponseWriter,
 			return
 		case serror.SandwichReqLimit:
 			logger.Debug().Msg("reach flow control limit")
+			writeRateLimitHeader(writer, reqinfo.From(request.Context()))
 			error_page.Cache(http.StatusTooManyRequests, writer, request, error_page.Forbidden)
 			return
 		case serror.SandwichDomainNotAllow:
 			logger.Debug().Msg("http: no host in request url")
 			error_page.Cache(http.StatusForbidden, writer, request, error_page.Forbidden)
 			return
+		case serror.SandwichIPNotAllow, serror.SandwichGeoNotAllow:
+			logger.Debug().Msg("client ip or country not allowed")
+			error_page.Cache(http.StatusForbidden, writer, request, error_page.Forbidden)
+			return
+		case serror.SandwichWAFBlock:
+			logger.Debug().Msg("request blocked by waf")
+			error_page.Cache(http.StatusForbidden, writer, request, error_page.Forbidden)
+			return
+		case serror.SandwichWAFChallenge:
+			logger.Debug().Msg("request challenged by waf")
+			waf.WriteChallenge(writer, request)
+			return
+		case serror.SandwichClientCertInvalid:
+			logger.Debug().Msg("client certificate rejected")
+			error_page.Cache(http.StatusForbidden, writer, request, error_page.ClientCert)
+			return
+		case serror.SandwichClientCertMisdirected:
+			logger.Debug().Msg("client certificate not requested on reused connection")
+			writer.WriteHeader(http.StatusMisdirectedRequest)
+			return
 		case serror.SandwichBackendError:
-			breaker.Set(request.Host)
 			logger.Debug().Msg("backend: service is down")
 			error_page.Cache(http.StatusBadGateway, writer, request, error_page.Unavailable)
 			return
@@ -182,3 +211,17 @@ func ProxyErrorHandler(logger *zerolog.Logger) func(writer http.ResponseWriter,
 		error_page.Cache(http.StatusBadGateway, writer, request, error_page.Unavailable)
 	}
 }
+
+// writeRateLimitHeader 限流响应的Retry-After和X-RateLimit-*响应头 时间向上取整到秒
+func writeRateLimitHeader(w http.ResponseWriter, info *reqinfo.Info) {
+	if info == nil || info.RateLimit == nil {
+		return
+	}
+	limit := info.RateLimit
+	reset := int64(math.Ceil(limit.Reset.Seconds()))
+	header := w.Header()
+	header.Set("Retry-After", strconv.FormatInt(max(reset, 1), 10))
+	header.Set("X-RateLimit-Limit", strconv.Itoa(limit.Limit))
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(limit.Remaining))
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"Hamburger/gateway/error_page"
+	"Hamburger/gateway/reqinfo"
+	"Hamburger/internal/config"
+	"Hamburger/internal/serror"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRateLimitResponse(t *testing.T) {
+	config.Set(&config.Config{})
+	logger := zerolog.Nop()
+	handler := ProxyErrorHandler(&logger)
+
+	cases := []struct {
+		name      string
+		reset     time.Duration
+		remaining int
+		retry     string
+		resetHdr  string
+	}{
+		{"rounded up", 1500 * time.Millisecond, 0, "2", "2"},
+		{"retry at least one second", 0, 3, "1", "0"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req, info := reqinfo.New(req)
+		info.SetRateLimit(10, c.remaining, c.reset)
+		req.Header.Set(serror.SandwichInternalFlag, serror.SandwichReqLimit)
+		rec := httptest.NewRecorder()
+		handler(rec, req, nil)
+
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("%s: status %d", c.name, rec.Code)
+		}
+		h := rec.Header()
+		if h.Get("Retry-After") != c.retry || h.Get("X-RateLimit-Reset") != c.resetHdr ||
+			h.Get("X-RateLimit-Limit") != "10" || h.Get("X-RateLimit-Remaining") != strconv.Itoa(c.remaining) {
+			t.Fatalf("%s: unexpected headers %v", c.name, h)
+		}
+	}
+
+	// 没有额度状态时不写入响应头
+	rec := httptest.NewRecorder()
+	writeRateLimitHeader(rec, nil)
+	if len(rec.Header()) != 0 {
+		t.Fatalf("unexpected headers %v", rec.Header())
+	}
+}
+
+func TestClientCertResponse(t *testing.T) {
+	config.Set(&config.Config{})
+	logger := zerolog.Nop()
+	handler := ProxyErrorHandler(&logger)
+
+	cases := []struct {
+		flag   string
+		accept string
+		code   int
+		page   []byte
+	}{
+		{serror.SandwichClientCertInvalid, "", http.StatusForbidden, nil},
+		{serror.SandwichClientCertInvalid, "text/html", http.StatusOK, error_page.ClientCertPage},
+		{serror.SandwichClientCertMisdirected, "text/html", http.StatusMisdirectedRequest, nil},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(serror.SandwichInternalFlag, c.flag)
+		req.Header.Set("Accept", c.accept)
+		rec := httptest.NewRecorder()
+		handler(rec, req, nil)
+		if rec.Code != c.code {
+			t.Fatalf("%s %q: status %d, want %d", c.flag, c.accept, rec.Code, c.code)
+		}
+		if c.page != nil && !bytes.Equal(rec.Body.Bytes(), c.page) {
+			t.Fatalf("%s %q: unexpected page", c.flag, c.accept)
+		}
+	}
+}
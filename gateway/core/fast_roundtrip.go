@@ -1,12 +1,16 @@
 package core
 
 import (
+	"Hamburger/gateway/upstreamtls"
 	"Hamburger/internal/config"
 	"Hamburger/internal/logger"
+	"Hamburger/internal/structure"
 	"bytes"
+	"crypto/tls"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/valyala/fasthttp"
@@ -14,21 +18,69 @@ import (
 
 // FastRoundTripper 使用 fasthttp 作为下游传输
 // 负责将 *http.Request 映射为 fasthttp 请求并转换响应
+// https上游按TLS配置(Profile)各自使用一个客户端 连接池和会话缓存在同一Profile内共享
 type FastRoundTripper struct {
 	// Client fasthttp客户端实例
-	Client *fasthttp.Client
+	Client   *fasthttp.Client
+	profiles *structure.Map[*fasthttp.Client]
+	mu       sync.Mutex
 }
 
 // NewFastRoundTripper 创建一个FastRoundTripper
 // 根据现有http.Transport的超时与连接配置进行参照设置
 func NewFastRoundTripper() *FastRoundTripper {
-	return &FastRoundTripper{
-		Client: &fasthttp.Client{
-			MaxConnsPerHost:     100,
-			MaxIdleConnDuration: 90 * time.Second,
-			ReadTimeout:         30 * time.Second,
-			WriteTimeout:        30 * time.Second,
-		},
+	f := &FastRoundTripper{
+		Client:   newFastClient(nil),
+		profiles: structure.NewMap[*fasthttp.Client](),
+	}
+	upstreamtls.OnReload(f.prune)
+	return f
+}
+
+func newFastClient(tlsConfig *tls.Config) *fasthttp.Client {
+	return &fasthttp.Client{
+		MaxConnsPerHost:     100,
+		MaxIdleConnDuration: 90 * time.Second,
+		ReadTimeout:         30 * time.Second,
+		WriteTimeout:        30 * time.Second,
+		TLSConfig:           tlsConfig,
+	}
+}
+
+// client 按上游的TLS配置选择客户端
+func (f *FastRoundTripper) client(req *http.Request) *fasthttp.Client {
+	if req.URL == nil || req.URL.Scheme != upstreamtls.SchemeHTTPS {
+		return f.Client
+	}
+	profile := upstreamtls.Lookup(req.Host, req.URL.Host)
+	if profile == nil {
+		return f.Client
+	}
+	if c, ok := f.profiles.Get(profile.Key()); ok {
+		return c
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.profiles.Get(profile.Key()); ok {
+		return c
+	}
+	c := newFastClient(profile.TLSConfig())
+	f.profiles.Put(profile.Key(), c)
+	return c
+}
+
+// prune 移除配置中不再使用的Profile的客户端 并关闭其空闲连接
+func (f *FastRoundTripper) prune() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range f.profiles.Keys() {
+		if upstreamtls.InUse(key) {
+			continue
+		}
+		if c, ok := f.profiles.Get(key); ok {
+			f.profiles.Delete(key)
+			c.CloseIdleConnections()
+		}
 	}
 }
 
@@ -102,8 +154,15 @@ func (f *FastRoundTripper) RoundTrip(req *http.Request) (*http.Response, error)
 		fr.Header.Set("X-Forwarded-Proto", "https")
 	}
 	fr.DisableRedirectPathNormalizing = true
-	// 发送请求
-	if err := f.Client.Do(&fr, &resp); err != nil {
+	// 发送请求 重试时按单次尝试的超时发送
+	var err error
+	client := f.client(req)
+	if timeout, ok := req.Context().Value(tryTimeoutKey{}).(time.Duration); ok && timeout > 0 {
+		err = client.DoTimeout(&fr, &resp, timeout)
+	} else {
+		err = client.Do(&fr, &resp)
+	}
+	if err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"Hamburger/gateway/grpc_proxy"
+	"Hamburger/gateway/grpc_server"
+	"Hamburger/gateway/reqinfo"
+	"Hamburger/gateway/stat"
+	"Hamburger/internal/serror"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// grpcHandler JSON桥接请求和h2/h2c上的gRPC请求经过前置处理器后分别交给桥接模块和gRPC透明代理
+// 拒绝时桥接请求返回JSON错误 gRPC请求返回gRPC状态码 两者都无法解析HTML错误页
+func grpcHandler(logger *zerolog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// JSON桥接请求由桥接模块直接写出响应 服务端流式响应需要逐条刷新
+		if proxy := grpc_proxy.GetGrpcProxy(); proxy != nil && proxy.IsGrpcRequest(r) {
+			logger.Debug().Msg("detected gRPC proxy request")
+			if !preHandle(logger, r) {
+				stat.Add(stat.Fail)
+				flag := r.Header.Get(serror.SandwichInternalFlag)
+				if flag == serror.SandwichReqLimit {
+					writeRateLimitHeader(w, reqinfo.From(r.Context()))
+				}
+				code, msg := bridgeRejectStatus(flag)
+				proxy.WriteError(w, msg, code)
+				return
+			}
+			proxy.HandleGrpcRequest(w, r)
+			return
+		}
+		if !grpc_server.Enabled() || !grpc_server.IsGrpcRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !preHandle(logger, r) {
+			stat.Add(stat.Fail)
+			code, msg := grpcRejectStatus(r.Header.Get(serror.SandwichInternalFlag))
+			stat.AddGrpcUnmatched(code)
+			grpc_server.WriteStatus(w, code, msg)
+			return
+		}
+		grpc_server.GetServer().ServeHTTP(w, r)
+	})
+}
+
+// bridgeRejectStatus 前置处理器拒绝原因对应的HTTP状态码 与错误页使用的状态码一致
+func bridgeRejectStatus(flag string) (int, string) {
+	switch flag {
+	case serror.SandwichReqLimit:
+		return http.StatusTooManyRequests, "rate limit exceeded"
+	case serror.SandwichBucketLimit:
+		return http.StatusGatewayTimeout, "upstream breaker is open"
+	case serror.SandwichClientCertInvalid:
+		return http.StatusForbidden, "client certificate rejected"
+	case serror.SandwichClientCertMisdirected:
+		return http.StatusMisdirectedRequest, "client certificate not requested on this connection"
+	case serror.SandwichIPNotAllow, serror.SandwichGeoNotAllow, serror.SandwichWAFBlock, serror.SandwichWAFChallenge, serror.SandwichDomainNotAllow:
+		return http.StatusForbidden, "request not allowed"
+	}
+	return http.StatusForbidden, "request rejected"
+}
+
+// grpcRejectStatus 前置处理器拒绝原因对应的gRPC状态码
+func grpcRejectStatus(flag string) (int, string) {
+	switch flag {
+	case serror.SandwichReqLimit:
+		return grpc_server.CodeResourceExhausted, "rate limit exceeded"
+	case serror.SandwichBucketLimit:
+		return grpc_server.CodeUnavailable, "upstream breaker is open"
+	case serror.SandwichIPNotAllow, serror.SandwichGeoNotAllow, serror.SandwichWAFBlock, serror.SandwichWAFChallenge, serror.SandwichDomainNotAllow:
+		return grpc_server.CodePermissionDenied, "request not allowed"
+	case serror.SandwichClientCertInvalid, serror.SandwichClientCertMisdirected:
+		return grpc_server.CodeUnauthenticated, "client certificate rejected"
+	}
+	return grpc_server.CodeUnavailable, "request rejected"
+}
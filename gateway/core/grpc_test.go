@@ -0,0 +1,59 @@
+package core
+
+import (
+	"Hamburger/gateway/prehandler"
+	"Hamburger/internal/config"
+	"Hamburger/internal/serror"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// rejectPreHandler 拒绝带有X-Test-Reject请求头的请求
+type rejectPreHandler struct{}
+
+func (rejectPreHandler) Handle(r *http.Request) error {
+	if r.Header.Get("X-Test-Reject") == "" {
+		return nil
+	}
+	r.Header.Set(serror.SandwichInternalFlag, serror.SandwichClientCertInvalid)
+	return errors.New("rejected")
+}
+
+func (rejectPreHandler) Name() string  { return "Reject" }
+func (rejectPreHandler) Enabled() bool { return true }
+
+func TestGrpcBridgeRunsPreHandlers(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Features.GrpcProxy = config.GrpcProxyConfig{Enabled: true, Bridge: true, GrpcHeader: "X-Grpc", GrpcAddr: "X-Grpc-Addr"}
+	config.Set(cfg)
+	prehandler.GetManager().Add(rejectPreHandler{})
+
+	logger := zerolog.Nop()
+	handler := grpcHandler(&logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("bridge request reached the http proxy")
+	}))
+
+	// 前置处理器拒绝时不进入桥接模块
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+	req.Header.Set("X-Grpc", "true")
+	req.Header.Set("X-Test-Reject", "1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || rec.Header().Get("Content-Type") != "application/json" || !strings.Contains(rec.Body.String(), "client certificate rejected") {
+		t.Fatalf("unexpected rejection %d %q", rec.Code, rec.Body.String())
+	}
+
+	// 通过后由桥接模块处理 缺少目标地址时返回400
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+	req.Header.Set("X-Grpc", "true")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "missing gRPC address header") {
+		t.Fatalf("unexpected bridge response %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"Hamburger/gateway/accesslog"
+	"Hamburger/gateway/clientip"
+	"Hamburger/gateway/metrics"
+	"Hamburger/gateway/reqinfo"
+	"net/http"
+)
+
+// observe 请求入口 创建请求信息并在请求结束后记录指标和访问日志
+func observe(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, info := reqinfo.New(r)
+		// 请求头清理会移除X-Forwarded-For 需要在进入处理链之前解析
+		info.SetClientIP(clientip.Resolve(r))
+		rw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+		metrics.ObserveRequest(r, info, rw.status)
+		accesslog.Log(r, rw.Header(), info, rw.status, rw.bytes)
+	})
+}
+
+// statusWriter 记录响应状态码和写出的字节数
+// 通过Unwrap支持http.ResponseController的Flush和Hijack
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	// 1xx信息响应之后还会有最终响应 101协议升级除外
+	if w.status == 0 && (code >= http.StatusOK || code == http.StatusSwitchingProtocols) {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -1,11 +1,32 @@
 package core
 
 import (
+	"Hamburger/gateway/upstreamtls"
+	"Hamburger/internal/structure"
+	"crypto/tls"
 	"net/http"
+	"sync"
 	"time"
 )
 
-func OriginRoundTrip() *http.Transport {
+// OriginTransport 使用标准库的下游传输
+// 普通上游共用一个Transport https上游按TLS配置(Profile)各自使用一个Transport 连接池和会话缓存在同一Profile内共享
+type OriginTransport struct {
+	base     *http.Transport
+	profiles *structure.Map[*http.Transport]
+	mu       sync.Mutex
+}
+
+func OriginRoundTrip() *OriginTransport {
+	t := &OriginTransport{
+		base:     newOriginTransport(nil),
+		profiles: structure.NewMap[*http.Transport](),
+	}
+	upstreamtls.OnReload(t.prune)
+	return t
+}
+
+func newOriginTransport(tlsConfig *tls.Config) *http.Transport {
 	return &http.Transport{
 		// 连接池配置
 		MaxIdleConns:        100,              // 最大空闲连接数
@@ -21,5 +42,47 @@ func OriginRoundTrip() *http.Transport {
 		ForceAttemptHTTP2: true,
 		// 禁用压缩以减少CPU开销（如果不需要）
 		DisableCompression: false,
+		// 上游TLS配置 为空时使用系统CA
+		TLSClientConfig: tlsConfig,
+	}
+}
+
+// RoundTrip 按上游的TLS配置选择Transport
+func (t *OriginTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == upstreamtls.SchemeHTTPS {
+		if profile := upstreamtls.Lookup(req.Host, req.URL.Host); profile != nil {
+			return t.transport(profile).RoundTrip(req)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// transport 获取或创建Profile的Transport
+func (t *OriginTransport) transport(profile *upstreamtls.Profile) *http.Transport {
+	if tr, ok := t.profiles.Get(profile.Key()); ok {
+		return tr
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tr, ok := t.profiles.Get(profile.Key()); ok {
+		return tr
+	}
+	tr := newOriginTransport(profile.TLSConfig())
+	t.profiles.Put(profile.Key(), tr)
+	return tr
+}
+
+// prune 移除配置中不再使用的Profile的Transport 并关闭其空闲连接
+func (t *OriginTransport) prune() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range t.profiles.Keys() {
+		if upstreamtls.InUse(key) {
+			continue
+		}
+		if tr, ok := t.profiles.Get(key); ok {
+			t.profiles.Delete(key)
+			tr.CloseIdleConnections()
+		}
 	}
 }
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"Hamburger/gateway/upstreamtls"
+	"Hamburger/internal/config"
+	"net/http"
+	"testing"
+)
+
+func TestPruneUpstreamTLS(t *testing.T) {
+	const domain = "tls.example.com"
+	const addr = "127.0.0.1:8443"
+	tlsConfig := func(serverNames ...string) config.PxyCustomServiceConfig {
+		conf := config.PxyCustomServiceConfig{Enable: true, CustomService: []config.CustomServiceConfig{{Domain: domain}}}
+		for i, name := range serverNames {
+			conf.CustomService[0].Upstream = append(conf.CustomService[0].Upstream, config.Upstream{
+				Host: "127.0.0.1", Port: 8443 + i, Scheme: upstreamtls.SchemeHTTPS, TLS: &config.UpstreamTLS{ServerName: name},
+			})
+		}
+		return conf
+	}
+	if err := upstreamtls.Init(tlsConfig("a.internal")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = upstreamtls.Init(config.PxyCustomServiceConfig{}) })
+
+	origin := OriginRoundTrip()
+	fast := NewFastRoundTripper()
+	use := func() {
+		origin.transport(upstreamtls.Lookup(domain, addr))
+		req, _ := http.NewRequest(http.MethodGet, "https://"+addr+"/", nil)
+		req.Host = domain
+		fast.client(req)
+	}
+	use()
+	if origin.profiles.Size() != 1 || fast.profiles.Size() != 1 {
+		t.Fatalf("profiles = %d %d", origin.profiles.Size(), fast.profiles.Size())
+	}
+
+	// 仍在使用的Profile保留 不再使用的被移除
+	if err := upstreamtls.Init(tlsConfig("a.internal", "b.internal")); err != nil {
+		t.Fatal(err)
+	}
+	if origin.profiles.Size() != 1 || fast.profiles.Size() != 1 {
+		t.Fatalf("profiles after unchanged reload = %d %d", origin.profiles.Size(), fast.profiles.Size())
+	}
+	if err := upstreamtls.Init(tlsConfig("c.internal")); err != nil {
+		t.Fatal(err)
+	}
+	if origin.profiles.Size() != 0 || fast.profiles.Size() != 0 {
+		t.Fatalf("profiles after reload = %d %d", origin.profiles.Size(), fast.profiles.Size())
+	}
+}
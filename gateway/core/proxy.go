@@ -35,6 +35,7 @@ func (p *Proxy) Create() http.Handler {
 		default:
 			p.handler = NewHttpProxy(p.conf, p.logger)
 		}
+		p.handler = observe(grpcHandler(p.logger, websocketHandler(p.conf, p.logger, p.handler)))
 	})
 
 	return p.proxy()
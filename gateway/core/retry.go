@@ -0,0 +1,214 @@
+package core
+
+import (
+	"Hamburger/gateway/balancer"
+	"Hamburger/gateway/reqinfo"
+	"Hamburger/gateway/resolver"
+	"Hamburger/gateway/stat"
+	"Hamburger/gateway/upstreamtls"
+	"Hamburger/internal/config"
+	"Hamburger/internal/logger"
+	"Hamburger/internal/utils"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// 上游请求失败重试
+// 每次重试通过负载均衡重新选择未尝试过的上游 请求体必须可以重放
+// 连接未建立的失败任何方法都可以重试 请求已发出后的失败仅重试幂等请求
+
+const (
+	DefaultRetryAttempts = 2
+	// RetryCountHeader 调试模式下返回给客户端的重试次数
+	RetryCountHeader = "X-Gateway-Retry-Count"
+	// retryDrainSize 丢弃失败响应时最多读取的字节数 便于连接复用
+	retryDrainSize = 4096
+)
+
+// tryTimeoutKey 单次尝试的超时 供不支持context取消的fasthttp传输使用
+type tryTimeoutKey struct{}
+
+// retryRoundTrip 按重试策略转发请求
+func (t *myTransport) retryRoundTrip(req *http.Request) (*http.Response, error) {
+	policy := config.Get().CoreProxy.Retry
+	if !policy.Enabled || req.URL.Host == "" {
+		return t.roundTrip(req)
+	}
+
+	attempts := utils.DefaultInt(policy.MaxAttempts, DefaultRetryAttempts)
+	replayable := prepareReplay(req, policy.MaxReplayBody)
+	tried := make(map[string]struct{}, attempts)
+
+	current := req
+	retries := 0
+	for {
+		tried[current.URL.Host] = struct{}{}
+		resp, err := t.tryOnce(current, utils.ToMillisecond(policy.PerTryTimeout))
+		if retries+1 >= attempts || !replayable || req.Context().Err() != nil ||
+			!shouldRetry(current, resp, err, policy.RetryOn) {
+			return t.markRetries(resp, retries), err
+		}
+
+		addr, ok := resolver.OneResolver(config.Get(), logger.L()).Alternative(current, tried)
+		if !ok {
+			return t.markRetries(resp, retries), err
+		}
+		next, nextErr := nextAttempt(req, addr)
+		if nextErr != nil {
+			return t.markRetries(resp, retries), err
+		}
+		discardResponse(resp)
+
+		event := logger.L().Warn().Str("host", req.Host).Str("from", current.URL.Host).Str("to", addr)
+		if resp != nil {
+			event = event.Int("status", resp.StatusCode)
+		}
+		event.Err(err).Msg("retry upstream request")
+
+		stat.Add(stat.Retry)
+		retries++
+		current = next
+	}
+}
+
+// roundTrip 单次转发 记录上游的连接和延迟统计并回报熔断器
+func (t *myTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	// 记录上游活跃连接和延迟 供负载均衡策略使用
+	info := reqinfo.From(req.Context())
+	info.SetUpstreamAddr(req.URL.Host)
+	done := balancer.Track(req.URL.Host)
+	start := time.Now()
+	resp, err := t.Transport.RoundTrip(req)
+	info.AddUpstreamTime(time.Since(start))
+	done(err)
+	t.report(req, resp, err)
+	return resp, err
+}
+
+// tryOnce 带单次超时的转发 超时只限制等待响应头的时间 不影响响应体的读取
+func (t *myTransport) tryOnce(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if timeout <= 0 {
+		return t.roundTrip(req)
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(req.Context(), tryTimeoutKey{}, timeout))
+	timer := time.AfterFunc(timeout, cancel)
+	resp, err := t.roundTrip(req.WithContext(ctx))
+	timer.Stop()
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *myTransport) markRetries(resp *http.Response, retries int) *http.Response {
+	if config.Get().Debug && resp != nil && retries > 0 {
+		resp.Header.Set(RetryCountHeader, strconv.Itoa(retries))
+	}
+	return resp
+}
+
+// prepareReplay 确保请求体可以在重试时重放
+// 长度已知且不超过limit的请求体会被缓存到内存 无法重放时返回false
+func prepareReplay(req *http.Request, limit int64) bool {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return true
+	}
+	if limit <= 0 || req.ContentLength <= 0 || req.ContentLength > limit {
+		return false
+	}
+
+	data, err := io.ReadAll(io.LimitReader(req.Body, req.ContentLength))
+	if err != nil {
+		// 已读取的部分拼接回请求体 保证首次请求不受影响
+		req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), req.Body))
+		return false
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return true
+}
+
+// nextAttempt 基于原始请求创建指向新上游的请求
+func nextAttempt(req *http.Request, addr string) (*http.Request, error) {
+	next := req.Clone(req.Context())
+	next.URL.Host = addr
+	// 自定义服务的上游可以分别使用http或https
+	if next.URL.Scheme == upstreamtls.SchemeHTTP || next.URL.Scheme == upstreamtls.SchemeHTTPS {
+		next.URL.Scheme = upstreamtls.Scheme(req.Host, addr)
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		next.Body = body
+	}
+	return next, nil
+}
+
+// shouldRetry 判断失败是否可以重试
+func shouldRetry(req *http.Request, resp *http.Response, err error, retryOn []int) bool {
+	if err != nil {
+		// 连接未建立 请求没有到达上游
+		if isDialError(err) {
+			return true
+		}
+		return isIdempotent(req)
+	}
+	return resp != nil && isIdempotent(req) && slices.Contains(retryOn, resp.StatusCode)
+}
+
+// isIdempotent 幂等方法或携带幂等键的请求
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, fasthttp.ErrDialTimeout) ||
+		errors.Is(err, fasthttp.ErrNoFreeConns)
+}
+
+func discardResponse(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, retryDrainSize))
+	_ = resp.Body.Close()
+}
+
+// cancelBody 响应体关闭时释放单次尝试的context
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
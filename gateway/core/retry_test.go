@@ -0,0 +1,248 @@
+package core
+
+import (
+	"Hamburger/gateway/runtime"
+	"Hamburger/internal/config"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShouldRetry(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	readErr := errors.New("connection reset")
+	status := func(code int) *http.Response { return &http.Response{StatusCode: code} }
+	retryOn := []int{http.StatusBadGateway, http.StatusServiceUnavailable}
+
+	cases := []struct {
+		name   string
+		method string
+		key    string
+		resp   *http.Response
+		err    error
+		want   bool
+	}{
+		{"dial error post", http.MethodPost, "", nil, dialErr, true},
+		{"read error get", http.MethodGet, "", nil, readErr, true},
+		{"read error post", http.MethodPost, "", nil, readErr, false},
+		{"read error post with key", http.MethodPost, "k1", nil, readErr, true},
+		{"status in list", http.MethodGet, "", status(http.StatusServiceUnavailable), nil, true},
+		{"status not in list", http.MethodGet, "", status(http.StatusInternalServerError), nil, false},
+		{"status post", http.MethodPost, "", status(http.StatusServiceUnavailable), nil, false},
+		{"status patch with key", http.MethodPatch, "k1", status(http.StatusBadGateway), nil, true},
+		{"success", http.MethodGet, "", status(http.StatusOK), nil, false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/", nil)
+		if c.key != "" {
+			req.Header.Set("Idempotency-Key", c.key)
+		}
+		if got := shouldRetry(req, c.resp, c.err, retryOn); got != c.want {
+			t.Errorf("%s: shouldRetry = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	cases := map[string]bool{
+		http.MethodGet:     true,
+		http.MethodHead:    true,
+		http.MethodOptions: true,
+		http.MethodPut:     true,
+		http.MethodDelete:  true,
+		http.MethodPost:    false,
+		http.MethodPatch:   false,
+	}
+	for method, want := range cases {
+		if got := isIdempotent(httptest.NewRequest(method, "/", nil)); got != want {
+			t.Errorf("%s: isIdempotent = %v, want %v", method, got, want)
+		}
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("X-Idempotency-Key", "k1")
+	if !isIdempotent(req) {
+		t.Error("post with idempotency key should be idempotent")
+	}
+}
+
+func TestPrepareReplay(t *testing.T) {
+	newReq := func(body string, length int64) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(body)))
+		req.ContentLength = length
+		req.GetBody = nil
+		return req
+	}
+	readBody := func(t *testing.T, r io.Reader) string {
+		t.Helper()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	if !prepareReplay(httptest.NewRequest(http.MethodGet, "/", nil), 0) {
+		t.Fatal("request without body should be replayable")
+	}
+
+	req := newReq("hello", 5)
+	if !prepareReplay(req, 16) {
+		t.Fatal("small body should be replayable")
+	}
+	if got := readBody(t, req.Body); got != "hello" {
+		t.Fatalf("first body = %q", got)
+	}
+	replay, _ := req.GetBody()
+	if got := readBody(t, replay); got != "hello" {
+		t.Fatalf("replayed body = %q", got)
+	}
+
+	// 超过限制 长度未知 或未开启缓存时不能重放 请求体保持不变
+	for _, c := range []struct {
+		length int64
+		limit  int64
+	}{{5, 4}, {-1, 16}, {5, 0}} {
+		req := newReq("hello", c.length)
+		if prepareReplay(req, c.limit) {
+			t.Fatalf("length %d limit %d should not be replayable", c.length, c.limit)
+		}
+		if got := readBody(t, req.Body); got != "hello" {
+			t.Fatalf("length %d limit %d: body = %q", c.length, c.limit, got)
+		}
+	}
+}
+
+func TestNextAttempt(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "http://example.com/api", io.NopCloser(strings.NewReader("data")))
+	req.URL.Host = "127.0.0.1:8001"
+	req.ContentLength = 4
+	req.GetBody = nil
+	if !prepareReplay(req, 16) {
+		t.Fatal("body should be replayable")
+	}
+	next, err := nextAttempt(req, "127.0.0.1:8002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.URL.Host != "127.0.0.1:8002" || req.URL.Host != "127.0.0.1:8001" {
+		t.Fatalf("unexpected hosts %s %s", next.URL.Host, req.URL.Host)
+	}
+	if next.Host != "example.com" {
+		t.Fatalf("host header changed to %s", next.Host)
+	}
+	data, _ := io.ReadAll(next.Body)
+	if string(data) != "data" {
+		t.Fatalf("next body = %q", data)
+	}
+}
+
+func TestTryOnceTimeout(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+	// 响应头及时返回后 读取响应体不受单次超时限制
+	body := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		_, _ = io.WriteString(w, "done")
+	}))
+	defer body.Close()
+
+	transport := &myTransport{conf: &config.Config{}, Transport: http.DefaultTransport}
+	start := time.Now()
+	_, err := transport.tryOnce(httptest.NewRequest(http.MethodGet, slow.URL, nil).WithContext(t.Context()), 50*time.Millisecond)
+	if err == nil || time.Since(start) > time.Second {
+		t.Fatalf("expected per-try timeout, got %v after %s", err, time.Since(start))
+	}
+
+	req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, body.URL, nil)
+	resp, err := transport.tryOnce(req, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil || string(data) != "done" {
+		t.Fatalf("body = %q, err = %v", data, err)
+	}
+}
+
+func TestRetryFailover(t *testing.T) {
+	var hits atomic.Int32
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = io.Copy(w, r.Body)
+	}))
+	defer good.Close()
+	goodAddr := good.Listener.Addr().String()
+
+	// 已关闭的端口 连接无法建立
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := ln.Addr().String()
+	_ = ln.Close()
+
+	port := func(addr string) int {
+		_, p, _ := net.SplitHostPort(addr)
+		n, _ := strconv.Atoi(p)
+		return n
+	}
+	const domain = "retry.example.com"
+	runtime.DomainPortsMap.Put(domain, []int{port(deadAddr), port(goodAddr)})
+	defer runtime.DomainPortsMap.Delete(domain)
+
+	cfg := &config.Config{}
+	cfg.CoreProxy.Retry = config.RetryConfig{Enabled: true, MaxAttempts: 2, MaxReplayBody: 1024}
+	config.Set(cfg)
+	transport := &myTransport{conf: cfg, Transport: http.DefaultTransport}
+
+	send := func(method string, body io.Reader, length int64) (*http.Response, error) {
+		req, _ := http.NewRequestWithContext(t.Context(), method, "http://"+deadAddr+"/", body)
+		req.Host = domain
+		req.ContentLength = length
+		req.GetBody = nil
+		return transport.RoundTrip(req)
+	}
+
+	resp, err := send(http.MethodGet, nil, 0)
+	if err != nil {
+		t.Fatalf("get should fail over: %v", err)
+	}
+	_ = resp.Body.Close()
+	if hits.Load() != 1 {
+		t.Fatalf("good upstream hits = %d", hits.Load())
+	}
+
+	// 长度未知的请求体无法重放 不重试
+	_, err = send(http.MethodPost, io.NopCloser(strings.NewReader("payload")), -1)
+	if err == nil || hits.Load() != 1 {
+		t.Fatalf("post without replayable body should not retry: err %v hits %d", err, hits.Load())
+	}
+
+	resp, err = send(http.MethodPost, io.NopCloser(strings.NewReader("payload")), 7)
+	if err != nil {
+		t.Fatalf("replayable post should fail over: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(data) != "payload" || hits.Load() != 2 {
+		t.Fatalf("body = %q, hits = %d", data, hits.Load())
+	}
+}
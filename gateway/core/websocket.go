@@ -0,0 +1,122 @@
+package core
+
+import (
+	"Hamburger/gateway/balancer"
+	"Hamburger/gateway/breaker"
+	"Hamburger/gateway/metrics"
+	"Hamburger/gateway/reqinfo"
+	"Hamburger/gateway/stat"
+	"Hamburger/gateway/upstreamtls"
+	"Hamburger/gateway/websocket"
+	"Hamburger/internal/config"
+	"Hamburger/internal/constant"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// websocketHandler WebSocket升级请求不经过ReverseProxy 由网关完成握手后按帧转发
+// 两种转发模式都使用同一套逻辑 普通请求交给next处理
+func websocketHandler(cfg *config.Config, logger *zerolog.Logger, next http.Handler) http.Handler {
+	director := ProxyDirector(cfg, logger)
+	errorHandler := ProxyErrorHandler(logger)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !websocket.IsUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		outreq := r.Clone(r.Context())
+		director(outreq)
+		if outreq.URL == nil || outreq.URL.Scheme == constant.SchemeSandwich || outreq.URL.Host == "" {
+			errorHandler(w, outreq, nil)
+			return
+		}
+		outreq.RequestURI = ""
+		if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			if prior := outreq.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+				ip = prior[len(prior)-1] + ", " + ip
+			}
+			outreq.Header.Set("X-Forwarded-For", ip)
+		}
+
+		opts := websocket.OptionsFrom(cfg.Features.WebSocket)
+		info := reqinfo.From(r.Context())
+		start := time.Now()
+		done := balancer.Track(outreq.URL.Host)
+		var tlsConfig *tls.Config
+		if profile := upstreamtls.Lookup(outreq.Host, outreq.URL.Host); profile != nil {
+			tlsConfig = profile.TLSConfig()
+		}
+		upstream, resp, err := websocket.Dial(outreq, opts.BufferSize, tlsConfig)
+		done(err)
+		info.AddUpstreamTime(time.Since(start))
+		reportWebsocket(outreq, resp, err)
+		if err != nil {
+			logger.Debug().Err(err).Str("Host", r.Host).Str("Upstream", outreq.URL.Host).Msg("websocket handshake failed")
+			errorHandler(w, outreq, err)
+			return
+		}
+
+		// 上游拒绝升级 原样返回响应
+		if upstream == nil {
+			copyHeader(w.Header(), resp.Header)
+			w.WriteHeader(resp.StatusCode)
+			_, _ = io.Copy(w, resp.Body)
+			return
+		}
+
+		counter := stat.WebSocket(r.Host)
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			logger.Error().Err(err).Str("Host", r.Host).Msg("websocket hijack failed")
+			_ = upstream.Conn.Close()
+			counter.Reject()
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// 网关设置的自定义响应头一并返回
+		copyHeader(resp.Header, w.Header())
+		resp.Body = nil
+		_ = conn.SetWriteDeadline(time.Now().Add(websocket.HandshakeTimeout))
+		if err = resp.Write(brw); err == nil {
+			err = brw.Flush()
+		}
+		if sw, ok := w.(*statusWriter); ok {
+			sw.status = http.StatusSwitchingProtocols
+		}
+		if err != nil {
+			_ = conn.Close()
+			_ = upstream.Conn.Close()
+			return
+		}
+		logger.Debug().Str("Host", r.Host).Str("Upstream", outreq.URL.Host).Msg("websocket connection established")
+		websocket.Relay(conn, brw.Reader, upstream, opts, counter)
+	})
+}
+
+// reportWebsocket 握手结果回报给熔断器 规则与普通请求一致
+func reportWebsocket(req *http.Request, resp *http.Response, err error) {
+	success := err == nil && resp != nil && resp.StatusCode < http.StatusInternalServerError
+	breaker.Report(req.Host, req.URL.Host, success)
+	if err != nil {
+		metrics.UpstreamError(req.Host, req.URL.Host, "error")
+	} else if !success {
+		metrics.UpstreamError(req.Host, req.URL.Host, "5xx")
+	}
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, vv := range src {
+		if _, ok := dst[k]; ok {
+			continue
+		}
+		for _, v := range vv {
+			dst.Add(k, v)
+		}
+	}
+}
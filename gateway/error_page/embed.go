@@ -7,4 +7,6 @@ var (
 	ForbiddenPage []byte
 	//go:embed static/unavailable.html
 	UnavailablePage []byte
+	//go:embed static/client_cert.html
+	ClientCertPage []byte
 )
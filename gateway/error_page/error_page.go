@@ -22,17 +22,20 @@ import (
 var (
 	ForbiddenPageGzip   []byte
 	UnavailablePageGzip []byte
+	ClientCertPageGzip  []byte
 )
 
 const (
 	Forbidden = iota
 	Unavailable
+	ClientCert // 客户端证书缺失或校验失败
 	Other
 )
 
 var CodeMap = map[int][]byte{
 	Forbidden:   ForbiddenPage,
 	Unavailable: UnavailablePage,
+	ClientCert:  ClientCertPage,
 	Other:       []byte(serror.ERRORSendProxy),
 }
 
@@ -52,6 +55,9 @@ func Cache(code int, w http.ResponseWriter, r *http.Request, resType int) {
 	case Unavailable:
 		writeResponse(w, r, Unavailable)
 		return
+	case ClientCert:
+		writeResponse(w, r, ClientCert)
+		return
 	default:
 		writeResponse(w, r, Other)
 		return
@@ -95,5 +101,9 @@ func InitErrorPageCache() {
 	if err != nil {
 		logger.GetLogger().Error().Err(err).Msg("compress UnavailablePage error")
 	}
+	ClientCertPageGzip, err = compressData(ClientCertPage)
+	if err != nil {
+		logger.GetLogger().Error().Err(err).Msg("compress ClientCertPage error")
+	}
 	logger.GetLogger().Info().Msg("gzip cache initialized")
 }
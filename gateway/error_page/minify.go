@@ -54,5 +54,8 @@ func getGzipCache(t int) []byte {
 	if t == Unavailable {
 		return UnavailablePageGzip
 	}
+	if t == ClientCert {
+		return ClientCertPageGzip
+	}
 	return nil
 }
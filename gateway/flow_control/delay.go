@@ -0,0 +1,77 @@
+package flow
+
+import (
+	"context"
+	"time"
+)
+
+// delay动作 超出限制的请求进入有界队列等待额度释放 而不是直接拒绝
+// 队列已满或预计等待时间超过最长等待时间时仍然拒绝
+
+const (
+	ActionBlock = "block"
+	ActionDelay = "delay"
+
+	DefaultMaxDelay  = 1000 // 毫秒
+	DefaultQueueSize = 100
+
+	minDelayStep = 10 * time.Millisecond
+)
+
+// delayQueue 单条规则的等待队列
+type delayQueue struct {
+	slots   chan struct{}
+	maxWait time.Duration
+}
+
+func newDelayQueue(maxDelay, queueSize int) *delayQueue {
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxDelay
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	return &delayQueue{
+		slots:   make(chan struct{}, queueSize),
+		maxWait: time.Duration(maxDelay) * time.Millisecond,
+	}
+}
+
+// wait 排队等待限流器放行 返回是否放行、最新的限流状态和等待的时间
+func (q *delayQueue) wait(ctx context.Context, rl *RateLimiter, key string, state LimitState) (bool, LimitState, time.Duration) {
+	select {
+	case q.slots <- struct{}{}:
+	default:
+		return false, state, 0
+	}
+	defer func() { <-q.slots }()
+
+	start := time.Now()
+	deadline := start.Add(q.maxWait)
+	for {
+		step := max(state.Reset, minDelayStep)
+		if step > time.Until(deadline) {
+			return false, state, time.Since(start)
+		}
+		timer := time.NewTimer(step)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false, state, time.Since(start)
+		case <-timer.C:
+		}
+		allowed, current := rl.Allow(key)
+		state = current
+		if allowed {
+			return true, state, time.Since(start)
+		}
+	}
+}
+
+// queued 正在等待的请求数
+func (q *delayQueue) queued() int {
+	if q == nil {
+		return 0
+	}
+	return len(q.slots)
+}
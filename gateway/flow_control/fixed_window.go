@@ -41,6 +41,17 @@ func (l *FixedWindowLimiter) Allow() bool {
 	return false
 }
 
+func (l *FixedWindowLimiter) State() LimitState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.After(l.resetTime) {
+		return LimitState{Limit: l.limit, Remaining: l.limit}
+	}
+	return LimitState{Limit: l.limit, Remaining: max(l.limit-l.count, 0), Reset: l.resetTime.Sub(now)}
+}
+
 func (l *FixedWindowLimiter) LastAccess() time.Time {
 	l.mu.Lock()
 	defer l.mu.Unlock()
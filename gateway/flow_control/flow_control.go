@@ -6,6 +6,7 @@ Created: 2021/12/14 by Landers
 package flow
 
 import (
+	"Hamburger/gateway/clientip"
 	"Hamburger/internal/config"
 	"Hamburger/internal/logger"
 	"fmt"
@@ -19,13 +20,19 @@ import (
 
 // 流量控制
 // 在访问的请求数超出限制时 禁止当前客户端请求
-// 支持基于host、请求头的精准匹配和多时间范围流控
+// 支持基于host、请求头、IP、路径、方法、查询参数和Cookie的组合匹配和多时间范围流控
+// 超出限制时按规则动作直接拒绝或排队等待
 
 var flowController *FlowController
 
-func InitLimiter() {
-	flowController = NewFlowController()
+func InitLimiter() error {
+	fc, err := NewFlowController()
+	if err != nil {
+		return err
+	}
+	flowController = fc
 	go flowController.CleanupExpiredRecords()
+	return nil
 }
 
 func GetLimiter() *FlowController {
@@ -37,14 +44,19 @@ type FlowController struct {
 	logger        *zerolog.Logger
 	globalLimiter *RateLimiter
 	ruleLimiters  map[string]*RateLimiter
+	rules         []*compiledRule // 按优先级排序的启用规则
 	mux           sync.RWMutex
 	config        *config.FlowControlConfig
+	shared        *sharedConfig // 共享计数存储 内存存储时为nil
+	stop          chan struct{}
+	stopOnce      sync.Once
 }
 
 // RateLimiter 多时间窗口速率限制器
 type RateLimiter struct {
 	rules    []ParsedRule
 	limiters map[string][]LimiterStrategy
+	shared   *sharedConfig // 配置共享计数存储时使用共享限流器
 	mux      sync.RWMutex
 }
 
@@ -57,39 +69,79 @@ type ParsedRule struct {
 // LimiterStrategy 限流策略接口
 type LimiterStrategy interface {
 	Allow() bool
+	State() LimitState
 	LastAccess() time.Time
 }
 
+// LimitState 限流器的额度状态
+type LimitState struct {
+	Limit     int           // 窗口内允许的请求数
+	Remaining int           // 剩余额度
+	Reset     time.Duration // 距离释放下一个额度的时间 有剩余额度时为0
+}
+
 // FlowCheckResult 流控检查结果
 type FlowCheckResult struct {
 	Allowed     bool
 	RuleName    string
 	MatchedRule *config.FlowControlRule
 	Reason      string
+	State       LimitState    // 触发限流的限流器状态
+	Delayed     time.Duration // delay动作排队等待的时间
+}
+
+// compiledRule 编译后的流控规则
+type compiledRule struct {
+	config.FlowControlRule
+	conds   []condition
+	limiter *RateLimiter
+	delay   *delayQueue // delay动作的等待队列 block动作为nil
 }
 
 // NewFlowController 创建流量控制器
-func NewFlowController() *FlowController {
+// 规则或存储配置无效时返回错误 不能静默地丢弃限流规则
+func NewFlowController() (*FlowController, error) {
 	cfg := config.Get().Features.FlowControl
+	if err := Validate(cfg); err != nil {
+		return nil, err
+	}
 	fc := &FlowController{
 		logger:       logger.L(),
 		ruleLimiters: make(map[string]*RateLimiter),
 		config:       &cfg,
+		stop:         make(chan struct{}),
 	}
 
 	// 初始化全局限流器
 	if cfg.Enabled {
+		store, err := NewCounterStore(cfg.Store, config.Get().Database.Redis)
+		if err != nil {
+			return nil, fmt.Errorf("flow control store: %w", err)
+		}
+		if store != nil {
+			fc.shared = newSharedConfig(store, cfg.Store.ErrorBound, cfg.Store.SyncInterval)
+		}
 		fc.globalLimiter = fc.createRateLimiter([]config.RateLimit{cfg.GlobalLimit})
 
 		// 初始化规则限流器
-		for _, rule := range cfg.Rules {
-			if rule.Enabled {
-				fc.ruleLimiters[rule.Name] = fc.createRateLimiter(rule.Limits)
+		for _, rule := range fc.getSortedRules() {
+			if !rule.Enabled {
+				continue
+			}
+			conds, err := compileConditions(rule)
+			if err != nil {
+				return nil, err
+			}
+			cr := &compiledRule{FlowControlRule: rule, conds: conds, limiter: fc.createRateLimiter(rule.Limits)}
+			if rule.Action == ActionDelay {
+				cr.delay = newDelayQueue(rule.MaxDelay, rule.QueueSize)
 			}
+			fc.rules = append(fc.rules, cr)
+			fc.ruleLimiters[rule.Name] = cr.limiter
 		}
 	}
 
-	return fc
+	return fc, nil
 }
 
 // createRateLimiter 创建速率限制器
@@ -97,15 +149,21 @@ func (fc *FlowController) createRateLimiter(limits []config.RateLimit) *RateLimi
 	rl := &RateLimiter{
 		rules:    make([]ParsedRule, 0, len(limits)),
 		limiters: make(map[string][]LimiterStrategy),
+		shared:   fc.shared,
 	}
 
 	for _, limit := range limits {
-		duration, err := fc.parseDuration(limit.Window, limit.Unit)
+		duration, err := parseWindow(limit.Window, limit.Unit)
 		if err != nil {
 			fc.logger.Error().Err(err).Str("Windows", limit.Window).Str("Unit", limit.Unit).
 				Msg("Invalid duration format")
 			continue
 		}
+		if fc.shared != nil && duration < minSharedWindow {
+			fc.logger.Error().Str("Windows", limit.Window).Str("Unit", limit.Unit).
+				Msg("Shared store window must be at least 1ms")
+			continue
+		}
 		rl.rules = append(rl.rules, ParsedRule{
 			Requests: limit.Requests,
 			Window:   duration,
@@ -116,8 +174,8 @@ func (fc *FlowController) createRateLimiter(limits []config.RateLimit) *RateLimi
 	return rl
 }
 
-// parseDuration 解析时间持续时间，支持s和min单位
-func (fc *FlowController) parseDuration(window, unit string) (time.Duration, error) {
+// parseWindow 解析时间持续时间，支持s和min单位
+func parseWindow(window, unit string) (time.Duration, error) {
 	value, err := strconv.Atoi(window)
 	if err != nil {
 		// 尝试从 window 中提取数字
@@ -154,23 +212,26 @@ func (fc *FlowController) CheckRequest(req *http.Request) *FlowCheckResult {
 	}
 
 	// 按优先级检查规则
-	for _, rule := range fc.getSortedRules() {
-		if !rule.Enabled {
+	var delayed time.Duration
+	for _, rule := range fc.rules {
+		if !rule.match(req) {
 			continue
 		}
-
-		if fc.matchRule(req, &rule) {
-			limiter := fc.ruleLimiters[rule.Name]
-			if limiter != nil {
-				key := fc.generateKey(req, &rule)
-				if !limiter.Allow(key) {
-					return &FlowCheckResult{
-						Allowed:     false,
-						RuleName:    rule.Name,
-						MatchedRule: &rule,
-						Reason:      fmt.Sprintf("Rule '%s' rate limit exceeded", rule.Name),
-					}
-				}
+		key := rule.key(req)
+		allowed, state := rule.limiter.Allow(key)
+		if !allowed && rule.delay != nil {
+			var waited time.Duration
+			allowed, state, waited = rule.delay.wait(req.Context(), rule.limiter, key, state)
+			delayed += waited
+		}
+		if !allowed {
+			return &FlowCheckResult{
+				Allowed:     false,
+				RuleName:    rule.Name,
+				MatchedRule: &rule.FlowControlRule,
+				Reason:      fmt.Sprintf("Rule '%s' rate limit exceeded", rule.Name),
+				State:       state,
+				Delayed:     delayed,
 			}
 		}
 	}
@@ -178,15 +239,17 @@ func (fc *FlowController) CheckRequest(req *http.Request) *FlowCheckResult {
 	// 检查全局限流
 	if fc.globalLimiter != nil {
 		key := fc.generateGlobalKey(req)
-		if !fc.globalLimiter.Allow(key) {
+		if allowed, state := fc.globalLimiter.Allow(key); !allowed {
 			return &FlowCheckResult{
 				Allowed: false,
 				Reason:  "Global rate limit exceeded",
+				State:   state,
+				Delayed: delayed,
 			}
 		}
 	}
 
-	return &FlowCheckResult{Allowed: true}
+	return &FlowCheckResult{Allowed: true, Delayed: delayed}
 }
 
 // getSortedRules 获取按优先级排序的规则
@@ -206,36 +269,28 @@ func (fc *FlowController) getSortedRules() []config.FlowControlRule {
 	return rules
 }
 
-// matchRule 匹配规则
-func (fc *FlowController) matchRule(req *http.Request, rule *config.FlowControlRule) bool {
-	switch rule.MatchType {
-	case "host":
-		return req.Host == rule.MatchValue || strings.Contains(req.Host, rule.MatchValue)
-	case "header":
-		headerValue := req.Header.Get(rule.HeaderKey)
-		return headerValue == rule.MatchValue || strings.Contains(headerValue, rule.MatchValue)
-	case "ip":
-		clientIP := fc.getClientIP(req)
-		return clientIP == rule.MatchValue
-	default:
-		return false
+// match 请求是否满足规则的全部条件
+func (r *compiledRule) match(req *http.Request) bool {
+	for i := range r.conds {
+		if !r.conds[i].match(req) {
+			return false
+		}
 	}
+	return true
 }
 
-// generateKey 生成限流key
-func (fc *FlowController) generateKey(req *http.Request, rule *config.FlowControlRule) string {
-	switch rule.MatchType {
-	case "host":
-		return fmt.Sprintf("rule:%s:host:%s", rule.Name, req.Host)
-	case "header":
-		headerValue := req.Header.Get(rule.HeaderKey)
-		return fmt.Sprintf("rule:%s:header:%s:%s", rule.Name, rule.HeaderKey, headerValue)
-	case "ip":
-		clientIP := fc.getClientIP(req)
-		return fmt.Sprintf("rule:%s:ip:%s", rule.Name, clientIP)
-	default:
-		return fmt.Sprintf("rule:%s:unknown", rule.Name)
+// key 生成限流key 由规则名和各条件中区分请求的值组成
+func (r *compiledRule) key(req *http.Request) string {
+	var b strings.Builder
+	b.WriteString("rule:")
+	b.WriteString(r.Name)
+	for i := range r.conds {
+		if part := r.conds[i].keyPart(req); part != "" {
+			b.WriteByte(':')
+			b.WriteString(part)
+		}
 	}
+	return b.String()
 }
 
 // generateGlobalKey 生成全局限流key
@@ -244,49 +299,29 @@ func (fc *FlowController) generateGlobalKey(req *http.Request) string {
 	return fmt.Sprintf("global:ip:%s", clientIP)
 }
 
-// getClientIP 获取客户端IP
+// getClientIP 获取客户端IP 只信任可信代理传递的客户端IP请求头
 func (fc *FlowController) getClientIP(req *http.Request) string {
-	// 检查 X-Forwarded-For
-	if xff := req.Header.Get("X-Forwarded-For"); xff != "" {
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// 检查 X-Real-IP
-	if xri := req.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-
-	// 使用 RemoteAddr
-	ip := req.RemoteAddr
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
-	}
-	return ip
+	return clientip.String(req)
 }
 
-// Allow 检查是否允许请求
-func (rl *RateLimiter) Allow(key string) bool {
+// Allow 检查是否允许请求 返回剩余额度最少的限流器状态 拒绝时为拒绝请求的限流器状态
+func (rl *RateLimiter) Allow(key string) (bool, LimitState) {
+	// 检查策略是否存在，不存在则创建 策略各自加锁 共享存储的网络请求不阻塞其他key
 	rl.mux.Lock()
-	defer rl.mux.Unlock()
-
-	// 检查策略是否存在，不存在则创建
 	strategies, exists := rl.limiters[key]
 	if !exists {
 		strategies = make([]LimiterStrategy, 0, len(rl.rules))
 		for _, rule := range rl.rules {
 			var strategy LimiterStrategy
-			switch rule.Mode {
-			case "fixed":
+			switch {
+			case rl.shared != nil:
+				strategy = rl.shared.newLimiter(fmt.Sprintf("%s:%d/%s", key, rule.Requests, rule.Window), rule)
+			case rule.Mode == "fixed":
 				strategy = NewFixedWindowLimiter(rule.Requests, rule.Window)
-			case "leaky":
+			case rule.Mode == "leaky":
 				strategy = NewLeakyBucketLimiter(rule.Requests, rule.Window)
-			case "token":
+			case rule.Mode == "token":
 				strategy = NewTokenBucketLimiter(rule.Requests, rule.Window)
-			case "sliding":
-				fallthrough
 			default:
 				strategy = NewSlidingWindowLimiter(rule.Requests, rule.Window)
 			}
@@ -294,15 +329,20 @@ func (rl *RateLimiter) Allow(key string) bool {
 		}
 		rl.limiters[key] = strategies
 	}
+	rl.mux.Unlock()
 
 	// 检查所有策略
-	for _, strategy := range strategies {
+	var state LimitState
+	for i, strategy := range strategies {
 		if !strategy.Allow() {
-			return false
+			return false, strategy.State()
+		}
+		if current := strategy.State(); i == 0 || current.Remaining < state.Remaining {
+			state = current
 		}
 	}
 
-	return true
+	return true, state
 }
 
 // CleanupExpiredRecords 定期清理过期记录
@@ -310,7 +350,12 @@ func (fc *FlowController) CleanupExpiredRecords() {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-fc.stop:
+			return
+		case <-ticker.C:
+		}
 		fc.mux.RLock()
 		if fc.globalLimiter != nil {
 			fc.globalLimiter.cleanup()
@@ -322,6 +367,16 @@ func (fc *FlowController) CleanupExpiredRecords() {
 	}
 }
 
+// Stop 停止后台清理任务 配置重载替换流控器时调用
+func (fc *FlowController) Stop() {
+	fc.stopOnce.Do(func() {
+		close(fc.stop)
+		if fc.shared != nil {
+			_ = fc.shared.store.Close()
+		}
+	})
+}
+
 // cleanup 清理限流器中的过期记录
 func (rl *RateLimiter) cleanup() {
 	rl.mux.Lock()
@@ -350,3 +405,74 @@ func (rl *RateLimiter) cleanup() {
 		}
 	}
 }
+
+// Status 流控运行状态
+type Status struct {
+	Enabled     bool             `json:"enabled"`
+	Store       string           `json:"store"` // 计数存储类型
+	GlobalLimit config.RateLimit `json:"global_limit"`
+	GlobalKeys  int              `json:"global_keys"` // 全局限流器跟踪的客户端数
+	Rules       []RuleStatus     `json:"rules"`
+}
+
+// RuleStatus 流控规则运行状态
+type RuleStatus struct {
+	config.FlowControlRule
+	Keys   int `json:"keys"`   // 规则限流器跟踪的客户端数
+	Queued int `json:"queued"` // delay动作正在等待的请求数
+}
+
+// Snapshot 获取流控规则和限流器状态
+func (fc *FlowController) Snapshot() Status {
+	fc.mux.RLock()
+	defer fc.mux.RUnlock()
+
+	status := Status{
+		Enabled:     fc.config.Enabled,
+		Store:       fc.storeType(),
+		GlobalLimit: fc.config.GlobalLimit,
+		GlobalKeys:  fc.globalLimiter.size(),
+		Rules:       make([]RuleStatus, 0, len(fc.config.Rules)),
+	}
+	for _, rule := range fc.getSortedRules() {
+		status.Rules = append(status.Rules, RuleStatus{
+			FlowControlRule: rule,
+			Keys:            fc.ruleLimiters[rule.Name].size(),
+			Queued:          fc.rule(rule.Name).queued(),
+		})
+	}
+	return status
+}
+
+func (rl *RateLimiter) size() int {
+	if rl == nil {
+		return 0
+	}
+	rl.mux.RLock()
+	defer rl.mux.RUnlock()
+	return len(rl.limiters)
+}
+
+// rule 按名称查找启用的规则
+func (fc *FlowController) rule(name string) *compiledRule {
+	for _, r := range fc.rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func (r *compiledRule) queued() int {
+	if r == nil {
+		return 0
+	}
+	return r.delay.queued()
+}
+
+func (fc *FlowController) storeType() string {
+	if fc.shared == nil {
+		return StoreMemory
+	}
+	return StoreRedis
+}
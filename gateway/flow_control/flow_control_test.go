@@ -0,0 +1,176 @@
+package flow
+
+import (
+	"Hamburger/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestController(t *testing.T, rules ...config.FlowControlRule) *FlowController {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Features.FlowControl = config.FlowControlConfig{Enabled: true, Rules: rules}
+	config.Set(cfg)
+	fc, err := NewFlowController()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fc.Stop)
+	return fc
+}
+
+func TestCombinedMatch(t *testing.T) {
+	fc := newTestController(t, config.FlowControlRule{
+		Name:    "api-post",
+		Enabled: true,
+		Match: []config.FlowMatch{
+			{Type: MatchPathPrefix, Value: "/api/"},
+			{Type: MatchMethod, Value: "post,put"},
+			{Type: MatchQuery, Key: "token"},
+		},
+		Limits: []config.RateLimit{{Requests: 1, Window: "60", Unit: "s", Mode: "fixed"}},
+	})
+
+	check := func(method, target string) *FlowCheckResult {
+		return fc.CheckRequest(httptest.NewRequest(method, target, nil))
+	}
+	if !check("POST", "/api/order?token=a").Allowed {
+		t.Fatal("first request should pass")
+	}
+	result := check("POST", "/api/order?token=a")
+	if result.Allowed || result.RuleName != "api-post" {
+		t.Fatalf("second request should be limited: %+v", result)
+	}
+	if result.State.Limit != 1 || result.State.Remaining != 0 || result.State.Reset <= 0 {
+		t.Fatalf("unexpected state: %+v", result.State)
+	}
+	// 查询参数的值区分计数
+	if !check("POST", "/api/order?token=b").Allowed {
+		t.Fatal("different token should have its own quota")
+	}
+	// 不满足全部条件时不匹配
+	for _, target := range []string{"/api/order", "/static/a?token=a"} {
+		if !check("POST", target).Allowed {
+			t.Fatalf("%s should not match", target)
+		}
+	}
+	if !check("GET", "/api/order?token=a").Allowed {
+		t.Fatal("GET should not match")
+	}
+}
+
+func TestCookieAndRegexMatch(t *testing.T) {
+	fc := newTestController(t, config.FlowControlRule{
+		Name:    "login",
+		Enabled: true,
+		Match: []config.FlowMatch{
+			{Type: MatchPathRegex, Value: `^/(login|signin)$`},
+			{Type: MatchCookie, Key: "session"},
+		},
+		Limits: []config.RateLimit{{Requests: 1, Window: "60", Unit: "s", Mode: "sliding"}},
+	})
+
+	req := func(path string, cookie bool) *http.Request {
+		r := httptest.NewRequest("GET", path, nil)
+		if cookie {
+			r.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+		}
+		return r
+	}
+	if !fc.CheckRequest(req("/login", true)).Allowed || fc.CheckRequest(req("/login", true)).Allowed {
+		t.Fatal("second login with the same session should be limited")
+	}
+	if !fc.CheckRequest(req("/login", false)).Allowed || !fc.CheckRequest(req("/login/x", true)).Allowed {
+		t.Fatal("requests not matching all conditions should pass")
+	}
+}
+
+func TestDelayAction(t *testing.T) {
+	rule := config.FlowControlRule{
+		Name:      "delay",
+		Enabled:   true,
+		MatchType: MatchHost,
+		Limits:    []config.RateLimit{{Requests: 10, Window: "1", Unit: "s", Mode: "token"}},
+		Action:    ActionDelay,
+		MaxDelay:  500,
+	}
+	fc := newTestController(t, rule)
+
+	for i := 0; i < 10; i++ {
+		fc.CheckRequest(httptest.NewRequest("GET", "/", nil))
+	}
+	// 令牌每100ms恢复一个 在最长等待时间内放行
+	start := time.Now()
+	result := fc.CheckRequest(httptest.NewRequest("GET", "/", nil))
+	if !result.Allowed || result.Delayed <= 0 || time.Since(start) > 450*time.Millisecond {
+		t.Fatalf("request should be delayed then allowed: %+v", result)
+	}
+
+	// 预计等待时间超过最长等待时间时直接拒绝
+	rule.MaxDelay = 20
+	rule.Limits = []config.RateLimit{{Requests: 1, Window: "60", Unit: "s", Mode: "fixed"}}
+	fc = newTestController(t, rule)
+	fc.CheckRequest(httptest.NewRequest("GET", "/", nil))
+	if result := fc.CheckRequest(httptest.NewRequest("GET", "/", nil)); result.Allowed {
+		t.Fatal("request exceeding max delay should be rejected")
+	}
+}
+
+func TestValidateRuleMatch(t *testing.T) {
+	rule := func(name string, match ...config.FlowMatch) config.FlowControlRule {
+		return config.FlowControlRule{
+			Name:    name,
+			Enabled: true,
+			Match:   match,
+			Limits:  []config.RateLimit{{Requests: 1, Window: "1", Unit: "s"}},
+		}
+	}
+	legacy := rule("legacy-ip")
+	legacy.MatchType = MatchIP
+
+	cases := []struct {
+		rule config.FlowControlRule
+		want string
+	}{
+		{legacy, "ip match requires value"},
+		{rule("bad-regex", config.FlowMatch{Type: MatchPathRegex, Value: "("}), "bad-regex"},
+	}
+	for _, c := range cases {
+		err := Validate(config.FlowControlConfig{Enabled: true, Rules: []config.FlowControlRule{c.rule}})
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: unexpected error %v", c.rule.Name, err)
+		}
+	}
+
+	// 无效规则不能被跳过 创建流控时直接报错
+	cfg := &config.Config{}
+	cfg.Features.FlowControl = config.FlowControlConfig{Enabled: true, Rules: []config.FlowControlRule{legacy}}
+	config.Set(cfg)
+	if _, err := NewFlowController(); err == nil || !strings.Contains(err.Error(), "ip match requires value") {
+		t.Fatalf("invalid rule should fail, got %v", err)
+	}
+}
+
+func TestInvalidStore(t *testing.T) {
+	cases := []struct {
+		store config.FlowStoreConfig
+		want  string
+	}{
+		{config.FlowStoreConfig{Type: "etcd"}, "unknown flow control store"},
+		{config.FlowStoreConfig{Type: StoreRedis}, "requires database.redis.addr"},
+	}
+	for _, c := range cases {
+		cfg := &config.Config{}
+		cfg.Features.FlowControl = config.FlowControlConfig{Enabled: true, Store: c.store}
+		config.Set(cfg)
+		if _, err := NewFlowController(); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: unexpected error %v", c.store.Type, err)
+		}
+	}
+	if err := Validate(config.FlowControlConfig{Enabled: true, Store: config.FlowStoreConfig{Type: "etcd"}}); err == nil {
+		t.Fatal("validate should reject unknown store")
+	}
+}
@@ -9,13 +9,13 @@ Copyright Renj
 package flow
 
 import (
+	"Hamburger/gateway/clientip"
 	"Hamburger/internal/config"
 	"Hamburger/internal/data"
 	"Hamburger/internal/json"
 	"Hamburger/internal/logger"
 	"net/http"
 	"os"
-	"strings"
 	"sync"
 	"time"
 )
@@ -89,6 +89,16 @@ func (fr *FlowRecorder) RecordBlocked(req *http.Request, result *FlowCheckResult
 		Str("Rule", record.RuleName).Str("Reason", record.Reason).Msg("Flow blocked")
 }
 
+// RecordRule 记录被其他规则(如WAF)命中的请求 与被限流的请求使用相同的开关和存储
+func (fr *FlowRecorder) RecordRule(req *http.Request, status, ruleName, reason string) {
+	if !fr.config.Enabled || !fr.config.RecordBlocked {
+		return
+	}
+
+	record := fr.createRecord(req, status, ruleName, reason)
+	fr.addRecord(record)
+}
+
 // RecordAllowed 记录通过的请求
 func (fr *FlowRecorder) RecordAllowed(req *http.Request) {
 	if !fr.config.Enabled || !fr.config.RecordAllowed {
@@ -132,28 +142,9 @@ func (fr *FlowRecorder) createRecord(req *http.Request, status, ruleName, reason
 	}
 }
 
-// getClientIP 获取客户端IP
+// getClientIP 获取客户端IP 只信任可信代理传递的客户端IP请求头
 func (fr *FlowRecorder) getClientIP(req *http.Request) string {
-	// 检查 X-Forwarded-For
-	if xff := req.Header.Get("X-Forwarded-For"); xff != "" {
-		// 取第一个IP
-		if idx := strings.Index(xff, ","); idx != -1 {
-			return strings.TrimSpace(xff[:idx])
-		}
-		return strings.TrimSpace(xff)
-	}
-
-	// 检查 X-Real-IP
-	if xri := req.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-
-	// 使用 RemoteAddr
-	ip := req.RemoteAddr
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
-	}
-	return ip
+	return clientip.String(req)
 }
 
 // addRecord 添加记录到缓冲区
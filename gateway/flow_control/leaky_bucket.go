@@ -50,6 +50,18 @@ func (l *LeakyBucketLimiter) Allow() bool {
 	return false
 }
 
+func (l *LeakyBucketLimiter) State() LimitState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	water := max(l.water-time.Since(l.lastLeak).Seconds()*l.rate, 0)
+	state := LimitState{Limit: int(l.capacity), Remaining: int(l.capacity - water)}
+	if water+1 > l.capacity && l.rate > 0 {
+		state.Reset = time.Duration((water + 1 - l.capacity) / l.rate * float64(time.Second))
+	}
+	return state
+}
+
 func (l *LeakyBucketLimiter) LastAccess() time.Time {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -0,0 +1,153 @@
+package flow
+
+import (
+	"Hamburger/gateway/clientip"
+	"Hamburger/internal/config"
+	"Hamburger/internal/structure"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// 匹配类型
+const (
+	MatchHost       = "host"
+	MatchHeader     = "header"
+	MatchIP         = "ip"
+	MatchPathPrefix = "path_prefix"
+	MatchPathRegex  = "path_regex"
+	MatchMethod     = "method"
+	MatchQuery      = "query"
+	MatchCookie     = "cookie"
+)
+
+// condition 编译后的匹配条件
+type condition struct {
+	config.FlowMatch
+	regex   *regexp.Regexp
+	ips     *structure.IPTrie
+	methods []string
+}
+
+// ruleConditions 规则的匹配条件 未配置组合条件时使用match_type和match_value
+func ruleConditions(rule config.FlowControlRule) ([]config.FlowMatch, error) {
+	if len(rule.Match) > 0 {
+		return rule.Match, nil
+	}
+	if rule.MatchType == "" {
+		return nil, fmt.Errorf("flow control rule %s: match type is empty", rule.Name)
+	}
+	// 单独的ip条件为空时会匹配所有客户端 组合条件中为空表示按客户端IP分别计数
+	if rule.MatchType == MatchIP && rule.MatchValue == "" {
+		return nil, fmt.Errorf("flow control rule %s: ip match requires value", rule.Name)
+	}
+	return []config.FlowMatch{{Type: rule.MatchType, Key: rule.HeaderKey, Value: rule.MatchValue}}, nil
+}
+
+// compileConditions 编译匹配条件
+func compileConditions(rule config.FlowControlRule) ([]condition, error) {
+	matches, err := ruleConditions(rule)
+	if err != nil {
+		return nil, err
+	}
+	conds := make([]condition, 0, len(matches))
+	for _, m := range matches {
+		c := condition{FlowMatch: m}
+		switch m.Type {
+		case MatchHost:
+		case MatchHeader, MatchQuery, MatchCookie:
+			if m.Key == "" {
+				return nil, fmt.Errorf("flow control rule %s: %s match requires key", rule.Name, m.Type)
+			}
+		case MatchIP:
+			if m.Value != "" {
+				if c.ips, err = structure.NewIPTrieFrom(strings.Split(m.Value, ",")); err != nil {
+					return nil, fmt.Errorf("flow control rule %s: %w", rule.Name, err)
+				}
+			}
+		case MatchPathPrefix:
+			if m.Value == "" {
+				return nil, fmt.Errorf("flow control rule %s: path prefix is empty", rule.Name)
+			}
+		case MatchPathRegex:
+			if c.regex, err = regexp.Compile(m.Value); err != nil {
+				return nil, fmt.Errorf("flow control rule %s: %w", rule.Name, err)
+			}
+		case MatchMethod:
+			for _, method := range strings.Split(m.Value, ",") {
+				if method = strings.TrimSpace(method); method != "" {
+					c.methods = append(c.methods, strings.ToUpper(method))
+				}
+			}
+			if len(c.methods) == 0 {
+				return nil, fmt.Errorf("flow control rule %s: method is empty", rule.Name)
+			}
+		default:
+			return nil, fmt.Errorf("flow control rule %s: unknown match type %s", rule.Name, m.Type)
+		}
+		conds = append(conds, c)
+	}
+	return conds, nil
+}
+
+// match 请求是否满足条件
+func (c *condition) match(req *http.Request) bool {
+	switch c.Type {
+	case MatchHost:
+		return strings.Contains(req.Host, c.Value)
+	case MatchHeader:
+		values := req.Header.Values(c.Key)
+		if len(values) == 0 {
+			return false
+		}
+		return strings.Contains(values[0], c.Value)
+	case MatchIP:
+		return c.ips == nil || c.ips.Contains(clientip.Get(req))
+	case MatchPathPrefix:
+		return strings.HasPrefix(req.URL.Path, c.Value)
+	case MatchPathRegex:
+		return c.regex.MatchString(req.URL.Path)
+	case MatchMethod:
+		for _, method := range c.methods {
+			if req.Method == method {
+				return true
+			}
+		}
+		return false
+	case MatchQuery:
+		values, ok := req.URL.Query()[c.Key]
+		if !ok {
+			return false
+		}
+		return c.Value == "" || (len(values) > 0 && values[0] == c.Value)
+	case MatchCookie:
+		cookie, err := req.Cookie(c.Key)
+		if err != nil {
+			return false
+		}
+		return c.Value == "" || cookie.Value == c.Value
+	}
+	return false
+}
+
+// keyPart 条件在限流key中的部分 路径和方法条件不区分请求 所有命中的请求共享计数
+func (c *condition) keyPart(req *http.Request) string {
+	switch c.Type {
+	case MatchHost:
+		return "host:" + req.Host
+	case MatchHeader:
+		return "header:" + c.Key + ":" + req.Header.Get(c.Key)
+	case MatchIP:
+		return "ip:" + clientip.String(req)
+	case MatchQuery:
+		return "query:" + c.Key + ":" + req.URL.Query().Get(c.Key)
+	case MatchCookie:
+		cookie, _ := req.Cookie(c.Key)
+		if cookie == nil {
+			return "cookie:" + c.Key + ":"
+		}
+		return "cookie:" + c.Key + ":" + cookie.Value
+	}
+	return ""
+}
@@ -0,0 +1,171 @@
+package flow
+
+import (
+	"Hamburger/internal/logger"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SharedLimiter 基于共享计数存储的限流
+// fixed模式使用固定窗口计数 其他模式使用滑动窗口计数器近似: 上一窗口计数按剩余比例加权后加上当前窗口计数
+// 本地累计的计数达到批量大小(error_bound x 限制数)或超过同步间隔时写入存储 同时读取其他实例的计数
+// 存储不可用时按最近一次同步结果和本地计数继续限流
+type SharedLimiter struct {
+	shared  *sharedConfig
+	key     string
+	limit   int
+	window  time.Duration
+	sliding bool
+
+	mu         sync.Mutex
+	count      WindowCount // 最近一次同步的窗口计数
+	synced     time.Time
+	pending    int64     // 本地未同步的计数
+	pendingAt  time.Time // 第一个未同步计数的时间
+	lastAccess time.Time
+}
+
+// sharedConfig 同一流控器中共享限流器的公共配置
+type sharedConfig struct {
+	store        CounterStore
+	errorBound   float64
+	syncInterval time.Duration
+	lastErrorLog atomic.Int64
+}
+
+func newSharedConfig(store CounterStore, errorBound float64, syncInterval int) *sharedConfig {
+	if syncInterval <= 0 {
+		syncInterval = DefaultSyncInterval
+	}
+	return &sharedConfig{
+		store:        store,
+		errorBound:   errorBound,
+		syncInterval: time.Duration(syncInterval) * time.Millisecond,
+	}
+}
+
+func (c *sharedConfig) newLimiter(key string, rule ParsedRule) *SharedLimiter {
+	return &SharedLimiter{
+		shared:     c,
+		key:        key,
+		limit:      rule.Requests,
+		window:     rule.Window,
+		sliding:    rule.Mode != "fixed",
+		lastAccess: time.Now(),
+	}
+}
+
+// logError 存储错误每分钟最多记录一次
+func (c *sharedConfig) logError(err error) {
+	now := time.Now().Unix()
+	last := c.lastErrorLog.Load()
+	if now-last < 60 || !c.lastErrorLog.CompareAndSwap(last, now) {
+		return
+	}
+	logger.L().Error().Err(err).Msg("flow control store unavailable, limit locally")
+}
+
+// batch 本地未同步计数的上限
+func (l *SharedLimiter) batch() int64 {
+	return max(int64(l.shared.errorBound*float64(l.limit)), 1)
+}
+
+func (l *SharedLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.lastAccess = now
+	if now.Sub(l.synced) >= l.shared.syncInterval || !windowStart(now, l.window).Equal(l.count.Start) {
+		l.sync(now)
+	}
+	strict := l.batch() == 1
+	if !strict && l.estimate(now)+1 > float64(l.limit) {
+		return false
+	}
+	if l.pending == 0 {
+		l.pendingAt = now
+	}
+	l.pending++
+	if l.pending >= l.batch() {
+		l.sync(now)
+	}
+	// 批量为1时先写入再按写入后的计数判断 被拒绝的请求同样计入窗口
+	return !strict || l.estimate(now) <= float64(l.limit)
+}
+
+// sync 写入本地计数并读取最新的窗口计数
+func (l *SharedLimiter) sync(now time.Time) {
+	store := l.shared.store
+	current := windowStart(now, l.window)
+	if l.pending > 0 && !windowStart(l.pendingAt, l.window).Equal(current) {
+		// 本地计数属于已结束的窗口 单独写入
+		if _, err := store.Add(l.key, l.pendingAt, l.window, l.pending); err != nil {
+			l.shared.logError(err)
+		}
+		l.pending = 0
+	}
+	count, err := store.Add(l.key, now, l.window, l.pending)
+	l.synced = now
+	if err != nil {
+		l.shared.logError(err)
+		return
+	}
+	l.count = count
+	l.pending = 0
+}
+
+// estimate 当前窗口内的请求数估计
+func (l *SharedLimiter) estimate(now time.Time) float64 {
+	start := windowStart(now, l.window)
+	var current, previous int64
+	switch {
+	case l.count.Start.Equal(start):
+		current, previous = l.count.Current, l.count.Previous
+	case l.count.Start.Add(l.window).Equal(start):
+		previous = l.count.Current
+	}
+	if l.pending > 0 {
+		if pendingStart := windowStart(l.pendingAt, l.window); pendingStart.Equal(start) {
+			current += l.pending
+		} else if pendingStart.Equal(start.Add(-l.window)) {
+			previous += l.pending
+		}
+	}
+	if !l.sliding {
+		return float64(current)
+	}
+	weight := 1 - float64(now.Sub(start))/float64(l.window)
+	return float64(previous)*weight + float64(current)
+}
+
+func (l *SharedLimiter) State() LimitState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	start := windowStart(now, l.window)
+	used := l.estimate(now)
+	state := LimitState{Limit: l.limit, Remaining: max(l.limit-int(math.Ceil(used)), 0)}
+	if state.Remaining > 0 {
+		return state
+	}
+	state.Reset = start.Add(l.window).Sub(now)
+	if l.sliding {
+		// 上一窗口的权重线性下降 计算估计值降到限制以下的时间
+		current := used - float64(l.count.Previous)*(1-float64(now.Sub(start))/float64(l.window))
+		if l.count.Start.Equal(start) && l.count.Previous > 0 && current+1 <= float64(l.limit) {
+			elapsed := float64(l.window) * (1 - (float64(l.limit)-1-current)/float64(l.count.Previous))
+			state.Reset = max(start.Add(time.Duration(elapsed)).Sub(now), 0)
+		}
+	}
+	return state
+}
+
+func (l *SharedLimiter) LastAccess() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastAccess
+}
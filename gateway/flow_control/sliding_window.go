@@ -50,6 +50,24 @@ func (l *SlidingWindowLimiter) Allow() bool {
 	return false
 }
 
+func (l *SlidingWindowLimiter) State() LimitState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-l.window)
+	state := LimitState{Limit: l.limit, Remaining: l.limit}
+	for i, t := range l.records {
+		if t.After(cutoff) {
+			state.Remaining = max(l.limit-(len(l.records)-i), 0)
+			// 最早的记录移出窗口后释放额度
+			state.Reset = t.Add(l.window).Sub(now)
+			break
+		}
+	}
+	return state
+}
+
 func (l *SlidingWindowLimiter) LastAccess() time.Time {
 	l.mu.Lock()
 	defer l.mu.Unlock()
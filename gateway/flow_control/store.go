@@ -0,0 +1,116 @@
+package flow
+
+import (
+	"Hamburger/internal/config"
+	"Hamburger/internal/data"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// 限流计数存储
+// 默认使用进程内的限流策略 配置共享存储后多个网关实例共用窗口计数
+
+const (
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+
+	DefaultStorePrefix  = "hamburger:flow:"
+	DefaultSyncInterval = 100 // 毫秒
+)
+
+// CounterStore 限流计数存储接口
+type CounterStore interface {
+	// Add 为key在at所在窗口的计数增加delta delta为0时只读取
+	// 窗口按Unix时间对齐 返回该窗口和上一个窗口的计数
+	Add(key string, at time.Time, window time.Duration, delta int64) (WindowCount, error)
+	Close() error
+}
+
+// WindowCount 窗口计数
+type WindowCount struct {
+	Start    time.Time // 窗口开始时间
+	Current  int64     // 当前窗口的计数
+	Previous int64     // 上一个窗口的计数
+}
+
+// minSharedWindow 共享计数的窗口按毫秒对齐 更小的窗口无法计数
+const minSharedWindow = time.Millisecond
+
+// windowStart 时间所在窗口的开始时间 小于1ms的窗口按1ms计算
+func windowStart(at time.Time, window time.Duration) time.Time {
+	ms := max(window.Milliseconds(), 1)
+	return time.UnixMilli(at.UnixMilli() - at.UnixMilli()%ms)
+}
+
+// NewCounterStore 按配置创建共享计数存储 内存存储返回nil 使用进程内的限流策略
+func NewCounterStore(conf config.FlowStoreConfig, redis config.RedisConfig) (CounterStore, error) {
+	switch conf.Type {
+	case "", StoreMemory:
+		return nil, nil
+	case StoreRedis:
+		if redis.Addr == "" {
+			return nil, fmt.Errorf("redis store requires database.redis.addr")
+		}
+		prefix := conf.Prefix
+		if prefix == "" {
+			prefix = DefaultStorePrefix
+		}
+		return &RedisStore{client: data.NewRedisClient(redis), prefix: prefix}, nil
+	}
+	return nil, fmt.Errorf("unknown flow control store: %s", conf.Type)
+}
+
+// RedisStore 基于Redis协议的共享计数
+// 每个窗口一个计数key 过期时间为两个窗口 保证滑动窗口计算时上一个窗口仍然存在
+type RedisStore struct {
+	client *data.RedisClient
+	prefix string
+}
+
+func (s *RedisStore) Add(key string, at time.Time, window time.Duration, delta int64) (WindowCount, error) {
+	start := windowStart(at, window)
+	current := s.prefix + key + ":" + strconv.FormatInt(start.UnixMilli(), 10)
+	previous := s.prefix + key + ":" + strconv.FormatInt(start.Add(-window).UnixMilli(), 10)
+
+	var cmds [][]string
+	if delta > 0 {
+		cmds = append(cmds,
+			[]string{"INCRBY", current, strconv.FormatInt(delta, 10)},
+			[]string{"PEXPIRE", current, strconv.FormatInt((2 * window).Milliseconds(), 10)},
+		)
+	} else {
+		cmds = append(cmds, []string{"GET", current})
+	}
+	cmds = append(cmds, []string{"GET", previous})
+
+	replies, err := s.client.Pipeline(cmds)
+	if err != nil {
+		return WindowCount{}, err
+	}
+	count := WindowCount{Start: start}
+	if count.Current, err = replyInt(replies[0]); err != nil {
+		return WindowCount{}, err
+	}
+	if count.Previous, err = replyInt(replies[len(replies)-1]); err != nil {
+		return WindowCount{}, err
+	}
+	return count, nil
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// replyInt 解析整数回复 key不存在时为0
+func replyInt(reply any) (int64, error) {
+	switch v := reply.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("unexpected reply %T", reply)
+}
@@ -0,0 +1,187 @@
+package flow
+
+import (
+	"Hamburger/internal/config"
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis 本地的Redis协议替身 只实现共享计数使用的命令
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]int64
+}
+
+func startFakeRedis(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	f := &fakeRedis{data: make(map[string]int64)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "INCRBY":
+			n, _ := strconv.ParseInt(args[2], 10, 64)
+			f.data[args[1]] += n
+			reply = ":" + strconv.FormatInt(f.data[args[1]], 10)
+		case "PEXPIRE":
+			reply = ":1"
+		case "GET":
+			if v, ok := f.data[args[1]]; ok {
+				s := strconv.FormatInt(v, 10)
+				reply = "$" + strconv.Itoa(len(s)) + "\r\n" + s
+			} else {
+				reply = "$-1"
+			}
+		default:
+			reply = "-ERR unknown command"
+		}
+		f.mu.Unlock()
+		if _, err = io.WriteString(conn, reply+"\r\n"); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	var n int
+	if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		var size int
+		if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestSharedStore(t *testing.T) {
+	for _, mode := range []string{"fixed", "sliding"} {
+		t.Run(mode, func(t *testing.T) { testSharedStore(t, mode) })
+	}
+}
+
+func testSharedStore(t *testing.T, mode string) {
+	addr := startFakeRedis(t)
+	newInstance := func(errorBound float64) *FlowController {
+		cfg := &config.Config{}
+		cfg.Database.Redis = config.RedisConfig{Addr: addr}
+		cfg.Features.FlowControl = config.FlowControlConfig{
+			Enabled:     true,
+			GlobalLimit: config.RateLimit{Requests: 10000, Window: "60", Unit: "s"},
+			Store:       config.FlowStoreConfig{Type: StoreRedis, ErrorBound: errorBound, Prefix: fmt.Sprintf("test:%s:%v:", mode, errorBound)},
+			Rules: []config.FlowControlRule{{
+				Name:      "shared",
+				Enabled:   true,
+				MatchType: MatchHost,
+				Limits:    []config.RateLimit{{Requests: 100, Window: "60", Unit: "s", Mode: mode}},
+			}},
+		}
+		config.Set(cfg)
+		fc, err := NewFlowController()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(fc.Stop)
+		return fc
+	}
+
+	for _, bound := range []float64{0, 0.1} {
+		instances := []*FlowController{newInstance(bound), newInstance(bound)}
+		allowed := 0
+		for i := 0; i < 300; i++ {
+			if instances[i%2].CheckRequest(httptest.NewRequest("GET", "/", nil)).Allowed {
+				allowed++
+			}
+		}
+		// 全局误差不超过 实例数 x error_bound x 限制数
+		maxAllowed := 100 + int(2*bound*100)
+		if allowed < 100 || allowed > maxAllowed {
+			t.Errorf("error bound %v: allowed %d, want 100..%d", bound, allowed, maxAllowed)
+		}
+	}
+}
+
+func TestSharedSlidingEstimate(t *testing.T) {
+	window := time.Minute
+	l := &SharedLimiter{limit: 50, window: window, sliding: true}
+	start := windowStart(time.Now(), window)
+	l.count = WindowCount{Start: start, Current: 10, Previous: 40}
+
+	// 上一窗口的计数按剩余比例加权
+	if got := l.estimate(start.Add(window / 4)); got != 40 {
+		t.Fatalf("estimate = %v, want 40", got)
+	}
+	// 进入下一个窗口后当前窗口成为上一窗口
+	if got := l.estimate(start.Add(window + window/2)); got != 5 {
+		t.Fatalf("estimate = %v, want 5", got)
+	}
+	l.sliding = false
+	if got := l.estimate(start.Add(window / 4)); got != 10 {
+		t.Fatalf("fixed estimate = %v, want 10", got)
+	}
+}
+
+func TestSharedWindowValidation(t *testing.T) {
+	now := time.UnixMilli(12345)
+	if got := windowStart(now, 0); !got.Equal(now) {
+		t.Fatalf("windowStart with zero window = %v", got)
+	}
+	conf := config.FlowControlConfig{
+		Enabled: true,
+		Store:   config.FlowStoreConfig{Type: StoreRedis},
+		Rules: []config.FlowControlRule{{
+			Name:       "zero",
+			MatchType:  MatchPathPrefix,
+			MatchValue: "/",
+			Enabled:    true,
+			Limits:     []config.RateLimit{{Requests: 1, Window: "0s"}, {Requests: 1, Window: "1", Unit: "s"}},
+		}},
+	}
+	if err := Validate(conf); err == nil || !strings.Contains(err.Error(), "shorter than 1ms") {
+		t.Fatalf("expected zero window rejected, got %v", err)
+	}
+	conf.Store.Type = StoreMemory
+	if err := Validate(conf); err != nil {
+		t.Fatalf("memory store: %v", err)
+	}
+}
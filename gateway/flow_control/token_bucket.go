@@ -9,6 +9,7 @@ import (
 
 // TokenBucketLimiter 令牌桶限流
 type TokenBucketLimiter struct {
+	limit      int
 	limiter    *rate.Limiter
 	lastAccess time.Time
 	mu         sync.Mutex
@@ -17,6 +18,7 @@ type TokenBucketLimiter struct {
 func NewTokenBucketLimiter(limit int, window time.Duration) *TokenBucketLimiter {
 	r := rate.Limit(float64(limit) / window.Seconds())
 	return &TokenBucketLimiter{
+		limit:      limit,
 		limiter:    rate.NewLimiter(r, limit),
 		lastAccess: time.Now(),
 	}
@@ -29,6 +31,15 @@ func (l *TokenBucketLimiter) Allow() bool {
 	return l.limiter.Allow()
 }
 
+func (l *TokenBucketLimiter) State() LimitState {
+	tokens := l.limiter.Tokens()
+	state := LimitState{Limit: l.limit, Remaining: max(int(tokens), 0)}
+	if r := float64(l.limiter.Limit()); tokens < 1 && r > 0 {
+		state.Reset = time.Duration((1 - tokens) / r * float64(time.Second))
+	}
+	return state
+}
+
 func (l *TokenBucketLimiter) LastAccess() time.Time {
 	l.mu.Lock()
 	defer l.mu.Unlock()
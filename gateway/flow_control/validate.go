@@ -0,0 +1,47 @@
+package flow
+
+import (
+	"Hamburger/internal/config"
+	"errors"
+	"fmt"
+)
+
+// Validate 校验流控配置中需要按运行时规则解析的匹配条件和窗口 用于热重载前检查
+func Validate(conf config.FlowControlConfig) error {
+	if !conf.Enabled {
+		return nil
+	}
+	shared := conf.Store.Type == StoreRedis
+	var errs []error
+	switch conf.Store.Type {
+	case "", StoreMemory, StoreRedis:
+	default:
+		errs = append(errs, fmt.Errorf("unknown flow control store: %s", conf.Store.Type))
+	}
+	check := func(name string, limits []config.RateLimit) {
+		for _, limit := range limits {
+			window, err := parseWindow(limit.Window, limit.Unit)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("flow control %s: %w", name, err))
+				continue
+			}
+			if shared && window < minSharedWindow {
+				errs = append(errs, fmt.Errorf("flow control %s: window %s%s is shorter than 1ms for shared store", name, limit.Window, limit.Unit))
+			}
+		}
+	}
+	if conf.GlobalLimit.Requests > 0 {
+		check("global limit", []config.RateLimit{conf.GlobalLimit})
+	}
+	for _, rule := range conf.Rules {
+		if !rule.Enabled {
+			continue
+		}
+		// 与创建流控器时使用同一个编译过程
+		if _, err := compileConditions(rule); err != nil {
+			errs = append(errs, err)
+		}
+		check("rule "+rule.Name, rule.Limits)
+	}
+	return errors.Join(errs...)
+}
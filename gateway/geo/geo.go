@@ -5,14 +5,28 @@ import (
 	_ "embed"
 	"github.com/oschwald/maxminddb-golang/v2"
 	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // 处理请求与地理位置的映射关系
 // https://github.com/P3TERX/GeoLite.mmdb/releases
+// 配置了外部mmdb文件时优先使用外部文件 并定期检查文件变化后热替换 加载失败时使用内置数据库
+
+// WatchInterval 检查外部mmdb文件变化的间隔
+var WatchInterval = time.Minute
 
 //go:embed GeoLite2-Country.mmdb
 var geoData []byte
-var db *maxminddb.Reader
+var db atomic.Pointer[maxminddb.Reader]
+
+var (
+	watchMu   sync.Mutex
+	watchPath string
+	watchStop chan struct{}
+)
 
 func LoadGEO() {
 	mmdb, err := maxminddb.OpenBytes(geoData)
@@ -20,25 +34,102 @@ func LoadGEO() {
 		logger.GetLogger().Error().Err(err).Msg("maxminddb.Open err")
 		return
 	}
-	db = mmdb
+	db.Store(mmdb)
 }
 
-func GeoLookUp(ip string) string {
-	if db == nil {
-		return ""
+// Init 加载mmdb文件并监听文件变化 路径为空时使用内置数据库
+// 路径未变化时不重复加载
+func Init(path string) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+
+	if db.Load() != nil && path == watchPath {
+		return
+	}
+	if watchStop != nil {
+		close(watchStop)
+		watchStop = nil
+	}
+	watchPath = path
+	if path == "" {
+		LoadGEO()
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err == nil {
+		err = LoadFile(path)
+	}
+	if err != nil {
+		logger.GetLogger().Error().Err(err).Str("file", path).Msg("load geo database failed, use embedded database")
+		LoadGEO()
+	}
+	watchStop = make(chan struct{})
+	go watch(path, info, watchStop)
+}
+
+// LoadFile 从文件加载mmdb并替换当前数据库
+// 文件内容读入内存 替换后仍在进行的查询不受影响
+func LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	mmdb, err := maxminddb.OpenBytes(data)
+	if err != nil {
+		return err
+	}
+	db.Store(mmdb)
+	logger.GetLogger().Info().Str("file", path).Str("type", mmdb.Metadata.DatabaseType).Msg("geo database loaded")
+	return nil
+}
+
+// watch 文件修改时间或大小变化后重新加载
+func watch(path string, last os.FileInfo, stop chan struct{}) {
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		if err = LoadFile(path); err != nil {
+			logger.GetLogger().Error().Err(err).Str("file", path).Msg("reload geo database failed")
+			continue
+		}
+		last = info
 	}
+}
+
+func GeoLookUp(ip string) string {
 	ipAddr, err := netip.ParseAddr(ip)
 	if err != nil {
 		return ""
 	}
+	return Country(ipAddr)
+}
+
+// Country 地址所属国家的ISO代码 无法识别时返回空
+func Country(addr netip.Addr) string {
+	reader := db.Load()
+	if reader == nil || !addr.IsValid() {
+		return ""
+	}
 	var record struct {
 		Country struct {
-			ISOCode string            `maxminddb:"iso_code"`
-			Names   map[string]string `maxminddb:"names"`
+			ISOCode string `maxminddb:"iso_code"`
 		} `maxminddb:"country"`
 	}
 
-	if err = db.Lookup(ipAddr).Decode(&record); err != nil {
+	if err := reader.Lookup(addr.Unmap()).Decode(&record); err != nil {
 		return ""
 	}
 
@@ -0,0 +1,85 @@
+package geo
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testdata中的数据库只包含以下网段
+// country.mmdb: 203.0.113.0/24 US 198.51.100.0/24 CN
+// country-swap.mmdb: 203.0.113.0/24 DE 198.51.100.0/24 CN
+
+func TestLoadFile(t *testing.T) {
+	if err := LoadFile("testdata/country.mmdb"); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"203.0.113.9":        "US",
+		"198.51.100.1":       "CN",
+		"192.0.2.1":          "",
+		"::ffff:203.0.113.9": "US",
+		"2001:db8::1":        "",
+	}
+	for ip, want := range cases {
+		if got := GeoLookUp(ip); got != want {
+			t.Errorf("%s: country = %q, want %q", ip, got, want)
+		}
+	}
+
+	// 无效文件不替换当前数据库
+	bad := filepath.Join(t.TempDir(), "bad.mmdb")
+	if err := os.WriteFile(bad, []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFile(bad); err == nil {
+		t.Fatal("expected invalid database error")
+	}
+	if got := GeoLookUp("203.0.113.9"); got != "US" {
+		t.Fatalf("country after failed load = %q", got)
+	}
+}
+
+func TestWatchReplacesDatabase(t *testing.T) {
+	interval := WatchInterval
+	WatchInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		Init("")
+		WatchInterval = interval
+	})
+
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	copyFile(t, "testdata/country.mmdb", path)
+	Init(path)
+	addr := netip.MustParseAddr("203.0.113.9")
+	if got := Country(addr); got != "US" {
+		t.Fatalf("initial country = %q", got)
+	}
+
+	copyFile(t, "testdata/country-swap.mmdb", path)
+	// 两个文件大小相同 修改时间的变化触发重新加载
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for Country(addr) != "DE" {
+		if time.Now().After(deadline) {
+			t.Fatalf("database not replaced, country = %q", Country(addr))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(dst, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
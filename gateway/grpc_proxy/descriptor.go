@@ -0,0 +1,253 @@
+package grpc_proxy
+
+import (
+	"Hamburger/internal/structure"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// 服务描述符解析
+// 优先从配置的描述符集文件中查找服务 找不到时通过上游的服务反射获取 反射结果按上游地址缓存
+
+// DefaultDescriptorTTL 反射获取的描述符缓存时间(秒)
+const DefaultDescriptorTTL = 300
+
+// 服务反射的方法 v1alpha与v1的消息定义相同 可以共用v1的消息类型
+var reflectionMethods = []string{
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// schema 一组可用于编解码的描述符
+type schema struct {
+	files *protoregistry.Files
+	types *dynamicpb.Types // JSON编解码时解析Any和扩展字段
+}
+
+func newSchema(files *protoregistry.Files) *schema {
+	return &schema{files: files, types: dynamicpb.NewTypes(files)}
+}
+
+// method 按服务名和方法名查找方法
+func (s *schema) method(service, method string) (protoreflect.MethodDescriptor, bool) {
+	if s == nil {
+		return nil, false
+	}
+	desc, err := s.files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, false
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, false
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	return md, md != nil
+}
+
+// reflectEntry 上游的反射结果
+type reflectEntry struct {
+	mu     sync.Mutex
+	schema *schema
+	expire time.Time
+}
+
+// descriptorResolver 描述符解析器
+type descriptorResolver struct {
+	static *schema // 描述符集文件
+	ttl    time.Duration
+	cache  *structure.Map[*reflectEntry]
+	mu     sync.Mutex
+}
+
+func newDescriptorResolver(static *schema, ttl int) *descriptorResolver {
+	if ttl <= 0 {
+		ttl = DefaultDescriptorTTL
+	}
+	return &descriptorResolver{
+		static: static,
+		ttl:    time.Duration(ttl) * time.Second,
+		cache:  structure.NewMap[*reflectEntry](),
+	}
+}
+
+// resolve 查找方法和所在的描述符集
+// 缓存中找不到方法时重新反射一次 上游可能新增了服务
+func (r *descriptorResolver) resolve(ctx context.Context, conn *grpc.ClientConn, addr, service, method string) (protoreflect.MethodDescriptor, *schema, error) {
+	if md, ok := r.static.method(service, method); ok {
+		return md, r.static, nil
+	}
+
+	entry := r.entry(addr)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.schema != nil && time.Now().Before(entry.expire) {
+		if md, ok := entry.schema.method(service, method); ok {
+			return md, entry.schema, nil
+		}
+	}
+	files, err := reflectFiles(ctx, conn, service)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry.schema = newSchema(files)
+	entry.expire = time.Now().Add(r.ttl)
+	if md, ok := entry.schema.method(service, method); ok {
+		return md, entry.schema, nil
+	}
+	return nil, nil, fmt.Errorf("method %s/%s not found", service, method)
+}
+
+func (r *descriptorResolver) entry(addr string) *reflectEntry {
+	if entry, ok := r.cache.Get(addr); ok {
+		return entry
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.cache.Get(addr); ok {
+		return entry
+	}
+	entry := &reflectEntry{}
+	r.cache.Put(addr, entry)
+	return entry
+}
+
+// LoadDescriptorSets 加载描述符集文件 文件由protoc --descriptor_set_out --include_imports生成
+func LoadDescriptorSets(paths []string) (*protoregistry.Files, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]struct{})
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("grpc descriptor set %s: %w", path, err)
+		}
+		var fds descriptorpb.FileDescriptorSet
+		if err = proto.Unmarshal(data, &fds); err != nil {
+			return nil, fmt.Errorf("grpc descriptor set %s: %w", path, err)
+		}
+		for _, fd := range fds.File {
+			if _, ok := seen[fd.GetName()]; ok {
+				continue
+			}
+			seen[fd.GetName()] = struct{}{}
+			set.File = append(set.File, fd)
+		}
+	}
+	files, err := buildFiles(set.File)
+	if err != nil {
+		return nil, fmt.Errorf("grpc descriptor set: %w", err)
+	}
+	return files, nil
+}
+
+// buildFiles 由文件描述符构建注册表 缺少的依赖从编译进网关的描述符中补充
+func buildFiles(fds []*descriptorpb.FileDescriptorProto) (*protoregistry.Files, error) {
+	names := make(map[string]struct{}, len(fds))
+	for _, fd := range fds {
+		names[fd.GetName()] = struct{}{}
+	}
+	for i := 0; i < len(fds); i++ {
+		for _, dep := range fds[i].GetDependency() {
+			if _, ok := names[dep]; ok {
+				continue
+			}
+			global, err := protoregistry.GlobalFiles.FindFileByPath(dep)
+			if err != nil {
+				return nil, fmt.Errorf("missing dependency %s of %s", dep, fds[i].GetName())
+			}
+			names[dep] = struct{}{}
+			fds = append(fds, protodesc.ToFileDescriptorProto(global))
+		}
+	}
+	return protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: fds})
+}
+
+// reflectFiles 通过服务反射获取服务所在的文件及其全部依赖
+func reflectFiles(ctx context.Context, conn *grpc.ClientConn, service string) (*protoregistry.Files, error) {
+	var lastErr error
+	for _, method := range reflectionMethods {
+		files, err := reflectWith(ctx, conn, method, service)
+		if status.Code(err) == codes.Unimplemented {
+			lastErr = err
+			continue
+		}
+		return files, err
+	}
+	return nil, fmt.Errorf("server reflection not supported: %w", lastErr)
+}
+
+func reflectWith(ctx context.Context, conn *grpc.ClientConn, method, service string) (*protoregistry.Files, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, method)
+	if err != nil {
+		return nil, err
+	}
+
+	fds := make(map[string]*descriptorpb.FileDescriptorProto)
+	request := &reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	}
+	for request != nil {
+		if err = stream.SendMsg(request); err != nil {
+			return nil, err
+		}
+		resp := &reflectionpb.ServerReflectionResponse{}
+		if err = stream.RecvMsg(resp); err != nil {
+			return nil, err
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return nil, status.Error(codes.Code(e.GetErrorCode()), e.GetErrorMessage())
+		}
+		for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err = proto.Unmarshal(raw, fd); err != nil {
+				return nil, err
+			}
+			fds[fd.GetName()] = fd
+		}
+		request = nextMissing(fds)
+	}
+	_ = stream.CloseSend()
+
+	list := make([]*descriptorpb.FileDescriptorProto, 0, len(fds))
+	for _, fd := range fds {
+		list = append(list, fd)
+	}
+	return buildFiles(list)
+}
+
+// nextMissing 返回获取下一个缺少的依赖的请求 编译进网关的文件不需要获取
+func nextMissing(fds map[string]*descriptorpb.FileDescriptorProto) *reflectionpb.ServerReflectionRequest {
+	for _, fd := range fds {
+		for _, dep := range fd.GetDependency() {
+			if _, ok := fds[dep]; ok {
+				continue
+			}
+			if _, err := protoregistry.GlobalFiles.FindFileByPath(dep); err == nil {
+				continue
+			}
+			return &reflectionpb.ServerReflectionRequest{
+				MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+			}
+		}
+	}
+	return nil
+}
+
+var errClientStreaming = errors.New("client streaming method is not supported")
@@ -15,11 +15,14 @@ var (
 func GetGrpcProxy() *GrpcProxy {
 	proxyOnce.Do(func() {
 		cfg := config.Get()
-		if cfg.Features.GrpcProxy.Enabled {
+		if cfg.Features.GrpcProxy.Enabled && cfg.Features.GrpcProxy.Bridge {
 			globalProxy = NewGrpcProxy(&cfg.Features.GrpcProxy)
 			logger.GetLogger().Info().Int("hosts", len(cfg.Features.GrpcProxy.Hosts)).Msg("gRPC proxy initialized with allowed hosts")
+		} else if cfg.Features.GrpcProxy.Enabled {
+			// 旧配置只需enabled即开启桥接 现在需要显式配置bridge
+			logger.GetLogger().Warn().Msg("gRPC json bridge is disabled, set features.grpc_proxy.bridge to true to keep bridging HTTP requests")
 		} else {
-			logger.GetLogger().Debug().Msg("gRPC proxy is disabled")
+			logger.GetLogger().Debug().Msg("gRPC json bridge is disabled")
 		}
 	})
 	return globalProxy
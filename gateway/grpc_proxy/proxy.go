@@ -1,6 +1,8 @@
 /*
 Package grpc_proxy
 gRPC代理模块，实现HTTP请求到gRPC调用的转换
+透明代理见grpc_server 本模块为可选的JSON桥接模式 需要开启bridge
+按描述符集文件或上游的服务反射将JSON转换为protobuf消息 服务端流式响应按NDJSON或SSE逐条返回
 */
 package grpc_proxy
 
@@ -17,46 +19,64 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 // GrpcRequest HTTP到gRPC转换的请求结构
 type GrpcRequest struct {
-	Service string                 `json:"service"` // gRPC服务名，如 "user.UserService"
-	Method  string                 `json:"method"`  // gRPC方法名，如 "GetUser"
-	Data    map[string]interface{} `json:"data"`    // 请求参数
-	Headers map[string]string      `json:"headers"` // 额外的gRPC metadata
-	Timeout int                    `json:"timeout"` // 超时时间（秒），默认30秒
+	Service string            `json:"service"` // gRPC服务名，如 "user.UserService"
+	Method  string            `json:"method"`  // gRPC方法名，如 "GetUser"
+	Data    json.RawMessage   `json:"data"`    // 请求参数 按protobuf的JSON映射编码
+	Headers map[string]string `json:"headers"` // 额外的gRPC metadata
+	Timeout int               `json:"timeout"` // 超时时间（秒），默认30秒
 }
 
 // GrpcResponse gRPC响应结构
 type GrpcResponse struct {
-	Success bool                   `json:"success"`
-	Data    map[string]interface{} `json:"data,omitempty"`
-	Error   string                 `json:"error,omitempty"`
-	Code    int                    `json:"code"`
-	Headers map[string]string      `json:"headers,omitempty"`
+	Success bool              `json:"success"`
+	Data    json.RawMessage   `json:"data,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Code    int               `json:"code"`
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 // GrpcProxy gRPC代理处理器
 type GrpcProxy struct {
-	config    *config.GrpcProxyConfig
-	connPool  map[string]*grpc.ClientConn // 连接池
-	connMutex sync.RWMutex
+	config      *config.GrpcProxyConfig
+	connPool    map[string]*grpc.ClientConn // 连接池
+	connMutex   sync.RWMutex
+	descriptors *descriptorResolver
 }
 
 // NewGrpcProxy 创建新的gRPC代理实例
+// 描述符集文件加载失败时只使用服务反射
 func NewGrpcProxy(cfg *config.GrpcProxyConfig) *GrpcProxy {
+	var static *schema
+	if len(cfg.DescriptorSets) > 0 {
+		files, err := LoadDescriptorSets(cfg.DescriptorSets)
+		if err != nil {
+			logger.GetLogger().Error().Err(err).Msg("failed to load gRPC descriptor sets")
+		} else {
+			static = newSchema(files)
+		}
+	}
 	return &GrpcProxy{
-		config:   cfg,
-		connPool: make(map[string]*grpc.ClientConn),
+		config:      cfg,
+		connPool:    make(map[string]*grpc.ClientConn),
+		descriptors: newDescriptorResolver(static, cfg.DescriptorTTL),
 	}
 }
 
 // IsGrpcRequest 判断是否为gRPC代理请求
 func (p *GrpcProxy) IsGrpcRequest(r *http.Request) bool {
-	if !p.config.Enabled {
+	if !p.config.Enabled || !p.config.Bridge {
 		return false
 	}
 
@@ -103,16 +123,50 @@ func (p *GrpcProxy) HandleGrpcRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 执行gRPC调用
-	resp, err := p.executeGrpcCall(grpcAddr, grpcReq)
+	// 获取连接
+	conn, err := p.getConnection(grpcAddr)
 	if err != nil {
 		logger.GetLogger().Error().Err(err).Msg("gRPC call failed")
-		p.writeErrorResponse(w, fmt.Sprintf("gRPC call failed: %v", err), http.StatusInternalServerError)
+		p.writeErrorResponse(w, fmt.Sprintf("gRPC call failed: %v", err), http.StatusBadGateway)
 		return
 	}
 
-	// 返回响应
-	p.writeGrpcResponse(w, resp)
+	// 创建上下文 客户端断开时取消调用
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(grpcReq.Timeout)*time.Second)
+	defer cancel()
+	// 添加metadata
+	if len(grpcReq.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(grpcReq.Headers))
+	}
+
+	// 查找方法描述符并将JSON转换为protobuf消息
+	md, sch, err := p.descriptors.resolve(ctx, conn, grpcAddr, grpcReq.Service, grpcReq.Method)
+	if err != nil {
+		logger.GetLogger().Error().Err(err).Str("address", grpcAddr).Msg("failed to resolve gRPC method")
+		p.writeErrorResponse(w, fmt.Sprintf("resolve method failed: %v", err), statusToHttp(err, http.StatusNotFound))
+		return
+	}
+	if md.IsStreamingClient() {
+		p.writeErrorResponse(w, errClientStreaming.Error(), http.StatusBadRequest)
+		return
+	}
+	reqMsg := dynamicpb.NewMessage(md.Input())
+	if len(grpcReq.Data) > 0 && string(grpcReq.Data) != "null" {
+		if err = (protojson.UnmarshalOptions{Resolver: sch.types}).Unmarshal(grpcReq.Data, reqMsg); err != nil {
+			p.writeErrorResponse(w, fmt.Sprintf("invalid request data: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// 构造gRPC方法全名
+	fullMethod := fmt.Sprintf("/%s/%s", grpcReq.Service, grpcReq.Method)
+	if md.IsStreamingServer() {
+		p.streamGrpcCall(ctx, w, r, conn, fullMethod, md, sch, reqMsg)
+		return
+	}
+
+	// 执行gRPC调用并返回响应
+	p.writeGrpcResponse(w, p.executeGrpcCall(ctx, conn, fullMethod, md, sch, reqMsg))
 }
 
 // parseHttpRequest 解析HTTP请求为gRPC请求结构
@@ -144,74 +198,81 @@ func (p *GrpcProxy) parseHttpRequest(r *http.Request) (*GrpcRequest, error) {
 	return &grpcReq, nil
 }
 
-// executeGrpcCall 执行gRPC调用
-func (p *GrpcProxy) executeGrpcCall(addr string, req *GrpcRequest) (*GrpcResponse, error) {
-	// 获取连接
-	conn, err := p.getConnection(addr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get connection: %w", err)
-	}
-
-	// 创建上下文
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Timeout)*time.Second)
-	defer cancel()
-
-	// 添加metadata
-	if len(req.Headers) > 0 {
-		md := metadata.New(req.Headers)
-		ctx = metadata.NewOutgoingContext(ctx, md)
-	}
-
-	// 构造gRPC方法全名
-	fullMethod := fmt.Sprintf("/%s/%s", req.Service, req.Method)
-
-	// 将请求数据转换为protobuf格式
-	reqData, err := json.Marshal(req.Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request data: %w", err)
-	}
-
-	// 执行通用gRPC调用
-	var respData json.RawMessage
-	err = conn.Invoke(ctx, fullMethod, reqData, &respData)
+// executeGrpcCall 执行一元gRPC调用 调用失败时错误记录在响应中
+func (p *GrpcProxy) executeGrpcCall(ctx context.Context, conn *grpc.ClientConn, fullMethod string,
+	md protoreflect.MethodDescriptor, sch *schema, req proto.Message) *GrpcResponse {
+	var header, trailer metadata.MD
+	respMsg := dynamicpb.NewMessage(md.Output())
+	err := conn.Invoke(ctx, fullMethod, req, respMsg, grpc.Header(&header), grpc.Trailer(&trailer))
 
 	response := &GrpcResponse{
 		Success: err == nil,
 		Code:    http.StatusOK,
+		Headers: flattenMetadata(header, trailer),
 	}
-
 	if err != nil {
 		response.Error = err.Error()
-		response.Code = http.StatusInternalServerError
-		// 根据gRPC错误码设置HTTP状态码
-		if strings.Contains(err.Error(), "NotFound") {
-			response.Code = http.StatusNotFound
-		} else if strings.Contains(err.Error(), "InvalidArgument") {
-			response.Code = http.StatusBadRequest
-		} else if strings.Contains(err.Error(), "Unauthenticated") {
-			response.Code = http.StatusUnauthorized
-		} else if strings.Contains(err.Error(), "PermissionDenied") {
-			response.Code = http.StatusForbidden
-		}
-	} else {
-		// 解析响应数据
-		var data map[string]interface{}
-		if err := json.Unmarshal(respData, &data); err == nil {
-			response.Data = data
-		}
+		response.Code = statusToHttp(err, http.StatusInternalServerError)
+		return response
 	}
 
-	// 获取响应headers
-	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		response.Headers = make(map[string]string)
+	data, err := (protojson.MarshalOptions{Resolver: sch.types}).Marshal(respMsg)
+	if err != nil {
+		response.Success = false
+		response.Error = fmt.Sprintf("failed to marshal response: %v", err)
+		response.Code = http.StatusBadGateway
+		return response
+	}
+	response.Data = data
+	return response
+}
+
+// statusToHttp 根据gRPC错误码设置HTTP状态码 非gRPC错误使用fallback
+func statusToHttp(err error, fallback int) int {
+	st, ok := status.FromError(err)
+	if !ok {
+		return fallback
+	}
+	switch st.Code() {
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Canceled:
+		return 499
+	}
+	return http.StatusInternalServerError
+}
+
+// flattenMetadata 合并响应头和trailer中的metadata 每个key只保留第一个值
+func flattenMetadata(mds ...metadata.MD) map[string]string {
+	var result map[string]string
+	for _, md := range mds {
 		for k, v := range md {
-			if len(v) > 0 {
-				response.Headers[k] = v[0]
+			if len(v) == 0 {
+				continue
+			}
+			if result == nil {
+				result = make(map[string]string)
+			}
+			if _, ok := result[k]; !ok {
+				result[k] = v[0]
 			}
 		}
 	}
-
-	return response, nil
+	return result
 }
 
 // getConnection 获取或创建gRPC连接
@@ -247,6 +308,11 @@ func (p *GrpcProxy) getConnection(addr string) (*grpc.ClientConn, error) {
 	return conn, nil
 }
 
+// WriteError 以桥接的JSON格式写入错误响应 用于被网关拒绝的桥接请求
+func (p *GrpcProxy) WriteError(w http.ResponseWriter, message string, code int) {
+	p.writeErrorResponse(w, message, code)
+}
+
 // writeErrorResponse 写入错误响应
 func (p *GrpcProxy) writeErrorResponse(w http.ResponseWriter, message string, code int) {
 	resp := &GrpcResponse{
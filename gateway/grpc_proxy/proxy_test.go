@@ -0,0 +1,177 @@
+package grpc_proxy
+
+import (
+	"Hamburger/internal/config"
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func startUpstream(t *testing.T, withReflection bool) (string, *health.Server) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	hs := health.NewServer()
+	grpc_health_v1.RegisterHealthServer(s, hs)
+	if withReflection {
+		reflection.Register(s)
+	}
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String(), hs
+}
+
+func newBridge(t *testing.T, sets ...string) *GrpcProxy {
+	p := NewGrpcProxy(&config.GrpcProxyConfig{
+		Enabled:        true,
+		Bridge:         true,
+		Hosts:          []string{"127.0.0.1"},
+		GrpcHeader:     "X-Grpc-Proxy",
+		GrpcAddr:       "X-Grpc-Addr",
+		DescriptorSets: sets,
+	})
+	t.Cleanup(p.Close)
+	return p
+}
+
+func bridgeRequest(addr, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("X-Grpc-Proxy", "1")
+	req.Header.Set("X-Grpc-Addr", addr)
+	return req
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) GrpcResponse {
+	var resp GrpcResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return resp
+}
+
+func TestUnaryWithReflection(t *testing.T) {
+	addr, _ := startUpstream(t, true)
+	p := newBridge(t)
+
+	rec := httptest.NewRecorder()
+	p.HandleGrpcRequest(rec, bridgeRequest(addr, `{"service":"grpc.health.v1.Health","method":"Check","data":{}}`))
+	resp := decode(t, rec)
+	if !resp.Success || string(resp.Data) != `{"status":"SERVING"}` {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// gRPC错误码转换为HTTP状态码
+	rec = httptest.NewRecorder()
+	p.HandleGrpcRequest(rec, bridgeRequest(addr, `{"service":"grpc.health.v1.Health","method":"Check","data":{"service":"missing"}}`))
+	if rec.Code != http.StatusNotFound || decode(t, rec).Success {
+		t.Fatalf("expected 404, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// 字段类型不匹配时拒绝请求
+	rec = httptest.NewRecorder()
+	p.HandleGrpcRequest(rec, bridgeRequest(addr, `{"service":"grpc.health.v1.Health","method":"Check","data":{"service":1}}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	if _, ok := p.descriptors.cache.Get(addr); !ok {
+		t.Fatal("expected descriptors cached for upstream")
+	}
+}
+
+func TestUnaryWithDescriptorSet(t *testing.T) {
+	addr, _ := startUpstream(t, false)
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		protodesc.ToFileDescriptorProto(grpc_health_v1.File_grpc_health_v1_health_proto),
+	}}
+	data, _ := proto.Marshal(set)
+	path := filepath.Join(t.TempDir(), "health.pb")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := newBridge(t, path)
+
+	rec := httptest.NewRecorder()
+	p.HandleGrpcRequest(rec, bridgeRequest(addr, `{"service":"grpc.health.v1.Health","method":"Check"}`))
+	if resp := decode(t, rec); !resp.Success || string(resp.Data) != `{"status":"SERVING"}` {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// 没有描述符也不支持反射
+	rec = httptest.NewRecorder()
+	p.HandleGrpcRequest(rec, bridgeRequest(addr, `{"service":"grpc.health.v1.Other","method":"Check"}`))
+	if decode(t, rec).Success {
+		t.Fatalf("expected failure, got %s", rec.Body.String())
+	}
+}
+
+func TestServerStreaming(t *testing.T) {
+	addr, hs := startUpstream(t, true)
+	p := newBridge(t)
+	srv := httptest.NewServer(http.HandlerFunc(p.HandleGrpcRequest))
+	defer srv.Close()
+
+	for _, sse := range []bool{false, true} {
+		req := bridgeRequest(addr, `{"service":"grpc.health.v1.Health","method":"Watch","data":{"service":"svc"}}`)
+		req.RequestURI = ""
+		req.URL, _ = req.URL.Parse(srv.URL)
+		if sse {
+			req.Header.Set("Accept", "text/event-stream")
+		}
+		// 第一条消息为调用时的状态
+		hs.SetServingStatus("svc", grpc_health_v1.HealthCheckResponse_SERVING)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		reader := bufio.NewReader(resp.Body)
+		next := func() string {
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					t.Fatalf("read stream: %v", err)
+				}
+				line = strings.TrimSpace(line)
+				if sse {
+					if !strings.HasPrefix(line, "data: ") {
+						continue
+					}
+					line = strings.TrimPrefix(line, "data: ")
+				}
+				return line
+			}
+		}
+
+		if line := next(); line != `{"status":"SERVING"}` {
+			t.Fatalf("sse=%v unexpected first message %q", sse, line)
+		}
+		hs.SetServingStatus("svc", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		if line := next(); line != `{"status":"NOT_SERVING"}` {
+			t.Fatalf("sse=%v unexpected second message %q", sse, line)
+		}
+		wantType := "application/x-ndjson"
+		if sse {
+			wantType = "text/event-stream"
+		}
+		if resp.Header.Get("Content-Type") != wantType {
+			t.Fatalf("unexpected content type %s", resp.Header.Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package grpc_proxy
+
+import (
+	"Hamburger/internal/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// 服务端流式响应
+// 客户端Accept包含text/event-stream时按SSE返回 每条消息一个message事件 结束时发送end或error事件
+// 否则按NDJSON返回 每行一条消息 调用失败时最后一行为GrpcResponse格式的错误
+
+// MetadataHeaderPrefix 流式响应中gRPC响应头metadata转为HTTP响应头的前缀
+const MetadataHeaderPrefix = "Grpc-Metadata-"
+
+// streamWriter 流式响应写出
+type streamWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	sse     bool
+	started bool
+}
+
+func newStreamWriter(w http.ResponseWriter, r *http.Request) *streamWriter {
+	return &streamWriter{
+		w:   w,
+		rc:  http.NewResponseController(w),
+		sse: strings.Contains(r.Header.Get("Accept"), "text/event-stream"),
+	}
+}
+
+// start 写出响应头 流式响应可能长时间保持 取消服务器的写超时
+func (s *streamWriter) start(header metadata.MD) {
+	s.started = true
+	h := s.w.Header()
+	if s.sse {
+		h.Set("Content-Type", "text/event-stream")
+	} else {
+		h.Set("Content-Type", "application/x-ndjson")
+	}
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	for k, v := range header {
+		for _, value := range v {
+			h.Add(MetadataHeaderPrefix+k, value)
+		}
+	}
+	_ = s.rc.SetWriteDeadline(time.Time{})
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// write 写出一条消息
+func (s *streamWriter) write(event string, data []byte) error {
+	var err error
+	if s.sse {
+		_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	} else {
+		_, err = fmt.Fprintf(s.w, "%s\n", data)
+	}
+	if err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// end 写出结束标记 NDJSON正常结束时不写出
+func (s *streamWriter) end(resp *GrpcResponse) {
+	if resp.Success {
+		if s.sse {
+			_ = s.write("end", []byte("{}"))
+		}
+		return
+	}
+	data, _ := json.Marshal(resp)
+	_ = s.write("error", data)
+}
+
+// streamGrpcCall 执行服务端流式调用 收到第一条消息前失败时返回普通的错误响应
+func (p *GrpcProxy) streamGrpcCall(ctx context.Context, w http.ResponseWriter, r *http.Request, conn *grpc.ClientConn,
+	fullMethod string, md protoreflect.MethodDescriptor, sch *schema, req proto.Message) {
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, fullMethod)
+	if err == nil {
+		err = stream.SendMsg(req)
+	}
+	if err == nil {
+		err = stream.CloseSend()
+	}
+	if err != nil {
+		p.writeErrorResponse(w, fmt.Sprintf("gRPC call failed: %v", err), statusToHttp(err, http.StatusBadGateway))
+		return
+	}
+
+	out := newStreamWriter(w, r)
+	marshal := protojson.MarshalOptions{Resolver: sch.types}
+	count := 0
+	for {
+		msg := dynamicpb.NewMessage(md.Output())
+		err = stream.RecvMsg(msg)
+		if errors.Is(err, io.EOF) {
+			if !out.started {
+				header, _ := stream.Header()
+				out.start(header)
+			}
+			out.end(&GrpcResponse{Success: true, Code: http.StatusOK, Headers: flattenMetadata(stream.Trailer())})
+			break
+		}
+		if err != nil {
+			resp := &GrpcResponse{Error: err.Error(), Code: statusToHttp(err, http.StatusInternalServerError), Headers: flattenMetadata(stream.Trailer())}
+			if !out.started {
+				p.writeGrpcResponse(w, resp)
+			} else {
+				out.end(resp)
+			}
+			break
+		}
+		if !out.started {
+			header, _ := stream.Header()
+			out.start(header)
+		}
+		data, err := marshal.Marshal(msg)
+		if err != nil {
+			out.end(&GrpcResponse{Error: fmt.Sprintf("failed to marshal response: %v", err), Code: http.StatusBadGateway})
+			break
+		}
+		if err = out.write("message", data); err != nil {
+			// 客户端已断开 取消上游调用
+			break
+		}
+		count++
+	}
+	logger.GetLogger().Debug().Str("method", fullMethod).Int("messages", count).Bool("sse", out.sse).Msg("gRPC stream finished")
+}
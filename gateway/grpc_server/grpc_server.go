@@ -1 +1,214 @@
+// Package grpc_server
+// gRPC透明代理 h2/h2c上content-type为application/grpc的请求按:authority和服务路径转发到上游
+// 请求体和响应体按流转发 trailers原样透传 上游返回的gRPC状态码回报给熔断器和统计
 package grpc_server
+
+import (
+	"Hamburger/gateway/balancer"
+	"Hamburger/gateway/breaker"
+	"Hamburger/gateway/reqinfo"
+	"Hamburger/gateway/stat"
+	"Hamburger/internal/config"
+	"Hamburger/internal/logger"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	server     *Server
+	serverOnce sync.Once
+)
+
+// Server gRPC透明代理
+type Server struct {
+	routes atomic.Pointer[[]*route]
+	proxy  *httputil.ReverseProxy
+}
+
+// GetServer 获取全局gRPC透明代理
+func GetServer() *Server {
+	serverOnce.Do(func() {
+		server = NewServer(config.Get().Features.GrpcProxy)
+	})
+	return server
+}
+
+// Enabled 是否启用gRPC透明代理
+func Enabled() bool {
+	return config.Get().Features.GrpcProxy.Enabled
+}
+
+// Reload 使用新配置替换路由
+func Reload(cfg *config.Config) {
+	GetServer().SetRoutes(cfg.Features.GrpcProxy.Routes)
+}
+
+// NewServer 创建gRPC透明代理
+func NewServer(conf config.GrpcProxyConfig) *Server {
+	s := &Server{}
+	s.SetRoutes(conf.Routes)
+	s.proxy = &httputil.ReverseProxy{
+		Rewrite:        rewrite,
+		Transport:      newTransport(),
+		FlushInterval:  -1, // 流式响应的每条消息立即写出
+		ModifyResponse: modifyResponse,
+		ErrorHandler:   errorHandler,
+	}
+	return s
+}
+
+// newTransport 上游传输层 仅使用HTTP/2 http上游使用h2c https上游使用h2
+func newTransport() *http.Transport {
+	protocols := &http.Protocols{}
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:   true,
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+		Protocols:           protocols,
+	}
+}
+
+// SetRoutes 替换路由
+func (s *Server) SetRoutes(routes []config.GrpcRoute) {
+	compiled := compileRoutes(routes)
+	s.routes.Store(&compiled)
+}
+
+// IsGrpcRequest 是否为需要透明代理的gRPC请求 gRPC-Web不在此列
+func IsGrpcRequest(r *http.Request) bool {
+	if r.ProtoMajor != 2 {
+		return false
+	}
+	ct := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(ct, "application/grpc") {
+		return false
+	}
+	rest := ct[len("application/grpc"):]
+	return rest == "" || rest[0] == '+' || rest[0] == ';'
+}
+
+type callKey struct{}
+
+// call 一次gRPC调用的转发状态
+type call struct {
+	host   string
+	method string
+	addr   string
+	scheme string
+	start  time.Time
+	info   *reqinfo.Info
+	track  func(err error)
+	once   sync.Once
+}
+
+// finish 记录调用结果 只记录一次
+func (c *call) finish(code int) {
+	c.once.Do(func() {
+		failed := isFailure(code)
+		var err error
+		if failed {
+			err = errors.New(stat.GrpcCodeName(code))
+		}
+		c.track(err)
+		breaker.Report(c.host, c.addr, !failed)
+		stat.AddGrpc(c.host, c.method, code)
+		logger.L().Debug().Str("Host", c.host).Str("Method", c.method).Str("Upstream", c.addr).
+			Str("Status", stat.GrpcCodeName(code)).Dur("Cost", time.Since(c.start)).Msg("grpc call finished")
+	})
+}
+
+// ServeHTTP 转发gRPC请求
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	rt := match(*s.routes.Load(), hostname(host), r.URL.Path)
+	if rt == nil {
+		stat.AddGrpcUnmatched(CodeUnimplemented)
+		WriteStatus(w, CodeUnimplemented, "no upstream for "+r.URL.Path)
+		return
+	}
+	target := rt.lb.Pick(r, nil)
+	addr := target.Addr()
+	info := reqinfo.From(r.Context())
+	if !breaker.Allow(host, addr) {
+		info.Reject("Breaker")
+		stat.AddGrpc(host, r.URL.Path, CodeUnavailable)
+		WriteStatus(w, CodeUnavailable, "upstream breaker is open")
+		return
+	}
+	info.SetUpstream(reqinfo.UpstreamGrpc, addr)
+
+	// 流式调用可能长时间保持 取消服务器的读写超时
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	c := &call{
+		host:   host,
+		method: r.URL.Path,
+		addr:   addr,
+		scheme: rt.scheme,
+		start:  time.Now(),
+		info:   info,
+		track:  balancer.Track(addr),
+	}
+	// 未读到响应体结尾就结束的调用按客户端取消处理 向客户端写出失败时ReverseProxy会panic中止流
+	defer c.finish(CodeCanceled)
+	s.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callKey{}, c)))
+}
+
+func callFrom(ctx context.Context) *call {
+	c, _ := ctx.Value(callKey{}).(*call)
+	return c
+}
+
+func rewrite(pr *httputil.ProxyRequest) {
+	c := callFrom(pr.In.Context())
+	pr.Out.URL.Scheme = c.scheme
+	pr.Out.URL.Host = c.addr
+	pr.Out.Host = pr.In.Host
+	pr.SetXForwarded()
+}
+
+func modifyResponse(resp *http.Response) error {
+	c := callFrom(resp.Request.Context())
+	c.info.AddUpstreamTime(time.Since(c.start))
+	if resp.StatusCode != http.StatusOK {
+		// 非200响应由客户端按HTTP状态码转换为gRPC状态 原样返回
+		c.finish(httpStatusCode(resp.StatusCode))
+		return nil
+	}
+	resp.Body = &statusBody{ReadCloser: resp.Body, resp: resp, call: c}
+	return nil
+}
+
+func errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	c := callFrom(r.Context())
+	if errors.Is(err, context.Canceled) {
+		c.finish(CodeCanceled)
+		return
+	}
+	logger.L().Debug().Err(err).Str("Host", r.Host).Str("Upstream", c.addr).Msg("grpc upstream error")
+	c.finish(CodeUnavailable)
+	WriteStatus(w, CodeUnavailable, "upstream unavailable")
+}
+
+// hostname 去掉端口
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
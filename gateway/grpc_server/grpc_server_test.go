@@ -0,0 +1,153 @@
+package grpc_server
+
+import (
+	"Hamburger/gateway/stat"
+	"Hamburger/internal/config"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// startProxy 启动h2c上游gRPC服务和转发到该服务的代理 返回代理地址
+func startProxy(t *testing.T) (string, *health.Server) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := grpc.NewServer()
+	hs := health.NewServer()
+	grpc_health_v1.RegisterHealthServer(upstream, hs)
+	go upstream.Serve(lis)
+	t.Cleanup(upstream.Stop)
+
+	port := lis.Addr().(*net.TCPAddr).Port
+	s := NewServer(config.GrpcProxyConfig{
+		Enabled: true,
+		Routes: []config.GrpcRoute{
+			{Authority: "grpc.test", Service: "grpc.health.v1.Health", Upstream: []config.Upstream{{Host: "127.0.0.1", Port: port}}},
+		},
+	})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsGrpcRequest(r) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.ServeHTTP(w, r)
+	}))
+	srv.Config.Protocols = &http.Protocols{}
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv.Listener.Addr().String(), hs
+}
+
+func dial(t *testing.T, addr, authority string) grpc_health_v1.HealthClient {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithAuthority(authority))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return grpc_health_v1.NewHealthClient(conn)
+}
+
+func TestUnaryAndStatus(t *testing.T) {
+	addr, _ := startProxy(t)
+	client := dial(t, addr, "grpc.test")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil || resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Fatalf("check: %v %v", resp, err)
+	}
+	// 上游返回的错误状态码在trailers中原样透传
+	_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	got := stat.GetGrpcStat()["grpc.test/grpc.health.v1.Health/Check"]
+	if got.Codes["OK"] != 1 || got.Codes["NOT_FOUND"] != 1 {
+		t.Fatalf("unexpected stat: %+v", got)
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	addr, hs := startProxy(t)
+	client := dial(t, addr, "grpc.test")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{Service: "svc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []grpc_health_v1.HealthCheckResponse_ServingStatus{
+		grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN,
+		grpc_health_v1.HealthCheckResponse_SERVING,
+		grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+	} {
+		resp, err := stream.Recv()
+		if err != nil || resp.Status != want {
+			t.Fatalf("message %d: %v %v", i, resp, err)
+		}
+		if i == 0 {
+			hs.SetServingStatus("svc", grpc_health_v1.HealthCheckResponse_SERVING)
+		} else if i == 1 {
+			hs.SetServingStatus("svc", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		}
+	}
+}
+
+func TestNoRoute(t *testing.T) {
+	addr, _ := startProxy(t)
+	client := dial(t, addr, "other.test")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected Unimplemented, got %v", err)
+	}
+	// 未匹配的调用不按客户端提供的域名和路径统计
+	if got := stat.GetGrpcStat()[stat.GrpcUnmatched]; got.Codes["UNIMPLEMENTED"] == 0 {
+		t.Fatalf("expected unmatched call recorded, got %+v", got)
+	}
+	if _, ok := stat.GetGrpcStat()["other.test/grpc.health.v1.Health/Check"]; ok {
+		t.Fatal("unmatched call recorded by host and path")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	routes := compileRoutes([]config.GrpcRoute{
+		{Authority: "*.example.com", Service: "pkg.Svc/Get", Upstream: []config.Upstream{{Host: "a", Port: 1}}},
+		{Authority: "api.example.com", Service: "pkg.Svc", Upstream: []config.Upstream{{Host: "b", Port: 2}}},
+		{Upstream: []config.Upstream{{Host: "c", Port: 3}}},
+	})
+	cases := map[string]int{
+		"api.example.com /pkg.Svc/Get":  0,
+		"api.example.com /pkg.Svc/List": 1,
+		"other.com /pkg.Svc/Get":        2,
+	}
+	for in, want := range cases {
+		host, path, _ := strings.Cut(in, " ")
+		rt := match(routes, host, path)
+		if rt == nil || rt.lb.Pick(nil, nil).Port != want+1 {
+			t.Fatalf("%s: expected route %d", in, want)
+		}
+	}
+	if match(routes, "x", "/invalid") != nil {
+		t.Fatal("expected no route for invalid path")
+	}
+}
@@ -0,0 +1,66 @@
+package grpc_server
+
+import (
+	"Hamburger/gateway/balancer"
+	"Hamburger/internal/config"
+	"strings"
+)
+
+// route 编译后的路由
+type route struct {
+	authority string // 空匹配全部
+	wildcard  bool   // authority为*.example.com
+	service   string // 空匹配全部
+	method    string // 空匹配服务的全部方法
+	scheme    string
+	lb        *balancer.LoadBalancer
+}
+
+func compileRoutes(routes []config.GrpcRoute) []*route {
+	compiled := make([]*route, 0, len(routes))
+	for _, r := range routes {
+		lb := balancer.GetStrategyBalancer(r.Balance, balancer.UpstreamTargets(r.Upstream))
+		if lb == nil {
+			continue
+		}
+		rt := &route{authority: strings.ToLower(r.Authority), scheme: "http", lb: lb}
+		if strings.HasPrefix(rt.authority, "*.") {
+			rt.wildcard = true
+			rt.authority = rt.authority[1:]
+		}
+		rt.service, rt.method, _ = strings.Cut(r.Service, "/")
+		if r.TLS {
+			rt.scheme = "https"
+		}
+		compiled = append(compiled, rt)
+	}
+	return compiled
+}
+
+// match 按配置顺序返回第一个匹配的路由 path为/pkg.Service/Method
+func match(routes []*route, host, path string) *route {
+	service, method, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok {
+		return nil
+	}
+	host = strings.ToLower(host)
+	for _, rt := range routes {
+		switch {
+		case rt.authority == "":
+		case rt.wildcard:
+			if !strings.HasSuffix(host, rt.authority) {
+				continue
+			}
+		case rt.authority != host:
+			continue
+		}
+		if rt.service != "" && rt.service != service {
+			continue
+		}
+		if rt.method != "" && rt.method != method {
+			continue
+		}
+		return rt
+	}
+	return nil
+}
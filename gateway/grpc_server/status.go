@@ -0,0 +1,112 @@
+package grpc_server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gRPC状态码 只列出网关使用到的
+const (
+	CodeOK                = 0
+	CodeCanceled          = 1
+	CodeUnknown           = 2
+	CodeDeadlineExceeded  = 4
+	CodePermissionDenied  = 7
+	CodeResourceExhausted = 8
+	CodeUnimplemented     = 12
+	CodeInternal          = 13
+	CodeUnavailable       = 14
+	CodeDataLoss          = 15
+	CodeUnauthenticated   = 16
+)
+
+// isFailure 是否为上游故障 业务错误不计入熔断
+func isFailure(code int) bool {
+	switch code {
+	case CodeUnknown, CodeDeadlineExceeded, CodeInternal, CodeUnavailable, CodeDataLoss:
+		return true
+	}
+	return false
+}
+
+// httpStatusCode 非200响应对应的gRPC状态码 与gRPC客户端的转换规则一致
+func httpStatusCode(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInternal
+	case http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case http.StatusForbidden:
+		return CodePermissionDenied
+	case http.StatusNotFound:
+		return CodeUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return CodeUnavailable
+	}
+	return CodeUnknown
+}
+
+// WriteStatus 写出只有响应头的gRPC错误响应
+func WriteStatus(w http.ResponseWriter, code int, msg string) {
+	h := w.Header()
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		h.Set("Grpc-Message", encodeMessage(msg))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// encodeMessage grpc-message需要对非可打印ASCII字符和%做百分号编码
+func encodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= 0x20 && c <= 0x7e && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteString(strings.ToUpper(strconv.FormatUint(uint64(c)>>4, 16)))
+		b.WriteString(strings.ToUpper(strconv.FormatUint(uint64(c)&0xf, 16)))
+	}
+	return b.String()
+}
+
+// statusBody 读到响应体结尾时从trailers获取gRPC状态码
+type statusBody struct {
+	io.ReadCloser
+	resp *http.Response
+	call *call
+}
+
+func (b *statusBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	switch {
+	case err == nil:
+	case errors.Is(err, io.EOF):
+		b.call.finish(responseCode(b.resp))
+	case b.resp.Request.Context().Err() != nil:
+		b.call.finish(CodeCanceled)
+	default:
+		// 上游在发送trailers之前断开
+		b.call.finish(CodeUnavailable)
+	}
+	return n, err
+}
+
+// responseCode 响应的gRPC状态码 只有响应头的响应状态码在响应头中
+func responseCode(resp *http.Response) int {
+	value := resp.Trailer.Get("Grpc-Status")
+	if value == "" {
+		value = resp.Header.Get("Grpc-Status")
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return CodeUnknown
+	}
+	return code
+}
@@ -0,0 +1,68 @@
+package health
+
+import (
+	"Hamburger/internal/structure"
+	"net"
+	"sort"
+	"strconv"
+)
+
+// 手动摘除上游
+// 按上游的host:port记录 不同主机上相同端口的上游互不影响
+// 被摘除的上游不再参与负载均衡 已建立的连接不受影响 与健康检查是否启用无关
+
+var drained = structure.NewMap[DrainedPort]()
+
+// DrainedPort 被摘除的上游
+type DrainedPort struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+// Drain 摘除上游 host为空时为本机端口
+func Drain(host string, port int) {
+	host = upstreamHost(host)
+	drained.Put(upstreamKey(host, port), DrainedPort{Host: host, Port: port})
+}
+
+// Undrain 恢复被摘除的上游 上游未被摘除时返回false
+func Undrain(host string, port int) bool {
+	key := upstreamKey(upstreamHost(host), port)
+	if !drained.Exist(key) {
+		return false
+	}
+	drained.Delete(key)
+	return true
+}
+
+// IsDrained 判断上游是否被摘除
+func IsDrained(host string, port int) bool {
+	return drained.Exist(upstreamKey(upstreamHost(host), port))
+}
+
+// Drained 获取全部被摘除的上游
+func Drained() []DrainedPort {
+	result := make([]DrainedPort, 0, drained.Size())
+	drained.Range(func(key string, value DrainedPort) bool {
+		result = append(result, value)
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Host == result[j].Host {
+			return result[i].Port < result[j].Port
+		}
+		return result[i].Host < result[j].Host
+	})
+	return result
+}
+
+func upstreamHost(host string) string {
+	if host == "" {
+		return probeHost
+	}
+	return host
+}
+
+func upstreamKey(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
@@ -0,0 +1,253 @@
+// Package health
+// 后端端口健康检查
+package health
+
+import (
+	"Hamburger/gateway/runtime"
+	"Hamburger/internal/config"
+	"Hamburger/internal/job_syncer"
+	"Hamburger/internal/structure"
+	"Hamburger/internal/utils"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// 周期性探测DomainPortsMap中登记的端口
+// 连续成功rise次标记为up 连续失败fall次标记为down
+// 负载均衡时跳过被标记为down的端口
+
+const (
+	DefaultInterval = 10 // 秒
+	DefaultTimeout  = 2  // 秒
+	DefaultRise     = 2
+	DefaultFall     = 3
+
+	probeHost = "127.0.0.1" // 单机服务的端口都在本地
+)
+
+var checker *Checker
+
+// Status 实例健康状态
+type Status struct {
+	Domain    string    `json:"domain"`
+	Port      int       `json:"port"`
+	Up        bool      `json:"up"`
+	Successes int       `json:"successes"` // 连续成功次数
+	Failures  int       `json:"failures"`  // 连续失败次数
+	LastCheck time.Time `json:"last_check"`
+	LastError string    `json:"last_error"`
+}
+
+type instanceState struct {
+	mu     sync.Mutex
+	status Status
+}
+
+type Checker struct {
+	cfg     *config.HealthCheckConfig
+	logger  *zerolog.Logger
+	client  *http.Client
+	states  *structure.Map[*instanceState] // domain:port -> 状态
+	rise    int
+	fall    int
+	timeout time.Duration
+}
+
+// InitHealthChecker 初始化健康检查 未启用时所有端口视为可用
+func InitHealthChecker(cfg *config.HealthCheckConfig, logger *zerolog.Logger) {
+	if !cfg.Enabled {
+		return
+	}
+	checker = NewChecker(cfg, logger)
+	// 立即探测一次
+	go checker.CheckAll()
+
+	job_syncer.NewJobSyncer(logger,
+		"health-check",
+		utils.ToSecond(utils.DefaultInt(cfg.Interval, DefaultInterval)),
+		checker.CheckAll).Start()
+}
+
+func NewChecker(cfg *config.HealthCheckConfig, logger *zerolog.Logger) *Checker {
+	timeout := utils.ToSecond(utils.DefaultInt(cfg.Timeout, DefaultTimeout))
+	return &Checker{
+		cfg:    cfg,
+		logger: logger,
+		client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		states:  structure.NewMap[*instanceState](),
+		rise:    utils.DefaultInt(cfg.Rise, DefaultRise),
+		fall:    utils.DefaultInt(cfg.Fall, DefaultFall),
+		timeout: timeout,
+	}
+}
+
+// IsUp 判断域名端口组中的本机端口是否可用
+// 手动摘除的端口不可用 未启用健康检查或尚未探测的端口默认可用
+func IsUp(domain string, port int) bool {
+	return IsUpstreamUp(domain, probeHost, port)
+}
+
+// IsUpstreamUp 判断域名的上游是否可用 健康检查只探测本机端口
+func IsUpstreamUp(domain, host string, port int) bool {
+	if IsDrained(host, port) {
+		return false
+	}
+	if checker == nil || host != probeHost {
+		return true
+	}
+	return checker.IsUp(domain, port)
+}
+
+// Snapshot 获取全部实例的健康状态
+func Snapshot() []Status {
+	if checker == nil {
+		return []Status{}
+	}
+	return checker.Snapshot()
+}
+
+func (c *Checker) IsUp(domain string, port int) bool {
+	st, ok := c.states.Get(stateKey(domain, port))
+	if !ok {
+		return true
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.status.Up
+}
+
+func (c *Checker) Snapshot() []Status {
+	result := make([]Status, 0, c.states.Size())
+	c.states.Range(func(key string, st *instanceState) bool {
+		st.mu.Lock()
+		result = append(result, st.status)
+		st.mu.Unlock()
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Domain == result[j].Domain {
+			return result[i].Port < result[j].Port
+		}
+		return result[i].Domain < result[j].Domain
+	})
+	return result
+}
+
+// CheckAll 并发探测所有已登记的端口 并清理已注销端口的状态
+func (c *Checker) CheckAll() {
+	alive := make(map[string]struct{})
+	var wg sync.WaitGroup
+	runtime.DomainPortsMap.Range(func(domain string, ports []int) bool {
+		for _, port := range ports {
+			alive[stateKey(domain, port)] = struct{}{}
+			st := c.getState(domain, port)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.probe(st)
+			}()
+		}
+		return true
+	})
+	wg.Wait()
+
+	for _, key := range c.states.Keys() {
+		if _, ok := alive[key]; !ok {
+			c.states.Delete(key)
+		}
+	}
+}
+
+func (c *Checker) getState(domain string, port int) *instanceState {
+	key := stateKey(domain, port)
+	if st, ok := c.states.Get(key); ok {
+		return st
+	}
+	st := &instanceState{status: Status{Domain: domain, Port: port, Up: true}}
+	c.states.Put(key, st)
+	return st
+}
+
+// probe 执行一次探测并根据阈值更新状态
+func (c *Checker) probe(st *instanceState) {
+	err := c.check(st.status.Domain, st.status.Port)
+
+	st.mu.Lock()
+	s := &st.status
+	s.LastCheck = time.Now()
+	changed := false
+	if err == nil {
+		s.Successes++
+		s.Failures = 0
+		s.LastError = ""
+		if !s.Up && s.Successes >= c.rise {
+			s.Up = true
+			changed = true
+		}
+	} else {
+		s.Failures++
+		s.Successes = 0
+		s.LastError = err.Error()
+		if s.Up && s.Failures >= c.fall {
+			s.Up = false
+			changed = true
+		}
+	}
+	status := *s
+	st.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if status.Up {
+		c.logger.Info().Str("domain", status.Domain).Int("port", status.Port).Msg("backend instance is up")
+	} else {
+		c.logger.Warn().Str("domain", status.Domain).Int("port", status.Port).Str("error", status.LastError).Msg("backend instance is down")
+	}
+}
+
+// check 配置了探测路径时使用HTTP探测 否则仅检查TCP连接
+func (c *Checker) check(domain string, port int) error {
+	addr := net.JoinHostPort(probeHost, strconv.Itoa(port))
+	path := c.cfg.Paths[domain]
+	if path == "" {
+		conn, err := net.DialTimeout("tcp", addr, c.timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Host = domain
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unhealthy status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func stateKey(domain string, port int) string {
+	return domain + ":" + strconv.Itoa(port)
+}
@@ -0,0 +1,121 @@
+package health
+
+import (
+	"Hamburger/internal/config"
+	"net"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func newTestChecker(paths map[string]string) *Checker {
+	logger := zerolog.Nop()
+	return NewChecker(&config.HealthCheckConfig{
+		Enabled: true,
+		Timeout: 1,
+		Rise:    2,
+		Fall:    2,
+		Paths:   paths,
+	}, &logger)
+}
+
+// TestProbeRiseFall 测试连续失败和连续成功的阈值切换
+func TestProbeRiseFall(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	c := newTestChecker(nil)
+	st := c.getState("test.renj.io", port)
+
+	c.probe(st)
+	if !c.IsUp("test.renj.io", port) {
+		t.Fatal("Expected instance to be up while listener is open")
+	}
+
+	ln.Close()
+	c.probe(st)
+	if !c.IsUp("test.renj.io", port) {
+		t.Error("Expected instance to stay up before reaching fall threshold")
+	}
+	c.probe(st)
+	if c.IsUp("test.renj.io", port) {
+		t.Error("Expected instance to be down after reaching fall threshold")
+	}
+
+	// 重新监听同一端口
+	ln, err = net.Listen("tcp", ln.Addr().String())
+	if err != nil {
+		t.Skipf("port reuse not available: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	c.probe(st)
+	if c.IsUp("test.renj.io", port) {
+		t.Error("Expected instance to stay down before reaching rise threshold")
+	}
+	c.probe(st)
+	if !c.IsUp("test.renj.io", port) {
+		t.Error("Expected instance to be up after reaching rise threshold")
+	}
+}
+
+// TestIsUpWithoutChecker 测试未启用健康检查时默认可用
+func TestIsUpWithoutChecker(t *testing.T) {
+	if !IsUp("unknown.renj.io", 1) {
+		t.Error("Expected unknown instance to be treated as up")
+	}
+	c := newTestChecker(nil)
+	if !c.IsUp("unknown.renj.io", 1) {
+		t.Error("Expected instance without state to be treated as up")
+	}
+}
+
+// TestDrain 测试手动摘除和恢复上游
+func TestDrain(t *testing.T) {
+	Drain("", 8080)
+	if IsUp("drain.renj.io", 8080) {
+		t.Error("Expected drained port to be down")
+	}
+	if !IsUp("drain.renj.io", 8081) {
+		t.Error("Expected other port to stay up")
+	}
+	if !IsUpstreamUp("drain.renj.io", "10.0.0.2", 8080) {
+		t.Error("Expected same port on another host to stay up")
+	}
+	if len(Drained()) != 1 || Drained()[0].Host != "127.0.0.1" {
+		t.Errorf("Expected 1 drained local port, got %v", Drained())
+	}
+	if !Undrain("127.0.0.1", 8080) || Undrain("", 8080) {
+		t.Error("Expected undrain to succeed only once")
+	}
+	if !IsUp("drain.renj.io", 8080) {
+		t.Error("Expected undrained port to be up")
+	}
+
+	Drain("10.0.0.2", 9000)
+	defer Undrain("10.0.0.2", 9000)
+	if IsUpstreamUp("custom.renj.io", "10.0.0.2", 9000) || !IsUpstreamUp("custom.renj.io", "10.0.0.3", 9000) {
+		t.Error("Expected only the drained host to be down")
+	}
+}
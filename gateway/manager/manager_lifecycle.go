@@ -32,7 +32,7 @@ func NewManager(cfg *config.Config, logger *zerolog.Logger, handler http.Handler
 	}
 	tlsManager.InitCertMap()
 	tlsManager.RegisterBeforeAutoCert(m.beforeHandleAutoCert)
-	tlsManager.RegisterBeforeAutoCert(m.afterHandleAutoCert)
+	tlsManager.RegisterAfterAutoCert(m.afterHandleAutoCert)
 	m.tlsManager = tlsManager
 
 	return m
@@ -79,6 +79,13 @@ func (m *Manager) Start() error {
 		return fmt.Errorf("no servers started successfully")
 	}
 
+	// 后台重新加载变化的证书并刷新OCSP装订 启用tls-alpn-01或dns-01时同时申请证书
+	m.wg.Add(1)
+	go func(ctx context.Context) {
+		defer m.wg.Done()
+		m.tlsManager.Watch(ctx)
+	}(m.ctx)
+
 	m.started = true
 	m.logger.Info().Msgf("[Gateway] server manager started, successfully started %d servers", len(m.servers))
 
@@ -324,3 +331,37 @@ func GetEnabledServers(cf *config.Config) []config.ServerConfig {
 	}
 	return enabled
 }
+
+// Reload 热重载配置 替换TLS证书映射
+// 监听地址和协议的变化需要重启进程才能生效
+func (m *Manager) Reload(cfg *config.Config) error {
+	if err := m.tlsManager.Reload(cfg); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if listenersChanged(m.config, cfg) {
+		m.logger.Warn().Msg("[Gateway] server listeners changed, restart is required to take effect")
+	}
+	m.config = cfg
+	return nil
+}
+
+// listenersChanged 比较启用的服务器监听配置是否变化
+func listenersChanged(old, cfg *config.Config) bool {
+	oldServers := GetEnabledServers(old)
+	newServers := GetEnabledServers(cfg)
+	if len(oldServers) != len(newServers) {
+		return true
+	}
+	for i := range oldServers {
+		if oldServers[i].Name != newServers[i].Name ||
+			oldServers[i].Host != newServers[i].Host ||
+			oldServers[i].Port != newServers[i].Port ||
+			oldServers[i].Protocol != newServers[i].Protocol {
+			return true
+		}
+	}
+	return old.Features.HTTP3.Enabled != cfg.Features.HTTP3.Enabled
+}
@@ -0,0 +1,148 @@
+// Package metrics
+// Prometheus指标 不依赖第三方库 以文本格式输出
+package metrics
+
+import (
+	"Hamburger/gateway/breaker"
+	"Hamburger/gateway/reqinfo"
+	"bufio"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 指标列表
+// hamburger_requests_total             请求数 按域名、状态码分类和上游类型(frontend/backend/custom/none)
+// hamburger_request_duration_seconds   请求耗时直方图 按域名和上游类型
+// hamburger_requests_by_protocol_total 请求数 按协议(h1/h2/h3)
+// hamburger_upstream_errors_total      上游错误 按域名、上游地址和错误类型(error/5xx)
+// hamburger_breaker_state              熔断状态 0关闭 1熔断 2半开
+// hamburger_flow_control_blocked_total 被流控拒绝的请求 按域名和规则
+// hamburger_gzip_original_bytes_total  gzip压缩前字节数
+// hamburger_gzip_compressed_bytes_total gzip压缩后字节数 与压缩前之差为节省的流量
+// hamburger_tls_handshakes_total       TLS握手次数 按SNI、TLS版本和是否会话复用
+
+var (
+	enabled  atomic.Bool
+	registry = &Registry{}
+
+	requests = NewCounterVec("hamburger_requests_total",
+		"Total number of proxied requests.", "domain", "code", "upstream_type")
+	duration = NewHistogramVec("hamburger_request_duration_seconds",
+		"Request latency in seconds.", nil, "domain", "upstream_type")
+	protocols = NewCounterVec("hamburger_requests_by_protocol_total",
+		"Total number of requests by HTTP protocol.", "protocol")
+	upstreamErrors = NewCounterVec("hamburger_upstream_errors_total",
+		"Total number of failed upstream requests.", "domain", "upstream", "kind")
+	flowBlocked = NewCounterVec("hamburger_flow_control_blocked_total",
+		"Total number of requests blocked by flow control.", "domain", "rule")
+	gzipOriginal = NewCounterVec("hamburger_gzip_original_bytes_total",
+		"Total response bytes before gzip compression.", "domain")
+	gzipCompressed = NewCounterVec("hamburger_gzip_compressed_bytes_total",
+		"Total response bytes after gzip compression.", "domain")
+	tlsHandshakes = NewCounterVec("hamburger_tls_handshakes_total",
+		"Total number of completed TLS handshakes.", "sni", "version", "resumed")
+)
+
+func init() {
+	for _, v := range []*vec{
+		requests.vec, duration.vec, protocols.vec, upstreamErrors.vec,
+		flowBlocked.vec, gzipOriginal.vec, gzipCompressed.vec, tlsHandshakes.vec,
+	} {
+		registry.register(v)
+	}
+	registry.Collect(collectBreaker)
+}
+
+// Enable 开启指标记录 未开启时记录函数直接返回
+func Enable() {
+	enabled.Store(true)
+}
+
+// Enabled 是否开启指标记录
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// ObserveRequest 记录请求结果
+func ObserveRequest(req *http.Request, info *reqinfo.Info, status int) {
+	if !enabled.Load() || info == nil {
+		return
+	}
+	requests.Inc(req.Host, statusClass(status), info.UpstreamType)
+	duration.Observe(time.Since(info.Start).Seconds(), req.Host, info.UpstreamType)
+	protocols.Inc(reqinfo.Protocol(req))
+}
+
+// UpstreamError 记录上游请求失败 kind为error(连接失败等)或5xx
+func UpstreamError(domain, upstream, kind string) {
+	if !enabled.Load() {
+		return
+	}
+	upstreamErrors.Inc(domain, upstream, kind)
+}
+
+// FlowBlocked 记录被流控拒绝的请求 全局限流的规则名为global
+func FlowBlocked(domain, rule string) {
+	if !enabled.Load() {
+		return
+	}
+	if rule == "" {
+		rule = "global"
+	}
+	flowBlocked.Inc(domain, rule)
+}
+
+// Gzip 记录gzip压缩前后的字节数
+func Gzip(domain string, original, compressed int) {
+	if !enabled.Load() {
+		return
+	}
+	gzipOriginal.Add(uint64(original), domain)
+	gzipCompressed.Add(uint64(compressed), domain)
+}
+
+// TLSHandshake 记录完成的TLS握手
+func TLSHandshake(sni, version string, resumed bool) {
+	if !enabled.Load() {
+		return
+	}
+	if sni == "" {
+		sni = "none"
+	}
+	tlsHandshakes.Inc(sni, version, strconv.FormatBool(resumed))
+}
+
+func statusClass(status int) string {
+	if status <= 0 {
+		status = http.StatusOK
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+func collectBreaker(w *bufio.Writer) {
+	snapshot := breaker.Snapshot()
+	gauges := make([]Gauge, 0, len(snapshot))
+	for _, status := range snapshot {
+		value := float64(breaker.Closed)
+		switch status.State {
+		case breaker.Open.String():
+			value = float64(breaker.Open)
+		case breaker.HalfOpen.String():
+			value = float64(breaker.HalfOpen)
+		}
+		gauges = append(gauges, Gauge{Values: []string{status.Domain, status.Upstream}, Value: value})
+	}
+	writeGauges(w, "hamburger_breaker_state",
+		"Upstream circuit breaker state: 0 closed, 1 open, 2 half-open.",
+		[]string{"domain", "upstream"}, gauges)
+}
+
+// Handler 输出全部指标
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = registry.WriteTo(w)
+	})
+}
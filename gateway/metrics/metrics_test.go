@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestExposition 测试计数器和直方图的文本格式
+func TestExposition(t *testing.T) {
+	r := &Registry{}
+	c := NewCounterVec("test_requests_total", "Test counter.", "domain", "code")
+	h := NewHistogramVec("test_duration_seconds", "Test histogram.", []float64{0.1, 1}, "domain")
+	r.register(c.vec)
+	r.register(h.vec)
+
+	c.Inc("a.renj.io", "2xx")
+	c.Add(2, `b"\.renj.io`, "5xx")
+	h.Observe(0.05, "a.renj.io")
+	h.Observe(0.5, "a.renj.io")
+	h.Observe(5, "a.renj.io")
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE test_requests_total counter",
+		`test_requests_total{domain="a.renj.io",code="2xx"} 1`,
+		`test_requests_total{domain="b\"\\.renj.io",code="5xx"} 2`,
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{domain="a.renj.io",le="0.1"} 1`,
+		`test_duration_seconds_bucket{domain="a.renj.io",le="1"} 2`,
+		`test_duration_seconds_bucket{domain="a.renj.io",le="+Inf"} 3`,
+		`test_duration_seconds_sum{domain="a.renj.io"} 5.55`,
+		`test_duration_seconds_count{domain="a.renj.io"} 3`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected line %q in output:\n%s", line, out)
+		}
+	}
+}
+
+// TestMaxSeries 测试标签组合超出上限后合并
+func TestMaxSeries(t *testing.T) {
+	c := NewCounterVec("test_overflow_total", "Test overflow.", "domain")
+	for i := 0; i < maxSeries+10; i++ {
+		c.Inc(strconv.Itoa(i))
+	}
+	if len(c.series) != maxSeries+1 {
+		t.Errorf("Expected %d series, got %d", maxSeries+1, len(c.series))
+	}
+	if s := c.get([]string{otherLabel}); s.count.Load() != 10 {
+		t.Errorf("Expected 10 requests in other series, got %d", s.count.Load())
+	}
+}
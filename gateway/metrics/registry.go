@@ -0,0 +1,253 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Prometheus文本格式的最小实现 只支持counter gauge和histogram
+// 每个指标的标签组合数量有上限 超出后的新组合合并到全部标签为other的序列 避免Host等外部输入造成内存膨胀
+
+const (
+	maxSeries  = 1000
+	otherLabel = "other"
+)
+
+var defaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type series struct {
+	values  []string
+	count   atomic.Uint64
+	buckets []atomic.Uint64 // histogram 各区间计数 非累计
+	sum     atomic.Uint64   // histogram 总和 float64的位表示
+}
+
+type vec struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.RWMutex
+	series map[string]*series
+}
+
+func newVec(kind, name, help string, buckets []float64, labels ...string) *vec {
+	return &vec{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+}
+
+// CounterVec 带标签的计数器
+type CounterVec struct{ *vec }
+
+// HistogramVec 带标签的直方图
+type HistogramVec struct{ *vec }
+
+func NewCounterVec(name, help string, labels ...string) CounterVec {
+	return CounterVec{newVec("counter", name, help, nil, labels...)}
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) HistogramVec {
+	if len(buckets) == 0 {
+		buckets = defaultBuckets
+	}
+	return HistogramVec{newVec("histogram", name, help, buckets, labels...)}
+}
+
+// Add 增加计数
+func (c CounterVec) Add(delta uint64, values ...string) {
+	c.get(values).count.Add(delta)
+}
+
+// Inc 计数加一
+func (c CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Observe 记录一次观测值
+func (h HistogramVec) Observe(value float64, values ...string) {
+	s := h.get(values)
+	i := sort.SearchFloat64s(h.buckets, value)
+	if i < len(s.buckets) {
+		s.buckets[i].Add(1)
+	}
+	s.count.Add(1)
+	for {
+		old := s.sum.Load()
+		if s.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+value)) {
+			return
+		}
+	}
+}
+
+func (v *vec) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok = v.series[key]; ok {
+		return s
+	}
+	if len(v.series) >= maxSeries {
+		values = make([]string, len(v.labels))
+		for i := range values {
+			values[i] = otherLabel
+		}
+		key = strings.Join(values, "\xff")
+		if s, ok = v.series[key]; ok {
+			return s
+		}
+	}
+	s = &series{values: cloneValues(values)}
+	if v.kind == "histogram" {
+		s.buckets = make([]atomic.Uint64, len(v.buckets))
+	}
+	v.series[key] = s
+	return s
+}
+
+func cloneValues(values []string) []string {
+	return append([]string(nil), values...)
+}
+
+func (v *vec) write(w *bufio.Writer) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	list := make([]*series, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, v.series[key])
+	}
+	v.mu.RUnlock()
+
+	writeHeader(w, v.name, v.help, v.kind)
+	for _, s := range list {
+		if v.kind != "histogram" {
+			writeSample(w, v.name, v.labels, s.values, "", "", float64(s.count.Load()))
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range v.buckets {
+			cumulative += s.buckets[i].Load()
+			writeSample(w, v.name+"_bucket", v.labels, s.values, "le", formatFloat(bound), float64(cumulative))
+		}
+		count := s.count.Load()
+		writeSample(w, v.name+"_bucket", v.labels, s.values, "le", "+Inf", float64(count))
+		writeSample(w, v.name+"_sum", v.labels, s.values, "", "", math.Float64frombits(s.sum.Load()))
+		writeSample(w, v.name+"_count", v.labels, s.values, "", "", float64(count))
+	}
+}
+
+// Gauge 采集时计算的指标值
+type Gauge struct {
+	Values []string
+	Value  float64
+}
+
+// writeGauges 输出采集时计算的gauge
+func writeGauges(w *bufio.Writer, name, help string, labels []string, gauges []Gauge) {
+	writeHeader(w, name, help, "gauge")
+	for _, g := range gauges {
+		writeSample(w, name, labels, g.Values, "", "", g.Value)
+	}
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	w.WriteString("# HELP " + name + " " + help + "\n")
+	w.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(label + `="` + escapeLabel(values[i]) + `"`)
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extraLabel + `="` + extraValue + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Registry 指标集合
+type Registry struct {
+	vecs       []*vec
+	collectors []func(w *bufio.Writer)
+}
+
+func (r *Registry) register(v *vec) {
+	r.vecs = append(r.vecs, v)
+}
+
+// Collect 注册采集时计算的指标
+func (r *Registry) Collect(fn func(w *bufio.Writer)) {
+	r.collectors = append(r.collectors, fn)
+}
+
+// WriteTo 以Prometheus文本格式输出全部指标
+func (r *Registry) WriteTo(out io.Writer) (int64, error) {
+	cw := &countWriter{w: out}
+	w := bufio.NewWriter(cw)
+	for _, v := range r.vecs {
+		v.write(w)
+	}
+	for _, fn := range r.collectors {
+		fn(w)
+	}
+	err := w.Flush()
+	return cw.n, err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
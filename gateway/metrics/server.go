@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"Hamburger/internal/config"
+	"Hamburger/internal/upgrade"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const DefaultPath = "/metrics"
+
+// MetricsServer 指标采集服务 启用监控且开启Prometheus时运行
+type MetricsServer struct {
+	Enabled bool
+	Addr    string
+	Path    string
+
+	logger *zerolog.Logger
+	server *http.Server
+}
+
+func NewMetricsServer(c config.MonitorConfig, l *zerolog.Logger) *MetricsServer {
+	path := c.Path
+	if path == "" {
+		path = DefaultPath
+	}
+	s := &MetricsServer{
+		Enabled: c.Enabled && c.Prometheus,
+		Addr:    fmt.Sprintf("%s:%d", c.Host, c.Port),
+		Path:    path,
+		logger:  l,
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET "+path, Handler())
+	s.server = &http.Server{
+		Addr:              s.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if s.Enabled {
+		Enable()
+	}
+	return s
+}
+
+func (s *MetricsServer) Start() error {
+	if !s.Enabled {
+		return nil
+	}
+	s.logger.Info().Str("address", s.Addr).Str("path", s.Path).Msg("start metrics server")
+
+	ln, err := upgrade.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		err := s.server.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error().Err(err).Msg("metrics server listen err")
+		}
+	}()
+	return nil
+}
+
+func (s *MetricsServer) Stop() error {
+	if !s.Enabled {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
@@ -64,8 +64,11 @@ func (c *CorsHeaderModifier) IsEnabled() bool {
 }
 
 func (c *CorsHeaderModifier) UpdateConfig() {
-	//TODO implement me
-	panic("implement me")
+	cfg := config.Get()
+	c.enabled = cfg.Middleware.CORS.Enabled
+	c.headers = cfg.Middleware.CORS.Header
+	c.methods = cfg.Middleware.CORS.Method
+	c.origins = cfg.Middleware.CORS.Origin
 }
 
 func (c *CorsHeaderModifier) GetName() string {
@@ -8,6 +8,7 @@ Copyright Renj
 package modifier
 
 import (
+	"Hamburger/gateway/metrics"
 	"Hamburger/internal/config"
 	"Hamburger/internal/logger"
 	"bytes"
@@ -129,6 +130,9 @@ func (g *GzipModifier) ModifyResponse(response *http.Response) error {
 
 	// 设置新的响应体
 	response.Body = io.NopCloser(bytes.NewReader(compressedBody))
+	if response.Request != nil {
+		metrics.Gzip(response.Request.Host, len(originalBody), len(compressedBody))
+	}
 
 	if config.Get().Debug {
 		logger.GetLogger().Debug().
@@ -19,7 +19,7 @@ import (
 type ModifierManager struct {
 	chain     *ModifierChain
 	lock      sync.RWMutex
-	modifiers []Modifier
+	modifiers []Modifier // 通过AddCustomModifier添加的自定义修改器 重载时保留
 }
 
 var (
@@ -50,18 +50,45 @@ func NewModifierManager() *ModifierManager {
 // InitModifiers 注册全部中间件 根据配置激活
 func InitModifiers() {
 	mm := GetManager()
-	// add trace
-	mm.RegisterModifier(NewTraceModifier())
-	// add secure header
-	mm.RegisterModifier(NewSecureHeaderModifier())
-	// no cache
-	mm.RegisterModifier(NewNoCache())
-	// custom header
-	mm.RegisterModifier(NewCustomHeaderModifier())
-	// 应用gzip压缩中间件
-	mm.RegisterModifier(NewGzipModifier())
-	// 应用cors
-	mm.RegisterModifier(NewCorsHeaderModifier())
+	for _, mod := range defaultModifiers() {
+		mm.RegisterModifier(mod)
+	}
+}
+
+// defaultModifiers 按当前配置创建内置的修改器 顺序即执行顺序
+func defaultModifiers() []Modifier {
+	return []Modifier{
+		// add trace
+		NewTraceModifier(),
+		// add secure header
+		NewSecureHeaderModifier(),
+		// no cache
+		NewNoCache(),
+		// custom header
+		NewCustomHeaderModifier(),
+		// 应用gzip压缩中间件
+		NewGzipModifier(),
+		// 应用cors
+		NewCorsHeaderModifier(),
+		// 会话保持 需在secure header之后追加Set-Cookie
+		NewStickySessionModifier(),
+	}
+}
+
+// Reload 按最新配置重建修改器链并整体替换 自定义修改器追加在内置修改器之后
+func (mm *ModifierManager) Reload() {
+	chain := NewModifierChain()
+	for _, mod := range defaultModifiers() {
+		chain.AddModifier(mod)
+	}
+
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+	for _, mod := range mm.modifiers {
+		chain.AddModifier(mod)
+	}
+	mm.chain = chain
+	logger.GetLogger().Debug().Int("count", len(chain.modifiers)).Msg("modifier chain reloaded")
 }
 
 func (mm *ModifierManager) RegisterModifier(modifier Modifier) {
@@ -72,7 +99,10 @@ func (mm *ModifierManager) RegisterModifier(modifier Modifier) {
 
 // ModifyResponse 对响应应用所有启用的修改器
 func (mm *ModifierManager) ModifyResponse(response *http.Response) error {
-	return mm.chain.ModifyResponse(response)
+	mm.lock.RLock()
+	chain := mm.chain
+	mm.lock.RUnlock()
+	return chain.ModifyResponse(response)
 }
 
 // UpdateConfig 更新所有修改器的配置
@@ -94,7 +124,10 @@ func (mm *ModifierManager) GetModifierByName(name string) Modifier {
 // AddCustomModifier 添加自定义修改器
 func (mm *ModifierManager) AddCustomModifier(modifier Modifier) {
 	if modifier != nil {
+		mm.lock.Lock()
 		mm.chain.AddModifier(modifier)
+		mm.modifiers = append(mm.modifiers, modifier)
+		mm.lock.Unlock()
 		logger.GetLogger().Debug().Str("name", modifier.GetName()).Msg("custom modifier added")
 	}
 }
@@ -17,7 +17,7 @@ func NewNoCache() *NoCache {
 	return mod
 }
 
-func (n NoCache) Use(response *http.Response) {
+func (n *NoCache) Use(response *http.Response) {
 	if !n.enabled {
 		return
 	}
@@ -32,20 +32,19 @@ func (n NoCache) Use(response *http.Response) {
 	}
 }
 
-func (n NoCache) ModifyResponse(response *http.Response) error {
+func (n *NoCache) ModifyResponse(response *http.Response) error {
 	n.Use(response)
 	return nil
 }
 
-func (n NoCache) IsEnabled() bool {
+func (n *NoCache) IsEnabled() bool {
 	return n.enabled
 }
 
-func (n NoCache) UpdateConfig() {
-	//TODO implement me
-	panic("implement me")
+func (n *NoCache) UpdateConfig() {
+	n.enabled = config.Get().Middleware.NoCache
 }
 
-func (n NoCache) GetName() string {
+func (n *NoCache) GetName() string {
 	return "nocache"
 }
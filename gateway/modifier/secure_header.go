@@ -17,11 +17,11 @@ func NewSecureHeaderModifier() *SecureHeaderModifier {
 	return mod
 }
 
-func (s SecureHeaderModifier) Use(response *http.Response) {
+func (s *SecureHeaderModifier) Use(response *http.Response) {
 	_ = s.ModifyResponse(response)
 }
 
-func (s SecureHeaderModifier) ModifyResponse(response *http.Response) error {
+func (s *SecureHeaderModifier) ModifyResponse(response *http.Response) error {
 	if !s.enable {
 		return nil
 	}
@@ -29,15 +29,14 @@ func (s SecureHeaderModifier) ModifyResponse(response *http.Response) error {
 	return nil
 }
 
-func (s SecureHeaderModifier) IsEnabled() bool {
+func (s *SecureHeaderModifier) IsEnabled() bool {
 	return s.enable
 }
 
-func (s SecureHeaderModifier) UpdateConfig() {
-	//TODO implement me
-	panic("implement me")
+func (s *SecureHeaderModifier) UpdateConfig() {
+	s.enable = config.Get().Middleware.SecureHeader
 }
 
-func (s SecureHeaderModifier) GetName() string {
+func (s *SecureHeaderModifier) GetName() string {
 	return "secure-header"
 }
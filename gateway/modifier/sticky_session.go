@@ -0,0 +1,68 @@
+package modifier
+
+import (
+	"Hamburger/gateway/affinity"
+	"Hamburger/gateway/runtime"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// StickySessionModifier 为开启会话保持的域名下发指向当前后端端口的Cookie
+// 请求已携带指向同一端口的有效Cookie时不重复下发
+type StickySessionModifier struct{}
+
+func NewStickySessionModifier() *StickySessionModifier {
+	return &StickySessionModifier{}
+}
+
+func (s *StickySessionModifier) Use(response *http.Response) {
+	_ = s.ModifyResponse(response)
+}
+
+func (s *StickySessionModifier) ModifyResponse(response *http.Response) error {
+	req := response.Request
+	if req == nil || req.URL == nil {
+		return nil
+	}
+	domain := req.Host
+	if !affinity.Enabled(domain) {
+		return nil
+	}
+
+	// 仅处理转发到后端端口的响应 前端服务不做会话保持
+	_, p, err := net.SplitHostPort(req.URL.Host)
+	if err != nil {
+		return nil
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return nil
+	}
+	ports, ok := runtime.DomainPortsMap.Get(domain)
+	if !ok || !slices.Contains(ports, port) {
+		return nil
+	}
+
+	if cookie, err := req.Cookie(affinity.CookieName()); err == nil {
+		if current, ok := affinity.Verify(domain, cookie.Value); ok && current == port {
+			return nil
+		}
+	}
+	response.Header.Add("Set-Cookie", affinity.NewCookie(domain, port).String())
+	return nil
+}
+
+// IsEnabled 是否开启由域名映射决定
+func (s *StickySessionModifier) IsEnabled() bool {
+	return true
+}
+
+func (s *StickySessionModifier) UpdateConfig() {
+	return
+}
+
+func (s *StickySessionModifier) GetName() string {
+	return "sticky-session"
+}
@@ -0,0 +1,60 @@
+package modifier
+
+import (
+	"Hamburger/gateway/affinity"
+	"Hamburger/gateway/runtime"
+	"Hamburger/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStickySessionModifier(t *testing.T) {
+	const domain = "sticky.example.com"
+	cfg := &config.Config{}
+	cfg.Features.Sticky = config.StickyConfig{Secret: "test-secret"}
+	config.Set(cfg)
+	path := filepath.Join(t.TempDir(), "domains.json")
+	if err := os.WriteFile(path, []byte(`{"`+domain+`": {"backend": "app", "sticky": true}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runtime.InitRuntimeDomains(&config.AppConfig{DomainMap: path})
+	runtime.DomainPortsMap.Put(domain, []int{8080, 8081})
+	defer runtime.DomainPortsMap.Delete(domain)
+
+	respond := func(upstream, cookie string) *http.Response {
+		req := httptest.NewRequest("GET", "http://"+domain+"/", nil)
+		req.URL.Host = upstream
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: affinity.CookieName(), Value: cookie})
+		}
+		resp := &http.Response{Header: http.Header{}, Request: req}
+		_ = NewStickySessionModifier().ModifyResponse(resp)
+		return resp
+	}
+
+	resp := respond("127.0.0.1:8081", "")
+	cookies := resp.Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected affinity cookie, got %v", resp.Header)
+	}
+	if port, ok := affinity.Verify(domain, cookies[0].Value); !ok || port != 8081 {
+		t.Fatalf("cookie points to %d %v", port, ok)
+	}
+
+	// 已指向同一端口时不重复下发 端口变化或签名无效时重新下发
+	if resp := respond("127.0.0.1:8081", cookies[0].Value); len(resp.Cookies()) != 0 {
+		t.Error("cookie reissued for the same port")
+	}
+	if resp := respond("127.0.0.1:8080", cookies[0].Value); len(resp.Cookies()) != 1 {
+		t.Error("cookie not reissued after fallback to another port")
+	}
+	if resp := respond("127.0.0.1:8081", "8081.0.forged"); len(resp.Cookies()) != 1 {
+		t.Error("cookie not reissued for a forged value")
+	}
+	if resp := respond("127.0.0.1:9000", ""); len(resp.Cookies()) != 0 {
+		t.Error("cookie issued for an unregistered port")
+	}
+}
@@ -20,25 +20,27 @@ func NewTraceModifier() *TraceModifier {
 	return mod
 }
 
-func (t TraceModifier) Use(response *http.Response) {
+func (t *TraceModifier) Use(response *http.Response) {
 	if !t.enable {
 		return
 	}
 	utils.AddTrace(response, t.header)
 }
 
-func (t TraceModifier) ModifyResponse(response *http.Response) error {
+func (t *TraceModifier) ModifyResponse(response *http.Response) error {
 	return nil
 }
 
-func (t TraceModifier) IsEnabled() bool {
+func (t *TraceModifier) IsEnabled() bool {
 	return t.enable
 }
 
-func (t TraceModifier) UpdateConfig() {
-	return
+func (t *TraceModifier) UpdateConfig() {
+	cfg := config.Get()
+	t.enable = cfg.Middleware.Trace.Enabled
+	t.header = cfg.Middleware.Trace.TraceId
 }
 
-func (t TraceModifier) GetName() string {
+func (t *TraceModifier) GetName() string {
 	return "trace-id"
 }
@@ -0,0 +1,50 @@
+package prehandler
+
+import (
+	"Hamburger/gateway/clientauth"
+	"Hamburger/gateway/stat"
+	"Hamburger/internal/logger"
+	"Hamburger/internal/serror"
+	"errors"
+	"net/http"
+)
+
+// ClientAuth 校验开启mTLS的域名组的客户端证书
+// 校验通过时把证书主题、SAN和指纹写入转发给上游的请求头 optional模式下未发送证书的请求直接放行
+type ClientAuth struct{}
+
+func NewClientAuth() *ClientAuth {
+	return &ClientAuth{}
+}
+
+func (c *ClientAuth) Handle(r *http.Request) error {
+	clientauth.Strip(r.Header)
+	policy := clientauth.Lookup(r.Host)
+	if policy == nil {
+		return nil
+	}
+	cert, err := policy.Verify(r.TLS)
+	switch {
+	case err == nil:
+		policy.SetHeaders(r.Header, cert)
+		return nil
+	case errors.Is(err, clientauth.ErrNoCertificate) && policy.Mode == clientauth.ModeOptional:
+		return nil
+	case errors.Is(err, clientauth.ErrMisdirected):
+		r.Header.Set(serror.SandwichInternalFlag, serror.SandwichClientCertMisdirected)
+		return err
+	}
+
+	logger.L().Debug().Str("Host", r.Host).Err(err).Msg("client certificate rejected")
+	stat.Add(stat.Blocked)
+	r.Header.Set(serror.SandwichInternalFlag, serror.SandwichClientCertInvalid)
+	return err
+}
+
+func (c *ClientAuth) Name() string {
+	return "ClientAuth"
+}
+
+func (c *ClientAuth) Enabled() bool {
+	return true
+}
@@ -0,0 +1,94 @@
+package prehandler
+
+import (
+	"Hamburger/gateway/clientauth"
+	"Hamburger/internal/config"
+	"Hamburger/internal/serror"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestCA(t *testing.T) string {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestClientAuthHandle(t *testing.T) {
+	caFile := writeTestCA(t)
+	cfg := &config.Config{Servers: []config.ServerConfig{{
+		Name:     "https",
+		Protocol: "https",
+		Enabled:  true,
+		DomainConfig: []config.DomainConfig{
+			{Domains: []string{"admin.example.com"}, ClientAuth: &config.ClientAuthConfig{Mode: clientauth.ModeRequired, CAFiles: []string{caFile}}},
+			{Domains: []string{"api.example.com"}, ClientAuth: &config.ClientAuthConfig{Mode: clientauth.ModeOptional, CAFiles: []string{caFile}}},
+			{Domains: []string{"www.example.com"}},
+		},
+	}}}
+	config.Set(cfg)
+	if err := clientauth.Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = clientauth.Init(&config.Config{}) })
+
+	cases := []struct {
+		name       string
+		host       string
+		serverName string
+		err        error
+		flag       string
+	}{
+		{"required without certificate", "admin.example.com", "admin.example.com", clientauth.ErrNoCertificate, serror.SandwichClientCertInvalid},
+		{"optional without certificate", "api.example.com", "api.example.com", nil, ""},
+		{"reused connection", "admin.example.com", "www.example.com", clientauth.ErrMisdirected, serror.SandwichClientCertMisdirected},
+		{"domain without client auth", "www.example.com", "www.example.com", nil, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "https://"+c.host+"/", nil)
+		req.TLS = &tls.ConnectionState{ServerName: c.serverName}
+		// 客户端伪造的证书信息请求头在任何情况下都被移除
+		req.Header.Set(clientauth.DefaultSubjectHeader, "CN=forged")
+		req.Header.Set(clientauth.DefaultFingerprintHeader, "forged")
+
+		err := NewClientAuth().Handle(req)
+		if !errors.Is(err, c.err) || (c.err == nil && err != nil) {
+			t.Errorf("%s: err = %v, want %v", c.name, err, c.err)
+		}
+		if got := req.Header.Get(serror.SandwichInternalFlag); got != c.flag {
+			t.Errorf("%s: flag = %q, want %q", c.name, got, c.flag)
+		}
+		if req.Header.Get(clientauth.DefaultSubjectHeader) != "" || req.Header.Get(clientauth.DefaultFingerprintHeader) != "" {
+			t.Errorf("%s: forged headers not stripped", c.name)
+		}
+	}
+}
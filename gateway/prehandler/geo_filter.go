@@ -0,0 +1,100 @@
+package prehandler
+
+import (
+	"Hamburger/gateway/clientip"
+	"Hamburger/gateway/geo"
+	"Hamburger/gateway/stat"
+	"Hamburger/internal/config"
+	"Hamburger/internal/logger"
+	"Hamburger/internal/serror"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// allDomains 对所有域名生效的国家规则
+const allDomains = "*"
+
+// GeoFilter 按客户端IP所属国家的访问控制
+// 先检查*规则再检查域名规则 任意一层拒绝即拒绝请求
+type GeoFilter struct {
+	enabled bool
+	global  *countryAccess
+	domains map[string]*countryAccess
+}
+
+type countryAccess struct {
+	allow map[string]struct{}
+	deny  map[string]struct{}
+}
+
+func NewGeoFilter() *GeoFilter {
+	cf := config.Get()
+	f := &GeoFilter{domains: make(map[string]*countryAccess)}
+	for domain, conf := range cf.Security.Countries {
+		if len(conf.Allow) == 0 && len(conf.Deny) == 0 {
+			continue
+		}
+		access := &countryAccess{allow: countrySet(conf.Allow), deny: countrySet(conf.Deny)}
+		if domain == allDomains {
+			f.global = access
+			continue
+		}
+		f.domains[strings.ToLower(domain)] = access
+	}
+	f.enabled = f.global != nil || len(f.domains) > 0
+	return f
+}
+
+func countrySet(list []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(list))
+	for _, code := range list {
+		set[strings.ToUpper(strings.TrimSpace(code))] = struct{}{}
+	}
+	return set
+}
+
+func (a *countryAccess) allowed(country string) bool {
+	if a == nil {
+		return true
+	}
+	if len(a.allow) > 0 {
+		_, ok := a.allow[country]
+		return ok
+	}
+	_, ok := a.deny[country]
+	return !ok
+}
+
+func (f *GeoFilter) Handle(r *http.Request) error {
+	if !f.enabled {
+		return nil
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	domain := f.domains[strings.ToLower(host)]
+	if f.global == nil && domain == nil {
+		return nil
+	}
+
+	country := geo.Country(clientip.Get(r))
+	if country == "" || (f.global.allowed(country) && domain.allowed(country)) {
+		return nil
+	}
+
+	logger.L().Debug().Str("Host", r.Host).Str("Country", country).Msg("client country not allowed")
+	stat.Add(stat.Blocked)
+	r.Header.Set(serror.SandwichInternalFlag, serror.SandwichGeoNotAllow)
+	return fmt.Errorf("country %s not allowed", country)
+}
+
+func (f *GeoFilter) Name() string {
+	return "GeoFilter"
+}
+
+func (f *GeoFilter) Enabled() bool {
+	return f.enabled
+}
@@ -0,0 +1,63 @@
+package prehandler
+
+import (
+	"Hamburger/gateway/geo"
+	"Hamburger/internal/config"
+	"Hamburger/internal/serror"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGeoFilter(t *testing.T) {
+	// 203.0.113.0/24 US 198.51.100.0/24 CN
+	if err := geo.LoadFile("../geo/testdata/country.mmdb"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	cfg.Security.Countries = map[string]config.CountryAccess{
+		"*":               {Deny: []string{"cn"}},
+		"us.example.com":  {Allow: []string{"US"}},
+		"any.example.com": {},
+	}
+	config.Set(cfg)
+	f := NewGeoFilter()
+	if !f.Enabled() {
+		t.Fatal("filter should be enabled")
+	}
+
+	cases := []struct {
+		name   string
+		host   string
+		remote string
+		allow  bool
+	}{
+		{"global deny", "www.example.com", "198.51.100.1:1000", false},
+		{"global pass", "www.example.com", "203.0.113.9:1000", true},
+		{"domain allow list", "us.example.com:443", "203.0.113.9:1000", true},
+		{"global deny before domain", "us.example.com", "198.51.100.1:1000", false},
+		{"unknown country passes", "us.example.com", "192.0.2.1:1000", true},
+		{"empty domain rule", "any.example.com", "198.51.100.1:1000", false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = c.host
+		req.RemoteAddr = c.remote
+		err := f.Handle(req)
+		if (err == nil) != c.allow {
+			t.Errorf("%s: err = %v", c.name, err)
+		}
+		if !c.allow && req.Header.Get(serror.SandwichInternalFlag) != serror.SandwichGeoNotAllow {
+			t.Errorf("%s: missing geo flag", c.name)
+		}
+	}
+
+	// 仅配置域名规则时 其他域名不受影响
+	cfg.Security.Countries = map[string]config.CountryAccess{"us.example.com": {Allow: []string{"US"}}}
+	f = NewGeoFilter()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "www.example.com"
+	req.RemoteAddr = "198.51.100.1:1000"
+	if err := f.Handle(req); err != nil {
+		t.Fatalf("unrelated domain rejected: %v", err)
+	}
+}
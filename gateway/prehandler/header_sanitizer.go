@@ -1,9 +1,9 @@
 package prehandler
 
 import (
+	"Hamburger/gateway/affinity"
 	"Hamburger/internal/config"
 	"net/http"
-	"sync"
 )
 
 // HeaderSanitizer 请求头安全清理器
@@ -16,39 +16,31 @@ type HeaderSanitizer struct {
 	keep map[string]struct{}
 }
 
-var (
-	sanitizer     *HeaderSanitizer
-	sanitizerOnce sync.Once
-)
-
-// NewHeaderSanitizer 创建单例的HeaderSanitizer
+// NewHeaderSanitizer 按当前配置创建HeaderSanitizer 重载时重新创建
 func NewHeaderSanitizer() *HeaderSanitizer {
-	sanitizerOnce.Do(func() {
-		cf := config.Get()
-		k := map[string]struct{}{
-			cf.ProxyHeader.TraceId:            {},
-			cf.ProxyHeader.FrontendHostHeader: {},
-			cf.ProxyHeader.BackendHeader:      {},
-			cf.ProxyHeader.ProxyApp:           {},
-		}
-		d := map[string]struct{}{
-			"Authorization":       {},
-			"Proxy-Authorization": {},
-			"Cookie":              {},
-			"X-Forwarded-For":     {},
-			"X-Real-IP":           {},
-			"X-Client-IP":         {},
-			"Forwarded":           {},
-			"X-Forwarded-Proto":   {},
-			"X-Forwarded-Host":    {},
-			"X-Forwarded-Port":    {},
-			"X-Amzn-Trace-Id":     {},
-			"X-Request-Id":        {},
-			"CF-Connecting-IP":    {},
-		}
-		sanitizer = &HeaderSanitizer{enabled: cf.Middleware.Sanitizer.Enabled, deny: d, keep: k}
-	})
-	return sanitizer
+	cf := config.Get()
+	k := map[string]struct{}{
+		cf.ProxyHeader.TraceId:            {},
+		cf.ProxyHeader.FrontendHostHeader: {},
+		cf.ProxyHeader.BackendHeader:      {},
+		cf.ProxyHeader.ProxyApp:           {},
+	}
+	d := map[string]struct{}{
+		"Authorization":       {},
+		"Proxy-Authorization": {},
+		"Cookie":              {},
+		"X-Forwarded-For":     {},
+		"X-Real-IP":           {},
+		"X-Client-IP":         {},
+		"Forwarded":           {},
+		"X-Forwarded-Proto":   {},
+		"X-Forwarded-Host":    {},
+		"X-Forwarded-Port":    {},
+		"X-Amzn-Trace-Id":     {},
+		"X-Request-Id":        {},
+		"CF-Connecting-IP":    {},
+	}
+	return &HeaderSanitizer{enabled: cf.Middleware.Sanitizer.Enabled, deny: d, keep: k}
 }
 
 // Access 执行请求头清理
@@ -59,6 +51,9 @@ func (h *HeaderSanitizer) Access(r *http.Request) {
 	if !h.enabled {
 		return
 	}
+	// 会话保持Cookie需要保留给解析器使用
+	affinityCookie, affinityErr := r.Cookie(affinity.CookieName())
+
 	// 遍历并删除敏感头（保留keep）
 	for name := range h.deny {
 		if _, ok := h.keep[name]; ok {
@@ -66,6 +61,10 @@ func (h *HeaderSanitizer) Access(r *http.Request) {
 		}
 		r.Header.Del(name)
 	}
+
+	if affinityErr == nil {
+		r.AddCookie(affinityCookie)
+	}
 }
 
 func (h *HeaderSanitizer) Handle(r *http.Request) error {
@@ -0,0 +1,30 @@
+package prehandler
+
+import (
+	"Hamburger/internal/config"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestHeaderSanitizerReload 测试每次创建都使用最新配置中的内部头部
+func TestHeaderSanitizerReload(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Middleware.Sanitizer.Enabled = true
+	config.Set(cfg)
+	strip := NewHeaderSanitizer()
+
+	cfg.ProxyHeader.TraceId = "X-Request-Id"
+	keep := NewHeaderSanitizer()
+
+	for _, c := range []struct {
+		h    *HeaderSanitizer
+		want string
+	}{{strip, ""}, {keep, "t1"}} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-Id", "t1")
+		c.h.Access(req)
+		if got := req.Header.Get("X-Request-Id"); got != c.want {
+			t.Fatalf("X-Request-Id = %q, want %q", got, c.want)
+		}
+	}
+}
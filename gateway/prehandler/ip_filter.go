@@ -0,0 +1,119 @@
+package prehandler
+
+import (
+	"Hamburger/gateway/clientip"
+	"Hamburger/gateway/stat"
+	"Hamburger/internal/config"
+	"Hamburger/internal/logger"
+	"Hamburger/internal/serror"
+	"Hamburger/internal/structure"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// IPFilter IP黑白名单
+// 先检查全局规则再检查域名规则 任意一层拒绝即拒绝请求
+// 命中拒绝列表且不在允许列表中时拒绝 严格模式下不在允许列表中即拒绝
+type IPFilter struct {
+	enabled bool
+	global  *ipAccess
+	domains map[string]*ipAccess
+}
+
+type ipAccess struct {
+	strict bool
+	allow  *structure.IPTrie
+	deny   *structure.IPTrie
+}
+
+// NewIPFilter 按当前配置创建IP黑白名单 列表中存在无效的IP或CIDR时返回错误
+// 访问控制不能因为配置错误而失效 调用方需要中止启动或重载
+func NewIPFilter() (*IPFilter, error) {
+	cf := config.Get()
+	f := &IPFilter{domains: make(map[string]*ipAccess)}
+
+	global, err := newIPAccess(cf.Security.StrictMode, cf.Security.AllowIPs, cf.Security.DenyIPs)
+	if err != nil {
+		return nil, fmt.Errorf("load global ip access list: %w", err)
+	}
+	if !global.empty() {
+		f.global = global
+	}
+	for domain, conf := range cf.Security.Domains {
+		access, err := newIPAccess(conf.StrictMode, conf.AllowIPs, conf.DenyIPs)
+		if err != nil {
+			return nil, fmt.Errorf("load ip access list of domain %s: %w", domain, err)
+		}
+		if !access.empty() {
+			f.domains[strings.ToLower(domain)] = access
+		}
+	}
+	f.enabled = f.global != nil || len(f.domains) > 0
+	return f, nil
+}
+
+func newIPAccess(strict bool, allowIPs, denyIPs []string) (*ipAccess, error) {
+	allow, err := structure.NewIPTrieFrom(allowIPs)
+	if err != nil {
+		return &ipAccess{}, err
+	}
+	deny, err := structure.NewIPTrieFrom(denyIPs)
+	if err != nil {
+		return &ipAccess{}, err
+	}
+	return &ipAccess{strict: strict, allow: allow, deny: deny}, nil
+}
+
+// empty 没有任何规则 严格模式需要允许列表非空才生效
+func (a *ipAccess) empty() bool {
+	return a.allow.Len() == 0 && a.deny.Len() == 0
+}
+
+func (a *ipAccess) allowed(addr netip.Addr) bool {
+	if a == nil {
+		return true
+	}
+	if a.allow.Contains(addr) {
+		return true
+	}
+	if a.strict && a.allow.Len() > 0 {
+		return false
+	}
+	return !a.deny.Contains(addr)
+}
+
+func (f *IPFilter) Handle(r *http.Request) error {
+	if !f.enabled {
+		return nil
+	}
+	addr := clientip.Get(r)
+	if f.global.allowed(addr) && f.domainAccess(r.Host).allowed(addr) {
+		return nil
+	}
+
+	logger.L().Debug().Str("Host", r.Host).Str("IP", addr.String()).Msg("client ip not allowed")
+	stat.Add(stat.Blocked)
+	r.Header.Set(serror.SandwichInternalFlag, serror.SandwichIPNotAllow)
+	return fmt.Errorf("ip %s not allowed", addr)
+}
+
+func (f *IPFilter) domainAccess(host string) *ipAccess {
+	if len(f.domains) == 0 {
+		return nil
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return f.domains[strings.ToLower(host)]
+}
+
+func (f *IPFilter) Name() string {
+	return "IPFilter"
+}
+
+func (f *IPFilter) Enabled() bool {
+	return f.enabled
+}
@@ -0,0 +1,113 @@
+package prehandler
+
+import (
+	"Hamburger/gateway/clientip"
+	"Hamburger/internal/config"
+	"Hamburger/internal/serror"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestIPFilter(t *testing.T, security config.SecurityConfig) *IPFilter {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Security = security
+	config.Set(cfg)
+	if err := clientip.Init(security); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = clientip.Init(config.SecurityConfig{}) })
+	f, err := NewIPFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestIPFilter(t *testing.T) {
+	f := newTestIPFilter(t, config.SecurityConfig{
+		AllowIPs:       []string{"203.0.113.5"},
+		DenyIPs:        []string{"203.0.113.0/24"},
+		TrustedProxies: []string{"10.0.0.0/8"},
+		Domains: map[string]config.IPAccessConfig{
+			"admin.example.com": {StrictMode: true, AllowIPs: []string{"198.51.100.0/24"}},
+		},
+	})
+
+	cases := []struct {
+		name      string
+		host      string
+		remote    string
+		forwarded string
+		allow     bool
+	}{
+		{"denied address", "www.example.com", "203.0.113.9:1000", "", false},
+		{"allow list exempts deny", "www.example.com", "203.0.113.5:1000", "", true},
+		{"unlisted address", "www.example.com", "192.0.2.1:1000", "", true},
+		{"client behind trusted proxy", "www.example.com", "10.0.0.1:1000", "203.0.113.9, 10.0.0.2", false},
+		{"trusted proxy chain allowed", "www.example.com", "10.0.0.1:1000", "192.0.2.1, 10.0.0.2", true},
+		{"header from untrusted remote ignored", "www.example.com", "192.0.2.1:1000", "203.0.113.9", true},
+		{"domain strict allow", "admin.example.com:443", "198.51.100.7:1000", "", true},
+		{"domain strict reject", "admin.example.com", "192.0.2.1:1000", "", false},
+		{"global deny before domain", "admin.example.com", "203.0.113.9:1000", "", false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = c.host
+		req.RemoteAddr = c.remote
+		if c.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		err := f.Handle(req)
+		if (err == nil) != c.allow {
+			t.Errorf("%s: err = %v", c.name, err)
+		}
+		if !c.allow && req.Header.Get(serror.SandwichInternalFlag) != serror.SandwichIPNotAllow {
+			t.Errorf("%s: missing ip flag", c.name)
+		}
+	}
+}
+
+func TestIPFilterStrictMode(t *testing.T) {
+	// 允许列表为空时严格模式不生效 只使用拒绝列表
+	f := newTestIPFilter(t, config.SecurityConfig{StrictMode: true, DenyIPs: []string{"203.0.113.0/24"}})
+	for remote, allow := range map[string]bool{"192.0.2.1:1000": true, "203.0.113.9:1000": false} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remote
+		if err := f.Handle(req); (err == nil) != allow {
+			t.Errorf("empty allow list %s: err = %v", remote, err)
+		}
+	}
+
+	f = newTestIPFilter(t, config.SecurityConfig{StrictMode: true, AllowIPs: []string{"198.51.100.0/24"}})
+	for remote, allow := range map[string]bool{"198.51.100.7:1000": true, "192.0.2.1:1000": false} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remote
+		if err := f.Handle(req); (err == nil) != allow {
+			t.Errorf("allow list %s: err = %v", remote, err)
+		}
+	}
+
+	if newTestIPFilter(t, config.SecurityConfig{StrictMode: true}).Enabled() {
+		t.Error("strict mode without any list should not enable the filter")
+	}
+}
+
+func TestIPFilterInvalidList(t *testing.T) {
+	// 无效的列表不能让访问控制静默失效
+	for _, security := range []config.SecurityConfig{
+		{DenyIPs: []string{"203.0.113.0/33"}},
+		{StrictMode: true, AllowIPs: []string{"198.51.100.x"}},
+		{Domains: map[string]config.IPAccessConfig{"admin.example.com": {DenyIPs: []string{"bogus"}}}},
+	} {
+		cfg := &config.Config{}
+		cfg.Security = security
+		config.Set(cfg)
+		if _, err := NewIPFilter(); err == nil {
+			t.Errorf("expected error for %+v", security)
+		}
+		if _, err := defaultPreHandlers(); err == nil {
+			t.Errorf("expected chain error for %+v", security)
+		}
+	}
+}
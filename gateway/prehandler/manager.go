@@ -31,12 +31,64 @@ func NewPreHandlerManager() *PreHandlerManager {
 	return manager
 }
 
-func InitPreHandlerManager() {
+// InitPreHandlerManager 按当前配置创建处理链 访问控制等配置无效时返回错误
+func InitPreHandlerManager() error {
+	handlers, err := defaultPreHandlers()
+	if err != nil {
+		return err
+	}
 	pm := GetManager()
-	pm.Add(NewHeaderSanitizer())
-	pm.Add(NewPreCheckDomains())
-	pm.Add(NewRateLimiter())
-	pm.Add(NewImageProtectModifier())
+	for _, ph := range handlers {
+		pm.Add(ph)
+	}
+	return nil
+}
+
+// defaultPreHandlers 按当前配置创建内置的前置处理器 顺序即执行顺序
+func defaultPreHandlers() ([]PreHandler, error) {
+	ipFilter, err := NewIPFilter()
+	if err != nil {
+		return nil, err
+	}
+	wafHandler, err := NewWAF()
+	if err != nil {
+		return nil, err
+	}
+	rateLimiter, err := NewRateLimiter()
+	if err != nil {
+		return nil, err
+	}
+	return []PreHandler{
+		NewClientAuth(), // 需在请求头清理之前 移除客户端伪造的证书信息请求头
+		ipFilter,
+		NewGeoFilter(),
+		wafHandler, // 需在请求头清理之前 检查原始请求头和Cookie
+		NewHeaderSanitizer(),
+		NewPreCheckDomains(),
+		rateLimiter,
+		NewImageProtectModifier(),
+	}, nil
+}
+
+// Reload 按最新配置重建处理链并整体替换 进行中的请求继续使用旧的处理链
+// 新处理链创建失败时保留旧的处理链
+func (m *PreHandlerManager) Reload() error {
+	handlers, err := defaultPreHandlers()
+	if err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	old := m.modifiers
+	m.modifiers = handlers
+	m.lock.Unlock()
+
+	for _, ph := range old {
+		if closer, ok := ph.(Closer); ok {
+			closer.Close()
+		}
+	}
+	return nil
 }
 
 func (m *PreHandlerManager) Add(ph PreHandler) {
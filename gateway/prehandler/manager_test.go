@@ -0,0 +1,49 @@
+package prehandler
+
+import (
+	"Hamburger/internal/config"
+	"net/http"
+	"testing"
+)
+
+type closeRecorder struct {
+	closed bool
+}
+
+func (c *closeRecorder) Handle(*http.Request) error { return nil }
+func (c *closeRecorder) Name() string               { return "closeRecorder" }
+func (c *closeRecorder) Enabled() bool              { return true }
+func (c *closeRecorder) Close()                     { c.closed = true }
+
+func TestManagerReload(t *testing.T) {
+	cfg := &config.Config{}
+	config.Set(cfg)
+	m := NewPreHandlerManager()
+	old := &closeRecorder{}
+	m.Add(old)
+
+	// 新的处理链创建失败时保留旧的处理链
+	cfg.Security.DenyIPs = []string{"not-an-ip"}
+	if err := m.Reload(); err == nil {
+		t.Fatal("expected error for invalid ip")
+	}
+	if chain := m.GetPreHandlers(); len(chain) != 1 || chain[0] != old || old.closed {
+		t.Fatal("old chain should be kept")
+	}
+
+	cfg.Security.DenyIPs = nil
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !old.closed {
+		t.Fatal("old handler should be closed")
+	}
+	for _, ph := range m.GetPreHandlers() {
+		if ph == old {
+			t.Fatal("old handler should be replaced")
+		}
+		if closer, ok := ph.(Closer); ok {
+			closer.Close()
+		}
+	}
+}
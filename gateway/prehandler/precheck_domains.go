@@ -29,8 +29,6 @@ func (cf *PreCheckDomains) Handle(r *http.Request) error {
 	if domain == "127.0.0.1" || domain == "localhost" {
 		return nil
 	}
-	switch {
-	}
 	for _, ad := range cf.allowedDomains {
 		if ad == domain {
 			return nil
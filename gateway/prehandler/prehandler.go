@@ -7,3 +7,8 @@ type PreHandler interface {
 	Name() string
 	Enabled() bool
 }
+
+// Closer 持有后台资源的前置处理器 在处理链被替换后释放
+type Closer interface {
+	Close()
+}
@@ -2,9 +2,12 @@ package prehandler
 
 import (
 	flow "Hamburger/gateway/flow_control"
+	"Hamburger/gateway/metrics"
+	"Hamburger/gateway/reqinfo"
 	"Hamburger/internal/config"
 	"Hamburger/internal/logger"
 	"Hamburger/internal/serror"
+	"errors"
 	"net/http"
 )
 
@@ -13,14 +16,18 @@ type RateLimiter struct {
 	fc      *flow.FlowController
 }
 
-func NewRateLimiter() *RateLimiter {
+// NewRateLimiter 按当前配置创建流控 规则或存储配置无效时返回错误
+func NewRateLimiter() (*RateLimiter, error) {
 	cf := config.Get()
-	flowController := flow.NewFlowController()
+	flowController, err := flow.NewFlowController()
+	if err != nil {
+		return nil, err
+	}
 	go flowController.CleanupExpiredRecords()
 	return &RateLimiter{
 		enabled: cf.Features.FlowControl.Enabled,
 		fc:      flowController,
-	}
+	}, nil
 }
 
 func (r RateLimiter) Handle(req *http.Request) error {
@@ -41,8 +48,12 @@ func (r RateLimiter) Handle(req *http.Request) error {
 				Str("Method", req.Method).
 				Str("Remote Addr", req.RemoteAddr).
 				Str("Reason", result.Reason).
+				Dur("Delayed", result.Delayed).
 				Msg("client has been rate limited")
+			metrics.FlowBlocked(req.Host, result.RuleName)
+			reqinfo.From(req.Context()).SetRateLimit(result.State.Limit, result.State.Remaining, result.State.Reset)
 			req.Header.Set(serror.SandwichInternalFlag, serror.SandwichReqLimit)
+			return errors.New(result.Reason)
 		} else {
 			// 记录通过的请求（如果启用）
 			flowRecorder := flow.GetFlowRecorder()
@@ -62,3 +73,28 @@ func (r RateLimiter) Name() string {
 func (r RateLimiter) Enabled() bool {
 	return r.enabled
 }
+
+// Close 释放流控器的后台任务
+func (r RateLimiter) Close() {
+	if r.fc != nil {
+		r.fc.Stop()
+	}
+}
+
+// FlowStatus 获取流控器状态
+func (r RateLimiter) FlowStatus() flow.Status {
+	if r.fc == nil {
+		return flow.Status{Enabled: r.enabled}
+	}
+	return r.fc.Snapshot()
+}
+
+// FlowStatus 获取当前处理链中限流处理器的流控状态
+func FlowStatus() (flow.Status, bool) {
+	for _, ph := range GetManager().GetPreHandlers() {
+		if limiter, ok := ph.(*RateLimiter); ok {
+			return limiter.FlowStatus(), true
+		}
+	}
+	return flow.Status{}, false
+}
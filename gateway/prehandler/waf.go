@@ -0,0 +1,82 @@
+package prehandler
+
+import (
+	flow "Hamburger/gateway/flow_control"
+	"Hamburger/gateway/stat"
+	"Hamburger/gateway/waf"
+	"Hamburger/internal/config"
+	"Hamburger/internal/logger"
+	"Hamburger/internal/serror"
+	"fmt"
+	"net/http"
+)
+
+// WAF Web应用防火墙
+// 命中的规则通过流量记录器保存 log动作只记录不拦截
+type WAF struct {
+	enabled bool
+	engine  *waf.Engine
+}
+
+// NewWAF 按当前配置创建WAF 开启时规则无法编译则返回错误 不能在没有规则的情况下放行
+func NewWAF() (*WAF, error) {
+	cf := config.Get().Middleware.WAF
+	if !cf.Enabled {
+		return &WAF{}, nil
+	}
+	engine, err := waf.New(cf)
+	if err != nil {
+		return nil, fmt.Errorf("load waf rules: %w", err)
+	}
+	return &WAF{enabled: true, engine: engine}, nil
+}
+
+func (w *WAF) Handle(r *http.Request) error {
+	if !w.enabled {
+		return nil
+	}
+	hits := w.engine.Inspect(r)
+	if len(hits) == 0 {
+		return nil
+	}
+
+	recorder := flow.GetFlowRecorder()
+	for _, hit := range hits {
+		logger.L().Info().Str("Host", r.Host).Str("Rule", hit.Rule).Str("Target", hit.Target).
+			Str("Action", hit.Action).Msg("waf rule matched")
+		if recorder != nil {
+			recorder.RecordRule(r, wafStatus(hit.Action), "waf:"+hit.Rule, "matched "+hit.Target)
+		}
+	}
+
+	last := hits[len(hits)-1]
+	switch last.Action {
+	case waf.ActionBlock:
+		stat.Add(stat.Blocked)
+		r.Header.Set(serror.SandwichInternalFlag, serror.SandwichWAFBlock)
+	case waf.ActionChallenge:
+		r.Header.Set(serror.SandwichInternalFlag, serror.SandwichWAFChallenge)
+	default:
+		return nil
+	}
+	return fmt.Errorf("waf rule %s matched", last.Rule)
+}
+
+// wafStatus 流量记录中的状态
+func wafStatus(action string) string {
+	switch action {
+	case waf.ActionBlock:
+		return "blocked"
+	case waf.ActionChallenge:
+		return "challenged"
+	}
+	return "logged"
+}
+
+func (w *WAF) Name() string {
+	return "WAF"
+}
+
+func (w *WAF) Enabled() bool {
+	return w.enabled
+}
@@ -0,0 +1,28 @@
+package prehandler
+
+import (
+	"Hamburger/internal/config"
+	"testing"
+)
+
+func TestNewWAFInvalidRule(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Middleware.WAF = config.WAFConfig{
+		Enabled: true,
+		Default: config.WAFRuleSet{Rules: []config.WAFRule{{Name: "bad", Targets: []string{"path"}, Pattern: "("}}},
+	}
+	config.Set(cfg)
+	if _, err := NewWAF(); err == nil {
+		t.Fatal("expected error for invalid rule")
+	}
+	if _, err := defaultPreHandlers(); err == nil {
+		t.Fatal("expected chain error for invalid rule")
+	}
+
+	// 未开启时不编译规则
+	cfg.Middleware.WAF.Enabled = false
+	w, err := NewWAF()
+	if err != nil || w.Enabled() {
+		t.Fatalf("disabled waf: %v %v", w, err)
+	}
+}
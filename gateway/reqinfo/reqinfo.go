@@ -0,0 +1,113 @@
+// Package reqinfo
+// 单次请求在网关内的处理信息 由入口创建并存入请求上下文 转发各环节补充
+package reqinfo
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"time"
+)
+
+// 上游类型
+const (
+	UpstreamFrontend = "frontend"
+	UpstreamBackend  = "backend"
+	UpstreamCustom   = "custom"
+	UpstreamGrpc     = "grpc"
+	UpstreamNone     = "none" // 未转发到上游 被拒绝或解析失败
+)
+
+type contextKey struct{}
+
+// Info 请求处理信息
+// 同一请求的各处理环节在同一个goroutine中顺序执行 字段读写无需加锁
+type Info struct {
+	Start        time.Time
+	ClientIP     netip.Addr // 经可信代理解析后的客户端IP
+	UpstreamType string
+	Upstream     string        // 最终使用的上游地址 重试后为最后一次尝试的地址
+	UpstreamTime time.Duration // 等待上游响应头的耗时 重试时累计
+	Rejected     string        // 拒绝请求的前置处理器、限流器或熔断器
+	RateLimit    *RateLimit    // 被流控拒绝时的额度状态
+}
+
+// RateLimit 流控额度状态 用于限流响应的Retry-After和X-RateLimit-*响应头
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+}
+
+// New 创建请求信息并存入请求上下文
+func New(req *http.Request) (*http.Request, *Info) {
+	info := &Info{Start: time.Now(), UpstreamType: UpstreamNone}
+	return req.WithContext(context.WithValue(req.Context(), contextKey{}, info)), info
+}
+
+// From 从上下文获取请求信息 不存在时返回nil 返回值的方法可以安全地在nil上调用
+func From(ctx context.Context) *Info {
+	info, _ := ctx.Value(contextKey{}).(*Info)
+	return info
+}
+
+// SetClientIP 记录客户端IP
+func (i *Info) SetClientIP(addr netip.Addr) {
+	if i == nil {
+		return
+	}
+	i.ClientIP = addr
+}
+
+// SetUpstream 记录转发的上游
+func (i *Info) SetUpstream(upstreamType, upstream string) {
+	if i == nil {
+		return
+	}
+	i.UpstreamType = upstreamType
+	i.Upstream = upstream
+}
+
+// SetUpstreamAddr 更新上游地址 重试切换上游时使用
+func (i *Info) SetUpstreamAddr(upstream string) {
+	if i == nil {
+		return
+	}
+	i.Upstream = upstream
+}
+
+// AddUpstreamTime 累计上游耗时
+func (i *Info) AddUpstreamTime(d time.Duration) {
+	if i == nil {
+		return
+	}
+	i.UpstreamTime += d
+}
+
+// Reject 记录拒绝请求的处理环节
+func (i *Info) Reject(name string) {
+	if i == nil {
+		return
+	}
+	i.Rejected = name
+}
+
+// SetRateLimit 记录流控额度状态
+func (i *Info) SetRateLimit(limit, remaining int, reset time.Duration) {
+	if i == nil {
+		return
+	}
+	i.RateLimit = &RateLimit{Limit: limit, Remaining: remaining, Reset: reset}
+}
+
+// Protocol 请求的协议简称 h1 h2 h3
+func Protocol(req *http.Request) string {
+	switch req.ProtoMajor {
+	case 3:
+		return "h3"
+	case 2:
+		return "h2"
+	default:
+		return "h1"
+	}
+}
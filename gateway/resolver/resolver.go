@@ -1,6 +1,7 @@
 package resolver
 
 import (
+	"Hamburger/gateway/reqinfo"
 	"Hamburger/gateway/stat"
 	"Hamburger/internal/config"
 	"Hamburger/internal/serror"
@@ -9,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"sync"
+	"sync/atomic"
 )
 
 // 解析器 解析为前端或后端代理转发
@@ -17,31 +19,33 @@ var once = sync.Once{}
 var resolver Resolver
 
 type Resolver struct {
-	cfg    *config.Config
 	logger *zerolog.Logger
-	ruler  *Ruler
+	ruler  atomic.Pointer[Ruler] // 规则随配置重载整体替换
 }
 
 func OneResolver(cfg *config.Config, logger *zerolog.Logger) *Resolver {
 	once.Do(func() {
-		resolver = Resolver{
-			cfg:    cfg,
-			logger: logger,
-			ruler:  NewRuler(cfg, logger),
-		}
+		resolver.logger = logger
+		resolver.ruler.Store(NewRuler(cfg, logger))
 	})
 
 	return &resolver
 }
 
+// Reload 使用新的配置重建转发规则和自定义服务映射
+func (r *Resolver) Reload(cfg *config.Config) {
+	r.ruler.Store(NewRuler(cfg, r.logger))
+}
+
 func (r *Resolver) FastCheckFront(request *http.Request) bool {
-	result := r.ruler.Parse(request)
+	result := r.ruler.Load().Parse(request)
 	return result.ProxyToType == Frontend
 }
 
 func (r *Resolver) Parse(request *http.Request) *url.URL {
 	host := request.Host
-	result := r.ruler.Parse(request)
+	ruler := r.ruler.Load()
+	result := ruler.Parse(request)
 
 	r.logger.Debug().Any("Result", result).Err(result.ProxyError).Msg("rule-parse request")
 	if r.ResolveError(result, request) {
@@ -50,18 +54,29 @@ func (r *Resolver) Parse(request *http.Request) *url.URL {
 
 	if result.ProxyToType == Frontend {
 		stat.Add(stat.Static)
-	} else if result.ProxyToType == Backend {
+	} else {
 		stat.Add(stat.API)
 	}
 
+	if result.ProxyPath != "" && result.ProxyPath != request.URL.Path {
+		// API规则重写后的路径
+		request.URL.Path = result.ProxyPath
+		request.URL.RawPath = ""
+	}
 	request.URL.Scheme = result.ProxyScheme
 	request.URL.Host = fmt.Sprintf("%s:%d", result.ProxyHost, result.ProxyPort)
-	request.Header.Set("Host", host)                               // 设置真实HOST
-	request.Header.Set(r.cfg.ProxyHeader.FrontendHostHeader, host) // 设置真实HOST
+	request.Header.Set("Host", host)                                   // 设置真实HOST
+	request.Header.Set(ruler.cfg.ProxyHeader.FrontendHostHeader, host) // 设置真实HOST
+	reqinfo.From(request.Context()).SetUpstream(upstreamTypes[result.ProxyToType], request.URL.Host)
 
 	return request.URL
 }
 
+// Alternative 为失败重试选择另一个未尝试过的上游地址
+func (r *Resolver) Alternative(request *http.Request, tried map[string]struct{}) (string, bool) {
+	return r.ruler.Load().Alternative(request, tried)
+}
+
 func (r *Resolver) ResolveError(result RuleResult, req *http.Request) (hasError bool) {
 	// 包含标准错误
 	if result.ProxyError != nil {
@@ -1,12 +1,19 @@
 package resolver
 
 import (
+	"Hamburger/gateway/affinity"
 	"Hamburger/gateway/balancer"
+	"Hamburger/gateway/clientip"
+	"Hamburger/gateway/geo"
+	"Hamburger/gateway/health"
 	"Hamburger/gateway/runtime"
+	"Hamburger/gateway/upstreamtls"
 	"Hamburger/internal/config"
+	"Hamburger/internal/route"
 	"errors"
 	"github.com/rs/zerolog"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -21,35 +28,24 @@ const (
 type Ruler struct {
 	cfg      *config.Config
 	logger   *zerolog.Logger
-	apiRules map[string][]Rule // 记录域名和对应的API服务转发映射
+	apiRules map[string]*route.Router // 记录域名和对应的API服务转发路由
 	rwLock   sync.RWMutex
 }
 
-type Rule struct {
-	API        string
-	Rewrite    string
-	UseRewrite bool
-	Backend    string
-}
-
 func NewRuler(cfg *config.Config, logger *zerolog.Logger) *Ruler {
 	apiServers := cfg.PxyFrontend.Servers
-	rules := make(map[string][]Rule)
+	rules := make(map[string]*route.Router)
 	// 转换为域名的规则映射
 	for _, server := range apiServers {
 		domain, ok := runtime.DomainsRuntimeMap.DomainFrontMap.Get(server.Name)
 		if !ok {
 			continue
 		}
-		rules[domain] = make([]Rule, 0)
-		for _, backend := range server.Backends {
-			rules[domain] = append(rules[domain], Rule{
-				API:        backend.API,
-				Rewrite:    backend.Rewrite,
-				UseRewrite: backend.UseRewrite,
-				Backend:    backend.Service,
-			})
+		router, err := route.NewRouter(server.Backends)
+		if err != nil {
+			logger.Warn().Err(err).Str("domain", domain).Msg("skip invalid api route")
 		}
+		rules[domain] = router
 	}
 
 	return &Ruler{
@@ -76,7 +72,7 @@ func (r *Ruler) Parse(req *http.Request) RuleResult {
 		if !ok {
 			// 未匹配运行时域名映射时尝试自定义服务映射
 			if r.IsCustomServiceEnabled() {
-				return r.ResolveCustomService(host)
+				return r.ResolveCustomService(req, host)
 			}
 			return RuleResult{
 				ProxyError: errors.New("domains map is empty"),
@@ -110,7 +106,7 @@ func (r *Ruler) Parse(req *http.Request) RuleResult {
 				ProxyToType: Backend,
 				ProxyTo:     serviceMap.Backend,
 				ProxyHost:   StaticHost,
-				ProxyPort:   balancer.PickOneRoundRobin(ports),
+				ProxyPort:   r.pickPort(req, host, ports),
 				ProxyScheme: StaticSchema,
 			}
 		}
@@ -137,46 +133,28 @@ func (r *Ruler) Parse(req *http.Request) RuleResult {
 	}
 }
 
-func (r *Ruler) MatchAPIRule(req *http.Request, rules []Rule) (RuleResult, bool) {
-	requestPath := req.URL.Path
-	host := req.Host
-	for _, rule := range rules {
-		// 检查API路径和服务名是否配置
-		if rule.API == "" || rule.Backend == "" {
-			continue
-		}
-
-		// 检查请求路径是否匹配backend.api
-		if strings.HasPrefix(requestPath, rule.API) {
-			// 执行后端代理转发
-
-			// 是否rewrite url
-			targetPath := req.URL.Path
-			if rule.UseRewrite {
-				// 将API路径重写为指定的rewrite路径
-				if strings.HasPrefix(targetPath, rule.API) {
-					targetPath = strings.Replace(targetPath, rule.API, rule.Rewrite, 1)
-				}
-			}
-
-			ports, ok := runtime.DomainPortsMap.Get(host)
-			if !ok {
-				return RuleResult{
-					ProxyError: errors.New("domains port is empty"),
-				}, true
-			}
-			return RuleResult{
-				ProxyTo:     rule.Backend,
-				ProxyHost:   StaticHost,
-				ProxyPath:   targetPath,
-				ProxyPort:   balancer.PickOneRoundRobin(ports),
-				ProxyScheme: StaticSchema,
-			}, true
-		}
+// MatchAPIRule 按路由表匹配API转发规则
+func (r *Ruler) MatchAPIRule(req *http.Request, router *route.Router) (RuleResult, bool) {
+	match, ok := router.Match(req)
+	if !ok {
 		return RuleResult{}, false
 	}
 
-	return RuleResult{}, false
+	host := req.Host
+	ports, ok := runtime.DomainPortsMap.Get(host)
+	if !ok {
+		return RuleResult{
+			ProxyError: errors.New("domains port is empty"),
+		}, true
+	}
+	return RuleResult{
+		ProxyToType: Backend,
+		ProxyTo:     match.Route.Backend.Service,
+		ProxyHost:   StaticHost,
+		ProxyPath:   match.Path,
+		ProxyPort:   r.pickPort(req, host, ports),
+		ProxyScheme: StaticSchema,
+	}, true
 }
 
 func (r *Ruler) IsCustomServiceEnabled() bool {
@@ -184,32 +162,115 @@ func (r *Ruler) IsCustomServiceEnabled() bool {
 }
 
 // ResolveCustomService 处理自定义后端服务转发
-func (r *Ruler) ResolveCustomService(host string) RuleResult {
+func (r *Ruler) ResolveCustomService(req *http.Request, host string) RuleResult {
+	serviceConfig, ok := r.customService(host)
+	if !ok {
+		return RuleResult{
+			ProxyError: errors.New("unknown host"),
+		}
+	}
+	lb := balancer.GetStrategyBalancer(serviceConfig.Balance, balancer.UpstreamTargets(upstreams(req, serviceConfig)))
+	if lb == nil {
+		return RuleResult{
+			ProxyError: errors.New("custom service upstream is empty"),
+		}
+	}
+	target := lb.Pick(req, func(target balancer.Target) bool {
+		return health.IsUpstreamUp(host, target.Host, target.Port)
+	})
+	return RuleResult{
+		ProxyToType: Custom,
+		ProxyTo:     "",
+		ProxyHost:   target.Host,
+		ProxyPort:   target.Port,
+		ProxyScheme: upstreamtls.Scheme(host, target.Addr()),
+	}
+}
+
+func (r *Ruler) customService(host string) (config.CustomServiceConfig, bool) {
 	for _, serviceConfig := range r.cfg.PxyCustomService.CustomService {
 		if host == serviceConfig.Domain {
-			var addrs []struct {
-				Host string
-				Port int
-			}
-			for _, upstream := range serviceConfig.Upstream {
-				addrs = append(addrs, struct {
-					Host string
-					Port int
-				}{Host: upstream.Host, Port: upstream.Port})
-			}
-			realHost, port := balancer.PickOneAddrRoundRobin(addrs)
-			return RuleResult{
-				ProxyToType: Backend,
-				ProxyTo:     "",
-				ProxyHost:   realHost,
-				ProxyPort:   port,
-				ProxyScheme: StaticSchema,
-			}
+			return serviceConfig, true
 		}
 	}
-	return RuleResult{
-		ProxyError: errors.New("unknown host"),
+	return config.CustomServiceConfig{}, false
+}
+
+// upstreams 按客户端所属国家选择上游组 未配置或未匹配时使用默认上游
+func upstreams(req *http.Request, serviceConfig config.CustomServiceConfig) []config.Upstream {
+	if len(serviceConfig.GeoRoutes) == 0 {
+		return serviceConfig.Upstream
+	}
+	country := geo.Country(clientip.Get(req))
+	if country == "" {
+		return serviceConfig.Upstream
+	}
+	for _, route := range serviceConfig.GeoRoutes {
+		if slices.ContainsFunc(route.Countries, func(code string) bool {
+			return strings.EqualFold(code, country)
+		}) {
+			return route.Upstream
+		}
+	}
+	return serviceConfig.Upstream
+}
+
+// Alternative 为失败重试重新选择上游地址 跳过已经尝试过的地址
+// 仅当前上游属于域名端口组或自定义服务时可以重选 前端服务等单一上游返回false
+func (r *Ruler) Alternative(req *http.Request, tried map[string]struct{}) (string, bool) {
+	host := req.Host
+	var targets []balancer.Target
+	var balanceConfig config.BalanceConfig
+	if ports, ok := runtime.DomainPortsMap.Get(host); ok {
+		targets = balancer.PortTargets(StaticHost, ports)
+		balanceConfig = r.balanceConfig(host)
+	} else if serviceConfig, ok := r.customService(host); ok && r.IsCustomServiceEnabled() {
+		targets = balancer.UpstreamTargets(upstreams(req, serviceConfig))
+		balanceConfig = serviceConfig.Balance
+	}
+
+	if !slices.ContainsFunc(targets, func(target balancer.Target) bool {
+		return target.Addr() == req.URL.Host
+	}) {
+		return "", false
+	}
+
+	lb := balancer.GetStrategyBalancer(balanceConfig, targets)
+	if lb == nil {
+		return "", false
+	}
+	target := lb.Pick(req, func(target balancer.Target) bool {
+		_, done := tried[target.Addr()]
+		return !done && health.IsUpstreamUp(host, target.Host, target.Port)
+	})
+	// 全部实例都已尝试或不可用时Pick会在全部实例中选择
+	if _, done := tried[target.Addr()]; done {
+		return "", false
+	}
+	return target.Addr(), true
+}
+
+// pickPort 按域名配置的均衡策略选择后端端口 跳过健康检查判定为down的端口
+// 开启会话保持的域名优先使用Cookie中记录的端口
+func (r *Ruler) pickPort(req *http.Request, host string, ports []int) int {
+	if port, ok := affinity.Lookup(req, host, ports); ok {
+		return port
+	}
+	lb := balancer.GetStrategyBalancer(r.balanceConfig(host), balancer.PortTargets(StaticHost, ports))
+	if lb == nil {
+		return 0
+	}
+	return lb.Pick(req, func(target balancer.Target) bool {
+		return health.IsUp(host, target.Port)
+	}).Port
+}
+
+// balanceConfig 获取域名的负载均衡策略 未单独配置时使用默认策略
+func (r *Ruler) balanceConfig(host string) config.BalanceConfig {
+	if bc, ok := r.cfg.Features.Balance.Domains[host]; ok {
+		return bc
 	}
+	return r.cfg.Features.Balance.Default
 }
 
 func IsBackend(req *http.Request) bool {
@@ -0,0 +1,73 @@
+package resolver
+
+import (
+	"Hamburger/gateway/geo"
+	"Hamburger/gateway/runtime"
+	"Hamburger/internal/config"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestAlternative(t *testing.T) {
+	const domain = "alt.example.com"
+	runtime.DomainPortsMap.Put(domain, []int{8001, 8002})
+	defer runtime.DomainPortsMap.Delete(domain)
+
+	logger := zerolog.Nop()
+	ruler := NewRuler(&config.Config{}, &logger)
+	req := httptest.NewRequest("GET", "http://"+domain+"/", nil)
+	req.URL.Host = "127.0.0.1:8001"
+
+	tried := map[string]struct{}{"127.0.0.1:8001": {}}
+	addr, ok := ruler.Alternative(req, tried)
+	if !ok || addr != "127.0.0.1:8002" {
+		t.Fatalf("Alternative = %s %v", addr, ok)
+	}
+
+	// 全部实例都已尝试
+	tried[addr] = struct{}{}
+	if addr, ok := ruler.Alternative(req, tried); ok {
+		t.Fatalf("all tried, got %s", addr)
+	}
+
+	// 当前上游不属于域名端口组
+	req.URL.Host = "127.0.0.1:9000"
+	if addr, ok := ruler.Alternative(req, map[string]struct{}{}); ok {
+		t.Fatalf("unknown upstream, got %s", addr)
+	}
+}
+
+func TestGeoUpstreams(t *testing.T) {
+	// 203.0.113.0/24 US 198.51.100.0/24 CN
+	if err := geo.LoadFile("../geo/testdata/country.mmdb"); err != nil {
+		t.Fatal(err)
+	}
+	defaults := []config.Upstream{{Host: "10.0.0.1", Port: 80}}
+	us := []config.Upstream{{Host: "10.0.1.1", Port: 80}}
+	service := config.CustomServiceConfig{
+		Domain:   "geo.example.com",
+		Upstream: defaults,
+		GeoRoutes: []config.GeoRoute{
+			{Countries: []string{"us", "CA"}, Upstream: us},
+		},
+	}
+
+	cases := []struct {
+		remote string
+		want   []config.Upstream
+	}{
+		{"203.0.113.9:1000", us},
+		{"198.51.100.1:1000", defaults},
+		{"192.0.2.1:1000", defaults},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "http://geo.example.com/", nil)
+		req.RemoteAddr = c.remote
+		if got := upstreams(req, service); !slices.Equal(got, c.want) {
+			t.Errorf("%s: upstreams = %v, want %v", c.remote, got, c.want)
+		}
+	}
+}
@@ -1,13 +1,22 @@
 package resolver
 
+import "Hamburger/gateway/reqinfo"
+
 // 规则解析结果
 // 直接转换为可读的服务Service调用形式
 
 const (
 	Frontend = iota
 	Backend
+	Custom // 自定义服务
 )
 
+var upstreamTypes = map[int]string{
+	Frontend: reqinfo.UpstreamFrontend,
+	Backend:  reqinfo.UpstreamBackend,
+	Custom:   reqinfo.UpstreamCustom,
+}
+
 type RuleResult struct {
 	ProxyToType int
 	ProxyTo     string // service 前端或后端或前后端合并项目
@@ -11,7 +11,7 @@ func InitRuntimeSyncer(cfg *config.AppConfig, logger *zerolog.Logger) {
 		"sync runtime-domains",
 		cfg.Syncer.JobSyncDomainsMap.Get(3600),
 		func() {
-			loadRuntimeDomains(cfg)
+			RefreshRuntimeDomains(cfg)
 		}).Start()
 
 	job_syncer.NewJobSyncer(logger,
@@ -21,3 +21,8 @@ func InitRuntimeSyncer(cfg *config.AppConfig, logger *zerolog.Logger) {
 			RefreshDomainPortsMap()
 		}).Start()
 }
+
+// RefreshRuntimeDomains 重新加载域名映射文件
+func RefreshRuntimeDomains(cfg *config.AppConfig) {
+	loadRuntimeDomains(cfg)
+}
@@ -3,4 +3,5 @@ package runtime
 type serviceMap struct {
 	Frontend string `json:"frontend"`
 	Backend  string `json:"backend"`
+	Sticky   bool   `json:"sticky"` // 开启基于Cookie的会话保持
 }
@@ -2,7 +2,6 @@ package server
 
 import (
 	"fmt"
-	"net"
 	"net/http"
 	"strings"
 	"sync"
@@ -11,6 +10,7 @@ import (
 	"Hamburger/gateway/tls"
 	"Hamburger/internal/config"
 	"Hamburger/internal/logger"
+	"Hamburger/internal/upgrade"
 	"Hamburger/internal/utils"
 
 	"github.com/rs/zerolog"
@@ -161,12 +161,13 @@ func CommonHttpServer(serverConfig config.ServerConfig, logger *zerolog.Logger,
 	if serverConfig.MaxRequestBody > 0 {
 		instance.Server.Handler = wrapHandlerWithMaxBody(instance.Server.Handler, logger, serverConfig)
 	}
+	instance.Server.Handler = wrapHandlerWithWebsocket(instance.Server.Handler, logger, serverConfig)
 	if serverConfig.Protocol == "http" {
 		instance.Server.Handler = wrapHandlerWithAutoHttpsRedirect(instance.Server.Handler, logger, serverConfig)
 	}
 
-	// 创建监听器
-	listener, err := net.Listen("tcp", addr)
+	// 创建监听器 平滑升级启动时复用父进程的监听器
+	listener, err := upgrade.Listen("tcp", addr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create listener: %v", err)
 	}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"Hamburger/internal/config"
+	"Hamburger/internal/upgrade"
 	"Hamburger/internal/utils"
 	"context"
 	"crypto/tls"
@@ -21,15 +22,15 @@ import (
 // Server HTTP/3 服务器
 // 基于 QUIC 协议实现的 HTTP/3 服务器
 type Server struct {
-	config   config.HTTP3Config // HTTP/3 配置
-	server   *http3.Server      // HTTP/3 服务器实例
-	listener *quic.Listener     // QUIC 监听器
-	handler  http.Handler       // 请求处理器
-	logger   *zerolog.Logger    // 日志记录器
-	mu       sync.RWMutex       // 读写锁
-	started  bool               // 是否已启动
-	ctx      context.Context    // 上下文
-	cancel   context.CancelFunc // 取消函数
+	config  config.HTTP3Config // HTTP/3 配置
+	server  *http3.Server      // HTTP/3 服务器实例
+	conn    net.PacketConn     // UDP 连接 平滑升级时传递给新进程
+	handler http.Handler       // 请求处理器
+	logger  *zerolog.Logger    // 日志记录器
+	mu      sync.RWMutex       // 读写锁
+	started bool               // 是否已启动
+	ctx     context.Context    // 上下文
+	cancel  context.CancelFunc // 取消函数
 
 	Name    string
 	Address string
@@ -64,23 +65,12 @@ func (s *Server) Start(addr string, tlsConfig *tls.Config) error {
 	// 创建 QUIC 配置
 	quicConfig := s.createQUICConfig()
 
-	// 创建 UDP 监听器
-	udpAddr, err := net.ResolveUDPAddr("udp", addr)
-	if err != nil {
-		return fmt.Errorf("failed to resolve udp address: %v", err)
-	}
-
-	udpConn, err := net.ListenUDP("udp", udpAddr)
+	// 创建 UDP 监听器 平滑升级启动时复用父进程的连接
+	conn, err := upgrade.ListenPacket("udp", addr)
 	if err != nil {
 		return fmt.Errorf("failed to create udp listener: %v", err)
 	}
-
-	// 创建 QUIC 监听器
-	s.listener, err = quic.Listen(udpConn, tlsConfig, quicConfig)
-	if err != nil {
-		udpConn.Close()
-		return fmt.Errorf("failed to create quic listener: %v", err)
-	}
+	s.conn = conn
 
 	// 创建 HTTP/3 服务器
 	s.server = &http3.Server{
@@ -90,7 +80,7 @@ func (s *Server) Start(addr string, tlsConfig *tls.Config) error {
 	}
 
 	// 启动服务器
-	go s.serve()
+	go s.serve(conn)
 
 	s.started = true
 	s.logger.Printf("http/3 server started, listening on: %s", addr)
@@ -113,14 +103,14 @@ func (s *Server) createQUICConfig() *quic.Config {
 }
 
 // serve 运行服务器
-func (s *Server) serve() {
+func (s *Server) serve(conn net.PacketConn) {
 	defer func() {
 		if r := recover(); r != nil {
 			s.logger.Printf("http/3 server panic: %v", r)
 		}
 	}()
 
-	err := s.server.ListenAndServe()
+	err := s.server.Serve(conn)
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		s.logger.Printf("http/3 server runtime error: %v", err)
 	} else {
@@ -150,9 +140,10 @@ func (s *Server) Stop() error {
 		s.server.Close()
 	}
 
-	// 关闭监听器
-	if s.listener != nil {
-		s.listener.Close()
+	// 关闭 UDP 连接 平滑升级时新进程持有的副本不受影响
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
 	}
 
 	// 取消上下文
@@ -185,7 +176,7 @@ func (s *Server) GetStats() *Stats {
 	}
 
 	// 如果服务器已启动，获取连接统计
-	if s.started && s.listener != nil {
+	if s.started && s.conn != nil {
 		// 注意：quic-go 可能不提供直接的连接统计接口
 		// 这里可能需要自己维护连接计数
 		stats.ActiveConnections = 0 // TODO: 实现连接计数
@@ -261,7 +252,7 @@ func (s *Server) HealthCheck() error {
 		return fmt.Errorf("http/3 server not started")
 	}
 
-	if s.listener == nil {
+	if s.conn == nil {
 		return fmt.Errorf("http/3 listener not initialized")
 	}
 
@@ -273,8 +264,8 @@ func (s *Server) GetListenAddr() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if s.listener != nil {
-		return s.listener.Addr().String()
+	if s.conn != nil {
+		return s.conn.LocalAddr().String()
 	}
 
 	return ""
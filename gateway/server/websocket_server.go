@@ -1,3 +1,52 @@
 package server
 
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"Hamburger/gateway/websocket"
+	"Hamburger/internal/config"
+
+	"github.com/rs/zerolog"
+)
+
 // ws服务器
+// 全局未启用WebSocket并且没有域名组开启use_websocket时不做限制 所有升级请求照常转发
+// 配置任意一项后 只允许全局启用并且域名所在的域名组开启use_websocket的升级请求 其余直接拒绝
+
+func wrapHandlerWithWebsocket(h http.Handler, logger *zerolog.Logger, serverConfig config.ServerConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsUpgrade(r) && !websocketAllowed(serverConfig, r.Host) {
+			logger.Debug().Str("Host", r.Host).Str("Server", serverConfig.Name).Msg("websocket upgrade not allowed")
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// websocketAllowed 域名是否允许WebSocket升级
+func websocketAllowed(serverConfig config.ServerConfig, host string) bool {
+	enabled := config.Get().Features.WebSocket.Enabled
+	if !enabled && !slices.ContainsFunc(serverConfig.DomainConfig, func(d config.DomainConfig) bool { return d.UseWebsocket }) {
+		return true
+	}
+	if !enabled {
+		return false
+	}
+	if colonIndex := strings.LastIndex(host, ":"); colonIndex != -1 {
+		host = host[:colonIndex]
+	}
+	for _, domainConfig := range serverConfig.DomainConfig {
+		if !domainConfig.UseWebsocket {
+			continue
+		}
+		for _, configuredDomain := range domainConfig.Domains {
+			if host == configuredDomain || (strings.HasPrefix(configuredDomain, "*.") && strings.HasSuffix(host, configuredDomain[1:])) {
+				return true
+			}
+		}
+	}
+	return false
+}
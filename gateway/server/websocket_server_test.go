@@ -0,0 +1,37 @@
+package server
+
+import (
+	"Hamburger/internal/config"
+	"testing"
+)
+
+func TestWebsocketAllowed(t *testing.T) {
+	restricted := config.ServerConfig{DomainConfig: []config.DomainConfig{
+		{Domains: []string{"ws.example.com", "*.live.example.com"}, UseWebsocket: true},
+		{Domains: []string{"www.example.com"}},
+	}}
+	plain := config.ServerConfig{DomainConfig: []config.DomainConfig{{Domains: []string{"www.example.com"}}}}
+
+	cases := []struct {
+		name    string
+		enabled bool
+		server  config.ServerConfig
+		host    string
+		want    bool
+	}{
+		{"not configured", false, plain, "www.example.com", true},
+		{"enabled without use_websocket", true, plain, "www.example.com", false},
+		{"use_websocket without feature", false, restricted, "ws.example.com", false},
+		{"allowed domain", true, restricted, "ws.example.com:443", true},
+		{"wildcard domain", true, restricted, "a.live.example.com", true},
+		{"other domain", true, restricted, "www.example.com", false},
+	}
+	for _, c := range cases {
+		cfg := &config.Config{}
+		cfg.Features.WebSocket.Enabled = c.enabled
+		config.Set(cfg)
+		if got := websocketAllowed(c.server, c.host); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -11,12 +11,14 @@ import (
 )
 
 var (
-	bc     *bigcache.BigCache
-	total  int64
-	api    int64
-	static int64
-	fail   int64
-	today  int64
+	bc      *bigcache.BigCache
+	total   int64
+	api     int64
+	static  int64
+	fail    int64
+	today   int64
+	retry   int64
+	blocked int64
 
 	// geo数据
 	geoIp *structure.Map[*int64] // 地区请求
@@ -53,6 +55,8 @@ func initCacheFromFile() {
 		atomic.StoreInt64(&static, m.MustGet("static"))
 		atomic.StoreInt64(&fail, m.MustGet("fail"))
 		atomic.StoreInt64(&today, m.MustGet("today"))
+		atomic.StoreInt64(&retry, m.MustGet("retry"))
+		atomic.StoreInt64(&blocked, m.MustGet("blocked"))
 	}
 	// 立即初始化一次
 	go syncStat()
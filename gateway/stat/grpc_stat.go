@@ -0,0 +1,107 @@
+package stat
+
+import (
+	"Hamburger/internal/structure"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// 按域名和gRPC方法统计调用结果 状态码为gRPC状态码
+
+const (
+	// grpcCodeCount gRPC状态码数量 0-16为标准状态码
+	grpcCodeCount = 17
+	// maxGrpcSeries 统计的域名和方法组合上限 超出后合并到GrpcOther
+	maxGrpcSeries = 1000
+
+	// GrpcUnmatched 未匹配路由和被拒绝的调用 域名和路径由客户端提供 不单独统计
+	GrpcUnmatched = "unmatched"
+	// GrpcOther 超出组合上限的调用
+	GrpcOther = "other"
+)
+
+// grpcCodeNames gRPC状态码名称
+var grpcCodeNames = [grpcCodeCount]string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND",
+	"ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION", "ABORTED",
+	"OUT_OF_RANGE", "UNIMPLEMENTED", "INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// GrpcStat gRPC方法的调用统计
+type GrpcStat struct {
+	Total int64            `json:"total"`
+	Codes map[string]int64 `json:"codes"`
+}
+
+type grpcCounter struct {
+	total atomic.Int64
+	codes [grpcCodeCount + 1]atomic.Int64 // 最后一个位置记录非标准状态码
+}
+
+var (
+	grpcStat   = structure.NewMap[*grpcCounter]()
+	grpcStatMu sync.Mutex
+)
+
+// AddGrpc 记录一次匹配到路由的gRPC调用 method为/pkg.Service/Method
+func AddGrpc(domain, method string, code int) {
+	addGrpc(domain+method, code)
+}
+
+// AddGrpcUnmatched 记录一次未匹配路由或被拒绝的gRPC调用
+func AddGrpcUnmatched(code int) {
+	addGrpc(GrpcUnmatched, code)
+}
+
+func addGrpc(key string, code int) {
+	c, ok := grpcStat.Get(key)
+	if !ok {
+		grpcStatMu.Lock()
+		if c, ok = grpcStat.Get(key); !ok {
+			if grpcStat.Size() >= maxGrpcSeries {
+				key = GrpcOther
+			}
+			if c, ok = grpcStat.Get(key); !ok {
+				c = &grpcCounter{}
+				grpcStat.Put(key, c)
+			}
+		}
+		grpcStatMu.Unlock()
+	}
+	c.total.Add(1)
+	if code < 0 || code >= grpcCodeCount {
+		code = grpcCodeCount
+	}
+	c.codes[code].Add(1)
+}
+
+// GetGrpcStat 所有gRPC方法的调用统计 key为域名加方法路径、GrpcUnmatched或GrpcOther
+func GetGrpcStat() map[string]GrpcStat {
+	result := make(map[string]GrpcStat)
+	grpcStat.Range(func(key string, c *grpcCounter) bool {
+		s := GrpcStat{Total: c.total.Load(), Codes: make(map[string]int64)}
+		for code := range c.codes {
+			n := c.codes[code].Load()
+			if n == 0 {
+				continue
+			}
+			if code < grpcCodeCount {
+				s.Codes[grpcCodeNames[code]] = n
+			} else {
+				s.Codes["OTHER"] = n
+			}
+		}
+		result[key] = s
+		return true
+	})
+	return result
+}
+
+// GrpcCodeName gRPC状态码名称
+func GrpcCodeName(code int) string {
+	if code >= 0 && code < grpcCodeCount {
+		return grpcCodeNames[code]
+	}
+	return "CODE_" + strconv.Itoa(code)
+}
@@ -33,11 +33,13 @@ func fileSaver(f string) {
 	if _, err := os.Stat(f); os.IsNotExist(err) {
 		// 创建文件
 		data, _ := json.Marshal(map[string]int64{
-			"total":  0,
-			"api":    0,
-			"static": 0,
-			"fail":   0,
-			"today":  0,
+			"total":   0,
+			"api":     0,
+			"static":  0,
+			"fail":    0,
+			"today":   0,
+			"retry":   0,
+			"blocked": 0,
 		})
 		_ = os.WriteFile(f, data, os.ModePerm)
 	}
@@ -47,6 +49,8 @@ func fileSaver(f string) {
 	m["static"] = Get(Static)
 	m["fail"] = Get(Fail)
 	m["today"] = Get(Today)
+	m["retry"] = Get(Retry)
+	m["blocked"] = Get(Blocked)
 
 	data, _ := json.Marshal(m)
 	_ = os.WriteFile(f, data, os.ModePerm)
@@ -80,6 +84,8 @@ func dbLoader() *structure.Map[int64] {
 	statMap.Put("api", stat.API)
 	statMap.Put("static", stat.Static)
 	statMap.Put("fail", stat.Fail)
+	statMap.Put("retry", stat.Retry)
+	statMap.Put("blocked", stat.Blocked)
 
 	return statMap
 }
@@ -89,18 +95,22 @@ func dbSaver() {
 	if err := db.GetDB().First(&stat).Error; err != nil {
 		// 新建
 		db.GetDB().Create(&StatModel{
-			Total:  Get(Total),
-			API:    Get(API),
-			Static: Get(Static),
-			Fail:   Get(Fail),
+			Total:   Get(Total),
+			API:     Get(API),
+			Static:  Get(Static),
+			Fail:    Get(Fail),
+			Retry:   Get(Retry),
+			Blocked: Get(Blocked),
 		})
 		return
 	}
 	db.GetDB().Model(&StatModel{}).Where("id=?", stat.ID).Updates(map[string]interface{}{
-		"total":  Get(Total),
-		"api":    Get(API),
-		"static": Get(Static),
-		"fail":   Get(Fail),
+		"total":   Get(Total),
+		"api":     Get(API),
+		"static":  Get(Static),
+		"fail":    Get(Fail),
+		"retry":   Get(Retry),
+		"blocked": Get(Blocked),
 	})
 }
 
@@ -117,10 +127,12 @@ func compatibleStat(cfg *config.Config) {
 	if err = db.GetDB().First(&stat).Error; err != nil {
 		// 新建
 		db.GetDB().Create(&StatModel{
-			Total:  tmp["total"],
-			API:    tmp["api"],
-			Static: tmp["static"],
-			Fail:   tmp["fail"],
+			Total:   tmp["total"],
+			API:     tmp["api"],
+			Static:  tmp["static"],
+			Fail:    tmp["fail"],
+			Retry:   tmp["retry"],
+			Blocked: tmp["blocked"],
 		})
 		return
 	}
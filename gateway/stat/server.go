@@ -1,9 +1,13 @@
 package stat
 
 import (
+	"Hamburger/gateway/breaker"
+	"Hamburger/gateway/health"
 	"Hamburger/internal/config"
 	"Hamburger/internal/json"
+	"Hamburger/internal/upgrade"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/rs/zerolog"
 	"net/http"
@@ -37,9 +41,13 @@ func (s *StatServer) Start() error {
 	}
 	s.logger.Info().Str("address", s.Addr).Int("port", s.Port).Msg("start stat server")
 
+	ln, err := upgrade.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
 	go func() {
-		err := s.server.ListenAndServe()
-		if err != nil {
+		err := s.server.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.logger.Error().Err(err).Msg("stat server listen err")
 		}
 	}()
@@ -73,6 +81,8 @@ func registerMux(mux *http.ServeMux) {
 		result["static"] = Get(Static)
 		result["fail"] = Get(Fail)
 		result["today"] = Get(Today)
+		result["retry"] = Get(Retry)
+		result["blocked"] = Get(Blocked)
 
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -93,6 +103,54 @@ func registerMux(mux *http.ServeMux) {
 		w.Write(result)
 	})
 
+	mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+		data, err := json.Marshal(health.Snapshot())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	})
+
+	mux.HandleFunc("/api/breaker", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+		data, err := json.Marshal(breaker.Snapshot())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	})
+
+	mux.HandleFunc("/api/grpc", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+		data, err := json.Marshal(GetGrpcStat())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	})
+
+	mux.HandleFunc("/api/websocket", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+		data, err := json.Marshal(GetWebSocketStat())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	})
+
 	mux.HandleFunc("/api/domain", func(w http.ResponseWriter, r *http.Request) {
 		result := GetDomainStat()
 		w.Header().Set("Content-Type", "application/json")
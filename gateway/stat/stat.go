@@ -15,6 +15,8 @@ import (
 // APIRequest 后端请求数
 // StaticRequest 前端请求数
 // FailRequest 失败次数
+// RetryRequest 上游重试次数
+// BlockedRequest 被访问控制拒绝的次数
 
 const (
 	Total = iota
@@ -22,6 +24,8 @@ const (
 	Static
 	Fail
 	Today
+	Retry
+	Blocked
 )
 
 // Add 后台异步的状态统计
@@ -40,6 +44,10 @@ func Add(tp int) {
 			addStatic()
 		case Fail:
 			addFail()
+		case Retry:
+			addRetry()
+		case Blocked:
+			addBlocked()
 		default:
 			addTotal()
 		}
@@ -79,6 +87,18 @@ func Get(tp int) int64 {
 			return 0
 		}
 		return int64(binary.BigEndian.Uint64(todayStatByte))
+	case Retry:
+		retryStatByte, err := C().Get("retry")
+		if err != nil {
+			return 0
+		}
+		return int64(binary.BigEndian.Uint64(retryStatByte))
+	case Blocked:
+		blockedStatByte, err := C().Get("blocked")
+		if err != nil {
+			return 0
+		}
+		return int64(binary.BigEndian.Uint64(blockedStatByte))
 	default:
 		return 0
 	}
@@ -101,6 +121,14 @@ func addFail() {
 	atomic.AddInt64(&fail, 1)
 }
 
+func addRetry() {
+	atomic.AddInt64(&retry, 1)
+}
+
+func addBlocked() {
+	atomic.AddInt64(&blocked, 1)
+}
+
 func addToday() {
 	atomic.AddInt64(&today, 1)
 }
@@ -110,6 +138,8 @@ func syncStat() {
 	apiStat := atomic.LoadInt64(&api)
 	staticStat := atomic.LoadInt64(&static)
 	failStat := atomic.LoadInt64(&fail)
+	retryStat := atomic.LoadInt64(&retry)
+	blockedStat := atomic.LoadInt64(&blocked)
 
 	totalStatByte := make([]byte, 8)
 	binary.BigEndian.PutUint64(totalStatByte, uint64(totalStat))
@@ -119,11 +149,17 @@ func syncStat() {
 	binary.BigEndian.PutUint64(staticByte, uint64(staticStat))
 	failByte := make([]byte, 8)
 	binary.BigEndian.PutUint64(failByte, uint64(failStat))
+	retryByte := make([]byte, 8)
+	binary.BigEndian.PutUint64(retryByte, uint64(retryStat))
+	blockedByte := make([]byte, 8)
+	binary.BigEndian.PutUint64(blockedByte, uint64(blockedStat))
 
 	C().Set("total", totalStatByte)
 	C().Set("api", apiByte)
 	C().Set("static", staticByte)
 	C().Set("fail", failByte)
+	C().Set("retry", retryByte)
+	C().Set("blocked", blockedByte)
 
 	// 对today特殊处理
 	now := time.Now()
@@ -7,11 +7,13 @@ type GeoModel struct {
 }
 
 type StatModel struct {
-	ID     int64 `json:"id" gorm:"column:id;primary_key"`
-	Total  int64 `json:"total" gorm:"column:total"`
-	API    int64 `json:"api" gorm:"column:api"`
-	Static int64 `json:"static" gorm:"column:static"`
-	Fail   int64 `json:"fail" gorm:"column:fail"`
+	ID      int64 `json:"id" gorm:"column:id;primary_key"`
+	Total   int64 `json:"total" gorm:"column:total"`
+	API     int64 `json:"api" gorm:"column:api"`
+	Static  int64 `json:"static" gorm:"column:static"`
+	Fail    int64 `json:"fail" gorm:"column:fail"`
+	Retry   int64 `json:"retry" gorm:"column:retry"`
+	Blocked int64 `json:"blocked" gorm:"column:blocked"`
 }
 
 type DomainModel struct {
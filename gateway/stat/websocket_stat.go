@@ -0,0 +1,117 @@
+package stat
+
+import (
+	"Hamburger/internal/structure"
+	"sync"
+	"sync/atomic"
+)
+
+// 按域名统计WebSocket连接
+// 活跃连接数需要实时准确 不受统计开关影响
+
+// WebSocketCounter 域名的WebSocket计数 方法可以安全地在nil上调用
+type WebSocketCounter struct {
+	active    atomic.Int64
+	total     atomic.Int64
+	framesIn  atomic.Int64 // 客户端发往上游的帧
+	framesOut atomic.Int64 // 上游发往客户端的帧
+	bytesIn   atomic.Int64
+	bytesOut  atomic.Int64
+	rejected  atomic.Int64 // 超出消息大小或超时被网关关闭的连接
+}
+
+// WebSocketStat WebSocket统计快照
+type WebSocketStat struct {
+	Active    int64 `json:"active"`
+	Total     int64 `json:"total"`
+	FramesIn  int64 `json:"frames_in"`
+	FramesOut int64 `json:"frames_out"`
+	BytesIn   int64 `json:"bytes_in"`
+	BytesOut  int64 `json:"bytes_out"`
+	Rejected  int64 `json:"rejected"`
+}
+
+var (
+	wsStat   = structure.NewMap[*WebSocketCounter]()
+	wsStatMu sync.Mutex
+)
+
+// WebSocket 获取域名的WebSocket计数 不存在时创建
+func WebSocket(domain string) *WebSocketCounter {
+	if c, ok := wsStat.Get(domain); ok {
+		return c
+	}
+	wsStatMu.Lock()
+	defer wsStatMu.Unlock()
+	if c, ok := wsStat.Get(domain); ok {
+		return c
+	}
+	c := &WebSocketCounter{}
+	wsStat.Put(domain, c)
+	return c
+}
+
+// Open 连接建立
+func (c *WebSocketCounter) Open() {
+	if c == nil {
+		return
+	}
+	c.active.Add(1)
+	c.total.Add(1)
+}
+
+// Close 连接关闭
+func (c *WebSocketCounter) Close() {
+	if c == nil {
+		return
+	}
+	c.active.Add(-1)
+}
+
+// Reject 连接被网关主动关闭
+func (c *WebSocketCounter) Reject() {
+	if c == nil {
+		return
+	}
+	c.rejected.Add(1)
+}
+
+// Frame 记录转发的帧 in为客户端发往上游
+func (c *WebSocketCounter) Frame(in bool, size int64) {
+	if c == nil {
+		return
+	}
+	if in {
+		c.framesIn.Add(1)
+		c.bytesIn.Add(size)
+		return
+	}
+	c.framesOut.Add(1)
+	c.bytesOut.Add(size)
+}
+
+// Snapshot 当前计数快照
+func (c *WebSocketCounter) Snapshot() WebSocketStat {
+	if c == nil {
+		return WebSocketStat{}
+	}
+	return WebSocketStat{
+		Active:    c.active.Load(),
+		Total:     c.total.Load(),
+		FramesIn:  c.framesIn.Load(),
+		FramesOut: c.framesOut.Load(),
+		BytesIn:   c.bytesIn.Load(),
+		BytesOut:  c.bytesOut.Load(),
+		Rejected:  c.rejected.Load(),
+	}
+}
+
+// GetWebSocketStat 所有域名的WebSocket统计
+func GetWebSocketStat() map[string]WebSocketStat {
+	result := make(map[string]WebSocketStat)
+	wsStat.Range(func(domain string, c *WebSocketCounter) bool {
+		result[domain] = c.Snapshot()
+		return true
+	})
+	return result
+}
@@ -0,0 +1,222 @@
+package tls
+
+import (
+	"Hamburger/internal/config"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 证书缓存
+// 证书在加载配置时解析一次 按域名建立精确和通配符索引 握手时不再读取文件
+// 后台定期检查证书文件的修改时间 变化后重新加载 加载失败时保留原证书
+
+// certEntry 一个证书组
+type certEntry struct {
+	name    string
+	conf    config.CertConfig
+	domains []string
+	cert    atomic.Pointer[tls.Certificate] // 装订OCSP响应时整体替换
+	leaf    *x509.Certificate
+	issuer  *x509.Certificate // 证书链中的签发者 没有时不做OCSP装订
+	certMod time.Time
+	keyMod  time.Time
+
+	// OCSP状态 只在后台任务中读写
+	ocspNext   time.Time
+	ocspExpire time.Time
+}
+
+// certStore 证书索引 创建后不再修改 变化时整体替换
+type certStore struct {
+	entries  []*certEntry
+	exact    map[string]*certEntry
+	wildcard map[string]*certEntry // key为去掉*的后缀 如.renj.io
+	def      *certEntry
+}
+
+// loadEntry 加载证书组
+func loadEntry(name string, conf config.CertConfig) (*certEntry, error) {
+	certInfo, err := os.Stat(conf.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo, err := os.Stat(conf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	leaf := cert.Leaf
+	if leaf == nil {
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
+		}
+		cert.Leaf = leaf
+	}
+
+	entry := &certEntry{
+		name:    name,
+		conf:    conf,
+		domains: conf.Domains,
+		leaf:    leaf,
+		certMod: certInfo.ModTime(),
+		keyMod:  keyInfo.ModTime(),
+	}
+	if len(entry.domains) == 0 {
+		entry.domains = leaf.DNSNames
+	}
+	if len(cert.Certificate) > 1 {
+		entry.issuer, _ = x509.ParseCertificate(cert.Certificate[1])
+	}
+	entry.cert.Store(&cert)
+	return entry, nil
+}
+
+// changed 证书或私钥文件的修改时间是否变化
+func (e *certEntry) changed() bool {
+	certInfo, err := os.Stat(e.conf.CertFile)
+	if err != nil {
+		return false
+	}
+	keyInfo, err := os.Stat(e.conf.KeyFile)
+	if err != nil {
+		return false
+	}
+	return !certInfo.ModTime().Equal(e.certMod) || !keyInfo.ModTime().Equal(e.keyMod)
+}
+
+// inherit 证书内容未变化时沿用原证书的OCSP响应
+func (e *certEntry) inherit(old *certEntry) {
+	if old == nil || !e.leaf.Equal(old.leaf) {
+		return
+	}
+	if staple := old.cert.Load().OCSPStaple; staple != nil {
+		cert := *e.cert.Load()
+		cert.OCSPStaple = staple
+		e.cert.Store(&cert)
+	}
+	e.ocspNext = old.ocspNext
+	e.ocspExpire = old.ocspExpire
+}
+
+// newCertStore 由证书组建立索引 同一域名出现在多个证书组时使用名称排序靠前的证书组
+func newCertStore(entries []*certEntry, defaultName string) *certStore {
+	slices.SortFunc(entries, func(a, b *certEntry) int {
+		return strings.Compare(a.name, b.name)
+	})
+	s := &certStore{
+		entries:  entries,
+		exact:    make(map[string]*certEntry),
+		wildcard: make(map[string]*certEntry),
+	}
+	for _, entry := range entries {
+		for _, domain := range entry.domains {
+			domain = normalizeName(domain)
+			index := s.exact
+			if strings.HasPrefix(domain, "*.") {
+				index = s.wildcard
+				domain = domain[1:]
+			}
+			if _, ok := index[domain]; !ok {
+				index[domain] = entry
+			}
+		}
+		if entry.name == defaultName {
+			s.def = entry
+		}
+	}
+	if s.def == nil && len(entries) > 0 {
+		s.def = entries[0]
+	}
+	return s
+}
+
+// lookup 按SNI查找证书组 先精确匹配再匹配通配符 SNI为空时使用默认证书组
+func (s *certStore) lookup(sni string) *certEntry {
+	if s == nil {
+		return nil
+	}
+	if sni == "" {
+		return s.def
+	}
+	name := normalizeName(sni)
+	if entry, ok := s.exact[name]; ok {
+		return entry
+	}
+	// 通配符只匹配一级子域名
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if entry, ok := s.wildcard[name[i:]]; ok {
+			return entry
+		}
+	}
+	return nil
+}
+
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// buildCertStore 加载配置中的全部证书组 无法加载的证书组不加入索引
+// 启用且未开启AutoTLS的服务器中存在无法加载的证书时同时返回错误
+func buildCertStore(cfg *config.Config, old *certStore) (*certStore, error) {
+	var entries []*certEntry
+	var errs []error
+	seen := make(map[string]struct{})
+	defaultName := ""
+	for _, server := range cfg.Servers {
+		if server.TLS == nil {
+			continue
+		}
+		if defaultName == "" {
+			defaultName = server.TLS.DefaultCert
+		}
+		for groupName, conf := range server.TLS.CertMap {
+			if _, ok := seen[groupName]; ok {
+				continue
+			}
+			entry, err := loadEntry(groupName, conf)
+			if err != nil {
+				if server.Enabled && !server.TLS.AutoTLS {
+					errs = append(errs, fmt.Errorf("server %s cert %s: %w", server.Name, groupName, err))
+				}
+				continue
+			}
+			seen[groupName] = struct{}{}
+			if old != nil {
+				entry.inherit(old.entry(groupName))
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return newCertStore(entries, defaultName), errors.Join(errs...)
+}
+
+// entry 按证书组名称查找
+func (s *certStore) entry(name string) *certEntry {
+	if s == nil {
+		return nil
+	}
+	for _, entry := range s.entries {
+		if entry.name == name {
+			return entry
+		}
+	}
+	return nil
+}
+
+// defaultName 默认证书组名称
+func (s *certStore) defaultName() string {
+	if s == nil || s.def == nil {
+		return ""
+	}
+	return s.def.name
+}
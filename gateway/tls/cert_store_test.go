@@ -0,0 +1,192 @@
+package tls
+
+import (
+	"Hamburger/internal/config"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/ocsp"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+// writeCert 签发证书并写入目录 证书文件包含签发者
+func (ca *testCA) writeCert(t *testing.T, dir, name string, serial int64, ocspURL string, dnsNames ...string) config.CertConfig {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if ocspURL != "" {
+		tmpl.OCSPServer = []string{ocspURL}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	conf := config.CertConfig{CertFile: filepath.Join(dir, name+".crt"), KeyFile: filepath.Join(dir, name+".key")}
+	certPEM := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...)
+	if err = os.WriteFile(conf.CertFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(conf.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+func newTestManager(certs map[string]config.CertConfig, defaultCert string) *TLSManager {
+	logger := zerolog.Nop()
+	cfg := &config.Config{Servers: []config.ServerConfig{{
+		Name:     "https",
+		Enabled:  true,
+		Protocol: "https",
+		TLS:      &config.TLSConfig{CertMap: certs, DefaultCert: defaultCert},
+	}}}
+	m := NewTLSManager(cfg, &logger)
+	m.InitCertMap()
+	return m
+}
+
+func serial(t *testing.T, cert *tls.Certificate) int64 {
+	if cert == nil {
+		t.Fatal("expected certificate")
+	}
+	return cert.Leaf.SerialNumber.Int64()
+}
+
+func TestSNILookup(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	m := newTestManager(map[string]config.CertConfig{
+		"apex":     ca.writeCert(t, dir, "apex", 10, "", "renj.io"),
+		"wildcard": ca.writeCert(t, dir, "wildcard", 11, "", "*.renj.io"),
+		"blog":     ca.writeCert(t, dir, "blog", 12, "", "blog.renj.io"),
+	}, "wildcard")
+	get := m.GetCertificateFunc()
+
+	cases := map[string]int64{
+		"renj.io":      10,
+		"RENJ.IO.":     10,
+		"blog.renj.io": 12,
+		"dev.renj.io":  11,
+		"":             11, // 没有SNI时使用默认证书
+	}
+	for sni, want := range cases {
+		cert, err := get(&tls.ClientHelloInfo{ServerName: sni})
+		if err != nil || serial(t, cert) != want {
+			t.Fatalf("%q: expected serial %d, got %v %v", sni, want, cert, err)
+		}
+	}
+	// 通配符只匹配一级子域名
+	if _, err := get(&tls.ClientHelloInfo{ServerName: "a.b.renj.io"}); err == nil {
+		t.Fatal("expected no certificate for nested subdomain")
+	}
+}
+
+func TestReloadChangedFile(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	m := newTestManager(map[string]config.CertConfig{
+		"site": ca.writeCert(t, dir, "site", 20, "", "renj.io"),
+	}, "")
+	get := m.GetCertificateFunc()
+
+	// 重新签发证书并修改文件时间
+	ca.writeCert(t, dir, "site", 21, "", "renj.io")
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(filepath.Join(dir, "site.crt"), future, future)
+	m.refresh(context.Background(), time.Now())
+
+	cert, _ := get(&tls.ClientHelloInfo{ServerName: "renj.io"})
+	if serial(t, cert) != 21 {
+		t.Fatalf("expected reloaded certificate, got serial %d", serial(t, cert))
+	}
+}
+
+func TestOCSPStapling(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	requests := 0
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := io.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		resp, _ := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   now.Add(-time.Minute),
+			NextUpdate:   now.Add(4 * time.Hour),
+		}, ca.key)
+		w.Write(resp)
+	}))
+	defer responder.Close()
+
+	m := newTestManager(map[string]config.CertConfig{
+		"site": ca.writeCert(t, dir, "site", 30, responder.URL, "renj.io"),
+	}, "")
+	now := time.Now()
+	m.refresh(context.Background(), now)
+	cert, _ := m.GetCertificateFunc()(&tls.ClientHelloInfo{ServerName: "renj.io"})
+	if len(cert.OCSPStaple) == 0 {
+		t.Fatal("expected ocsp staple")
+	}
+
+	// 有效期过半之前不再请求
+	m.refresh(context.Background(), now.Add(time.Hour))
+	if requests != 1 {
+		t.Fatalf("expected 1 ocsp request, got %d", requests)
+	}
+	m.refresh(context.Background(), now.Add(3*time.Hour))
+	if requests != 2 {
+		t.Fatalf("expected ocsp refresh, got %d requests", requests)
+	}
+}
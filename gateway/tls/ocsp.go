@@ -0,0 +1,118 @@
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// OCSP装订
+// 后台获取证书的OCSP响应并在握手时发送给客户端 在响应有效期过半时刷新
+// 获取失败时稍后重试 原响应过期后不再发送
+
+const (
+	// ocspTimeout 请求OCSP服务器的超时
+	ocspTimeout = 10 * time.Second
+	// ocspRetry 获取失败后的重试间隔
+	ocspRetry = 5 * time.Minute
+	// ocspDefaultRefresh 响应没有下次更新时间时的刷新间隔
+	ocspDefaultRefresh = time.Hour
+	// ocspMinRefresh 最短刷新间隔
+	ocspMinRefresh = time.Minute
+)
+
+var errNoOCSPServer = errors.New("certificate has no ocsp server")
+
+var ocspClient = &http.Client{Timeout: ocspTimeout}
+
+// ocspEnabled 证书组是否需要装订OCSP响应
+func (e *certEntry) ocspEnabled() bool {
+	return !e.conf.DisableOCSP && e.issuer != nil && len(e.leaf.OCSPServer) > 0
+}
+
+// refreshOCSP 到达刷新时间时获取OCSP响应 返回是否更新了证书
+func (e *certEntry) refreshOCSP(ctx context.Context, now time.Time) (bool, error) {
+	if !e.ocspEnabled() || now.Before(e.ocspNext) {
+		return false, nil
+	}
+
+	raw, resp, err := fetchOCSP(ctx, e)
+	if err != nil {
+		e.ocspNext = now.Add(ocspRetry)
+		// 原响应过期后移除
+		if !e.ocspExpire.IsZero() && now.After(e.ocspExpire) {
+			e.setStaple(nil)
+			e.ocspExpire = time.Time{}
+			return true, err
+		}
+		return false, err
+	}
+
+	e.setStaple(raw)
+	e.ocspExpire = resp.NextUpdate
+	e.ocspNext = nextOCSPRefresh(resp, now)
+	return true, nil
+}
+
+// setStaple 替换证书的OCSP响应 握手中正在使用的证书不受影响
+func (e *certEntry) setStaple(raw []byte) {
+	cert := *e.cert.Load()
+	cert.OCSPStaple = raw
+	e.cert.Store(&cert)
+}
+
+// nextOCSPRefresh 响应有效期过半时刷新
+func nextOCSPRefresh(resp *ocsp.Response, now time.Time) time.Time {
+	next := now.Add(ocspDefaultRefresh)
+	if !resp.NextUpdate.IsZero() {
+		next = resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate) / 2)
+	}
+	if minNext := now.Add(ocspMinRefresh); next.Before(minNext) {
+		return minNext
+	}
+	return next
+}
+
+// fetchOCSP 向证书中的第一个OCSP服务器请求响应 只接受状态为good的响应
+func fetchOCSP(ctx context.Context, e *certEntry) ([]byte, *ocsp.Response, error) {
+	if len(e.leaf.OCSPServer) == 0 {
+		return nil, nil, errNoOCSPServer
+	}
+	body, err := ocsp.CreateRequest(e.leaf, e.issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.leaf.OCSPServer[0], bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+	httpResp, err := ocspClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("ocsp server returned %s", httpResp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(httpResp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := ocsp.ParseResponseForCert(raw, e.leaf, e.issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.Status != ocsp.Good {
+		return nil, nil, fmt.Errorf("ocsp status is not good: %d", resp.Status)
+	}
+	return raw, resp, nil
+}
@@ -0,0 +1,177 @@
+package tls
+
+import (
+	"Hamburger/gateway/clientauth"
+	"Hamburger/internal/config"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/acme"
+)
+
+// TLS握手策略
+// 域名组可以单独配置版本、密码套件、曲线和ALPN 握手时按SNI选择策略 并为开启客户端证书认证的域名请求证书
+// 按基础配置、策略和是否请求客户端证书缓存派生的配置 配置重新加载后整体替换
+
+// tlsPolicy 解析后的域名组策略
+type tlsPolicy struct {
+	minVersion uint16
+	maxVersion uint16
+	ciphers    []uint16
+	curves     []tls.CurveID
+	alpn       []string
+}
+
+// policyStore 域名到策略的索引 通配符按去掉*的后缀索引 只匹配一级子域名
+type policyStore struct {
+	exact    map[string]*tlsPolicy
+	wildcard map[string]*tlsPolicy
+	derived  sync.Map // derivedKey -> *tls.Config
+}
+
+type derivedKey struct {
+	base       *tls.Config
+	policy     *tlsPolicy
+	clientAuth bool
+}
+
+// buildPolicyStore 按https和HTTP/3服务器的域名组建立策略索引 同一域名配置多次时使用先出现的策略
+func buildPolicyStore(cfg *config.Config) (*policyStore, error) {
+	s := &policyStore{exact: make(map[string]*tlsPolicy), wildcard: make(map[string]*tlsPolicy)}
+	var errs []error
+	for _, server := range cfg.Servers {
+		if !server.Enabled || (server.Protocol != "https" && server.Protocol != "http3") {
+			continue
+		}
+		for _, group := range server.DomainConfig {
+			if group.TLSPolicy == nil {
+				continue
+			}
+			policy, err := newTLSPolicy(*group.TLSPolicy)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("server %s domains %v: %w", server.Name, group.Domains, err))
+				continue
+			}
+			for _, domain := range group.Domains {
+				domain = strings.ToLower(domain)
+				index, key := s.exact, domain
+				if strings.HasPrefix(domain, "*.") {
+					index, key = s.wildcard, domain[1:]
+				}
+				if _, ok := index[key]; !ok {
+					index[key] = policy
+				}
+			}
+		}
+	}
+	return s, errors.Join(errs...)
+}
+
+func newTLSPolicy(conf config.TLSPolicyConfig) (*tlsPolicy, error) {
+	p := &tlsPolicy{alpn: conf.ALPN}
+	var err error
+	if p.minVersion, err = config.ParseTLSVersion(conf.MinVersion); err != nil {
+		return nil, err
+	}
+	if p.maxVersion, err = config.ParseTLSVersion(conf.MaxVersion); err != nil {
+		return nil, err
+	}
+	if p.ciphers, err = config.ParseCipherSuites(conf.CipherSuites); err != nil {
+		return nil, err
+	}
+	if p.curves, err = config.ParseCurves(conf.Curves); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// lookup SNI对应的策略 未配置时返回nil
+func (s *policyStore) lookup(serverName string) *tlsPolicy {
+	if s == nil || (len(s.exact) == 0 && len(s.wildcard) == 0) {
+		return nil
+	}
+	if h, _, err := net.SplitHostPort(serverName); err == nil {
+		serverName = h
+	}
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if p, ok := s.exact[serverName]; ok {
+		return p
+	}
+	if i := strings.IndexByte(serverName, '.'); i > 0 {
+		return s.wildcard[serverName[i:]]
+	}
+	return nil
+}
+
+// apply 把策略写入派生配置 HTTP/3固定使用TLS1.3和h3 只应用曲线
+func (p *tlsPolicy) apply(cfg *tls.Config, quic bool) {
+	if p == nil {
+		return
+	}
+	if len(p.curves) > 0 {
+		cfg.CurvePreferences = p.curves
+	}
+	if quic {
+		return
+	}
+	if p.minVersion != 0 {
+		cfg.MinVersion = p.minVersion
+	}
+	if p.maxVersion != 0 {
+		cfg.MaxVersion = p.maxVersion
+	}
+	if len(p.ciphers) > 0 {
+		cfg.CipherSuites = p.ciphers
+	}
+	if len(p.alpn) > 0 {
+		alpn := slices.Clone(p.alpn)
+		// 保留tls-alpn-01验证使用的协议
+		if slices.Contains(cfg.NextProtos, acme.ALPNProto) && !slices.Contains(alpn, acme.ALPNProto) {
+			alpn = append(alpn, acme.ALPNProto)
+		}
+		cfg.NextProtos = alpn
+	}
+}
+
+// prepare 为监听使用的基础配置设置会话票据密钥和按SNI选择配置的回调
+// 派生配置在首次握手时复制 此时http.Server已经补充了NextProtos
+// quic-go启动时会复制基础配置 HTTP/3总是返回派生配置 使轮换后的票据密钥生效
+func (m *TLSManager) prepare(base *tls.Config, quic bool) *tls.Config {
+	m.tickets.register(base)
+	base.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		store := m.policies.Load()
+		if store == nil {
+			return nil, nil
+		}
+		key := derivedKey{
+			base:       base,
+			policy:     store.lookup(hello.ServerName),
+			clientAuth: clientauth.Lookup(hello.ServerName) != nil,
+		}
+		if key.policy == nil && !key.clientAuth && !quic {
+			return nil, nil
+		}
+		if cfg, ok := store.derived.Load(key); ok {
+			return cfg.(*tls.Config), nil
+		}
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		if key.clientAuth {
+			// 握手时不校验证书 由前置处理器按域名组校验 校验失败时可以返回错误页而不是中断握手
+			cfg.ClientAuth = tls.RequestClientCert
+		}
+		key.policy.apply(cfg, quic)
+		actual, loaded := store.derived.LoadOrStore(key, cfg)
+		if !loaded {
+			// 复制后票据密钥可能已经轮换
+			m.tickets.apply(cfg)
+		}
+		return actual.(*tls.Config), nil
+	}
+	return base
+}
@@ -0,0 +1,130 @@
+package tls
+
+import (
+	"Hamburger/internal/config"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// serveTLS 按管理器的配置完成握手后写入一个字节并关闭连接
+func serveTLS(t *testing.T, m *TLSManager) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, lis, err := m.ConfigureTLS(&config.TLSConfig{}, ln)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// http.Server启动时补充的协议
+	base.NextProtos = []string{"h2", "http/1.1"}
+	t.Cleanup(func() { _ = lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte{1})
+			_ = conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func dialTLS(t *testing.T, addr, serverName string, roots *x509.CertPool, cache tls.ClientSessionCache) tls.ConnectionState {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{
+		ServerName:         serverName,
+		RootCAs:            roots,
+		NextProtos:         []string{"h2", "http/1.1"},
+		ClientSessionCache: cache,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// 读取数据时处理TLS1.3握手后发送的票据
+	_, _ = io.ReadAll(conn)
+	return conn.ConnectionState()
+}
+
+func TestDomainPolicyAndSessionTickets(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	cfg := &config.Config{Servers: []config.ServerConfig{{
+		Name:     "https",
+		Enabled:  true,
+		Protocol: "https",
+		TLS:      &config.TLSConfig{CertMap: map[string]config.CertConfig{"wildcard": ca.writeCert(t, dir, "wildcard", 2, "", "*.renj.io")}},
+		DomainConfig: []config.DomainConfig{
+			{Domains: []string{"legacy.renj.io"}, TLSPolicy: &config.TLSPolicyConfig{
+				MaxVersion:   "1.2",
+				CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+				Curves:       []string{"P-256"},
+				ALPN:         []string{"http/1.1"},
+			}},
+			{Domains: []string{"www.renj.io"}},
+		},
+	}}}
+	cfg.Features.SessionTicket = config.SessionTicketConfig{KeyFile: filepath.Join(dir, "tickets", "keys.json"), Keep: 1}
+	logger := zerolog.Nop()
+	m := NewTLSManager(cfg, &logger)
+	m.InitCertMap()
+	addr := serveTLS(t, m)
+
+	legacy := dialTLS(t, addr, "legacy.renj.io", roots, nil)
+	if legacy.Version != tls.VersionTLS12 || legacy.NegotiatedProtocol != "http/1.1" || legacy.CipherSuite != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Fatalf("legacy policy not applied: version %x alpn %q cipher %x", legacy.Version, legacy.NegotiatedProtocol, legacy.CipherSuite)
+	}
+	cache := tls.NewLRUClientSessionCache(4)
+	www := dialTLS(t, addr, "www.renj.io", roots, cache)
+	if www.Version != tls.VersionTLS13 || www.NegotiatedProtocol != "h2" || www.DidResume {
+		t.Fatalf("default policy not applied: version %x alpn %q resumed %v", www.Version, www.NegotiatedProtocol, www.DidResume)
+	}
+
+	// 重启后从密钥文件读取密钥 可以恢复之前的会话
+	restarted := NewTLSManager(cfg, &logger)
+	restarted.InitCertMap()
+	addr = serveTLS(t, restarted)
+	if !dialTLS(t, addr, "www.renj.io", roots, cache).DidResume {
+		t.Fatal("session not resumed after restart")
+	}
+
+	// 轮换后旧密钥仍可用于恢复会话 超出保留数量后失效
+	for i, resume := range []bool{true, false} {
+		if err := restarted.refreshTickets(time.Now().Add(time.Duration(i+1) * 25 * time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		cached := tls.NewLRUClientSessionCache(4)
+		if state, ok := cache.Get("www.renj.io"); ok {
+			cached.Put("www.renj.io", state)
+		}
+		if got := dialTLS(t, addr, "www.renj.io", roots, cached); got.DidResume != resume {
+			t.Fatalf("rotation %d: resumed %v, want %v", i+1, got.DidResume, resume)
+		}
+	}
+	keys, err := readTicketKeys(cfg.Features.SessionTicket.KeyFile)
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("expected 2 persisted keys, got %d (%v)", len(keys), err)
+	}
+
+	// HTTP/3使用相同的策略 只应用曲线 其余域名同样返回派生配置
+	h3 := restarted.GetTlsConfig()
+	legacyH3, _ := h3.GetConfigForClient(&tls.ClientHelloInfo{ServerName: "legacy.renj.io"})
+	if legacyH3 == nil || !slices.Equal(legacyH3.CurvePreferences, []tls.CurveID{tls.CurveP256}) || legacyH3.MaxVersion != 0 {
+		t.Fatal("http3 policy not applied")
+	}
+	if wwwH3, _ := h3.GetConfigForClient(&tls.ClientHelloInfo{ServerName: "www.renj.io"}); wwwH3 == nil {
+		t.Fatal("expected derived config for http3")
+	}
+}
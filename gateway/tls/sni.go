@@ -2,59 +2,148 @@ package tls
 
 import (
 	"Hamburger/internal/config"
+	"context"
 	"crypto/tls"
 	"fmt"
+	"sync"
+	"time"
 )
 
 // 处理多域名SNI
 
+// certCheckInterval 检查证书文件变化和OCSP刷新时间的间隔
+const certCheckInterval = 10 * time.Second
+
 func (m *TLSManager) GetCertificateFunc() func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	return func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
 		if info == nil {
 			return nil, nil
 		}
-		sniName := info.ServerName
-		if sniName == "" {
-			return nil, nil
-		}
-		domainCert, domainKey := m.GetCert(sniName)
-		if domainCert == "" || domainKey == "" {
-			return nil, nil
+		if m.issuer != nil {
+			if cert, err := m.issuer.GetCertificate(info); cert != nil || err != nil {
+				return cert, err
+			}
 		}
-		// 加载证书和私钥
-		cert, err := tls.LoadX509KeyPair(domainCert, domainKey)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+		entry := m.store.Load().lookup(info.ServerName)
+		if entry == nil {
+			return nil, fmt.Errorf("no certificate for server name %q", info.ServerName)
 		}
-
-		return &cert, nil
+		return entry.cert.Load(), nil
 	}
 }
 
+// GetCert 获取SNI对应的证书文件
 func (m *TLSManager) GetCert(sni string) (certFile, keyFile string) {
-	m.certMu.RLock()
-	defer m.certMu.RUnlock()
-	for _, cert := range m.certMap {
-		for _, domain := range cert.Domains {
-			if sni == domain {
-				return cert.CertFile, cert.KeyFile
-			}
-		}
+	entry := m.store.Load().lookup(sni)
+	if entry == nil {
+		return "", ""
 	}
-
-	return "", ""
+	return entry.conf.CertFile, entry.conf.KeyFile
 }
 
+// InitCertMap 加载证书 无法加载的证书记录日志后跳过
 func (m *TLSManager) InitCertMap() {
+	store, err := buildCertStore(m.config, nil)
+	if err != nil {
+		m.logger.Error().Err(err).Msg("failed to load tls certificates")
+	}
+	m.store.Store(store)
+	m.logger.Info().Int("certs", len(store.entries)).Str("default", store.defaultName()).Msg("tls certificates loaded")
+
+	policies, err := buildPolicyStore(m.config)
+	if err != nil {
+		m.logger.Error().Err(err).Msg("failed to load tls policies")
+	}
+	m.policies.Store(policies)
+	m.tickets.configure(m.config.Features.SessionTicket)
+	if err = m.refreshTickets(time.Now()); err != nil {
+		m.logger.Error().Err(err).Msg("failed to load session ticket keys")
+	}
+}
+
+// Reload 使用新配置替换证书映射、域名组策略和会话票据配置
+// 启用的服务器中存在无法加载的证书或策略时返回错误并保留原有映射
+func (m *TLSManager) Reload(cfg *config.Config) error {
+	m.certMu.Lock()
+	defer m.certMu.Unlock()
+	store, err := buildCertStore(cfg, m.store.Load())
+	if err != nil {
+		return err
+	}
+	policies, err := buildPolicyStore(cfg)
+	if err != nil {
+		return err
+	}
+	m.tickets.configure(cfg.Features.SessionTicket)
+	if err = m.refreshTickets(time.Now()); err != nil {
+		return err
+	}
+	m.config = cfg
+	m.store.Store(store)
+	m.policies.Store(policies)
+	return nil
+}
+
+// Watch 定期检查证书文件变化、刷新OCSP响应和轮换会话票据密钥 启用Issuer时同时运行证书申请 直到ctx取消
+func (m *TLSManager) Watch(ctx context.Context) {
+	m.acmeMU.Lock()
+	issuer := m.issuer
+	m.acmeMU.Unlock()
+	if issuer != nil {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			issuer.Run(ctx)
+		}()
+		defer wg.Wait()
+	}
+
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+	for {
+		m.refresh(ctx, time.Now())
+		if err := m.refreshTickets(time.Now()); err != nil {
+			m.logger.Warn().Err(err).Msg("failed to refresh session ticket keys")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh 重新加载变化的证书并刷新到期的OCSP响应
+func (m *TLSManager) refresh(ctx context.Context, now time.Time) {
 	m.certMu.Lock()
 	defer m.certMu.Unlock()
+	store := m.store.Load()
+	if store == nil {
+		return
+	}
 
-	m.certMap = make(map[string]config.CertConfig)
-	for _, server := range m.config.Servers {
-		if server.TLS != nil && server.TLS.CertMap != nil {
-			for groupName, cert := range server.TLS.CertMap {
-				m.certMap[groupName] = cert
+	changed := false
+	entries := make([]*certEntry, 0, len(store.entries))
+	for _, entry := range store.entries {
+		if entry.changed() {
+			reloaded, err := loadEntry(entry.name, entry.conf)
+			if err != nil {
+				// 证书和私钥可能尚未全部写入 下次检查时重试
+				m.logger.Warn().Err(err).Str("cert", entry.name).Msg("failed to reload changed tls certificate")
+			} else {
+				m.logger.Info().Str("cert", entry.name).Time("not_after", reloaded.leaf.NotAfter).Msg("tls certificate reloaded")
+				reloaded.inherit(entry)
+				entry = reloaded
+				changed = true
 			}
 		}
+		if _, err := entry.refreshOCSP(ctx, now); err != nil {
+			m.logger.Warn().Err(err).Str("cert", entry.name).Msg("failed to refresh ocsp staple")
+		}
+		entries = append(entries, entry)
+	}
+	if changed {
+		m.store.Store(newCertStore(entries, store.defaultName()))
 	}
 }
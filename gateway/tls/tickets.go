@@ -0,0 +1,188 @@
+package tls
+
+import (
+	"Hamburger/internal/config"
+	"Hamburger/internal/json"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 会话票据密钥
+// 密钥由网关生成并按间隔轮换 最新的密钥用于签发票据 旧密钥保留一段时间用于恢复会话
+// 配置密钥文件后轮换时写入文件 启动时读取 文件被共用的其他实例更新后重新读取
+
+const (
+	defaultTicketRotate = 24 * time.Hour
+	defaultTicketKeep   = 6
+)
+
+type ticketKey struct {
+	Key     []byte    `json:"key"`
+	Created time.Time `json:"created"`
+}
+
+type ticketFile struct {
+	Keys []ticketKey `json:"keys"`
+}
+
+type ticketKeys struct {
+	mu      sync.Mutex
+	conf    config.SessionTicketConfig
+	keys    []ticketKey // 最新的在前
+	mod     time.Time   // 密钥文件的修改时间
+	bases   []*tls.Config
+	current atomic.Pointer[[][32]byte]
+}
+
+// configure 替换票据配置 密钥文件变化后在下次刷新时读取
+func (t *ticketKeys) configure(conf config.SessionTicketConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if conf.KeyFile != t.conf.KeyFile {
+		t.mod = time.Time{}
+	}
+	t.conf = conf
+}
+
+// refresh 密钥文件被更新时重新读取 到达轮换间隔时生成新密钥并写入文件 返回密钥是否变化
+func (t *ticketKeys) refresh(now time.Time) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	changed := false
+	save := false
+	if file := t.conf.KeyFile; file != "" {
+		info, err := os.Stat(file)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			save = true
+		case err != nil:
+			return false, err
+		case !info.ModTime().Equal(t.mod):
+			keys, err := readTicketKeys(file)
+			if err != nil {
+				return false, err
+			}
+			t.mod = info.ModTime()
+			if len(keys) > 0 {
+				t.keys = keys
+				changed = true
+			}
+		}
+	}
+
+	interval := defaultTicketRotate
+	if t.conf.RotateInterval > 0 {
+		interval = time.Duration(t.conf.RotateInterval) * time.Hour
+	}
+	if len(t.keys) == 0 || now.Sub(t.keys[0].Created) >= interval {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return false, err
+		}
+		keep := defaultTicketKeep
+		if t.conf.Keep > 0 {
+			keep = t.conf.Keep
+		}
+		t.keys = append([]ticketKey{{Key: key, Created: now}}, t.keys[:min(len(t.keys), keep)]...)
+		changed = true
+		save = t.conf.KeyFile != ""
+	}
+
+	if save {
+		if err := writeTicketKeys(t.conf.KeyFile, t.keys); err != nil {
+			return changed, err
+		}
+		if info, err := os.Stat(t.conf.KeyFile); err == nil {
+			t.mod = info.ModTime()
+		}
+	}
+	if changed {
+		keys := make([][32]byte, len(t.keys))
+		for i, key := range t.keys {
+			copy(keys[i][:], key.Key)
+		}
+		t.current.Store(&keys)
+	}
+	return changed, nil
+}
+
+// register 记录监听使用的基础配置 轮换时一起更新
+func (t *ticketKeys) register(cfg *tls.Config) {
+	t.mu.Lock()
+	t.bases = append(t.bases, cfg)
+	t.mu.Unlock()
+	t.apply(cfg)
+}
+
+// apply 把当前密钥写入配置 尚未生成密钥时使用标准库的默认密钥
+func (t *ticketKeys) apply(cfg *tls.Config) {
+	if keys := t.current.Load(); keys != nil {
+		cfg.SetSessionTicketKeys(*keys)
+	}
+}
+
+// applyBases 把当前密钥写入所有基础配置
+func (t *ticketKeys) applyBases() {
+	t.mu.Lock()
+	bases := t.bases
+	t.mu.Unlock()
+	for _, cfg := range bases {
+		t.apply(cfg)
+	}
+}
+
+func readTicketKeys(file string) ([]ticketKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var f ticketFile
+	if err = json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse session ticket keys %s: %w", file, err)
+	}
+	for _, key := range f.Keys {
+		if len(key.Key) != 32 {
+			return nil, fmt.Errorf("parse session ticket keys %s: invalid key length %d", file, len(key.Key))
+		}
+	}
+	return f.Keys, nil
+}
+
+// writeTicketKeys 先写入临时文件再替换 避免其他实例读到不完整的文件
+func writeTicketKeys(file string, keys []ticketKey) error {
+	data, err := json.Marshal(ticketFile{Keys: keys})
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// refreshTickets 刷新票据密钥 密钥变化后更新所有基础配置和派生配置
+func (m *TLSManager) refreshTickets(now time.Time) error {
+	changed, err := m.tickets.refresh(now)
+	if changed {
+		m.tickets.applyBases()
+		if store := m.policies.Load(); store != nil {
+			store.derived.Range(func(_, cfg any) bool {
+				m.tickets.apply(cfg.(*tls.Config))
+				return true
+			})
+		}
+	}
+	return err
+}
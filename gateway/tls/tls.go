@@ -2,14 +2,17 @@ package tls
 
 import (
 	autocert2 "Hamburger/gateway/autocert"
+	"Hamburger/gateway/metrics"
 	"Hamburger/internal/config"
 	"Hamburger/internal/structure"
 	"crypto/tls"
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 
 	"github.com/rs/zerolog"
+	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
 	"golang.org/x/sync/singleflight"
 )
@@ -18,12 +21,16 @@ type TLSManager struct {
 	config *config.Config
 	logger *zerolog.Logger
 	// AutoTLS
-	AcmeMgr *autocert.Manager  // autocert 管理器
+	AcmeMgr *autocert.Manager  // autocert 管理器 仅用于http-01
+	issuer  *autocert2.Issuer  // tls-alpn-01和dns-01的证书申请
 	acmeMU  sync.Mutex         // 刷新证书过程的互斥锁，避免并发冲突
 	sf      singleflight.Group // 用于合并并发的证书请求
-	// cert map
-	certMu  sync.RWMutex
-	certMap map[string]config.CertConfig
+	// 证书索引 certMu串行化重新加载
+	certMu sync.Mutex
+	store  atomic.Pointer[certStore]
+	// 域名组的TLS策略和会话票据密钥
+	policies atomic.Pointer[policyStore]
+	tickets  ticketKeys
 
 	beforeAutoCert func() error
 	afterAutoCert  func() error
@@ -58,9 +65,17 @@ func (m *TLSManager) ConfigureTLS(tlsConfig *config.TLSConfig, listener net.List
 			return nil, nil, fmt.Errorf("autotls enabled but no domains configured, cannot request certificate")
 		}
 
+		if autocert2.UseIssuer(m.config.Features.AutoCert) {
+			return m.configureIssuer(domains, listener)
+		}
+
 		// 初始化或复用 autocert 管理器
 		if m.AcmeMgr == nil {
-			m.AcmeMgr = autocert2.NewCertManager(domains, m.config.Features.AutoCert.Email)
+			mgr, err := autocert2.NewCertManager(m.config.Features.AutoCert, domains)
+			if err != nil {
+				return nil, nil, err
+			}
+			m.AcmeMgr = mgr
 		}
 
 		// 基础TLS配置来自autocert
@@ -103,6 +118,8 @@ func (m *TLSManager) ConfigureTLS(tlsConfig *config.TLSConfig, listener net.List
 		// 强化TLS安全参数
 		base.MinVersion = tls.VersionTLS12
 		base.PreferServerCipherSuites = true
+		base.VerifyConnection = recordHandshake
+		m.prepare(base, false)
 
 		// 应用 TLS 配置
 		lis := tls.NewListener(listener, base)
@@ -117,7 +134,9 @@ func (m *TLSManager) ConfigureTLS(tlsConfig *config.TLSConfig, listener net.List
 		MinVersion: tls.VersionTLS12,
 		// 优先使用服务器的密码套件顺序
 		PreferServerCipherSuites: true,
+		VerifyConnection:         recordHandshake,
 	}
+	m.prepare(tlsCfg, false)
 
 	// 应用 TLS 配置
 	lis := tls.NewListener(listener, tlsCfg)
@@ -125,6 +144,32 @@ func (m *TLSManager) ConfigureTLS(tlsConfig *config.TLSConfig, listener net.List
 	return tlsCfg, lis, nil
 }
 
+// configureIssuer tls-alpn-01和dns-01不需要接管80端口
+// 证书由Issuer在后台申请 签发前使用配置的证书 tls-alpn-01验证在当前监听上完成
+func (m *TLSManager) configureIssuer(domains []string, listener net.Listener) (*tls.Config, net.Listener, error) {
+	m.acmeMU.Lock()
+	if m.issuer == nil {
+		issuer, err := autocert2.NewIssuer(m.config.Features.AutoCert, domains, m.logger)
+		if err != nil {
+			m.acmeMU.Unlock()
+			return nil, nil, err
+		}
+		m.issuer = issuer
+	}
+	m.acmeMU.Unlock()
+
+	tlsCfg := &tls.Config{
+		GetCertificate:           m.GetCertificateFunc(),
+		NextProtos:               []string{"h2", "http/1.1", acme.ALPNProto},
+		MinVersion:               tls.VersionTLS12,
+		PreferServerCipherSuites: true,
+		VerifyConnection:         recordHandshake,
+	}
+	m.prepare(tlsCfg, false)
+	return tlsCfg, tls.NewListener(listener, tlsCfg), nil
+}
+
+// GetTlsConfig HTTP/3使用的TLS配置 与https服务器使用相同的证书、域名组策略和会话票据密钥
 func (m *TLSManager) GetTlsConfig() *tls.Config {
 	tlsCfg := &tls.Config{
 		GetCertificate: m.GetCertificateFunc(),
@@ -132,9 +177,16 @@ func (m *TLSManager) GetTlsConfig() *tls.Config {
 		MinVersion: tls.VersionTLS12,
 		// 优先使用服务器的密码套件顺序
 		PreferServerCipherSuites: true,
+		VerifyConnection:         recordHandshake,
 	}
 
-	return tlsCfg
+	return m.prepare(tlsCfg, true)
+}
+
+// recordHandshake 握手完成后记录指标 未找到证书的SNI握手失败不会记录
+func recordHandshake(cs tls.ConnectionState) error {
+	metrics.TLSHandshake(cs.ServerName, tls.VersionName(cs.Version), cs.DidResume)
+	return nil
 }
 
 // GetTlsDomains 获取sever和autoCert配置中的ssl域名 取交集
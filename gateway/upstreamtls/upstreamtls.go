@@ -0,0 +1,202 @@
+// Package upstreamtls
+// 自定义服务上游的TLS配置
+// 配置相同的上游共用一个Profile 传输层按Profile缓存客户端 连接池和TLS会话缓存在这些上游间共享
+package upstreamtls
+
+import (
+	"Hamburger/internal/config"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+
+	// sessionCacheSize 每个Profile缓存的TLS会话数 按上游地址缓存
+	sessionCacheSize = 256
+)
+
+// Profile 一组相同的上游TLS配置
+type Profile struct {
+	key    string
+	config *tls.Config
+}
+
+// Key Profile的唯一标识 包含配置项和证书文件内容的摘要 文件内容变化后重新加载会得到新的Profile
+func (p *Profile) Key() string {
+	return p.key
+}
+
+// TLSConfig 返回配置副本 副本共享会话缓存 连接同一上游时可以恢复会话
+func (p *Profile) TLSConfig() *tls.Config {
+	return p.config.Clone()
+}
+
+type registry struct {
+	routes   map[string]*Profile // 域名和上游地址 -> Profile
+	profiles map[string]*Profile // Key -> Profile
+}
+
+var current atomic.Pointer[registry]
+
+var (
+	hookMu sync.Mutex
+	hooks  []func()
+)
+
+// OnReload 注册配置替换后的回调 传输层用于释放不再使用的Profile对应的客户端
+func OnReload(fn func()) {
+	hookMu.Lock()
+	defer hookMu.Unlock()
+	hooks = append(hooks, fn)
+}
+
+// Init 按自定义服务配置替换上游TLS配置 证书文件无法加载时返回错误并保留原有配置
+// Key未变化的Profile沿用原有实例 保留已建立的连接池和会话缓存
+func Init(conf config.PxyCustomServiceConfig) error {
+	old := current.Load()
+	r := &registry{routes: make(map[string]*Profile), profiles: make(map[string]*Profile)}
+	var errs []error
+	for _, service := range conf.CustomService {
+		upstreams := slices.Clone(service.Upstream)
+		for _, route := range service.GeoRoutes {
+			upstreams = append(upstreams, route.Upstream...)
+		}
+		for _, upstream := range upstreams {
+			if upstream.Scheme != SchemeHTTPS {
+				continue
+			}
+			profile, err := r.profile(upstream.TLS, old)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("custom service %s upstream %s:%d: %w", service.Domain, upstream.Host, upstream.Port, err))
+				continue
+			}
+			r.routes[routeKey(service.Domain, address(upstream))] = profile
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	current.Store(r)
+	hookMu.Lock()
+	fns := slices.Clone(hooks)
+	hookMu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+	return nil
+}
+
+// profile 创建或复用Profile
+func (r *registry) profile(conf *config.UpstreamTLS, old *registry) (*Profile, error) {
+	if conf == nil {
+		conf = &config.UpstreamTLS{}
+	}
+	var ca, cert, key []byte
+	var err error
+	if conf.CAFile != "" {
+		if ca, err = os.ReadFile(conf.CAFile); err != nil {
+			return nil, err
+		}
+	}
+	if conf.CertFile != "" {
+		if cert, err = os.ReadFile(conf.CertFile); err != nil {
+			return nil, err
+		}
+		if key, err = os.ReadFile(conf.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+	k := profileKey(conf, ca, cert, key)
+	if p, ok := r.profiles[k]; ok {
+		return p, nil
+	}
+	if old != nil {
+		if p, ok := old.profiles[k]; ok {
+			r.profiles[k] = p
+			return p, nil
+		}
+	}
+
+	tlsCfg := &tls.Config{
+		ServerName:         conf.ServerName,
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+		ClientSessionCache: tls.NewLRUClientSessionCache(sessionCacheSize),
+		MinVersion:         tls.VersionTLS12,
+	}
+	if ca != nil {
+		tlsCfg.RootCAs = x509.NewCertPool()
+		if !tlsCfg.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s", conf.CAFile)
+		}
+	}
+	if cert != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{pair}
+	}
+	p := &Profile{key: k, config: tlsCfg}
+	r.profiles[k] = p
+	return p, nil
+}
+
+func profileKey(conf *config.UpstreamTLS, ca, cert, key []byte) string {
+	h := sha256.New()
+	for _, b := range [][]byte{ca, cert, key} {
+		sum := sha256.Sum256(b)
+		h.Write(sum[:])
+	}
+	return strings.Join([]string{
+		conf.ServerName,
+		strconv.FormatBool(conf.InsecureSkipVerify),
+		hex.EncodeToString(h.Sum(nil)[:16]),
+	}, "|")
+}
+
+func routeKey(domain, addr string) string {
+	return strings.ToLower(domain) + "|" + addr
+}
+
+func address(upstream config.Upstream) string {
+	return fmt.Sprintf("%s:%d", upstream.Host, upstream.Port)
+}
+
+// Lookup 域名的上游地址对应的Profile 上游不是https时返回nil
+func Lookup(domain, addr string) *Profile {
+	r := current.Load()
+	if r == nil || len(r.routes) == 0 {
+		return nil
+	}
+	return r.routes[routeKey(domain, addr)]
+}
+
+// InUse Key对应的Profile是否仍被当前配置使用
+func InUse(key string) bool {
+	r := current.Load()
+	if r == nil {
+		return false
+	}
+	_, ok := r.profiles[key]
+	return ok
+}
+
+// Scheme 域名的上游地址使用的协议
+func Scheme(domain, addr string) string {
+	if Lookup(domain, addr) != nil {
+		return SchemeHTTPS
+	}
+	return SchemeHTTP
+}
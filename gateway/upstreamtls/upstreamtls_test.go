@@ -0,0 +1,129 @@
+package upstreamtls
+
+import (
+	"Hamburger/internal/config"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// writeCert 签发证书并写入PEM文件 parent为空时生成自签名CA
+func writeCert(t *testing.T, dir, name string, tmpl *x509.Certificate, parent *tls.Certificate) tls.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.Subject = pkix.Name{CommonName: name}
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	issuer, signer := tmpl, any(key)
+	if parent != nil {
+		issuer, signer = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, key.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err = os.WriteFile(filepath.Join(dir, name+".pem"), certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	pair, _ := tls.X509KeyPair(certPEM, keyPEM)
+	pair.Leaf, _ = x509.ParseCertificate(der)
+	return pair
+}
+
+func TestUpstreamTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeCert(t, dir, "ca", &x509.Certificate{IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}, nil)
+	server := writeCert(t, dir, "server", &x509.Certificate{DNSNames: []string{"backend.internal"}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}, &ca)
+	writeCert(t, dir, "client", &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, &ca)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Client", r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	backend.TLS = &tls.Config{Certificates: []tls.Certificate{server}, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: roots}
+	backend.StartTLS()
+	defer backend.Close()
+	host, portStr, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	conf := config.PxyCustomServiceConfig{Enable: true, CustomService: []config.CustomServiceConfig{{
+		Domain: "app.example.com",
+		Upstream: []config.Upstream{
+			{Host: host, Port: port, Scheme: SchemeHTTPS, TLS: &config.UpstreamTLS{
+				ServerName: "backend.internal",
+				CAFile:     filepath.Join(dir, "ca.pem"),
+				CertFile:   filepath.Join(dir, "client.pem"),
+				KeyFile:    filepath.Join(dir, "client.key"),
+			}},
+			{Host: host, Port: port + 1},
+		},
+	}}}
+	if err := Init(conf); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { current.Store(nil) })
+
+	addr := backend.Listener.Addr().String()
+	profile := Lookup("app.example.com", addr)
+	if profile == nil || Scheme("app.example.com", addr) != SchemeHTTPS {
+		t.Fatal("expected https profile for upstream")
+	}
+	if Scheme("app.example.com", net.JoinHostPort(host, strconv.Itoa(port+1))) != SchemeHTTP || Lookup("other.example.com", addr) != nil {
+		t.Fatal("unexpected profile for plain upstream")
+	}
+
+	// 配置未变化时沿用原有Profile
+	if err := Init(conf); err != nil {
+		t.Fatal(err)
+	}
+	if Lookup("app.example.com", addr) != profile {
+		t.Fatal("profile not reused after reload")
+	}
+
+	// 同一Profile的连接可以恢复会话
+	for i, resume := range []bool{false, true} {
+		transport := &http.Transport{TLSClientConfig: profile.TLSConfig()}
+		req, _ := http.NewRequest(http.MethodGet, "https://"+addr+"/", nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		transport.CloseIdleConnections()
+		if resp.Header.Get("X-Client") != "client" {
+			t.Fatalf("client certificate not presented: %q", resp.Header.Get("X-Client"))
+		}
+		if resp.TLS.DidResume != resume {
+			t.Fatalf("request %d: DidResume = %v", i, resp.TLS.DidResume)
+		}
+	}
+
+	// 证书文件缺失时保留原有配置
+	conf.CustomService[0].Upstream[0].TLS.CAFile = filepath.Join(dir, "missing.pem")
+	if err := Init(conf); err == nil {
+		t.Fatal("expected error for missing ca file")
+	}
+	if Lookup("app.example.com", addr) != profile {
+		t.Fatal("profile replaced after failed reload")
+	}
+}
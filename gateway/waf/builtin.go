@@ -0,0 +1,84 @@
+package waf
+
+import "Hamburger/internal/config"
+
+// 内置规则组
+const (
+	BuiltinSQLi        = "sqli"
+	BuiltinXSS         = "xss"
+	BuiltinTraversal   = "traversal"
+	BuiltinScanner     = "scanner"
+	BuiltinQueryLength = "query_length"
+)
+
+var builtinGroups = map[string][]config.WAFRule{
+	BuiltinSQLi: {
+		{
+			Name:    "sqli-union",
+			Targets: []string{TargetQuery, TargetBody},
+			Pattern: `(?i)\bunion\b[\s/*]+(all[\s/*]+)?select\b`,
+		},
+		{
+			Name:    "sqli-tautology",
+			Targets: []string{TargetQuery, TargetBody},
+			Pattern: `(?i)['"\d]\s*\b(or|and)\b\s+['"]?(\w+)['"]?\s*(=|like)\s*['"]?\w+`,
+		},
+		{
+			Name:    "sqli-stacked",
+			Targets: []string{TargetQuery, TargetBody},
+			Pattern: `(?i);\s*(drop|truncate|alter|delete\s+from|insert\s+into|update\s+\w+\s+set|exec(ute)?)\b`,
+		},
+		{
+			Name:    "sqli-function",
+			Targets: []string{TargetQuery, TargetBody},
+			Pattern: `(?i)\b(sleep|benchmark|pg_sleep|extractvalue|updatexml|load_file)\s*\(|\bwaitfor\s+delay\b|\binformation_schema\b`,
+		},
+	},
+	BuiltinXSS: {
+		{
+			Name:    "xss-script",
+			Targets: []string{TargetQuery, TargetBody, TargetPath},
+			Pattern: `(?i)<\s*(script|iframe|object|embed|svg|img)[\s/>]|javascript\s*:|vbscript\s*:`,
+		},
+		{
+			Name:    "xss-event",
+			Targets: []string{TargetQuery, TargetBody},
+			Pattern: `(?i)\bon(error|load|click|mouseover|focus|blur|submit|toggle|animationstart)\s*=|document\.(cookie|domain)|\beval\s*\(`,
+		},
+	},
+	BuiltinTraversal: {
+		{
+			Name:    "traversal-dot",
+			Targets: []string{TargetPath, TargetQuery},
+			Pattern: `(?i)(^|[/\\=])\.\.([/\\]|$)|%2e%2e(%2f|%5c|/|\\)`,
+		},
+		{
+			Name:    "traversal-file",
+			Targets: []string{TargetPath, TargetQuery},
+			Pattern: `(?i)/etc/(passwd|shadow|hosts)|/proc/self/|c:[/\\]windows[/\\]|\bboot\.ini\b|/\.(git|svn|env)(/|$)`,
+		},
+	},
+	BuiltinScanner: {
+		{
+			Name:    "scanner-user-agent",
+			Targets: []string{TargetUserAgent},
+			Pattern: `(?i)\b(sqlmap|nikto|nmap|masscan|zgrab|nuclei|acunetix|wpscan|dirbuster|gobuster|dirsearch|havij|w3af|netsparker|appscan|openvas|fimap|jaeles)\b`,
+		},
+	},
+}
+
+// builtinRules 内置规则组的规则 query_length使用规则集配置的阈值
+func builtinRules(group string, maxQueryLength int) ([]config.WAFRule, bool) {
+	if group == BuiltinQueryLength {
+		return []config.WAFRule{{
+			Name:      "query-length",
+			Targets:   []string{TargetQuery},
+			MaxLength: maxQueryLength,
+		}}, true
+	}
+	rules, ok := builtinGroups[group]
+	if !ok {
+		return nil, false
+	}
+	return append([]config.WAFRule(nil), rules...), true
+}
@@ -0,0 +1,121 @@
+package waf
+
+import (
+	"Hamburger/gateway/clientip"
+	"Hamburger/internal/config"
+	"Hamburger/internal/utils"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// challenge动作返回一个由浏览器脚本写入验证Cookie并刷新的页面
+// Cookie绑定客户端IP和过期时间 有效期内同一客户端不再触发challenge规则
+
+const (
+	DefaultCookieName   = "HAMBURGER_WAF"
+	DefaultChallengeTTL = 3600
+	signLength          = 32 // 签名截取的hex长度
+)
+
+var (
+	secret     []byte
+	secretOnce sync.Once
+)
+
+func getSecret() []byte {
+	secretOnce.Do(func() {
+		if s := config.Get().Middleware.WAF.Challenge.Secret; s != "" {
+			secret = []byte(s)
+			return
+		}
+		// 未配置密钥时随机生成 重启后旧Cookie失效需重新验证
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	})
+	return secret
+}
+
+// CookieName 验证Cookie名称
+func CookieName() string {
+	return utils.DefaultString(config.Get().Middleware.WAF.Challenge.CookieName, DefaultCookieName)
+}
+
+func challengeTTL() time.Duration {
+	if ttl := config.Get().Middleware.WAF.Challenge.TTL; ttl > 0 {
+		return time.Duration(ttl) * time.Second
+	}
+	return DefaultChallengeTTL * time.Second
+}
+
+// SignChallenge 生成绑定客户端IP的验证值 格式: expire.signature
+func SignChallenge(ip string, expire time.Time) string {
+	e := strconv.FormatInt(expire.Unix(), 10)
+	return e + "." + challengeSignature(ip, e)
+}
+
+// VerifyChallengeValue 校验验证值未过期且属于该客户端IP
+func VerifyChallengeValue(ip, value string) bool {
+	e, sign, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	expire, err := strconv.ParseInt(e, 10, 64)
+	if err != nil || time.Now().Unix() > expire {
+		return false
+	}
+	return hmac.Equal([]byte(sign), []byte(challengeSignature(ip, e)))
+}
+
+// VerifyChallenge 请求是否携带有效的验证Cookie
+func VerifyChallenge(req *http.Request) bool {
+	cookie, err := req.Cookie(CookieName())
+	if err != nil {
+		return false
+	}
+	return VerifyChallengeValue(clientip.String(req), cookie.Value)
+}
+
+func challengeSignature(ip, expire string) string {
+	mac := hmac.New(sha256.New, getSecret())
+	mac.Write([]byte(ip + "|" + expire))
+	return hex.EncodeToString(mac.Sum(nil))[:signLength]
+}
+
+var challengePage = template.Must(template.New("challenge").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Checking your browser</title>
+</head>
+<body>
+<noscript>Please enable JavaScript to continue.</noscript>
+<p>Checking your browser...</p>
+<script>
+document.cookie = {{.Name}} + "=" + {{.Value}} + "; path=/; max-age=" + {{.MaxAge}} + "; SameSite=Lax";
+window.location.reload();
+</script>
+</body>
+</html>
+`))
+
+// WriteChallenge 返回challenge页面
+func WriteChallenge(w http.ResponseWriter, r *http.Request) {
+	ttl := challengeTTL()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusForbidden)
+	_ = challengePage.Execute(w, map[string]any{
+		"Name":   CookieName(),
+		"Value":  SignChallenge(clientip.String(r), time.Now().Add(ttl)),
+		"MaxAge": int(ttl.Seconds()),
+	})
+}
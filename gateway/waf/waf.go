@@ -0,0 +1,345 @@
+// Package waf
+// Web应用防火墙 按规则检查请求的方法、路径、查询串、请求头和请求体前缀
+package waf
+
+import (
+	"Hamburger/internal/config"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 规则按顺序检查 自定义规则在内置规则之前
+// log动作只记录命中并继续检查 block和challenge动作命中后停止检查
+// challenge动作在请求携带有效的验证Cookie时跳过
+
+const (
+	ActionBlock     = "block"
+	ActionLog       = "log"
+	ActionChallenge = "challenge"
+
+	DefaultInspectBody    = 8192
+	DefaultMaxQueryLength = 2048
+)
+
+// 检查目标
+const (
+	TargetMethod    = "method"
+	TargetPath      = "path"
+	TargetQuery     = "query"
+	TargetHeaders   = "headers"
+	TargetUserAgent = "user_agent"
+	TargetBody      = "body"
+	targetHeader    = "header:"
+)
+
+// Engine 编译后的规则集合
+type Engine struct {
+	inspectBody int64
+	def         *RuleSet
+	domains     map[string]*RuleSet
+}
+
+// RuleSet 规则集
+type RuleSet struct {
+	rules []*Rule
+	body  bool // 是否有规则检查请求体
+}
+
+// Rule 单条规则
+type Rule struct {
+	Name      string
+	Action    string
+	targets   []target
+	pattern   *regexp.Regexp
+	maxLength int
+}
+
+type target struct {
+	kind   string
+	header string
+}
+
+// Hit 命中的规则
+type Hit struct {
+	Rule   string
+	Action string
+	Target string
+}
+
+// New 编译WAF配置 规则错误时返回错误
+func New(conf config.WAFConfig) (*Engine, error) {
+	e := &Engine{inspectBody: conf.InspectBody, domains: make(map[string]*RuleSet)}
+	if e.inspectBody == 0 {
+		e.inspectBody = DefaultInspectBody
+	}
+	var err error
+	if e.def, err = NewRuleSet(conf.Default); err != nil {
+		return nil, fmt.Errorf("waf default: %w", err)
+	}
+	for domain, set := range conf.Domains {
+		rs, err := NewRuleSet(set)
+		if err != nil {
+			return nil, fmt.Errorf("waf domain %s: %w", domain, err)
+		}
+		e.domains[strings.ToLower(domain)] = rs
+	}
+	return e, nil
+}
+
+// NewRuleSet 编译规则集
+func NewRuleSet(conf config.WAFRuleSet) (*RuleSet, error) {
+	rs := &RuleSet{}
+	for _, rc := range conf.Rules {
+		rule, err := NewRule(rc)
+		if err != nil {
+			return nil, err
+		}
+		rs.add(rule)
+	}
+	builtinAction := conf.BuiltinAction
+	if builtinAction == "" {
+		builtinAction = ActionBlock
+	}
+	maxQuery := conf.MaxQueryLength
+	if maxQuery <= 0 {
+		maxQuery = DefaultMaxQueryLength
+	}
+	for _, group := range conf.Builtin {
+		rules, ok := builtinRules(group, maxQuery)
+		if !ok {
+			return nil, fmt.Errorf("unknown builtin rule group: %s", group)
+		}
+		for _, rc := range rules {
+			rc.Action = builtinAction
+			rule, err := NewRule(rc)
+			if err != nil {
+				return nil, err
+			}
+			rs.add(rule)
+		}
+	}
+	return rs, nil
+}
+
+func (rs *RuleSet) add(rule *Rule) {
+	rs.rules = append(rs.rules, rule)
+	for _, t := range rule.targets {
+		if t.kind == TargetBody {
+			rs.body = true
+		}
+	}
+}
+
+// NewRule 编译单条规则
+func NewRule(conf config.WAFRule) (*Rule, error) {
+	if conf.Name == "" {
+		return nil, errors.New("rule name is empty")
+	}
+	rule := &Rule{Name: conf.Name, Action: conf.Action, maxLength: conf.MaxLength}
+	switch rule.Action {
+	case "":
+		rule.Action = ActionBlock
+	case ActionBlock, ActionLog, ActionChallenge:
+	default:
+		return nil, fmt.Errorf("rule %s: unknown action %s", conf.Name, conf.Action)
+	}
+	if conf.Pattern == "" && conf.MaxLength <= 0 {
+		return nil, fmt.Errorf("rule %s: pattern or max_length is required", conf.Name)
+	}
+	if conf.Pattern != "" {
+		pattern, err := regexp.Compile(conf.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", conf.Name, err)
+		}
+		rule.pattern = pattern
+	}
+	if len(conf.Targets) == 0 {
+		return nil, fmt.Errorf("rule %s: targets is empty", conf.Name)
+	}
+	for _, t := range conf.Targets {
+		switch {
+		case t == TargetMethod, t == TargetPath, t == TargetQuery, t == TargetHeaders,
+			t == TargetUserAgent, t == TargetBody:
+			rule.targets = append(rule.targets, target{kind: t})
+		case strings.HasPrefix(t, targetHeader) && len(t) > len(targetHeader):
+			rule.targets = append(rule.targets, target{kind: targetHeader, header: t[len(targetHeader):]})
+		default:
+			return nil, fmt.Errorf("rule %s: unknown target %s", conf.Name, t)
+		}
+	}
+	return rule, nil
+}
+
+// Inspect 检查请求 返回命中的规则 最后一个命中的动作不是log时需要拦截请求
+func (e *Engine) Inspect(req *http.Request) []Hit {
+	rs := e.ruleSet(req.Host)
+	if rs == nil || len(rs.rules) == 0 {
+		return nil
+	}
+	in := &inspection{req: req}
+	// gRPC请求体为二进制消息并且可能是长时间的流 读取前缀会阻塞转发
+	if rs.body && e.inspectBody > 0 && !strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc") {
+		in.body = readBodyPrefix(req, e.inspectBody)
+	}
+
+	var hits []Hit
+	for _, rule := range rs.rules {
+		name, ok := rule.match(in)
+		if !ok {
+			continue
+		}
+		if rule.Action == ActionChallenge && VerifyChallenge(req) {
+			continue
+		}
+		hits = append(hits, Hit{Rule: rule.Name, Action: rule.Action, Target: name})
+		if rule.Action != ActionLog {
+			break
+		}
+	}
+	return hits
+}
+
+func (e *Engine) ruleSet(host string) *RuleSet {
+	if len(e.domains) > 0 {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if rs, ok := e.domains[strings.ToLower(host)]; ok {
+			return rs
+		}
+	}
+	return e.def
+}
+
+// inspection 单次检查中缓存的目标值
+type inspection struct {
+	req   *http.Request
+	body  []byte
+	query []string
+}
+
+func (r *Rule) match(in *inspection) (string, bool) {
+	for _, t := range r.targets {
+		for _, value := range in.values(t) {
+			if r.maxLength > 0 && len(value) > r.maxLength {
+				return t.name(), true
+			}
+			if r.pattern != nil && r.pattern.MatchString(value) {
+				return t.name(), true
+			}
+		}
+	}
+	return "", false
+}
+
+func (t target) name() string {
+	if t.kind == targetHeader {
+		return t.kind + t.header
+	}
+	return t.kind
+}
+
+func (in *inspection) values(t target) []string {
+	req := in.req
+	switch t.kind {
+	case TargetMethod:
+		return []string{req.Method}
+	case TargetPath:
+		if raw := req.URL.EscapedPath(); raw != req.URL.Path {
+			return []string{req.URL.Path, raw}
+		}
+		return []string{req.URL.Path}
+	case TargetQuery:
+		if in.query == nil {
+			in.query = formValues(req.URL.RawQuery)
+		}
+		return in.query
+	case TargetHeaders:
+		var values []string
+		for _, v := range req.Header {
+			values = append(values, v...)
+		}
+		return values
+	case targetHeader:
+		return req.Header.Values(t.header)
+	case TargetUserAgent:
+		return []string{req.UserAgent()}
+	case TargetBody:
+		if len(in.body) == 0 {
+			return nil
+		}
+		body := string(in.body)
+		if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			return formValues(body)
+		}
+		return []string{body}
+	}
+	return nil
+}
+
+// formValues 原始字符串和逐个解码键值后的字符串
+// 键值分别解码 无效的转义保留原样 避免一个无效转义使整段都不被解码
+func formValues(raw string) []string {
+	pairs := strings.Split(raw, "&")
+	for i, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		pairs[i] = unescape(key)
+		if ok {
+			pairs[i] += "=" + unescape(value)
+		}
+	}
+	if decoded := strings.Join(pairs, "&"); decoded != raw {
+		return []string{raw, decoded}
+	}
+	return []string{raw}
+}
+
+// unescape 按表单编码解码 跳过无效的转义
+func unescape(s string) string {
+	if decoded, err := url.QueryUnescape(s); err == nil {
+		return decoded
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '+':
+			b.WriteByte(' ')
+		case c == '%' && i+2 < len(s):
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// readBodyPrefix 读取请求体前缀 读取的部分拼接回请求体 不影响转发
+func readBodyPrefix(req *http.Request, limit int64) []byte {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	prefix, err := io.ReadAll(io.LimitReader(req.Body, limit))
+	req.Body = &prefixBody{Reader: io.MultiReader(bytes.NewReader(prefix), req.Body), Closer: req.Body}
+	if err != nil {
+		return nil
+	}
+	return prefix
+}
+
+type prefixBody struct {
+	io.Reader
+	io.Closer
+}
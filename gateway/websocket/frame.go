@@ -0,0 +1,143 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// 帧头格式 RFC 6455 5.2
+// 转发时只解析帧头 负载按原样复制 不做解码和重新编码
+
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+)
+
+// 关闭码
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseMessageTooBig = 1009
+)
+
+const (
+	maxControlPayload = 125
+	maxFrameHeader    = 14
+)
+
+var errProtocol = errors.New("websocket: protocol error")
+
+// frameHeader 帧头
+type frameHeader struct {
+	fin    bool
+	opcode byte
+	masked bool
+	mask   [4]byte
+	length int64
+	raw    []byte // 原始帧头字节 转发时原样写出
+}
+
+func (h *frameHeader) control() bool {
+	return h.opcode >= OpClose
+}
+
+// readFrameHeader 读取帧头 buf长度至少为maxFrameHeader
+func readFrameHeader(r io.Reader, buf []byte) (frameHeader, error) {
+	var h frameHeader
+	if _, err := io.ReadFull(r, buf[:2]); err != nil {
+		return h, err
+	}
+	h.fin = buf[0]&0x80 != 0
+	h.opcode = buf[0] & 0x0F
+	h.masked = buf[1]&0x80 != 0
+	// RSV位由两端协商的扩展使用(如permessage-deflate) 原样转发不做检查
+
+	n := 2
+	switch length := buf[1] & 0x7F; length {
+	case 126:
+		if _, err := io.ReadFull(r, buf[n:n+2]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint16(buf[n:]))
+		n += 2
+	case 127:
+		if _, err := io.ReadFull(r, buf[n:n+8]); err != nil {
+			return h, err
+		}
+		v := binary.BigEndian.Uint64(buf[n:])
+		if v>>63 != 0 {
+			return h, errProtocol
+		}
+		h.length = int64(v)
+		n += 8
+	default:
+		h.length = int64(length)
+	}
+	if h.masked {
+		if _, err := io.ReadFull(r, buf[n:n+4]); err != nil {
+			return h, err
+		}
+		copy(h.mask[:], buf[n:n+4])
+		n += 4
+	}
+	if h.control() && (h.length > maxControlPayload || !h.fin) {
+		return h, errProtocol
+	}
+	if (h.opcode > OpBinary && h.opcode < OpClose) || h.opcode > OpPong {
+		return h, errProtocol
+	}
+	h.raw = buf[:n]
+	return h, nil
+}
+
+// maskBytes 按掩码异或 掩码和解码是同一操作
+func maskBytes(mask [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+}
+
+// appendFrame 编码一个完整的帧 发往服务端的帧需要掩码
+func appendFrame(dst []byte, opcode byte, payload []byte, masked bool) []byte {
+	b1 := byte(0)
+	if masked {
+		b1 = 0x80
+	}
+	dst = append(dst, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		dst = append(dst, b1|byte(n))
+	case n <= 0xFFFF:
+		dst = append(dst, b1|126)
+		dst = binary.BigEndian.AppendUint16(dst, uint16(n))
+	default:
+		dst = append(dst, b1|127)
+		dst = binary.BigEndian.AppendUint64(dst, uint64(n))
+	}
+	if !masked {
+		return append(dst, payload...)
+	}
+	var mask [4]byte
+	_, _ = rand.Read(mask[:])
+	dst = append(dst, mask[:]...)
+	start := len(dst)
+	dst = append(dst, payload...)
+	maskBytes(mask, dst[start:])
+	return dst
+}
+
+// closePayload 关闭帧负载: 2字节关闭码和原因
+func closePayload(code int, reason string) []byte {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+	return append(payload, reason...)
+}
@@ -0,0 +1,229 @@
+package websocket
+
+import (
+	"Hamburger/gateway/stat"
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// peer 转发的一端
+type peer struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	w      *bufio.Writer
+	mu     sync.Mutex // 转发帧和网关生成的控制帧可能并发写出
+	server bool       // 对端是否为服务端 发往服务端的帧需要掩码
+}
+
+// writeFrame 写出网关生成的控制帧
+func (p *peer) writeFrame(opcode byte, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_ = p.conn.SetWriteDeadline(time.Now().Add(HandshakeTimeout))
+	if _, err := p.w.Write(appendFrame(nil, opcode, payload, p.server)); err != nil {
+		return err
+	}
+	return p.w.Flush()
+}
+
+// relay 一个WebSocket连接的双向转发
+type relay struct {
+	opts     Options
+	client   *peer
+	upstream *peer
+	counter  *stat.WebSocketCounter
+	ping     []byte       // 网关心跳的负载 用于识别客户端对网关心跳的响应
+	lastPing atomic.Int64 // 最近一次发送心跳的时间
+	lastPong atomic.Int64 // 最近一次收到网关心跳响应的时间
+	active   atomic.Int64 // 最近一次转发数据帧的时间
+	once     sync.Once
+	done     chan struct{}
+}
+
+// Relay 在客户端和上游之间转发帧 任意一端关闭或超出限制后关闭两端连接
+// client为劫持的客户端连接 clientReader为劫持时返回的缓冲读取器
+func Relay(client net.Conn, clientReader *bufio.Reader, upstream *Upstream, opts Options, counter *stat.WebSocketCounter) {
+	// 劫持的连接可能保留了服务器设置的读写超时
+	_ = client.SetDeadline(time.Time{})
+	_ = upstream.Conn.SetDeadline(time.Time{})
+
+	token := make([]byte, 8)
+	_, _ = rand.Read(token)
+	r := &relay{
+		opts:     opts,
+		client:   &peer{conn: client, r: clientReader, w: bufio.NewWriterSize(client, opts.BufferSize)},
+		upstream: &peer{conn: upstream.Conn, r: upstream.Reader, w: bufio.NewWriterSize(upstream.Conn, opts.BufferSize), server: true},
+		counter:  counter,
+		ping:     []byte("hamburger-" + hex.EncodeToString(token)),
+		done:     make(chan struct{}),
+	}
+	now := time.Now().UnixNano()
+	r.active.Store(now)
+	r.lastPong.Store(now)
+
+	counter.Open()
+	defer counter.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		r.transfer(r.client, r.upstream, true)
+	}()
+	go func() {
+		defer wg.Done()
+		r.transfer(r.upstream, r.client, false)
+	}()
+	if opts.PingInterval > 0 || opts.IdleTimeout > 0 {
+		go r.watch()
+	}
+	wg.Wait()
+}
+
+// transfer 从src读取帧转发到dst in表示客户端发往上游
+func (r *relay) transfer(src, dst *peer, in bool) {
+	defer r.close()
+
+	buf := make([]byte, maxFrameHeader)
+	var message int64
+	for {
+		if in && r.opts.PingInterval > 0 {
+			// 客户端需要在心跳间隔加响应超时内有数据 否则视为断开
+			_ = src.conn.SetReadDeadline(time.Now().Add(r.opts.PingInterval + r.opts.PongTimeout))
+		}
+		h, err := readFrameHeader(src.r, buf)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				r.fail(CloseProtocolError, "protocol error")
+			}
+			return
+		}
+		// 客户端发出的帧必须掩码 服务端发出的帧不能掩码
+		if h.masked != in {
+			r.fail(CloseProtocolError, "invalid frame mask")
+			return
+		}
+
+		if h.control() {
+			payload := make([]byte, h.length)
+			if _, err = io.ReadFull(src.r, payload); err != nil {
+				return
+			}
+			if in && h.opcode == OpPong && r.ownPong(h, payload) {
+				r.lastPong.Store(time.Now().UnixNano())
+				continue
+			}
+			if err = r.forward(dst, h, bytes.NewReader(payload)); err != nil {
+				return
+			}
+			r.counter.Frame(in, h.length)
+			continue
+		}
+
+		if h.opcode == OpContinuation {
+			message += h.length
+		} else {
+			message = h.length
+		}
+		if r.opts.MaxMessageSize > 0 && message > r.opts.MaxMessageSize {
+			r.fail(CloseMessageTooBig, "message too big")
+			return
+		}
+		if err = r.forward(dst, h, src.r); err != nil {
+			return
+		}
+		r.active.Store(time.Now().UnixNano())
+		r.counter.Frame(in, h.length)
+	}
+}
+
+// forward 写出帧头并复制负载
+func (r *relay) forward(dst *peer, h frameHeader, payload io.Reader) error {
+	dst.mu.Lock()
+	defer dst.mu.Unlock()
+	if _, err := dst.w.Write(h.raw); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(dst.w, payload, h.length); err != nil {
+		return err
+	}
+	return dst.w.Flush()
+}
+
+// ownPong 是否为客户端对网关心跳的响应 这类响应不转发给上游
+func (r *relay) ownPong(h frameHeader, payload []byte) bool {
+	if len(payload) != len(r.ping) {
+		return false
+	}
+	data := bytes.Clone(payload)
+	maskBytes(h.mask, data)
+	return bytes.Equal(data, r.ping)
+}
+
+// watch 发送心跳并检查心跳响应和空闲超时
+func (r *relay) watch() {
+	interval := r.opts.PingInterval
+	if interval <= 0 || (r.opts.IdleTimeout > 0 && r.opts.IdleTimeout < interval) {
+		interval = max(r.opts.IdleTimeout/4, time.Second)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		if r.opts.IdleTimeout > 0 && now.Sub(time.Unix(0, r.active.Load())) > r.opts.IdleTimeout {
+			r.fail(CloseGoingAway, "idle timeout")
+			return
+		}
+		if r.opts.PingInterval <= 0 {
+			continue
+		}
+		lastPing := r.lastPing.Load()
+		if lastPing > r.lastPong.Load() && now.Sub(time.Unix(0, lastPing)) > r.opts.PongTimeout {
+			r.fail(CloseGoingAway, "ping timeout")
+			return
+		}
+		if lastPing == 0 || lastPing <= r.lastPong.Load() && now.Sub(time.Unix(0, lastPing)) >= r.opts.PingInterval {
+			r.lastPing.Store(now.UnixNano())
+			if err := r.client.writeFrame(OpPing, r.ping); err != nil {
+				r.close()
+				return
+			}
+		}
+	}
+}
+
+// fail 网关主动关闭连接 向两端发送关闭帧
+func (r *relay) fail(code int, reason string) {
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+	r.counter.Reject()
+	payload := closePayload(code, reason)
+	_ = r.client.writeFrame(OpClose, payload)
+	_ = r.upstream.writeFrame(OpClose, payload)
+	r.close()
+}
+
+// close 关闭两端连接 阻塞中的读取随之返回
+func (r *relay) close() {
+	r.once.Do(func() {
+		close(r.done)
+		_ = r.client.conn.Close()
+		_ = r.upstream.conn.Close()
+	})
+}
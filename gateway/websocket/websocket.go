@@ -0,0 +1,148 @@
+// Package websocket
+// WebSocket代理 完成与上游的握手后在客户端和上游之间按帧转发
+// 转发时检查消息大小 由网关向客户端发送心跳并在空闲或心跳超时时关闭连接
+package websocket
+
+import (
+	"Hamburger/internal/config"
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPongTimeout = 10
+	DefaultBufferSize  = 4096
+	// HandshakeTimeout 连接上游并完成握手的超时
+	HandshakeTimeout = 10 * time.Second
+)
+
+// Options 连接限制
+type Options struct {
+	MaxMessageSize int64         // 最大消息大小 0不限制
+	PingInterval   time.Duration // 向客户端发送心跳的间隔 0不发送
+	PongTimeout    time.Duration // 心跳响应超时
+	IdleTimeout    time.Duration // 双向都没有数据帧时关闭连接 0不限制
+	BufferSize     int
+}
+
+// OptionsFrom 从配置创建连接限制
+func OptionsFrom(conf config.WebSocketConfig) Options {
+	opts := Options{
+		MaxMessageSize: conf.MaxMessageSize,
+		PingInterval:   time.Duration(conf.PingInterval) * time.Second,
+		PongTimeout:    time.Duration(conf.PongTimeout) * time.Second,
+		IdleTimeout:    time.Duration(conf.IdleTimeout) * time.Second,
+		BufferSize:     conf.BufferSize,
+	}
+	if opts.PongTimeout <= 0 {
+		opts.PongTimeout = DefaultPongTimeout * time.Second
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	return opts
+}
+
+// IsUpgrade 是否为WebSocket升级请求
+func IsUpgrade(r *http.Request) bool {
+	return r.ProtoMajor == 1 && headerContains(r.Header, "Connection", "upgrade") &&
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Upstream 已完成握手的上游连接
+type Upstream struct {
+	Conn   net.Conn
+	Reader *bufio.Reader // 握手响应之后可能已缓冲了上游发送的帧
+}
+
+// Dial 连接上游并发送升级请求 返回上游的握手响应
+// 响应状态码不是101时上游连接已关闭 响应体已读入内存可以直接返回给客户端
+// tlsConfig为空时使用系统CA校验上游证书 未设置ServerName时使用上游主机名
+func Dial(req *http.Request, bufferSize int, tlsConfig *tls.Config) (*Upstream, *http.Response, error) {
+	addr := req.URL.Host
+	secure := req.URL.Scheme == "https" || req.URL.Scheme == "wss"
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		if secure {
+			addr = net.JoinHostPort(addr, "443")
+		} else {
+			addr = net.JoinHostPort(addr, "80")
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: HandshakeTimeout}
+	var conn net.Conn
+	var err error
+	if secure {
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = req.URL.Hostname()
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.DialContext(req.Context(), "tcp", addr)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	w := bufio.NewWriterSize(conn, bufferSize)
+	if err = req.Write(w); err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	reader := bufio.NewReaderSize(conn, bufferSize)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer conn.Close()
+		return nil, resp, bufferBody(resp)
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+		_ = conn.Close()
+		return nil, nil, errors.New("websocket: upstream switched to unexpected protocol")
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return &Upstream{Conn: conn, Reader: reader}, resp, nil
+}
+
+// bufferBody 非升级响应的响应体读入内存 上限1MB
+func bufferBody(resp *http.Response) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	_ = resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("websocket: read upstream response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	resp.Header.Del("Transfer-Encoding")
+	return nil
+}
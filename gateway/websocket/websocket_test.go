@@ -0,0 +1,133 @@
+package websocket
+
+import (
+	"Hamburger/gateway/stat"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// echoServer 回显数据帧的上游
+func echoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsUpgrade(r) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		_ = brw.Flush()
+		buf := make([]byte, maxFrameHeader)
+		for {
+			h, err := readFrameHeader(brw, buf)
+			if err != nil || h.opcode == OpClose {
+				return
+			}
+			payload := make([]byte, h.length)
+			if _, err = io.ReadFull(brw, payload); err != nil {
+				return
+			}
+			maskBytes(h.mask, payload)
+			if _, err = conn.Write(appendFrame(nil, h.opcode, payload, false)); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func dialRelay(t *testing.T, opts Options, counter *stat.WebSocketCounter) (net.Conn, chan struct{}) {
+	srv := echoServer(t)
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+	req.Host = u.Host
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	upstream, resp, err := Dial(req, DefaultBufferSize, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("dial: %v %v", resp, err)
+	}
+	client, gateway := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		Relay(gateway, bufio.NewReader(gateway), upstream, opts, counter)
+		close(done)
+	}()
+	t.Cleanup(func() { _ = client.Close() })
+	return client, done
+}
+
+func readFrame(t *testing.T, r io.Reader) (frameHeader, []byte) {
+	h, err := readFrameHeader(r, make([]byte, maxFrameHeader))
+	if err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	payload := make([]byte, h.length)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	return h, payload
+}
+
+func TestRelayEcho(t *testing.T) {
+	counter := &stat.WebSocketCounter{}
+	client, done := dialRelay(t, Options{BufferSize: DefaultBufferSize, PongTimeout: time.Second}, counter)
+	_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	go client.Write(appendFrame(nil, OpText, []byte("hello"), true))
+	h, payload := readFrame(t, client)
+	if h.opcode != OpText || h.masked || string(payload) != "hello" {
+		t.Fatalf("unexpected echo: opcode=%d masked=%v payload=%q", h.opcode, h.masked, payload)
+	}
+
+	_ = client.Close()
+	<-done
+	if counter.Snapshot().FramesIn != 1 || counter.Snapshot().FramesOut != 1 || counter.Snapshot().Active != 0 {
+		t.Fatalf("unexpected stat: %+v", counter.Snapshot())
+	}
+}
+
+func TestRelayMessageTooBig(t *testing.T) {
+	counter := &stat.WebSocketCounter{}
+	client, done := dialRelay(t, Options{MaxMessageSize: 16, BufferSize: DefaultBufferSize, PongTimeout: time.Second}, counter)
+	_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// 分片消息按累计大小计算
+	frame := appendFrame(nil, OpText, bytes.Repeat([]byte("a"), 10), true)
+	frame[0] &^= 0x80
+	frame = append(frame, appendFrame(nil, OpContinuation, bytes.Repeat([]byte("b"), 10), true)...)
+	go client.Write(frame)
+
+	h, payload := readFrame(t, client)
+	if h.opcode != OpClose || binary.BigEndian.Uint16(payload) != CloseMessageTooBig {
+		t.Fatalf("expected close 1009, got opcode=%d payload=%q", h.opcode, payload)
+	}
+	<-done
+	if counter.Snapshot().Rejected != 1 {
+		t.Fatalf("unexpected stat: %+v", counter.Snapshot())
+	}
+}
+
+func TestReadFrameHeader(t *testing.T) {
+	// 控制帧不能分片
+	if _, err := readFrameHeader(bytes.NewReader([]byte{OpPing, 0}), make([]byte, maxFrameHeader)); err == nil {
+		t.Fatal("expected error for fragmented control frame")
+	}
+	frame := appendFrame(nil, OpBinary, make([]byte, 70000), true)
+	h, err := readFrameHeader(bytes.NewReader(frame), make([]byte, maxFrameHeader))
+	if err != nil || h.length != 70000 || !h.masked || len(h.raw) != 14 {
+		t.Fatalf("unexpected header: %+v %v", h, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"Hamburger/frontend_proxy"
 	"Hamburger/gateway/core"
 	"Hamburger/gateway/manager"
+	"Hamburger/gateway/metrics"
 	"Hamburger/gateway/modifier"
 	"Hamburger/gateway/stat"
 	"Hamburger/internal/config"
@@ -27,6 +28,7 @@ type Initializer struct {
 	GrpcProxy       *grpc_proxy.GrpcProxy
 	ModifierManager *modifier.ModifierManager
 	StatServer      *stat.StatServer
+	MetricsServer   *metrics.MetricsServer
 }
 
 type Runner struct {
@@ -48,10 +50,12 @@ func Initialize(appConf *config.AppConfig, cfg *config.Config) (*Initializer, er
 	i.logger = logger.GetLogger()
 
 	i.Register(i.InitLogger())
+	i.Register(i.InitAccessLog())
 	i.Register(i.InitMongo())
 	i.Register(i.InitRuntime())
 	i.Register(i.InitFrontServer())
 	i.Register(i.InitGateway())
+	i.Register(i.InitHealthCheck())
 	i.Register(i.InitGatewayManager())
 	i.Register(i.InitBackendServer())
 	i.Register(i.InitGrpcProxy())
@@ -59,6 +63,7 @@ func Initialize(appConf *config.AppConfig, cfg *config.Config) (*Initializer, er
 	i.Register(i.InitPreHandlerManager())
 	i.Register(i.InitStatManager())
 	i.Register(i.InitStatServer())
+	i.Register(i.InitMetricsServer())
 	i.Register(i.InitPProf())
 
 	// 按优先级排序
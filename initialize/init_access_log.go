@@ -0,0 +1,15 @@
+package initialize
+
+import "Hamburger/gateway/accesslog"
+
+func (i *Initializer) InitAccessLog() Runner {
+	return Runner{
+		Priority: PriorityNormal,
+		fn: func() error {
+			if err := accesslog.Init(i.cfg.Log.Access); err != nil {
+				i.logger.Error().Err(err).Msg("init access log failed")
+				return err
+			}
+			return nil
+		}}
+}
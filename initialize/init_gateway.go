@@ -3,12 +3,18 @@ package initialize
 import (
 	"Hamburger/gateway/breaker"
 	"Hamburger/gateway/core"
+	"Hamburger/gateway/upstreamtls"
 )
 
 func (i *Initializer) InitGateway() Runner {
 	return Runner{
 		Priority: PriorityNormal,
 		fn: func() error {
+			// 自定义服务https上游的TLS配置
+			if err := upstreamtls.Init(i.cfg.PxyCustomService); err != nil {
+				i.logger.Error().Err(err).Msg("init upstream tls failed")
+				return err
+			}
 			gw := core.NewProxy(i.cfg, i.logger)
 			i.Gateway = gw
 			breaker.InitBreaker()
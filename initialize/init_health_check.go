@@ -0,0 +1,16 @@
+package initialize
+
+import "Hamburger/gateway/health"
+
+// 初始化后端健康检查
+
+func (i *Initializer) InitHealthCheck() Runner {
+	return Runner{
+		Priority: PriorityLow,
+		fn: func() error {
+			health.InitHealthChecker(&i.cfg.Features.HealthCheck, i.logger)
+			i.logger.Info().Msg("health checker initialized")
+			return nil
+		},
+	}
+}
@@ -8,7 +8,10 @@ func (i *Initializer) InitLogger() Runner {
 	return Runner{
 		Priority: PriorityHigh,
 		fn: func() error {
-			logger.ReloadLogger(&i.cfg.Log)
+			if err := logger.ReloadLogger(&i.cfg.Log); err != nil {
+				i.logger.Error().Err(err).Msg("init app logger failed")
+				return err
+			}
 			i.logger = logger.GetLogger()
 			i.logger.Info().Msg("init app logger success")
 			return nil
@@ -0,0 +1,12 @@
+package initialize
+
+import "Hamburger/gateway/metrics"
+
+func (i *Initializer) InitMetricsServer() Runner {
+	return Runner{
+		Priority: PriorityLow,
+		fn: func() error {
+			i.MetricsServer = metrics.NewMetricsServer(i.cfg.Features.Monitor, i.logger)
+			return nil
+		}}
+}
@@ -1,6 +1,9 @@
 package initialize
 
 import (
+	"Hamburger/gateway/clientauth"
+	"Hamburger/gateway/clientip"
+	flow "Hamburger/gateway/flow_control"
 	"Hamburger/gateway/prehandler"
 )
 
@@ -8,7 +11,22 @@ func (i *Initializer) InitPreHandlerManager() Runner {
 	return Runner{
 		Priority: PriorityLow,
 		fn: func() error {
-			prehandler.InitPreHandlerManager()
+			// 客户端IP解析在前置处理器和流控中使用
+			if err := clientip.Init(i.cfg.Security); err != nil {
+				i.logger.Error().Err(err).Msg("init trusted proxies failed")
+				return err
+			}
+			// 客户端证书认证在TLS握手和前置处理器中使用
+			if err := clientauth.Init(i.cfg); err != nil {
+				i.logger.Error().Err(err).Msg("init client auth failed")
+				return err
+			}
+			// 流量记录器保存限流和WAF命中的请求
+			flow.InitFlowRecorder()
+			if err := prehandler.InitPreHandlerManager(); err != nil {
+				i.logger.Error().Err(err).Msg("init prehandlers failed")
+				return err
+			}
 			return nil
 		},
 	}
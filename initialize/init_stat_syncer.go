@@ -15,8 +15,8 @@ func (i *Initializer) InitStatManager() Runner {
 			if err := db.Init(i.cfg); err != nil {
 				return err
 			}
-			// load GEO
-			geo.LoadGEO()
+			// load GEO 配置了外部mmdb文件时监听文件变化
+			geo.Init(i.cfg.Stat.GeoDB)
 			// init syncer
 			stat.InitStatSyncer()
 			return nil
@@ -16,6 +16,7 @@ type AppConfig struct {
 	Log          LogConfig         `yaml:"log" json:"log"`                     // 日志配置
 	Module       []ModuleConfig    `yaml:"module" json:"module"`               // 模块
 	Stat         StatConfig        `yaml:"stat" json:"stat"`                   // 状态统计配置
+	Admin        AdminConfig       `yaml:"admin" json:"admin"`                 // 管理接口配置
 	CustomHeader map[string]string `yaml:"custom_header" json:"custom_header"` // 自定义Header
 	Syncer       Syncer            `yaml:"syncer" json:"syncer"`               // 定时器时间
 	Debug        bool              `yaml:"debug" json:"debug"`                 // 调试模式
@@ -23,6 +24,7 @@ type AppConfig struct {
 	MaxCores     int               `yaml:"max_cores" json:"max_cores"`
 
 	// 第二优先级
-	PxyBackend  PxyBackendConfig `yaml:"pxy_backend" json:"pxy_backend"`
-	PxyFrontend PxyFrontConfig   `yaml:"pxy_frontend" json:"pxy_frontend"`
+	PxyBackend       PxyBackendConfig       `yaml:"pxy_backend" json:"pxy_backend"`
+	PxyFrontend      PxyFrontConfig         `yaml:"pxy_frontend" json:"pxy_frontend"`
+	PxyCustomService PxyCustomServiceConfig `yaml:"pxy_custom_service" json:"pxy_custom_service"` // 定义的转发服务
 }
@@ -8,11 +8,32 @@ type PxyCustomServiceConfig struct {
 // CustomServiceConfig 自定义的域名服务映射关系
 // 仅作为后端使用
 type CustomServiceConfig struct {
-	Domain   string     `yaml:"domain" json:"domain"`
-	Upstream []Upstream `yaml:"upstream" json:"upstream"` // 负载均衡
+	Domain    string        `yaml:"domain" json:"domain"`
+	Upstream  []Upstream    `yaml:"upstream" json:"upstream"`     // 负载均衡
+	Balance   BalanceConfig `yaml:"balance" json:"balance"`       // 负载均衡策略
+	GeoRoutes []GeoRoute    `yaml:"geo_routes" json:"geo_routes"` // 按客户端国家选择上游 未匹配时使用Upstream
+}
+
+// GeoRoute 指定国家的请求转发到单独的上游组
+type GeoRoute struct {
+	Countries []string   `yaml:"countries" json:"countries"` // ISO 3166国家代码
+	Upstream  []Upstream `yaml:"upstream" json:"upstream"`
 }
 
 type Upstream struct {
-	Host string `yaml:"host" json:"host"`
-	Port int    `yaml:"port" json:"port"`
+	Host   string       `yaml:"host" json:"host"`
+	Port   int          `yaml:"port" json:"port"`
+	Weight int          `yaml:"weight" json:"weight"`               // 权重 仅weighted策略生效 默认1
+	Scheme string       `yaml:"scheme" json:"scheme"`               // 上游协议: http(默认), https 仅自定义服务生效
+	TLS    *UpstreamTLS `yaml:"tls,omitempty" json:"tls,omitempty"` // https上游的TLS配置 为空时使用系统CA校验上游证书
+}
+
+// UpstreamTLS 连接https上游时的TLS配置
+// 配置相同的上游共用连接池和TLS会话缓存
+type UpstreamTLS struct {
+	ServerName         string `yaml:"server_name" json:"server_name"`                   // SNI和证书校验使用的域名 默认使用上游host
+	CAFile             string `yaml:"ca_file" json:"ca_file"`                           // 校验上游证书的CA文件 默认使用系统CA
+	CertFile           string `yaml:"cert_file" json:"cert_file"`                       // 客户端证书 上游要求mTLS时使用
+	KeyFile            string `yaml:"key_file" json:"key_file"`                         // 客户端证书私钥
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" json:"insecure_skip_verify"` // 跳过上游证书校验 仅用于测试
 }
@@ -17,10 +17,15 @@ type FrontCacheConfig struct {
 
 // BackendConfig 后端配置
 type BackendConfig struct {
-	API        string `json:"api" toml:"api"`
-	Service    string `json:"service" toml:"service"`
-	UseRewrite bool   `json:"use_rewrite" toml:"use_rewrite"`
-	Rewrite    string `json:"rewrite" toml:"rewrite"`
+	API        string            `json:"api" toml:"api"`
+	Service    string            `json:"service" toml:"service"`
+	UseRewrite bool              `json:"use_rewrite" toml:"use_rewrite"`
+	Rewrite    string            `json:"rewrite" toml:"rewrite"`
+	Match      string            `json:"match" toml:"match"`       // 匹配方式: prefix(默认) | exact | regex | template
+	Methods    []string          `json:"methods" toml:"methods"`   // 限定请求方法 为空时不限制
+	Headers    map[string]string `json:"headers" toml:"headers"`   // 请求头匹配 空值只要求存在 ~开头为正则
+	Query      map[string]string `json:"query" toml:"query"`       // 查询参数匹配 规则同headers
+	Priority   int               `json:"priority" toml:"priority"` // 优先级 越大越优先
 }
 
 // FrontServerConfig 服务器配置
@@ -8,12 +8,23 @@ import (
 )
 
 type ProxyConfig struct {
-	FlushInterval   int64  `yaml:"flush_interval" json:"flush_interval"`
-	BufSize         int    `yaml:"buf_size" json:"buf_size"`
-	Transport       string `yaml:"transport" json:"transport"`                   // 传统 | fast
-	ProxyMode       string `yaml:"proxy_mode" json:"proxy_mode"`                 // 代理模式: http | fasthttp
-	MaxConnsPerHost int    `yaml:"max_conns_per_host" json:"max_conns_per_host"` // 每个主机最大连接数
-	IdleConnTimeout int    `yaml:"idle_conn_timeout" json:"idle_conn_timeout"`   // 空闲连接超时
+	FlushInterval   int64       `yaml:"flush_interval" json:"flush_interval"`
+	BufSize         int         `yaml:"buf_size" json:"buf_size"`
+	Transport       string      `yaml:"transport" json:"transport"`                   // 传统 | fast
+	ProxyMode       string      `yaml:"proxy_mode" json:"proxy_mode"`                 // 代理模式: http | fasthttp
+	MaxConnsPerHost int         `yaml:"max_conns_per_host" json:"max_conns_per_host"` // 每个主机最大连接数
+	IdleConnTimeout int         `yaml:"idle_conn_timeout" json:"idle_conn_timeout"`   // 空闲连接超时
+	Retry           RetryConfig `yaml:"retry" json:"retry"`                           // 上游失败重试
+}
+
+// RetryConfig 上游请求失败重试配置
+// 幂等请求在连接失败或返回指定状态码时重试 非幂等请求仅在连接未建立时重试
+type RetryConfig struct {
+	Enabled       bool  `yaml:"enabled" json:"enabled"`                 // 是否启用重试
+	MaxAttempts   int   `yaml:"max_attempts" json:"max_attempts"`       // 最大尝试次数(含首次请求)
+	PerTryTimeout int   `yaml:"per_try_timeout" json:"per_try_timeout"` // 单次尝试等待响应头的超时(毫秒) 0为不限制
+	RetryOn       []int `yaml:"retry_on" json:"retry_on"`               // 触发重试的上游状态码
+	MaxReplayBody int64 `yaml:"max_replay_body" json:"max_replay_body"` // 可缓存用于重放的最大请求体(字节) 0为不缓存
 }
 
 // ServerConfig 服务器配置结构体
@@ -39,15 +50,17 @@ type ServerConfig struct {
 }
 
 type CertConfig struct {
-	Domains  []string `yaml:"domains" json:"domains"`     // 域名组
-	CertFile string   `yaml:"cert_file" json:"cert_file"` // 证书文件路径
-	KeyFile  string   `yaml:"key_file" json:"key_file"`   // 私钥文件路径
+	Domains     []string `yaml:"domains" json:"domains"`           // 域名组 支持*.example.com 为空时使用证书中的域名
+	CertFile    string   `yaml:"cert_file" json:"cert_file"`       // 证书文件路径 文件变化后自动重新加载
+	KeyFile     string   `yaml:"key_file" json:"key_file"`         // 私钥文件路径
+	DisableOCSP bool     `yaml:"disable_ocsp" json:"disable_ocsp"` // 关闭OCSP装订 证书包含OCSP地址时默认开启
 }
 
 // TLSConfig TLS证书配置结构体
 type TLSConfig struct {
-	CertMap map[string]CertConfig `yaml:"cert_map" json:"cert_map"`
-	AutoTLS bool                  `yaml:"auto_tls" json:"auto_tls"` // 是否启用自动TLS
+	CertMap     map[string]CertConfig `yaml:"cert_map" json:"cert_map"`
+	AutoTLS     bool                  `yaml:"auto_tls" json:"auto_tls"`         // 是否启用自动TLS
+	DefaultCert string                `yaml:"default_cert" json:"default_cert"` // 客户端未发送SNI时使用的证书组 为空时使用名称排序第一的证书组
 }
 
 // DomainConfig 域名配置结构体
@@ -59,13 +72,72 @@ type DomainConfig struct {
 	HSTSMaxAge     int      `yaml:"hsts_max_age" json:"hsts_max_age"`       // HSTS最大生存时间（秒），0表示不设置HSTS
 	HSTSSubdomains bool     `yaml:"hsts_subdomains" json:"hsts_subdomains"` // HSTS是否包含子域名
 	HSTSPreload    bool     `yaml:"hsts_preload" json:"hsts_preload"`       // HSTS是否启用预加载
+	// 客户端证书认证 仅对https服务器生效
+	ClientAuth *ClientAuthConfig `yaml:"client_auth,omitempty" json:"client_auth,omitempty"`
+	// TLS握手策略 对https和HTTP/3服务器生效 为空时最低使用TLS1.2 其余使用标准库默认值
+	TLSPolicy *TLSPolicyConfig `yaml:"tls_policy,omitempty" json:"tls_policy,omitempty"`
+}
+
+// TLSPolicyConfig 域名组的TLS握手策略 握手时按SNI选择
+// HTTP/3固定使用TLS1.3和h3 只有曲线配置生效
+type TLSPolicyConfig struct {
+	MinVersion   string   `yaml:"min_version" json:"min_version"`     // 最低版本: 1.0, 1.1, 1.2(默认), 1.3
+	MaxVersion   string   `yaml:"max_version" json:"max_version"`     // 最高版本 默认1.3
+	CipherSuites []string `yaml:"cipher_suites" json:"cipher_suites"` // TLS1.2及以下使用的密码套件 使用IANA名称 如TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+	Curves       []string `yaml:"curves" json:"curves"`               // 密钥交换曲线 按优先级排列: X25519MLKEM768, X25519, P-256, P-384, P-521
+	ALPN         []string `yaml:"alpn" json:"alpn"`                   // 协商的应用层协议 如只配置http/1.1为旧客户端关闭h2
+}
+
+// ClientAuthConfig 域名组的客户端证书认证(mTLS)配置
+// 校验通过的证书信息通过请求头转发给上游 客户端发送的同名请求头总是被移除
+type ClientAuthConfig struct {
+	Mode              string   `yaml:"mode" json:"mode"`                             // 校验模式: none(默认), optional, required
+	CAFiles           []string `yaml:"ca_files" json:"ca_files"`                     // 签发客户端证书的CA文件
+	CRLFiles          []string `yaml:"crl_files" json:"crl_files"`                   // 证书吊销列表文件 支持PEM和DER 文件变化后自动重新加载
+	SubjectHeader     string   `yaml:"subject_header" json:"subject_header"`         // 证书主题请求头 默认X-Client-Cert-Subject
+	SANHeader         string   `yaml:"san_header" json:"san_header"`                 // 证书SAN请求头 默认X-Client-Cert-SAN
+	FingerprintHeader string   `yaml:"fingerprint_header" json:"fingerprint_header"` // 证书SHA-256指纹请求头 默认X-Client-Cert-Fingerprint
+}
+
+// BreakConfig 熔断配置 按上游(域名+地址)独立熔断
+type BreakConfig struct {
+	Enabled        bool    `yaml:"enabled" json:"enabled"`                   // 是否启用熔断
+	Bucket         int     `yaml:"bucket" json:"bucket"`                     // 统计窗口大小（最近的请求数）
+	MaxError       int     `yaml:"max_error" json:"max_error"`               // 最大允许的连续错误
+	ErrorRate      float64 `yaml:"error_rate" json:"error_rate"`             // 统计窗口内的错误率阈值 0-1
+	MinRequests    int     `yaml:"min_requests" json:"min_requests"`         // 计算错误率所需的最少请求数
+	Reset          int     `yaml:"reset" json:"reset"`                       // 熔断冷却时间（秒）
+	HalfOpenProbes int     `yaml:"half_open_probes" json:"half_open_probes"` // 半开状态允许的探测请求数
 }
 
-// BreakConfig 熔断配置
-type BreakConfig struct {
-	Bucket   int `yaml:"bucket" json:"bucket"`       // 桶数量
-	MaxError int `yaml:"max_error" json:"max_error"` // 最大允许错误
-	Reset    int `yaml:"reset" json:"reset"`         // 重置时间
+// HealthCheckConfig 后端端口健康检查配置
+// 未配置探测路径的域名仅做TCP连接探测
+type HealthCheckConfig struct {
+	Enabled  bool              `yaml:"enabled" json:"enabled"`   // 是否启用健康检查
+	Interval int               `yaml:"interval" json:"interval"` // 检查间隔（秒）
+	Timeout  int               `yaml:"timeout" json:"timeout"`   // 单次探测超时（秒）
+	Rise     int               `yaml:"rise" json:"rise"`         // 连续成功多少次标记为up
+	Fall     int               `yaml:"fall" json:"fall"`         // 连续失败多少次标记为down
+	Paths    map[string]string `yaml:"paths" json:"paths"`       // 域名 -> HTTP探测路径
+}
+
+// BalanceConfig 负载均衡策略
+type BalanceConfig struct {
+	Strategy string `yaml:"strategy" json:"strategy"` // 策略: round_robin, weighted, least_conn, hash, p2c
+	HashKey  string `yaml:"hash_key" json:"hash_key"` // hash策略的键: ip, header:<name>, cookie:<name>
+}
+
+// LoadBalanceConfig 负载均衡配置 域名未单独配置时使用默认策略
+type LoadBalanceConfig struct {
+	Default BalanceConfig            `yaml:"default" json:"default"` // 默认策略
+	Domains map[string]BalanceConfig `yaml:"domains" json:"domains"` // 域名 -> 策略
+}
+
+// StickyConfig 会话保持配置 仅对域名映射中开启sticky的域名生效
+type StickyConfig struct {
+	CookieName string `yaml:"cookie_name" json:"cookie_name"` // Cookie名称
+	Secret     string `yaml:"secret" json:"secret"`           // 签名密钥 为空时启动时随机生成
+	MaxAge     int    `yaml:"max_age" json:"max_age"`         // Cookie有效期（秒） 0为会话Cookie
 }
 
 // FlowControlRule 流控规则配置结构体
@@ -73,14 +145,25 @@ type FlowControlRule struct {
 	Name        string      `yaml:"name" json:"name"`               // 规则名称
 	Enabled     bool        `yaml:"enabled" json:"enabled"`         // 是否启用
 	Priority    int         `yaml:"priority" json:"priority"`       // 优先级，数字越小优先级越高
-	MatchType   string      `yaml:"match_type" json:"match_type"`   // 匹配类型: host, header, ip
+	MatchType   string      `yaml:"match_type" json:"match_type"`   // 匹配类型: host, header, ip, path_prefix, path_regex, method, query, cookie
 	MatchValue  string      `yaml:"match_value" json:"match_value"` // 匹配值
-	HeaderKey   string      `yaml:"header_key" json:"header_key"`   // 当match_type为header时的header键名
+	HeaderKey   string      `yaml:"header_key" json:"header_key"`   // 当match_type为header、query、cookie时的键名
+	Match       []FlowMatch `yaml:"match" json:"match"`             // 组合匹配条件 全部满足才命中 配置后忽略match_type
 	Limits      []RateLimit `yaml:"limits" json:"limits"`           // 速率限制配置列表
 	Action      string      `yaml:"action" json:"action"`           // 限流动作: block, delay
+	MaxDelay    int         `yaml:"max_delay" json:"max_delay"`     // delay动作的最长等待时间(毫秒) 默认1000
+	QueueSize   int         `yaml:"queue_size" json:"queue_size"`   // delay动作同时等待的最大请求数 默认100 队列满时直接拒绝
 	Description string      `yaml:"description" json:"description"` // 规则描述
 }
 
+// FlowMatch 流控匹配条件
+// host、header、ip、query、cookie条件以请求中的实际值区分限流计数 值为空时只要求存在(ip为空时匹配所有客户端)
+type FlowMatch struct {
+	Type  string `yaml:"type" json:"type"`   // 匹配类型: host, header, ip, path_prefix, path_regex, method, query, cookie
+	Key   string `yaml:"key" json:"key"`     // header、query、cookie的键名
+	Value string `yaml:"value" json:"value"` // 匹配值 ip支持CIDR method支持逗号分隔的多个方法
+}
+
 // RateLimit 速率限制配置结构体
 type RateLimit struct {
 	Requests int    `yaml:"requests" json:"requests"` // 允许的请求数
@@ -95,6 +178,18 @@ type FlowControlConfig struct {
 	GlobalLimit RateLimit         `yaml:"global_limit" json:"global_limit"` // 全局限流配置
 	Rules       []FlowControlRule `yaml:"rules" json:"rules"`               // 流控规则列表
 	Recording   FlowRecordConfig  `yaml:"recording" json:"recording"`       // 流控记录配置
+	Store       FlowStoreConfig   `yaml:"store" json:"store"`               // 限流计数存储
+}
+
+// FlowStoreConfig 限流计数存储配置
+// memory为进程内计数 redis时多个网关实例通过database.redis共享计数
+// 共享计数按固定窗口和滑动窗口计数器计算 各实例在本地累计一定数量后批量同步
+// 全局误差不超过 实例数 x error_bound x 限制数 error_bound x 限制数不足1时每个请求先写入共享计数再判断
+type FlowStoreConfig struct {
+	Type         string  `yaml:"type" json:"type"`                   // 存储类型: memory, redis 默认memory
+	Prefix       string  `yaml:"prefix" json:"prefix"`               // 共享计数的key前缀 默认hamburger:flow:
+	ErrorBound   float64 `yaml:"error_bound" json:"error_bound"`     // 单个实例未同步计数占限制数的最大比例 0-1 0表示每个请求都同步
+	SyncInterval int     `yaml:"sync_interval" json:"sync_interval"` // 本地计数的最长同步间隔(毫秒) 默认100
 }
 
 // FlowRecordConfig 流控记录配置结构体
@@ -124,6 +219,7 @@ type MiddlewareConfig struct {
 	Sanitizer    Sanitizer    `yaml:"sanitizer" json:"sanitizer"`         // 请求头标准化
 	DomainCheck  DomainCheck  `yaml:"domain_check" json:"domain_check"`   // 域名强制校验
 	ImageProtect ImageProtect `yaml:"image_protect" json:"image_protect"` // 图片防盗链
+	WAF          WAFConfig    `yaml:"waf" json:"waf"`                     // Web应用防火墙
 }
 
 // FeatureConfig 功能特性配置结构体
@@ -135,6 +231,20 @@ type FeatureConfig struct {
 	GrpcProxy   GrpcProxyConfig   `yaml:"grpc_proxy" json:"grpc_proxy"`     // gRPC代理配置
 	FlowControl FlowControlConfig `yaml:"flow_control" json:"flow_control"` // 流控配置
 	Break       BreakConfig       `yaml:"break" json:"break"`               // 熔断配置
+	HealthCheck HealthCheckConfig `yaml:"health_check" json:"health_check"` // 后端健康检查配置
+	Balance     LoadBalanceConfig `yaml:"balance" json:"balance"`           // 负载均衡配置
+	Sticky      StickyConfig      `yaml:"sticky" json:"sticky"`             // 会话保持配置
+	Monitor     MonitorConfig     `yaml:"monitor" json:"monitor"`           // 监控指标配置
+	// TLS会话票据密钥配置
+	SessionTicket SessionTicketConfig `yaml:"session_ticket" json:"session_ticket"`
+}
+
+// SessionTicketConfig TLS会话票据密钥配置
+// 密钥由网关按间隔轮换 保存到文件后重启或共用文件的其他实例可以继续恢复之前签发的会话
+type SessionTicketConfig struct {
+	KeyFile        string `yaml:"key_file" json:"key_file"`               // 密钥文件 为空时密钥只保存在内存中
+	RotateInterval int    `yaml:"rotate_interval" json:"rotate_interval"` // 轮换间隔(小时) 默认24
+	Keep           int    `yaml:"keep" json:"keep"`                       // 轮换后保留用于恢复会话的旧密钥数 默认6
 }
 
 // HTTP3Config HTTP/3协议配置结构体
@@ -146,12 +256,14 @@ type HTTP3Config struct {
 }
 
 // WebSocketConfig WebSocket协议配置结构体
+// 启用后域名组还需要开启use_websocket才允许协议升级
 type WebSocketConfig struct {
 	Enabled        bool  `yaml:"enabled" json:"enabled"`                   // 是否启用WebSocket
-	PingInterval   int   `yaml:"ping_interval" json:"ping_interval"`       // 心跳间隔
-	PongTimeout    int   `yaml:"pong_timeout" json:"pong_timeout"`         // 心跳响应超时
-	MaxMessageSize int64 `yaml:"max_message_size" json:"max_message_size"` // 最大消息大小
-	BufferSize     int   `yaml:"buffer_size" json:"buffer_size"`           // 缓冲区大小
+	PingInterval   int   `yaml:"ping_interval" json:"ping_interval"`       // 网关向客户端发送心跳的间隔(秒) 0不发送
+	PongTimeout    int   `yaml:"pong_timeout" json:"pong_timeout"`         // 心跳响应超时(秒) 默认10
+	IdleTimeout    int   `yaml:"idle_timeout" json:"idle_timeout"`         // 双向都没有数据帧时关闭连接的时间(秒) 0不限制
+	MaxMessageSize int64 `yaml:"max_message_size" json:"max_message_size"` // 最大消息大小(字节) 分片消息按累计大小计算 0不限制
+	BufferSize     int   `yaml:"buffer_size" json:"buffer_size"`           // 读写缓冲区大小 默认4096
 }
 
 // GzipConfig Gzip压缩配置结构体
@@ -176,22 +288,71 @@ type TraceConfig struct {
 }
 
 // AutoCertConfig 自动证书配置结构体
+// http-01使用autocert并在验证时接管80端口 tls-alpn-01在已有的443监听上完成验证
+// dns-01通过DNS提供方写入TXT记录 是唯一支持通配符域名的验证方式
 type AutoCertConfig struct {
-	Email   string   `yaml:"email" json:"email"`     // 注册邮箱
-	Domains []string `yaml:"domains" json:"domains"` // 域名列表
+	Email        string            `yaml:"email" json:"email"`                 // 注册邮箱
+	Domains      []string          `yaml:"domains" json:"domains"`             // 域名列表
+	Challenge    string            `yaml:"challenge" json:"challenge"`         // 验证方式: http-01(默认), tls-alpn-01, dns-01
+	DirectoryURL string            `yaml:"directory_url" json:"directory_url"` // ACME目录地址 默认Let's Encrypt
+	DirectoryCA  string            `yaml:"directory_ca" json:"directory_ca"`   // 访问ACME目录时信任的CA证书文件 用于本地Pebble等测试服务
+	CacheDir     string            `yaml:"cache_dir" json:"cache_dir"`         // 账户密钥和证书的缓存目录 默认./autocert
+	RenewBefore  int               `yaml:"renew_before" json:"renew_before"`   // 证书到期前多少天续期 默认30
+	DNS          DNSProviderConfig `yaml:"dns" json:"dns"`                     // dns-01验证的DNS提供方
+}
+
+// DNSProviderConfig dns-01验证的DNS提供方配置
+// rfc2136通过DNS UPDATE直接修改权威服务器上的记录 exec调用外部命令完成记录的写入和清理
+type DNSProviderConfig struct {
+	Provider         string `yaml:"provider" json:"provider"`                   // 提供方: rfc2136, exec
+	Nameserver       string `yaml:"nameserver" json:"nameserver"`               // rfc2136: 接受UPDATE的服务器地址 host:port
+	Zone             string `yaml:"zone" json:"zone"`                           // rfc2136: 记录所在的区域
+	TSIGKey          string `yaml:"tsig_key" json:"tsig_key"`                   // rfc2136: TSIG密钥名 为空时不签名
+	TSIGSecret       string `yaml:"tsig_secret" json:"tsig_secret"`             // rfc2136: base64编码的TSIG密钥
+	TSIGAlgorithm    string `yaml:"tsig_algorithm" json:"tsig_algorithm"`       // rfc2136: hmac-sha256(默认), hmac-sha512, hmac-sha1
+	TTL              int    `yaml:"ttl" json:"ttl"`                             // rfc2136: TXT记录的TTL(秒) 默认60
+	Command          string `yaml:"command" json:"command"`                     // exec: 命令路径 参数为 present|cleanup <fqdn> <value>
+	Timeout          int    `yaml:"timeout" json:"timeout"`                     // 单次写入或清理的超时(秒) 默认30
+	PropagationDelay int    `yaml:"propagation_delay" json:"propagation_delay"` // 写入记录后等待生效的时间(秒) 之后再通知ACME服务验证
+}
+
+// GrpcProxyConfig gRPC代理配置结构体
+// 透明代理按:authority和服务路径将h2/h2c上的gRPC请求转发到routes配置的上游
+// JSON桥接为可选模式 开启bridge后带有grpc_header标识的HTTP请求转换为gRPC调用
+type GrpcProxyConfig struct {
+	Enabled        bool        `yaml:"enabled" json:"enabled"`                 // 是否启用gRPC代理
+	Routes         []GrpcRoute `yaml:"routes" json:"routes"`                   // 透明代理路由 按配置顺序匹配
+	Bridge         bool        `yaml:"bridge" json:"bridge"`                   // 是否启用JSON桥接
+	Hosts          []string    `yaml:"hosts" json:"hosts"`                     // 桥接模式允许的目标gRPC主机列表
+	GrpcHeader     string      `yaml:"grpc_header" json:"grpc_header"`         // 桥接模式的gRPC识别请求头
+	GrpcAddr       string      `yaml:"grpc_addr" json:"grpc_addr"`             // 桥接模式的目标gRPC地址请求头
+	DescriptorSets []string    `yaml:"descriptor_sets" json:"descriptor_sets"` // 桥接模式的描述符集文件 未找到服务时使用上游的服务反射
+	DescriptorTTL  int         `yaml:"descriptor_ttl" json:"descriptor_ttl"`   // 反射获取的描述符按上游缓存的时间(秒) 默认300
 }
 
-type GrpcProxyConfig struct {
-	Enabled    bool     `yaml:"enabled" json:"enabled"`         // 是否启用gRPC代理
-	Hosts      []string `yaml:"hosts" json:"hosts"`             // 目标gRPC主机列表
-	GrpcHeader string   `yaml:"grpc_header" json:"grpc_header"` // gRPC识别请求头
-	GrpcAddr   string   `yaml:"grpc_addr" json:"grpc_addr"`     // 目标gRPC地址
+// GrpcRoute gRPC透明代理路由
+type GrpcRoute struct {
+	Authority string        `yaml:"authority" json:"authority"` // 匹配的:authority 不含端口 支持*.example.com 空匹配全部
+	Service   string        `yaml:"service" json:"service"`     // 匹配的服务 如pkg.Service或pkg.Service/Method 空匹配全部
+	Upstream  []Upstream    `yaml:"upstream" json:"upstream"`   // 上游gRPC服务
+	TLS       bool          `yaml:"tls" json:"tls"`             // 上游使用h2 默认使用h2c
+	Balance   BalanceConfig `yaml:"balance" json:"balance"`     // 负载均衡策略
 }
 
 // DatabaseConfig 数据库配置结构体
 type DatabaseConfig struct {
 	Mongo  MongoConfig  `yaml:"mongo" json:"mongo"`   // MongoDB配置
 	Influx InfluxConfig `yaml:"influx" json:"influx"` // InfluxDB配置
+	Redis  RedisConfig  `yaml:"redis" json:"redis"`   // Redis配置
+}
+
+// RedisConfig Redis配置结构体 兼容Redis协议的服务均可使用
+type RedisConfig struct {
+	Addr     string `yaml:"addr" json:"addr"`           // 地址 host:port
+	Password string `yaml:"password" json:"password"`   // 密码
+	DB       int    `yaml:"db" json:"db"`               // 数据库编号
+	Timeout  int    `yaml:"timeout" json:"timeout"`     // 连接和读写超时(毫秒) 默认200
+	PoolSize int    `yaml:"pool_size" json:"pool_size"` // 最大空闲连接数 默认16
 }
 
 // MongoConfig MongoDB配置结构体
@@ -214,6 +375,7 @@ type InfluxConfig struct {
 // MonitorConfig 监控配置结构体
 type MonitorConfig struct {
 	Enabled    bool   `yaml:"enabled" json:"enabled"`       // 是否启用监控
+	Host       string `yaml:"host" json:"host"`             // 监控地址
 	Port       int    `yaml:"port" json:"port"`             // 监控端口
 	Path       string `yaml:"path" json:"path"`             // 监控路径
 	Interval   int    `yaml:"interval" json:"interval"`     // 监控间隔
@@ -229,11 +391,16 @@ type CorsConfig struct {
 
 // SecurityConfig 安全配置结构体
 type SecurityConfig struct {
-	StrictMode bool     `yaml:"strict_mode" json:"strict_mode"` // 严格模式
-	AllowIPs   []string `yaml:"allow_ips" json:"allow_ips"`     // 允许的IP列表
-	DenyIPs    []string `yaml:"deny_ips" json:"deny_ips"`       // 拒绝的IP列表
+	StrictMode bool     `yaml:"strict_mode" json:"strict_mode"` // 严格模式 AllowIPs非空时只允许其中的IP访问
+	AllowIPs   []string `yaml:"allow_ips" json:"allow_ips"`     // 允许的IP列表 支持单个IP和CIDR 非严格模式下用于豁免DenyIPs
+	DenyIPs    []string `yaml:"deny_ips" json:"deny_ips"`       // 拒绝的IP列表 支持单个IP和CIDR
 	RateLimit  int      `yaml:"rate_limit" json:"rate_limit"`   // 速率限制
 
+	TrustedProxies []string                  `yaml:"trusted_proxies" json:"trusted_proxies"` // 可信代理 来自这些地址的请求从RealIPHeader解析客户端IP
+	RealIPHeader   string                    `yaml:"real_ip_header" json:"real_ip_header"`   // 客户端IP请求头 默认X-Forwarded-For
+	Domains        map[string]IPAccessConfig `yaml:"domains" json:"domains"`                 // 域名 -> IP访问控制 在全局规则之后检查
+	Countries      map[string]CountryAccess  `yaml:"countries" json:"countries"`             // 域名 -> 国家访问控制 *对所有域名生效
+
 	HSTS             bool `yaml:"hsts" json:"hsts"`                     // HSTS策略
 	HSTSSubdomain    bool `yaml:"hsts_subdomain" json:"hsts_subdomain"` // 包含子域名
 	HSTSPreload      bool `yaml:"hsts_preload" json:"hsts_preload"`     // 预加载
@@ -242,6 +409,20 @@ type SecurityConfig struct {
 	SameSite         bool `yaml:"same_site" json:"same_site"` // 同源策略
 }
 
+// IPAccessConfig 域名的IP访问控制 规则与全局规则相同
+type IPAccessConfig struct {
+	StrictMode bool     `yaml:"strict_mode" json:"strict_mode"`
+	AllowIPs   []string `yaml:"allow_ips" json:"allow_ips"`
+	DenyIPs    []string `yaml:"deny_ips" json:"deny_ips"`
+}
+
+// CountryAccess 按客户端IP所属国家的访问控制 使用ISO 3166国家代码
+// Allow非空时只允许其中的国家 否则拒绝Deny中的国家 无法识别国家的地址(内网等)不受限制
+type CountryAccess struct {
+	Allow []string `yaml:"allow" json:"allow"`
+	Deny  []string `yaml:"deny" json:"deny"`
+}
+
 type FrontProxyConfig struct {
 	GrpcAddr     string `yaml:"grpc_addr" json:"grpc_addr"`
 	FrontendFlag string `yaml:"frontend_flag" json:"frontend_flag"`
@@ -258,9 +439,30 @@ type ProxyHeader struct {
 }
 
 type LogConfig struct {
-	LogLevel string `yaml:"log_level" json:"log_level"`
-	LogFile  string `yaml:"log_file" json:"log_file"`
-	Color    bool   `yaml:"color" json:"color"`
+	LogLevel        string           `yaml:"log_level" json:"log_level"`
+	LogFile         string           `yaml:"log_file" json:"log_file"` // 日志文件 为空时输出到标准输出
+	Color           bool             `yaml:"color" json:"color"`       // 输出到文件时不使用颜色
+	Encoding        string           `yaml:"encoding" json:"encoding"` // console | json 默认console
+	LogRotateConfig `yaml:",inline"` // 日志文件的轮转和保留
+	Access          AccessLogConfig  `yaml:"access" json:"access"` // 网关访问日志
+}
+
+// LogRotateConfig 日志文件轮转和保留 大小和时间条件满足任意一个即轮转
+type LogRotateConfig struct {
+	MaxSize    int    `yaml:"max_size" json:"max_size"`       // 单个文件的最大大小(MB) 超出后轮转 默认100
+	Interval   string `yaml:"interval" json:"interval"`       // 按时间轮转 hourly | daily 为空时不按时间轮转
+	MaxBackups int    `yaml:"max_backups" json:"max_backups"` // 保留的轮转文件数量 0不限制
+	MaxAge     int    `yaml:"max_age" json:"max_age"`         // 轮转文件的保留天数 0不限制
+	Compress   bool   `yaml:"compress" json:"compress"`       // 使用gzip压缩轮转文件
+}
+
+// AccessLogConfig 网关访问日志 每个请求一行
+type AccessLogConfig struct {
+	Enabled         bool   `yaml:"enabled" json:"enabled"`
+	File            string `yaml:"file" json:"file"`         // 日志文件 为空时输出到标准输出
+	Format          string `yaml:"format" json:"format"`     // json | nginx 默认json
+	Template        string `yaml:"template" json:"template"` // nginx格式的模板 使用$变量 为空时使用默认模板
+	LogRotateConfig `yaml:",inline"`
 }
 
 type ModuleConfig struct {
@@ -282,10 +484,19 @@ type StatConfig struct {
 	SaveFile     string         `json:"save_file"`
 	GeoFile      string         `json:"geo_file"`
 	DomainFile   string         `json:"domain_file"`
-	GeoDB        string         `json:"geo_db"`                   // geo数据库
+	GeoDB        string         `json:"geo_db"`                   // 外部mmdb文件 为空时使用内置数据库 文件变化后自动重新加载
 	Sequence     SequenceConfig `yaml:"sequence" json:"sequence"` // 时序统计配置
 }
 
+// AdminConfig 管理接口配置
+// 管理接口使用独立的监听地址 所有请求需要携带Bearer令牌
+type AdminConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Host    string `yaml:"host" json:"host"`
+	Port    int    `yaml:"port" json:"port"`
+	Token   string `yaml:"token" json:"token"` // 认证令牌 为空时不启动管理接口
+}
+
 // SequenceConfig 时序统计配置结构体
 // 用于配置是否启用时序统计、数据库文件路径以及统计时间间隔
 type SequenceConfig struct {
@@ -299,6 +510,40 @@ type ImageProtect struct {
 	AllowReferer []string `yaml:"allow_referer" json:"allow_referer"` // 允许的请求头
 }
 
+// WAFConfig Web应用防火墙配置
+// 域名配置了规则集时使用域名的规则集 否则使用默认规则集
+type WAFConfig struct {
+	Enabled     bool                  `yaml:"enabled" json:"enabled"`
+	InspectBody int64                 `yaml:"inspect_body" json:"inspect_body"` // 检查的请求体前缀大小(字节) 默认8192 负数不检查请求体
+	Default     WAFRuleSet            `yaml:"default" json:"default"`           // 默认规则集
+	Domains     map[string]WAFRuleSet `yaml:"domains" json:"domains"`           // 域名 -> 规则集
+	Challenge   WAFChallengeConfig    `yaml:"challenge" json:"challenge"`       // challenge动作的验证Cookie
+}
+
+// WAFRuleSet WAF规则集
+type WAFRuleSet struct {
+	Builtin        []string  `yaml:"builtin" json:"builtin"`                   // 启用的内置规则组: sqli, xss, traversal, scanner, query_length
+	BuiltinAction  string    `yaml:"builtin_action" json:"builtin_action"`     // 内置规则的动作 默认block
+	MaxQueryLength int       `yaml:"max_query_length" json:"max_query_length"` // query_length规则组的阈值 默认2048
+	Rules          []WAFRule `yaml:"rules" json:"rules"`                       // 自定义规则 在内置规则之前检查
+}
+
+// WAFRule WAF规则 Pattern和MaxLength至少配置一个 同时配置时任意一个命中即命中
+type WAFRule struct {
+	Name      string   `yaml:"name" json:"name"`
+	Targets   []string `yaml:"targets" json:"targets"`       // 检查目标: method, path, query, headers, header:<name>, user_agent, body
+	Pattern   string   `yaml:"pattern" json:"pattern"`       // 正则表达式
+	MaxLength int      `yaml:"max_length" json:"max_length"` // 目标长度超过该值时命中
+	Action    string   `yaml:"action" json:"action"`         // block | log | challenge 默认block
+}
+
+// WAFChallengeConfig challenge动作通过后下发的验证Cookie
+type WAFChallengeConfig struct {
+	CookieName string `yaml:"cookie_name" json:"cookie_name"` // Cookie名称
+	Secret     string `yaml:"secret" json:"secret"`           // 签名密钥 为空时启动时随机生成
+	TTL        int    `yaml:"ttl" json:"ttl"`                 // 有效期(秒) 默认3600
+}
+
 type PProf struct {
 	Enable bool `yaml:"enable" json:"enable"`
 	Port   int  `yaml:"port" json:"port"`
@@ -307,6 +552,15 @@ type PProf struct {
 // GetDefaultConfig 获取默认配置
 func GetDefaultConfig() *AppConfig {
 	return &AppConfig{
+		CoreProxy: ProxyConfig{
+			Retry: RetryConfig{
+				Enabled:       false,
+				MaxAttempts:   2,
+				PerTryTimeout: 0,
+				RetryOn:       []int{502, 503, 504},
+				MaxReplayBody: 64 * 1024, // 64KB
+			},
+		},
 		Servers: []ServerConfig{
 			{
 				Name:           "http-server",
@@ -326,9 +580,9 @@ func GetDefaultConfig() *AppConfig {
 				TLS: &TLSConfig{
 					CertMap: map[string]CertConfig{
 						"renj.io": {
-							[]string{""},
-							"/path/to/cert.pem",
-							"/path/to/key.pem",
+							Domains:  []string{""},
+							CertFile: "/path/to/cert.pem",
+							KeyFile:  "/path/to/key.pem",
 						},
 					},
 					AutoTLS: false,
@@ -362,6 +616,20 @@ func GetDefaultConfig() *AppConfig {
 				TTL:      60,
 				Strategy: "lru",
 			},
+			HealthCheck: HealthCheckConfig{
+				Enabled:  false,
+				Interval: 10,
+				Timeout:  2,
+				Rise:     2,
+				Fall:     3,
+			},
+			Monitor: MonitorConfig{
+				Enabled:    false,
+				Host:       "127.0.0.1",
+				Port:       9091,
+				Path:       "/metrics",
+				Prometheus: true,
+			},
 		},
 		Database: DatabaseConfig{
 			Mongo: MongoConfig{
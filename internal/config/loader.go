@@ -45,5 +45,7 @@ func Set(cfg *Config) {
 
 // Get 获取全局唯一的配置
 func Get() *Config {
+	globalConfigLock.RLock()
+	defer globalConfigLock.RUnlock()
 	return globalConfig
 }
@@ -7,23 +7,25 @@ func Merge(appConfig *AppConfig) *Config {
 	}
 
 	conf := &Config{
-		CoreProxy:    appConfig.CoreProxy,
-		Servers:      appConfig.Servers,
-		Middleware:   appConfig.Middleware,
-		Features:     appConfig.Features,
-		Database:     appConfig.Database,
-		Security:     appConfig.Security,
-		ProxyHeader:  appConfig.ProxyHeader,
-		Log:          appConfig.Log,
-		Module:       appConfig.Module,
-		Stat:         appConfig.Stat,
-		CustomHeader: appConfig.CustomHeader,
-		Syncer:       appConfig.Syncer,
-		Debug:        appConfig.Debug,
-		PProf:        appConfig.PProf,
-		MaxCores:     appConfig.MaxCores,
-		PxyBackend:   appConfig.PxyBackend,
-		PxyFrontend:  appConfig.PxyFrontend,
+		CoreProxy:        appConfig.CoreProxy,
+		Servers:          appConfig.Servers,
+		Middleware:       appConfig.Middleware,
+		Features:         appConfig.Features,
+		Database:         appConfig.Database,
+		Security:         appConfig.Security,
+		ProxyHeader:      appConfig.ProxyHeader,
+		Log:              appConfig.Log,
+		Module:           appConfig.Module,
+		Stat:             appConfig.Stat,
+		Admin:            appConfig.Admin,
+		CustomHeader:     appConfig.CustomHeader,
+		Syncer:           appConfig.Syncer,
+		Debug:            appConfig.Debug,
+		PProf:            appConfig.PProf,
+		MaxCores:         appConfig.MaxCores,
+		PxyBackend:       appConfig.PxyBackend,
+		PxyFrontend:      appConfig.PxyFrontend,
+		PxyCustomService: appConfig.PxyCustomService,
 	}
 
 	if appConfig.PxyFrontendFile != "" {
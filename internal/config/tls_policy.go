@@ -0,0 +1,69 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// TLS策略配置项的解析 配置校验和TLS管理器共用
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"x25519mlkem768": tls.X25519MLKEM768,
+	"x25519":         tls.X25519,
+	"p-256":          tls.CurveP256,
+	"p-384":          tls.CurveP384,
+	"p-521":          tls.CurveP521,
+}
+
+// ParseTLSVersion 解析TLS版本 支持1.2和TLS1.2两种写法 为空时返回0
+func ParseTLSVersion(name string) (uint16, error) {
+	if name == "" {
+		return 0, nil
+	}
+	version, ok := tlsVersions[strings.TrimPrefix(strings.ToUpper(name), "TLS")]
+	if !ok {
+		return 0, fmt.Errorf("unknown tls version %s", name)
+	}
+	return version, nil
+}
+
+// ParseCipherSuites 按IANA名称解析密码套件 TLS1.3的套件不可配置
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	suites := make(map[string]*tls.CipherSuite)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites[suite.Name] = suite
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		suite, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %s", name)
+		}
+		if len(suite.SupportedVersions) == 1 && suite.SupportedVersions[0] == tls.VersionTLS13 {
+			return nil, fmt.Errorf("cipher suite %s is tls 1.3 only and not configurable", name)
+		}
+		ids = append(ids, suite.ID)
+	}
+	return ids, nil
+}
+
+// ParseCurves 解析密钥交换曲线
+func ParseCurves(names []string) ([]tls.CurveID, error) {
+	curves := make([]tls.CurveID, 0, len(names))
+	for _, name := range names {
+		curve, ok := tlsCurves[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown curve %s", name)
+		}
+		curves = append(curves, curve)
+	}
+	return curves, nil
+}
@@ -0,0 +1,254 @@
+package config
+
+import (
+	"Hamburger/internal/structure"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Validate 校验合并后的配置 用于热重载前检查 校验失败时不应替换正在使用的配置
+func Validate(cfg *Config) error {
+	if cfg == nil {
+		return errors.New("config is empty")
+	}
+
+	var errs []error
+	enabled := 0
+	names := make(map[string]struct{})
+	for _, server := range cfg.Servers {
+		if !server.Enabled {
+			continue
+		}
+		enabled++
+		if _, ok := names[server.Name]; ok {
+			errs = append(errs, fmt.Errorf("server %s: duplicate name", server.Name))
+		}
+		names[server.Name] = struct{}{}
+		if server.Port <= 0 || server.Port > 65535 {
+			errs = append(errs, fmt.Errorf("server %s: invalid port %d", server.Name, server.Port))
+		}
+		for _, group := range server.DomainConfig {
+			if group.TLSPolicy != nil {
+				if err := validateTLSPolicy(*group.TLSPolicy); err != nil {
+					errs = append(errs, fmt.Errorf("server %s: tls policy for %v: %w", server.Name, group.Domains, err))
+				}
+			}
+			if group.ClientAuth == nil {
+				continue
+			}
+			switch group.ClientAuth.Mode {
+			case "", "none":
+			case "optional", "required":
+				if server.Protocol != "https" {
+					errs = append(errs, fmt.Errorf("server %s: client auth requires https", server.Name))
+				}
+				if len(group.ClientAuth.CAFiles) == 0 {
+					errs = append(errs, fmt.Errorf("server %s: client auth for %v requires ca_files", server.Name, group.Domains))
+				}
+			default:
+				errs = append(errs, fmt.Errorf("server %s: unknown client auth mode %s", server.Name, group.ClientAuth.Mode))
+			}
+		}
+		switch server.Protocol {
+		case "", "http", "http3":
+		case "https":
+			if server.TLS == nil {
+				errs = append(errs, fmt.Errorf("server %s: https server missing tls config", server.Name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("server %s: unknown protocol %s", server.Name, server.Protocol))
+		}
+	}
+	if enabled == 0 {
+		errs = append(errs, errors.New("no enabled server"))
+	}
+
+	for _, service := range cfg.PxyCustomService.CustomService {
+		if service.Domain == "" {
+			errs = append(errs, errors.New("custom service: domain is empty"))
+		}
+		for _, upstream := range service.Upstream {
+			if upstream.Host == "" || upstream.Port <= 0 || upstream.Port > 65535 {
+				errs = append(errs, fmt.Errorf("custom service %s: invalid upstream %s:%d", service.Domain, upstream.Host, upstream.Port))
+			}
+			errs = append(errs, validateUpstreamTLS(service.Domain, upstream)...)
+		}
+		for _, route := range service.GeoRoutes {
+			if len(route.Countries) == 0 || len(route.Upstream) == 0 {
+				errs = append(errs, fmt.Errorf("custom service %s: geo route requires countries and upstream", service.Domain))
+			}
+			for _, upstream := range route.Upstream {
+				if upstream.Host == "" || upstream.Port <= 0 || upstream.Port > 65535 {
+					errs = append(errs, fmt.Errorf("custom service %s: invalid geo upstream %s:%d", service.Domain, upstream.Host, upstream.Port))
+				}
+				errs = append(errs, validateUpstreamTLS(service.Domain, upstream)...)
+			}
+		}
+	}
+
+	for i, route := range cfg.Features.GrpcProxy.Routes {
+		if len(route.Upstream) == 0 {
+			errs = append(errs, fmt.Errorf("grpc route %d: upstream is empty", i))
+		}
+		if strings.HasPrefix(route.Service, "/") || strings.Count(route.Service, "/") > 1 {
+			errs = append(errs, fmt.Errorf("grpc route %d: invalid service %s", i, route.Service))
+		}
+		for _, upstream := range route.Upstream {
+			if upstream.Host == "" || upstream.Port <= 0 || upstream.Port > 65535 {
+				errs = append(errs, fmt.Errorf("grpc route %d: invalid upstream %s:%d", i, upstream.Host, upstream.Port))
+			}
+		}
+	}
+
+	errs = append(errs, validateAutoCert(cfg.Features.AutoCert)...)
+	if ticket := cfg.Features.SessionTicket; ticket.RotateInterval < 0 || ticket.Keep < 0 {
+		errs = append(errs, errors.New("session ticket: rotate_interval and keep must not be negative"))
+	}
+
+	rules := make(map[string]struct{})
+	for _, rule := range cfg.Features.FlowControl.Rules {
+		if !rule.Enabled {
+			continue
+		}
+		if _, ok := rules[rule.Name]; ok || rule.Name == "" {
+			errs = append(errs, fmt.Errorf("flow control rule %q: name must be unique and not empty", rule.Name))
+		}
+		rules[rule.Name] = struct{}{}
+		switch rule.Action {
+		case "", "block", "delay":
+		default:
+			errs = append(errs, fmt.Errorf("flow control rule %s: unknown action %s", rule.Name, rule.Action))
+		}
+	}
+
+	store := cfg.Features.FlowControl.Store
+	switch store.Type {
+	case "", "memory":
+	case "redis":
+		if cfg.Database.Redis.Addr == "" {
+			errs = append(errs, errors.New("flow control store: redis requires database.redis.addr"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("flow control store: unknown type %s", store.Type))
+	}
+	if store.ErrorBound < 0 || store.ErrorBound >= 1 {
+		errs = append(errs, fmt.Errorf("flow control store: error bound %v out of range [0, 1)", store.ErrorBound))
+	}
+
+	errs = append(errs, validateIPs("security.trusted_proxies", cfg.Security.TrustedProxies)...)
+	errs = append(errs, validateIPs("security.allow_ips", cfg.Security.AllowIPs)...)
+	errs = append(errs, validateIPs("security.deny_ips", cfg.Security.DenyIPs)...)
+	for domain, access := range cfg.Security.Domains {
+		errs = append(errs, validateIPs("security.domains."+domain+".allow_ips", access.AllowIPs)...)
+		errs = append(errs, validateIPs("security.domains."+domain+".deny_ips", access.DenyIPs)...)
+	}
+
+	switch cfg.Log.Encoding {
+	case "", "console", "json":
+	default:
+		errs = append(errs, fmt.Errorf("log: unknown encoding %s", cfg.Log.Encoding))
+	}
+	for name, interval := range map[string]string{"log": cfg.Log.Interval, "access log": cfg.Log.Access.Interval} {
+		switch interval {
+		case "", "hourly", "daily":
+		default:
+			errs = append(errs, fmt.Errorf("%s: unknown rotate interval %s", name, interval))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateUpstreamTLS 校验自定义服务上游的协议和TLS配置
+func validateUpstreamTLS(domain string, upstream Upstream) []error {
+	var errs []error
+	switch upstream.Scheme {
+	case "", "http":
+		if upstream.TLS != nil {
+			errs = append(errs, fmt.Errorf("custom service %s: upstream %s:%d has tls config but scheme is not https", domain, upstream.Host, upstream.Port))
+		}
+	case "https":
+		if tls := upstream.TLS; tls != nil && (tls.CertFile == "") != (tls.KeyFile == "") {
+			errs = append(errs, fmt.Errorf("custom service %s: upstream %s:%d requires both cert_file and key_file", domain, upstream.Host, upstream.Port))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("custom service %s: unknown upstream scheme %s", domain, upstream.Scheme))
+	}
+	return errs
+}
+
+// validateTLSPolicy 校验域名组的TLS版本、密码套件、曲线和ALPN
+func validateTLSPolicy(policy TLSPolicyConfig) error {
+	minVersion, err := ParseTLSVersion(policy.MinVersion)
+	if err != nil {
+		return err
+	}
+	maxVersion, err := ParseTLSVersion(policy.MaxVersion)
+	if err != nil {
+		return err
+	}
+	if minVersion != 0 && maxVersion != 0 && minVersion > maxVersion {
+		return fmt.Errorf("min_version %s is greater than max_version %s", policy.MinVersion, policy.MaxVersion)
+	}
+	if _, err = ParseCipherSuites(policy.CipherSuites); err != nil {
+		return err
+	}
+	if _, err = ParseCurves(policy.Curves); err != nil {
+		return err
+	}
+	if slices.Contains(policy.ALPN, "") {
+		return errors.New("alpn protocol must not be empty")
+	}
+	return nil
+}
+
+// validateAutoCert 校验自动证书的验证方式和DNS提供方
+func validateAutoCert(conf AutoCertConfig) []error {
+	var errs []error
+	wildcard := false
+	for _, domain := range conf.Domains {
+		if strings.HasPrefix(domain, "*.") {
+			wildcard = true
+		}
+	}
+	switch conf.Challenge {
+	case "", "http-01", "tls-alpn-01":
+		if wildcard {
+			errs = append(errs, errors.New("auto cert: wildcard domains require dns-01 challenge"))
+		}
+	case "dns-01":
+		switch conf.DNS.Provider {
+		case "rfc2136":
+			if conf.DNS.Nameserver == "" || conf.DNS.Zone == "" {
+				errs = append(errs, errors.New("auto cert: rfc2136 provider requires nameserver and zone"))
+			}
+			switch conf.DNS.TSIGAlgorithm {
+			case "", "hmac-sha256", "hmac-sha512", "hmac-sha1":
+			default:
+				errs = append(errs, fmt.Errorf("auto cert: unknown tsig algorithm %s", conf.DNS.TSIGAlgorithm))
+			}
+		case "exec":
+			if conf.DNS.Command == "" {
+				errs = append(errs, errors.New("auto cert: exec provider requires command"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("auto cert: unknown dns provider %q", conf.DNS.Provider))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auto cert: unknown challenge %s", conf.Challenge))
+	}
+	return errs
+}
+
+// validateIPs 校验IP和CIDR列表
+func validateIPs(name string, list []string) []error {
+	var errs []error
+	for _, s := range list {
+		if _, err := structure.ParsePrefix(s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid ip or cidr %q", name, s))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,230 @@
+package data
+
+import (
+	"Hamburger/internal/config"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Redis协议客户端
+// 只实现网关需要的命令调用和流水线 连接按需创建 用完放回空闲连接池
+
+const (
+	defaultRedisTimeout  = 200 * time.Millisecond
+	defaultRedisPoolSize = 16
+)
+
+var ErrRedisClosed = errors.New("redis: client closed")
+
+// RedisError 服务端返回的错误
+type RedisError string
+
+func (e RedisError) Error() string {
+	return string(e)
+}
+
+// RedisClient Redis协议客户端 可并发使用
+type RedisClient struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+	idle     chan *redisConn
+	closed   atomic.Bool
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// NewRedisClient 创建客户端 不立即建立连接
+func NewRedisClient(conf config.RedisConfig) *RedisClient {
+	timeout := time.Duration(conf.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultRedisTimeout
+	}
+	poolSize := conf.PoolSize
+	if poolSize <= 0 {
+		poolSize = defaultRedisPoolSize
+	}
+	return &RedisClient{
+		addr:     conf.Addr,
+		password: conf.Password,
+		db:       conf.DB,
+		timeout:  timeout,
+		idle:     make(chan *redisConn, poolSize),
+	}
+}
+
+// Do 执行单条命令
+// 返回值类型: 简单字符串和批量字符串为string 整数为int64 数组为[]any 空值为nil
+func (c *RedisClient) Do(args ...string) (any, error) {
+	replies, err := c.Pipeline([][]string{args})
+	if err != nil {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// Pipeline 在同一连接上批量发送命令并按顺序读取结果 任意命令返回错误时返回第一个错误
+func (c *RedisClient) Pipeline(cmds [][]string) ([]any, error) {
+	if c.closed.Load() {
+		return nil, ErrRedisClosed
+	}
+	rc, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	replies, err := rc.pipeline(cmds, c.timeout)
+	if err != nil {
+		var redisErr RedisError
+		if !errors.As(err, &redisErr) {
+			// 连接状态未知 不再复用
+			_ = rc.conn.Close()
+			return nil, err
+		}
+	}
+	c.put(rc)
+	return replies, err
+}
+
+// Close 关闭空闲连接 使用中的连接归还时关闭
+func (c *RedisClient) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	for {
+		select {
+		case rc := <-c.idle:
+			_ = rc.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *RedisClient) get() (*redisConn, error) {
+	select {
+	case rc := <-c.idle:
+		return rc, nil
+	default:
+	}
+	conn, err := net.DialTimeout("tcp", c.addr, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	rc := &redisConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	var setup [][]string
+	if c.password != "" {
+		setup = append(setup, []string{"AUTH", c.password})
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	if len(setup) > 0 {
+		if _, err = rc.pipeline(setup, c.timeout); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+func (c *RedisClient) put(rc *redisConn) {
+	if c.closed.Load() {
+		_ = rc.conn.Close()
+		return
+	}
+	select {
+	case c.idle <- rc:
+	default:
+		_ = rc.conn.Close()
+	}
+}
+
+func (rc *redisConn) pipeline(cmds [][]string, timeout time.Duration) ([]any, error) {
+	if err := rc.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	for _, args := range cmds {
+		writeRedisCommand(rc.w, args)
+	}
+	if err := rc.w.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]any, len(cmds))
+	var firstErr error
+	for i := range cmds {
+		reply, err := readRedisReply(rc.r)
+		var redisErr RedisError
+		if err != nil && !errors.As(err, &redisErr) {
+			return nil, err
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		replies[i] = reply
+	}
+	return replies, firstErr
+}
+
+// writeRedisCommand 按RESP数组格式写入命令
+func writeRedisCommand(w *bufio.Writer, args []string) {
+	w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		w.WriteString(arg)
+		w.WriteString("\r\n")
+	}
+}
+
+// readRedisReply 读取一个RESP回复 服务端错误以RedisError返回
+func readRedisReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: invalid reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, RedisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
@@ -18,11 +18,11 @@ func GetGrpcProxy() *GrpcProxy {
 
 func InitGrpcProxy(cfg *config.GrpcProxyConfig, logger *zerolog.Logger) {
 	proxyOnce.Do(func() {
-		if cfg.Enabled {
+		if cfg.Enabled && cfg.Bridge {
 			globalProxy = NewGrpcProxy(cfg, logger)
 			logger.Info().Int("hosts", len(cfg.Hosts)).Msg("gRPC proxy initialized with allowed hosts")
 		} else {
-			logger.Debug().Msg("gRPC proxy is disabled")
+			logger.Debug().Msg("gRPC json bridge is disabled")
 		}
 	})
 }
@@ -1,6 +1,7 @@
 /*
 Package grpc_proxy
 gRPC代理模块，实现HTTP请求到gRPC调用的转换
+透明代理见grpc_server 本模块为可选的JSON桥接模式 需要开启bridge
 */
 package grpc_proxy
 
@@ -58,7 +59,7 @@ func NewGrpcProxy(cfg *config.GrpcProxyConfig, logger *zerolog.Logger) *GrpcProx
 
 // IsGrpcRequest 判断是否为gRPC代理请求
 func (p *GrpcProxy) IsGrpcRequest(r *http.Request) bool {
-	if !p.config.Enabled {
+	if !p.config.Enabled || !p.config.Bridge {
 		return false
 	}
 
@@ -2,32 +2,103 @@ package logger
 
 import (
 	"Hamburger/internal/config"
+	"fmt"
 	"github.com/rs/zerolog"
+	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// 全局日志的输出目标可以在运行时替换 日志级别使用zerolog的全局级别
+// 重载时只替换输出和级别 各模块持有的*zerolog.Logger保持有效
+
 var (
-	globalLogger zerolog.Logger
-	once         sync.Once
+	output       = &switchWriter{}
+	globalLogger = zerolog.New(output).With().Timestamp().Caller().Logger()
+
+	reloadMu sync.Mutex
+	logFile  *RotateWriter
+	fileConf config.LogRotateConfig
 )
 
+// switchWriter 可替换的输出目标
+type switchWriter struct {
+	w atomic.Pointer[io.Writer]
+}
+
+func (s *switchWriter) set(w io.Writer) {
+	s.w.Store(&w)
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	w := s.w.Load()
+	if w == nil {
+		return os.Stdout.Write(p)
+	}
+	return (*w).Write(p)
+}
+
 func InitLogger() {
-	globalLogger = zerolog.New(zerolog.ConsoleWriter{
+	output.set(zerolog.ConsoleWriter{
 		Out:        os.Stdout,
 		TimeFormat: time.DateTime,
-	}).Level(zerolog.NoLevel).With().Timestamp().Logger()
+	})
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 }
 
-func ReloadLogger(conf *config.LogConfig) {
-	once.Do(func() {
-		globalLogger = zerolog.New(zerolog.ConsoleWriter{
-			Out:        os.Stdout,
-			NoColor:    !conf.Color,
+// ReloadLogger 按配置替换日志输出和级别 可重复调用
+// 日志文件和轮转配置未变化时继续使用已打开的文件
+func ReloadLogger(conf *config.LogConfig) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	var w io.Writer = os.Stdout
+	file := logFile
+	if conf.LogFile == "" {
+		file = nil
+	} else if file == nil || file.Filename != conf.LogFile || fileConf != conf.LogRotateConfig {
+		var err error
+		if file, err = NewRotateWriter(conf.LogFile, conf.LogRotateConfig); err != nil {
+			return err
+		}
+	}
+	if file != nil {
+		w = file
+	}
+	if conf.Encoding != "json" {
+		w = zerolog.ConsoleWriter{
+			Out:        w,
+			NoColor:    !conf.Color || file != nil,
 			TimeFormat: time.DateTime,
-		}).Level(getLevel(conf.LogLevel)).With().Timestamp().Caller().Logger()
-	})
+		}
+	}
+	output.set(w)
+	zerolog.SetGlobalLevel(getLevel(conf.LogLevel))
+
+	if logFile != nil && logFile != file {
+		_ = logFile.Close()
+	}
+	logFile = file
+	fileConf = conf.LogRotateConfig
+	return nil
+}
+
+// SetLevel 修改日志级别 配置重载时会恢复为配置文件中的级别
+func SetLevel(level string) error {
+	switch level {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("unknown log level: %s", level)
+	}
+	zerolog.SetGlobalLevel(getLevel(level))
+	return nil
+}
+
+// Level 当前的日志级别
+func Level() string {
+	return zerolog.GlobalLevel().String()
 }
 
 func L() *zerolog.Logger {
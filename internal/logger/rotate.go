@@ -0,0 +1,287 @@
+package logger
+
+import (
+	"Hamburger/internal/config"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 可轮转的日志文件
+// 超出大小限制或到达轮转时间时将当前文件重命名为 name-时间.ext 并创建新文件
+// 轮转后在后台压缩旧文件 并按保留数量和保留天数删除过期文件
+// Reopen只重新打开文件不做重命名 用于配合外部logrotate的移动操作
+
+const (
+	DefaultMaxSize   = 100 // MB
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+	compressSuffix   = ".gz"
+	megabyte         = 1 << 20
+)
+
+var (
+	writersMu sync.Mutex
+	writers   = make(map[*RotateWriter]struct{})
+)
+
+// RotateWriter 可轮转的日志文件 并发安全
+type RotateWriter struct {
+	Filename string
+	config.LogRotateConfig
+
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+
+	millMu sync.Mutex
+	millWg sync.WaitGroup
+}
+
+// NewRotateWriter 打开日志文件 目录不存在时自动创建
+func NewRotateWriter(filename string, conf config.LogRotateConfig) (*RotateWriter, error) {
+	if filename == "" {
+		return nil, errors.New("log file is empty")
+	}
+	if conf.MaxSize <= 0 {
+		conf.MaxSize = DefaultMaxSize
+	}
+	w := &RotateWriter{Filename: filename, LogRotateConfig: conf}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	w.nextRotate = w.nextBoundary(time.Now())
+
+	writersMu.Lock()
+	writers[w] = struct{}{}
+	writersMu.Unlock()
+	return w, nil
+}
+
+// ReopenAll 重新打开全部日志文件
+func ReopenAll() error {
+	writersMu.Lock()
+	list := make([]*RotateWriter, 0, len(writers))
+	for w := range writers {
+		list = append(list, w)
+	}
+	writersMu.Unlock()
+
+	var errs []error
+	for _, w := range list {
+		if err := w.Reopen(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", w.Filename, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	now := time.Now()
+	if (w.size > 0 && w.size+int64(len(p)) > int64(w.MaxSize)*megabyte) ||
+		(!w.nextRotate.IsZero() && !now.Before(w.nextRotate)) {
+		if err := w.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate 立即轮转当前文件
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotate(time.Now())
+}
+
+// Reopen 关闭并重新打开当前文件 文件被外部移走后会创建新文件
+func (w *RotateWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	return w.open()
+}
+
+// Close 关闭文件并等待后台的压缩和清理完成
+func (w *RotateWriter) Close() error {
+	writersMu.Lock()
+	delete(writers, w)
+	writersMu.Unlock()
+
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+	w.millWg.Wait()
+	return err
+}
+
+func (w *RotateWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.Filename), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+func (w *RotateWriter) rotate(now time.Time) error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+	}
+	if err := os.Rename(w.Filename, w.backupName(now)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.nextRotate = w.nextBoundary(now)
+
+	w.millWg.Add(1)
+	go w.mill()
+	return nil
+}
+
+// nextBoundary 下一个按时间轮转的时刻 未配置时返回零值
+func (w *RotateWriter) nextBoundary(now time.Time) time.Time {
+	switch w.Interval {
+	case "hourly":
+		return now.Truncate(time.Hour).Add(time.Hour)
+	case "daily":
+		year, month, day := now.Date()
+		return time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+	default:
+		return time.Time{}
+	}
+}
+
+// backupName access.log -> access-2006-01-02T15-04-05.000.log
+func (w *RotateWriter) backupName(t time.Time) string {
+	ext := filepath.Ext(w.Filename)
+	prefix := strings.TrimSuffix(w.Filename, ext)
+	return fmt.Sprintf("%s-%s%s", prefix, t.Format(backupTimeFormat), ext)
+}
+
+type backup struct {
+	path string
+	time time.Time
+}
+
+// backups 已轮转的文件 包括已压缩的 按时间从新到旧排序
+func (w *RotateWriter) backups() []backup {
+	ext := filepath.Ext(w.Filename)
+	prefix := filepath.Base(strings.TrimSuffix(w.Filename, ext)) + "-"
+	dir := filepath.Dir(w.Filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var files []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp, ok := strings.CutSuffix(strings.TrimSuffix(name, compressSuffix), ext)
+		if !ok {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, strings.TrimPrefix(stamp, prefix), time.Local)
+		if err != nil {
+			continue
+		}
+		files = append(files, backup{path: filepath.Join(dir, name), time: t})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].time.After(files[j].time)
+	})
+	return files
+}
+
+// mill 压缩轮转文件并删除超出保留数量和天数的文件
+func (w *RotateWriter) mill() {
+	defer w.millWg.Done()
+	w.millMu.Lock()
+	defer w.millMu.Unlock()
+
+	files := w.backups()
+	var cutoff time.Time
+	if w.MaxAge > 0 {
+		cutoff = time.Now().AddDate(0, 0, -w.MaxAge)
+	}
+	for i, file := range files {
+		if (w.MaxBackups > 0 && i >= w.MaxBackups) || (!cutoff.IsZero() && file.time.Before(cutoff)) {
+			_ = os.Remove(file.path)
+			continue
+		}
+		if w.Compress && !strings.HasSuffix(file.path, compressSuffix) {
+			if err := compressFile(file.path); err != nil {
+				globalLogger.Error().Err(err).Str("file", file.path).Msg("compress log file failed")
+			}
+		}
+	}
+}
+
+// compressFile 压缩文件为.gz并删除原文件
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+compressSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path + compressSuffix)
+		return err
+	}
+	_ = src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"Hamburger/internal/config"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateWriter(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	w, err := NewRotateWriter(name, config.LogRotateConfig{MaxSize: 1, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 超出大小后自动轮转
+	line := []byte(strings.Repeat("x", 1023) + "\n")
+	for i := 0; i < 1024; i++ {
+		if _, err = w.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = w.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		time.Sleep(2 * time.Millisecond)
+		if err = w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.millWg.Wait()
+
+	files := w.backups()
+	if len(files) != 2 {
+		t.Fatalf("expected 2 backups, got %d", len(files))
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.path, ".log"+compressSuffix) {
+			t.Fatalf("backup not compressed: %s", file.path)
+		}
+	}
+
+	// 外部移走文件后重新打开
+	if _, err = w.Write([]byte("before\n")); err != nil {
+		t.Fatal(err)
+	}
+	moved := filepath.Join(dir, "moved.log")
+	if err = os.Rename(name, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err = ReopenAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte("after\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, moved, "before\n")
+	assertContent(t, name, "after\n")
+}
+
+func TestRotateRetention(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "access.log")
+	w, err := NewRotateWriter(name, config.LogRotateConfig{MaxAge: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := w.backupName(time.Now().AddDate(0, 0, -2))
+	if err = os.WriteFile(old+compressSuffix, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(old + compressSuffix); !os.IsNotExist(err) {
+		t.Fatal("expired backup not removed")
+	}
+	if files := w.backups(); len(files) != 1 {
+		t.Fatalf("expected 1 backup, got %d", len(files))
+	}
+
+	daily := &RotateWriter{LogRotateConfig: config.LogRotateConfig{Interval: "daily"}}
+	now := time.Date(2025, 3, 1, 23, 59, 0, 0, time.Local)
+	if next := daily.nextBoundary(now); !next.Equal(time.Date(2025, 3, 2, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("unexpected daily boundary %s", next)
+	}
+}
+
+func assertContent(t *testing.T, path, want string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, compressSuffix) {
+		if r, err = gzip.NewReader(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ := io.ReadAll(r)
+	if string(data) != want {
+		t.Fatalf("%s: got %q, want %q", path, data, want)
+	}
+}
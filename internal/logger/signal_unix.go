@@ -0,0 +1,11 @@
+//go:build !windows
+
+package logger
+
+import (
+	"os"
+	"syscall"
+)
+
+// ReopenSignal 触发重新打开日志文件的信号
+var ReopenSignal os.Signal = syscall.SIGUSR1
@@ -0,0 +1,8 @@
+//go:build windows
+
+package logger
+
+import "os"
+
+// ReopenSignal windows没有SIGUSR1 不支持通过信号重新打开日志文件
+var ReopenSignal os.Signal
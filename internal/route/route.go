@@ -0,0 +1,277 @@
+// Package route
+// API转发路由匹配 网关和前端服务共用
+package route
+
+import (
+	"Hamburger/internal/config"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// 匹配方式
+// prefix   路径前缀(默认) rewrite替换匹配的前缀
+// exact    路径完全相等 rewrite替换整个路径
+// regex    正则匹配 rewrite支持$1 ${name}引用捕获分组
+// template 路径模板 /api/:id/*rest rewrite支持:id *rest引用参数
+// 路由按优先级降序 同优先级时精确匹配优先 其余按匹配规则长度降序(最长匹配)
+
+const (
+	Prefix   = "prefix"
+	Exact    = "exact"
+	Regex    = "regex"
+	Template = "template"
+)
+
+var templateParam = regexp.MustCompile(`[:*]([A-Za-z_][A-Za-z0-9_]*)`)
+
+// Route 编译后的转发路由
+type Route struct {
+	Backend config.BackendConfig
+	kind    string
+	pattern *regexp.Regexp // regex和template使用
+	rewrite string
+	methods []string
+	headers map[string]valueMatcher
+	query   map[string]valueMatcher
+}
+
+// Match 路由匹配结果
+type Match struct {
+	Route  *Route
+	Path   string            // 转发路径 开启rewrite时为重写后的路径
+	Params map[string]string // 路径模板参数和正则命名分组
+}
+
+// Router 按顺序排列的路由表
+type Router struct {
+	routes []*Route
+}
+
+// Kind 规范化匹配方式 未知方式按前缀匹配处理
+func Kind(name string) string {
+	switch name {
+	case Exact, Regex, Template:
+		return name
+	default:
+		return Prefix
+	}
+}
+
+// NewRouter 编译路由表 无效的路由会被跳过并在错误中返回
+func NewRouter(backends []config.BackendConfig) (*Router, error) {
+	router := &Router{routes: make([]*Route, 0, len(backends))}
+	var errs []error
+	for _, backend := range backends {
+		// 检查API路径和服务名是否配置
+		if backend.API == "" || backend.Service == "" {
+			continue
+		}
+		r, err := Compile(backend)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("route %s: %w", backend.API, err))
+			continue
+		}
+		router.routes = append(router.routes, r)
+	}
+
+	sort.SliceStable(router.routes, func(i, j int) bool {
+		a, b := router.routes[i], router.routes[j]
+		if a.Backend.Priority != b.Backend.Priority {
+			return a.Backend.Priority > b.Backend.Priority
+		}
+		if (a.kind == Exact) != (b.kind == Exact) {
+			return a.kind == Exact
+		}
+		return len(a.Backend.API) > len(b.Backend.API)
+	})
+	return router, errors.Join(errs...)
+}
+
+// Compile 编译单条路由
+func Compile(backend config.BackendConfig) (*Route, error) {
+	r := &Route{
+		Backend: backend,
+		kind:    Kind(backend.Match),
+		rewrite: backend.Rewrite,
+	}
+
+	switch r.kind {
+	case Regex:
+		pattern, err := regexp.Compile(backend.API)
+		if err != nil {
+			return nil, err
+		}
+		r.pattern = pattern
+	case Template:
+		pattern, err := compileTemplate(backend.API)
+		if err != nil {
+			return nil, err
+		}
+		r.pattern = pattern
+		r.rewrite = templateParam.ReplaceAllString(backend.Rewrite, "$${$1}")
+	}
+
+	for _, method := range backend.Methods {
+		r.methods = append(r.methods, strings.ToUpper(method))
+	}
+	var err error
+	if r.headers, err = compileValues(backend.Headers); err != nil {
+		return nil, err
+	}
+	if r.query, err = compileValues(backend.Query); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// compileTemplate 将路径模板转换为正则 :name匹配单级路径 *name匹配剩余路径
+func compileTemplate(template string) (*regexp.Regexp, error) {
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":") && len(segment) > 1:
+			segments[i] = fmt.Sprintf("(?P<%s>[^/]+)", segment[1:])
+		case strings.HasPrefix(segment, "*"):
+			if len(segment) > 1 {
+				segments[i] = fmt.Sprintf("(?P<%s>.*)", segment[1:])
+			} else {
+				segments[i] = "(.*)"
+			}
+		default:
+			segments[i] = regexp.QuoteMeta(segment)
+		}
+	}
+	return regexp.Compile("^" + strings.Join(segments, "/") + "$")
+}
+
+// Match 按顺序返回第一个匹配的路由
+func (r *Router) Match(req *http.Request) (Match, bool) {
+	if r == nil {
+		return Match{}, false
+	}
+	var query url.Values
+	for _, route := range r.routes {
+		if len(route.query) > 0 && query == nil {
+			query = req.URL.Query()
+		}
+		if m, ok := route.match(req, query); ok {
+			return m, true
+		}
+	}
+	return Match{}, false
+}
+
+// Size 路由数量
+func (r *Router) Size() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.routes)
+}
+
+// Kind 路由的匹配方式
+func (r *Route) Kind() string {
+	return r.kind
+}
+
+func (r *Route) match(req *http.Request, query url.Values) (Match, bool) {
+	if len(r.methods) > 0 && !slices.Contains(r.methods, req.Method) {
+		return Match{}, false
+	}
+	for name, matcher := range r.headers {
+		if _, ok := req.Header[http.CanonicalHeaderKey(name)]; !ok || !matcher.match(req.Header.Get(name)) {
+			return Match{}, false
+		}
+	}
+	for name, matcher := range r.query {
+		if !query.Has(name) || !matcher.match(query.Get(name)) {
+			return Match{}, false
+		}
+	}
+
+	path, params, ok := r.matchPath(req.URL.Path)
+	if !ok {
+		return Match{}, false
+	}
+	return Match{Route: r, Path: path, Params: params}, true
+}
+
+// matchPath 匹配路径并计算转发路径
+func (r *Route) matchPath(path string) (string, map[string]string, bool) {
+	api := r.Backend.API
+	switch r.kind {
+	case Exact:
+		if path != api {
+			return "", nil, false
+		}
+		if r.Backend.UseRewrite {
+			return r.rewrite, nil, true
+		}
+		return path, nil, true
+	case Regex, Template:
+		loc := r.pattern.FindStringSubmatchIndex(path)
+		if loc == nil {
+			return "", nil, false
+		}
+		params := make(map[string]string)
+		for i, name := range r.pattern.SubexpNames() {
+			if name != "" && loc[2*i] >= 0 {
+				params[name] = path[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		if r.Backend.UseRewrite {
+			// 仅替换匹配的部分 未匹配的前后缀保持不变
+			expanded := r.pattern.ExpandString(nil, r.rewrite, path, loc)
+			path = path[:loc[0]] + string(expanded) + path[loc[1]:]
+		}
+		return path, params, true
+	default:
+		if !strings.HasPrefix(path, api) {
+			return "", nil, false
+		}
+		if r.Backend.UseRewrite {
+			// 将API路径重写为指定的rewrite路径
+			path = r.rewrite + path[len(api):]
+		}
+		return path, nil, true
+	}
+}
+
+// valueMatcher 请求头和查询参数的值匹配
+// 空值只要求存在 ~开头按正则匹配 其余完全相等
+type valueMatcher struct {
+	value   string
+	pattern *regexp.Regexp
+}
+
+func compileValues(values map[string]string) (map[string]valueMatcher, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	matchers := make(map[string]valueMatcher, len(values))
+	for name, value := range values {
+		matcher := valueMatcher{value: value}
+		if strings.HasPrefix(value, "~") {
+			pattern, err := regexp.Compile(value[1:])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			matcher.pattern = pattern
+		}
+		matchers[name] = matcher
+	}
+	return matchers, nil
+}
+
+func (m valueMatcher) match(value string) bool {
+	if m.pattern != nil {
+		return m.pattern.MatchString(value)
+	}
+	return m.value == "" || m.value == value
+}
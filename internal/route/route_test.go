@@ -0,0 +1,73 @@
+package route
+
+import (
+	"Hamburger/internal/config"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRouterMatch 测试各种匹配方式和重写
+func TestRouterMatch(t *testing.T) {
+	router, err := NewRouter([]config.BackendConfig{
+		{API: "/api", Service: "short"},
+		{API: "/api/v1", Service: "long", UseRewrite: true, Rewrite: "/v1"},
+		{API: "/api/v1/health", Service: "exact", Match: Exact},
+		{API: `^/img/(\d+)\.png$`, Service: "regex", Match: Regex, UseRewrite: true, Rewrite: "/image/$1"},
+		{API: "/users/:id/*rest", Service: "template", Match: Template, UseRewrite: true, Rewrite: "/u/:id/*rest"},
+		{API: "/api/v1/admin", Service: "post", Methods: []string{"post"}, Priority: 10},
+		{API: "/api/v1/beta", Service: "beta", Headers: map[string]string{"X-Beta": ""}, Query: map[string]string{"v": "~^[0-9]+$"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method  string
+		target  string
+		header  string
+		service string
+		path    string
+	}{
+		{"GET", "/api/ping", "", "short", "/api/ping"},
+		{"GET", "/api/v1/users", "", "long", "/v1/users"},
+		{"GET", "/api/v1/health", "", "exact", "/api/v1/health"},
+		{"GET", "/img/42.png", "", "regex", "/image/42"},
+		{"GET", "/users/7/posts/1", "", "template", "/u/7/posts/1"},
+		{"POST", "/api/v1/admin", "", "post", "/api/v1/admin"},
+		{"GET", "/api/v1/admin", "", "long", "/v1/admin"},
+		{"GET", "/api/v1/beta?v=2", "1", "beta", "/api/v1/beta"},
+		{"GET", "/api/v1/beta?v=x", "1", "long", "/v1/beta"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		if tt.header != "" {
+			req.Header.Set("X-Beta", tt.header)
+		}
+		m, ok := router.Match(req)
+		if !ok {
+			t.Errorf("%s %s: expected match", tt.method, tt.target)
+			continue
+		}
+		if m.Route.Backend.Service != tt.service || m.Path != tt.path {
+			t.Errorf("%s %s: expected %s %s, got %s %s", tt.method, tt.target, tt.service, tt.path, m.Route.Backend.Service, m.Path)
+		}
+	}
+
+	if _, ok := router.Match(httptest.NewRequest("GET", "/static/app.js", nil)); ok {
+		t.Error("Expected no match for unrelated path")
+	}
+}
+
+// TestInvalidRoute 测试无效路由被跳过
+func TestInvalidRoute(t *testing.T) {
+	router, err := NewRouter([]config.BackendConfig{
+		{API: "([", Service: "bad", Match: Regex},
+		{API: "/ok", Service: "ok"},
+	})
+	if err == nil {
+		t.Error("Expected error for invalid regex")
+	}
+	if router.Size() != 1 {
+		t.Errorf("Expected 1 valid route, got %d", router.Size())
+	}
+}
@@ -18,6 +18,18 @@ const (
 	SandwichReqLimit = "SandwichReqLimit"
 	// SandwichDomainNotAllow 域名不支持
 	SandwichDomainNotAllow = "SandwichDomainNotAllow"
+	// SandwichIPNotAllow 客户端IP被访问控制拒绝
+	SandwichIPNotAllow = "SandwichIPNotAllow"
+	// SandwichGeoNotAllow 客户端所属国家被访问控制拒绝
+	SandwichGeoNotAllow = "SandwichGeoNotAllow"
+	// SandwichWAFBlock 请求被WAF规则拦截
+	SandwichWAFBlock = "SandwichWAFBlock"
+	// SandwichWAFChallenge 请求需要通过WAF的浏览器验证
+	SandwichWAFChallenge = "SandwichWAFChallenge"
+	// SandwichClientCertInvalid 客户端证书缺失或校验失败
+	SandwichClientCertInvalid = "SandwichClientCertInvalid"
+	// SandwichClientCertMisdirected 复用的连接没有请求客户端证书 需要客户端使用新连接重试
+	SandwichClientCertMisdirected = "SandwichClientCertMisdirected"
 	// SandwichBackendError 后端服务异常 针对API类服务异常
 	SandwichBackendError = "SandwichBackendError"
 )
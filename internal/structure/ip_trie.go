@@ -0,0 +1,130 @@
+package structure
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// IPTrie 按位存储的CIDR前缀树 同时支持IPv4和IPv6
+// 查找的耗时只与地址长度有关 与网段数量无关 适合大量网段的黑白名单
+// 构建完成后只读 插入与查找不能并发进行
+type IPTrie struct {
+	v4  *ipTrieNode
+	v6  *ipTrieNode
+	len int
+}
+
+type ipTrieNode struct {
+	children [2]*ipTrieNode
+	terminal bool
+}
+
+// NewIPTrie 创建空的前缀树
+func NewIPTrie() *IPTrie {
+	return &IPTrie{v4: &ipTrieNode{}, v6: &ipTrieNode{}}
+}
+
+// ParsePrefix 解析单个IP或CIDR 单个IP视为/32或/128 IPv4映射的IPv6地址转换为IPv4
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if addr := prefix.Addr(); addr.Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(addr.Unmap(), prefix.Bits()-96)
+	}
+	return prefix.Masked(), nil
+}
+
+// NewIPTrieFrom 从IP或CIDR列表创建前缀树
+func NewIPTrieFrom(list []string) (*IPTrie, error) {
+	t := NewIPTrie()
+	for _, s := range list {
+		prefix, err := ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip or cidr %q: %w", s, err)
+		}
+		t.Insert(prefix)
+	}
+	return t, nil
+}
+
+// Insert 插入网段
+func (t *IPTrie) Insert(prefix netip.Prefix) {
+	addr := prefix.Addr()
+	node := t.root(addr)
+	bytes := addr.AsSlice()
+	for i := 0; i < prefix.Bits(); i++ {
+		if node.terminal {
+			// 已被更大的网段覆盖
+			return
+		}
+		bit := bytes[i/8] >> (7 - i%8) & 1
+		if node.children[bit] == nil {
+			node.children[bit] = &ipTrieNode{}
+		}
+		node = node.children[bit]
+	}
+	if !node.terminal {
+		// 被新网段覆盖的子网段不再需要
+		t.len -= node.count()
+		node.terminal = true
+		node.children = [2]*ipTrieNode{}
+		t.len++
+	}
+}
+
+// Contains 地址是否属于任意一个网段
+func (t *IPTrie) Contains(addr netip.Addr) bool {
+	if t == nil || !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	node := t.root(addr)
+	bytes := addr.AsSlice()
+	for i := 0; i < addr.BitLen(); i++ {
+		if node.terminal {
+			return true
+		}
+		node = node.children[bytes[i/8]>>(7-i%8)&1]
+		if node == nil {
+			return false
+		}
+	}
+	return node.terminal
+}
+
+// Len 网段数量 被更大网段覆盖的网段不计入
+func (t *IPTrie) Len() int {
+	if t == nil {
+		return 0
+	}
+	return t.len
+}
+
+func (n *ipTrieNode) count() int {
+	if n == nil {
+		return 0
+	}
+	if n.terminal {
+		return 1
+	}
+	return n.children[0].count() + n.children[1].count()
+}
+
+func (t *IPTrie) root(addr netip.Addr) *ipTrieNode {
+	if addr.Is4() {
+		return t.v4
+	}
+	return t.v6
+}
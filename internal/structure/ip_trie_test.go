@@ -0,0 +1,53 @@
+package structure
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestIPTrie(t *testing.T) {
+	trie, err := NewIPTrieFrom([]string{
+		"10.0.0.0/8",
+		"192.168.1.10",
+		"2001:db8::/32",
+		"::ffff:172.16.0.0/108",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]bool{
+		"10.1.2.3":        true,
+		"11.0.0.1":        false,
+		"192.168.1.10":    true,
+		"192.168.1.11":    false,
+		"::ffff:10.0.0.1": true,
+		"2001:db8:1::1":   true,
+		"2001:db9::1":     false,
+		"172.16.5.5":      true,
+		"172.32.0.1":      false,
+		"::1":             false,
+	}
+	for ip, want := range cases {
+		if got := trie.Contains(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("Contains(%s) = %v, want %v", ip, got, want)
+		}
+	}
+	if trie.Len() != 4 {
+		t.Errorf("expected 4 prefixes, got %d", trie.Len())
+	}
+
+	// 更大的网段覆盖已有网段
+	trie.Insert(netip.MustParsePrefix("192.168.0.0/16"))
+	if trie.Len() != 4 || !trie.Contains(netip.MustParseAddr("192.168.200.1")) {
+		t.Errorf("unexpected state after covering insert: len %d", trie.Len())
+	}
+
+	if _, err = NewIPTrieFrom([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("expected error for invalid cidr")
+	}
+	var empty *IPTrie
+	if empty.Contains(netip.MustParseAddr("10.0.0.1")) {
+		t.Error("nil trie should not contain any address")
+	}
+}
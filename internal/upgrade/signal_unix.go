@@ -0,0 +1,11 @@
+//go:build !windows
+
+package upgrade
+
+import (
+	"os"
+	"syscall"
+)
+
+// Signal 触发平滑升级的信号
+var Signal os.Signal = syscall.SIGUSR2
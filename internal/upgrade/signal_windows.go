@@ -0,0 +1,8 @@
+//go:build windows
+
+package upgrade
+
+import "os"
+
+// Signal windows不支持继承监听器 不提供平滑升级
+var Signal os.Signal
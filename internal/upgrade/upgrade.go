@@ -0,0 +1,269 @@
+// Package upgrade
+// 平滑升级 新旧进程之间通过继承文件描述符传递监听器
+package upgrade
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 升级流程:
+// 1. 旧进程收到升级信号后 将当前持有的TCP监听器和UDP连接作为ExtraFiles传给新进程
+// 2. 新进程通过Listen/ListenPacket优先复用继承的描述符 启动完成后调用Ready通知旧进程
+// 3. 旧进程收到就绪通知后停止接收新连接 处理完进行中的请求后退出
+// 新进程启动失败或超时未就绪时旧进程终止新进程并继续提供服务
+
+const (
+	// EnvListeners 继承的监听器 格式: network://addr=fd;network://addr=fd
+	EnvListeners = "HAMBURGER_LISTENERS"
+	// EnvReadyFD 新进程就绪后写入的管道描述符
+	EnvReadyFD = "HAMBURGER_READY_FD"
+
+	DefaultTimeout = 30 * time.Second
+
+	// 0 1 2为标准输入输出 ExtraFiles从3开始编号
+	firstExtraFD = 3
+)
+
+type filer interface {
+	File() (*os.File, error)
+}
+
+var (
+	mu        sync.Mutex
+	inherited map[string]*os.File // 父进程传递且尚未被使用的描述符
+	active    = make(map[string]filer)
+	parseOnce sync.Once
+)
+
+func key(network, addr string) string {
+	return network + "://" + addr
+}
+
+func parseInherited() {
+	inherited = make(map[string]*os.File)
+	value := os.Getenv(EnvListeners)
+	_ = os.Unsetenv(EnvListeners)
+	if value == "" {
+		return
+	}
+	for _, item := range strings.Split(value, ";") {
+		name, fdStr, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		fd, err := strconv.Atoi(fdStr)
+		if err != nil {
+			continue
+		}
+		inherited[name] = os.NewFile(uintptr(fd), name)
+	}
+}
+
+func takeInherited(name string) *os.File {
+	parseOnce.Do(parseInherited)
+	mu.Lock()
+	defer mu.Unlock()
+	f := inherited[name]
+	delete(inherited, name)
+	return f
+}
+
+func track(name string, l any) {
+	f, ok := l.(filer)
+	if !ok {
+		return
+	}
+	mu.Lock()
+	active[name] = f
+	mu.Unlock()
+}
+
+// Inherited 当前进程是否由平滑升级启动
+func Inherited() bool {
+	parseOnce.Do(parseInherited)
+	mu.Lock()
+	defer mu.Unlock()
+	return len(inherited) > 0 || os.Getenv(EnvReadyFD) != ""
+}
+
+// Listen 创建TCP监听器 父进程传递了同一地址的监听器时直接复用
+func Listen(network, addr string) (net.Listener, error) {
+	name := key(network, addr)
+	if f := takeInherited(name); f != nil {
+		ln, err := net.FileListener(f)
+		_ = f.Close()
+		if err == nil {
+			track(name, ln)
+			return ln, nil
+		}
+	}
+
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	track(name, ln)
+	return ln, nil
+}
+
+// ListenPacket 创建UDP连接 父进程传递了同一地址的连接时直接复用
+func ListenPacket(network, addr string) (net.PacketConn, error) {
+	name := key(network, addr)
+	if f := takeInherited(name); f != nil {
+		conn, err := net.FilePacketConn(f)
+		_ = f.Close()
+		if err == nil {
+			track(name, conn)
+			return conn, nil
+		}
+	}
+
+	conn, err := net.ListenPacket(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	track(name, conn)
+	return conn, nil
+}
+
+// Ready 新进程启动完成后通知父进程 并关闭未被使用的继承描述符
+// 非平滑升级启动时无操作
+func Ready() error {
+	parseOnce.Do(parseInherited)
+	mu.Lock()
+	for name, f := range inherited {
+		_ = f.Close()
+		delete(inherited, name)
+	}
+	mu.Unlock()
+
+	fdStr := os.Getenv(EnvReadyFD)
+	if fdStr == "" {
+		return nil
+	}
+	_ = os.Unsetenv(EnvReadyFD)
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil {
+		return err
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	defer f.Close()
+	_, err = f.Write([]byte("ready\n"))
+	return err
+}
+
+// Upgrade 启动新进程并传递当前持有的全部监听器 新进程就绪后返回
+// 返回错误时新进程已被终止 当前进程应继续提供服务
+func Upgrade(timeout time.Duration) (*os.Process, error) {
+	exe, err := executable()
+	if err != nil {
+		return nil, err
+	}
+
+	files, pairs := activeFiles()
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	readyFD := firstExtraFD + len(files)
+	files = append(files, w)
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(cleanEnv(os.Environ()),
+		EnvListeners+"="+strings.Join(pairs, ";"),
+		EnvReadyFD+"="+strconv.Itoa(readyFD),
+	)
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start new process: %w", err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 16)
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			result <- nil
+			return
+		}
+		result <- fmt.Errorf("new process exited before ready: %v", readErr)
+	}()
+	// 关闭当前进程持有的写端 新进程退出时读端立即返回
+	_ = w.Close()
+	files = files[:len(files)-1]
+
+	select {
+	case err = <-result:
+	case <-time.After(timeout):
+		err = errors.New("timeout waiting for new process")
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, err
+	}
+	return cmd.Process, nil
+}
+
+// activeFiles 复制当前监听器的描述符 已关闭的监听器会被跳过
+func activeFiles() ([]*os.File, []string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := make([]string, 0, len(active))
+	for name := range active {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := make([]*os.File, 0, len(names))
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		f, err := active[name].File()
+		if err != nil {
+			delete(active, name)
+			continue
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%d", name, firstExtraFD+len(files)))
+		files = append(files, f)
+	}
+	return files, pairs
+}
+
+// executable 优先使用启动时的路径 以便执行替换后的新版本二进制
+func executable() (string, error) {
+	if path, err := exec.LookPath(os.Args[0]); err == nil {
+		return path, nil
+	}
+	return os.Executable()
+}
+
+func cleanEnv(env []string) []string {
+	result := make([]string, 0, len(env))
+	for _, kv := range env {
+		if strings.HasPrefix(kv, EnvListeners+"=") || strings.HasPrefix(kv, EnvReadyFD+"=") {
+			continue
+		}
+		result = append(result, kv)
+	}
+	return result
+}
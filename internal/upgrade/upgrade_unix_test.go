@@ -0,0 +1,207 @@
+//go:build !windows
+
+package upgrade
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 测试二进制同时作为升级启动的新进程 行为由环境变量指定
+const (
+	envChild     = "HAMBURGER_UPGRADE_TEST_CHILD"
+	envChildAddr = "HAMBURGER_UPGRADE_TEST_ADDR"
+)
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(envChild) {
+	case "":
+		os.Exit(m.Run())
+	case "ready":
+		runChild()
+	case "hang":
+		// 等待父进程超时后终止
+		time.Sleep(time.Minute)
+	}
+	os.Exit(1)
+}
+
+// runChild 复用继承的监听器 通知就绪后向第一个连接写入标识
+func runChild() {
+	ln, err := Listen("tcp", os.Getenv(envChildAddr))
+	if err != nil {
+		os.Exit(2)
+	}
+	if err = Ready(); err != nil {
+		os.Exit(3)
+	}
+	conn, err := ln.Accept()
+	if err != nil {
+		os.Exit(4)
+	}
+	_, _ = conn.Write([]byte("child\n"))
+	_ = conn.Close()
+	os.Exit(0)
+}
+
+// setActive 替换当前进程持有的监听器 测试结束后恢复
+func setActive(t *testing.T, listeners map[string]filer) {
+	t.Helper()
+	mu.Lock()
+	saved := active
+	active = listeners
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		active = saved
+		mu.Unlock()
+	})
+}
+
+// dup 复制描述符 交给继承列表后由其负责关闭
+func dup(t *testing.T, f *os.File) int {
+	t.Helper()
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+func TestListenersEncoding(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = closed.Close()
+
+	setActive(t, map[string]filer{
+		key("udp", ":53"):   udp.(filer),
+		key("tcp", ":80"):   tcp.(filer),
+		key("tcp", ":8080"): closed.(filer),
+	})
+	files, pairs := activeFiles()
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	// 按名称排序 描述符从3开始编号 已关闭的监听器被移除
+	if want := []string{"tcp://:80=3", "udp://:53=4"}; !slices.Equal(pairs, want) {
+		t.Fatalf("pairs = %v, want %v", pairs, want)
+	}
+	if _, ok := active[key("tcp", ":8080")]; ok {
+		t.Fatal("closed listener should be removed")
+	}
+
+	// 子进程中描述符编号即为继承的位置 这里使用当前进程复制出的描述符
+	value := fmt.Sprintf("tcp://:80=%d;invalid;udp://:53=x;udp://:53=%d", dup(t, files[0]), dup(t, files[1]))
+	t.Setenv(EnvListeners, value)
+	parseOnce = sync.Once{}
+	t.Cleanup(func() { parseOnce = sync.Once{} })
+	if !Inherited() {
+		t.Fatal("expected inherited listeners")
+	}
+	if os.Getenv(EnvListeners) != "" {
+		t.Fatal("listener env should be cleared after parsing")
+	}
+
+	ln, err := Listen("tcp", ":80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if ln.Addr().String() != tcp.Addr().String() {
+		t.Fatalf("inherited tcp addr = %s, want %s", ln.Addr(), tcp.Addr())
+	}
+	conn, err := ListenPacket("udp", ":53")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if conn.LocalAddr().String() != udp.LocalAddr().String() {
+		t.Fatalf("inherited udp addr = %s, want %s", conn.LocalAddr(), udp.LocalAddr())
+	}
+	if Inherited() {
+		t.Fatal("all inherited listeners should be taken")
+	}
+}
+
+func TestCleanEnv(t *testing.T) {
+	env := cleanEnv([]string{"PATH=/bin", EnvListeners + "=tcp://:80=3", EnvReadyFD + "=4", "HOME=/root"})
+	if want := []string{"PATH=/bin", "HOME=/root"}; !slices.Equal(env, want) {
+		t.Fatalf("env = %v, want %v", env, want)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	const addr = "127.0.0.1:0"
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	setActive(t, map[string]filer{key("tcp", addr): ln.(filer)})
+	t.Setenv(envChildAddr, addr)
+
+	t.Run("ready", func(t *testing.T) {
+		t.Setenv(envChild, "ready")
+		proc, err := Upgrade(10 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 当前进程不接受连接 只有复用了监听器的新进程能够响应
+		conn, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil || line != "child\n" {
+			t.Fatalf("read %q: %v", line, err)
+		}
+		state, err := proc.Wait()
+		if err != nil || !state.Success() {
+			t.Fatalf("child exited with %v: %v", state, err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Setenv(envChild, "hang")
+		start := time.Now()
+		_, err := Upgrade(200 * time.Millisecond)
+		if err == nil || !strings.Contains(err.Error(), "timeout") {
+			t.Fatalf("expected timeout, got %v", err)
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("timeout took %s", time.Since(start))
+		}
+	})
+
+	t.Run("exit", func(t *testing.T) {
+		t.Setenv(envChild, "exit")
+		_, err := Upgrade(10 * time.Second)
+		if err == nil || !strings.Contains(err.Error(), "exited before ready") {
+			t.Fatalf("expected early exit, got %v", err)
+		}
+	})
+}
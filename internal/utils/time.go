@@ -9,3 +9,7 @@ func ToSecond(t int) time.Duration {
 func ToMinute(t int) time.Duration {
 	return time.Duration(t) * time.Minute
 }
+
+func ToMillisecond(t int) time.Duration {
+	return time.Duration(t) * time.Millisecond
+}